@@ -0,0 +1,832 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+AggregateEx，多交易所聚合
+
+Ex built from several underlying exchanges, like Binance and Huobi watched together by one desk.
+
+market data and accounts are merged: GetTicks and GetMarketInfo return the union for Ex interface only, they fail if
+the same PairM is listed by more than one exchange because ticks and pair infos of a pair differ between exchanges,
+GetTicksByPlatform and GetMarketInfos are the real APIs which return them keyed by platform, GetTicks works with
+WithPlatform too. GetAccount sums balances of all exchanges by fintypes.Account.AddAccount.
+
+requests of one pair are routed to the platform set by WithPlatform, or the first exchange listing the pair.
+requests of one asset, like Borrow and Withdraw, require WithPlatform unless there is only one exchange.
+
+order ids are namespaced by platform, like "spot:no:BTC/USDT:binance@12345", so GetOrder and CancelOrder
+find the exchange by id only, use SplitAggregateOrderId to get the original id.
+*/
+
+type (
+	AggregateEx struct {
+		exs      []Ex
+		property fintypes.ExProperty
+
+		mu          sync.Mutex
+		marketInfos map[fintypes.Platform]*fintypes.MarketInfo // cache for routing
+	}
+
+	aggregatePlatformKey struct{}
+)
+
+const (
+	// delimiter between platform and original StrId in order id of AggregateEx
+	AggregateOrderIdDelimiter = "@"
+)
+
+// exchanges should be of different platforms, the first one is the primary whose property is inherited
+func NewAggregateEx(exs ...Ex) (*AggregateEx, error) {
+	if len(exs) == 0 {
+		return nil, gerror.Errorf("no exchange to aggregate")
+	}
+	ae := &AggregateEx{marketInfos: map[fintypes.Platform]*fintypes.MarketInfo{}}
+	var names []string
+	seen := map[fintypes.Platform]bool{}
+	for _, e := range exs {
+		if e == nil {
+			return nil, gerror.Errorf("nil exchange to aggregate")
+		}
+		name := e.Property().Name
+		if seen[name] {
+			return nil, gerror.Errorf("duplicate exchange %s to aggregate", name)
+		}
+		seen[name] = true
+		names = append(names, name.String())
+		ae.exs = append(ae.exs, e)
+	}
+
+	ae.property = *exs[0].Property()
+	ae.property.Name = fintypes.Platform(strings.Join(names, "+"))
+	ae.property.Email = ""
+	ae.property.MarketEnabled = map[fintypes.Market]bool{}
+	for _, e := range exs {
+		for mkt, enabled := range e.Property().MarketEnabled {
+			if enabled {
+				ae.property.MarketEnabled[mkt] = true
+			}
+		}
+	}
+	return ae, nil
+}
+
+// route requests of ctx to exchange of platform
+func WithPlatform(ctx context.Context, platform fintypes.Platform) context.Context {
+	return context.WithValue(ctx, aggregatePlatformKey{}, platform)
+}
+
+// platform of id placed by AggregateEx and the original id of that exchange
+func SplitAggregateOrderId(id fintypes.OrderId) (fintypes.Platform, fintypes.OrderId, error) {
+	ss := strings.SplitN(id.StrId(), AggregateOrderIdDelimiter, 2)
+	if len(ss) != 2 || ss[0] == "" || ss[1] == "" {
+		return fintypes.PlatformUnknown, "", gerror.Errorf("order id(%s) isn't namespaced by platform", id)
+	}
+	return fintypes.Platform(ss[0]), fintypes.NewOrderId(id.Market(), id.Margin(), id.Pair(), ss[1]), nil
+}
+
+func aggregateOrderId(platform fintypes.Platform, id fintypes.OrderId) fintypes.OrderId {
+	if id == "" {
+		return id
+	}
+	return fintypes.NewOrderId(id.Market(), id.Margin(), id.Pair(), platform.String()+AggregateOrderIdDelimiter+id.StrId())
+}
+
+func (ae *AggregateEx) Property() *fintypes.ExProperty {
+	return &ae.property
+}
+
+// underlying exchanges in constructor order
+func (ae *AggregateEx) Exs() []Ex {
+	return append([]Ex{}, ae.exs...)
+}
+
+func (ae *AggregateEx) ex(platform fintypes.Platform) (Ex, error) {
+	for _, e := range ae.exs {
+		if e.Property().Name == platform {
+			return e, nil
+		}
+	}
+	return nil, gerror.Errorf("exchange %s not found in %s", platform, ae.property.Name)
+}
+
+// exchanges selected by WithPlatform, or all exchanges
+func (ae *AggregateEx) selected(ctx context.Context) ([]Ex, error) {
+	if p, ok := ctx.Value(aggregatePlatformKey{}).(fintypes.Platform); ok {
+		e, err := ae.ex(p)
+		if err != nil {
+			return nil, err
+		}
+		return []Ex{e}, nil
+	}
+	return ae.exs, nil
+}
+
+// exchange for requests without pair
+func (ae *AggregateEx) single(ctx context.Context) (Ex, error) {
+	if p, ok := ctx.Value(aggregatePlatformKey{}).(fintypes.Platform); ok {
+		return ae.ex(p)
+	}
+	if len(ae.exs) == 1 {
+		return ae.exs[0], nil
+	}
+	return nil, gerror.Errorf("platform of %s is ambiguous, set it by WithPlatform", ae.property.Name)
+}
+
+func (ae *AggregateEx) marketInfo(ctx context.Context, e Ex) (*fintypes.MarketInfo, error) {
+	name := e.Property().Name
+	ae.mu.Lock()
+	mi := ae.marketInfos[name]
+	ae.mu.Unlock()
+	if mi != nil {
+		return mi, nil
+	}
+	mi, err := e.GetMarketInfoContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	ae.mu.Lock()
+	ae.marketInfos[name] = mi
+	ae.mu.Unlock()
+	return mi, nil
+}
+
+// whether pair is listed by e, in any market if market is nil
+func (ae *AggregateEx) lists(ctx context.Context, e Ex, market *fintypes.Market, target fintypes.Pair) (bool, error) {
+	mi, err := ae.marketInfo(ctx, e)
+	if err != nil {
+		return false, err
+	}
+	if market != nil {
+		_, ok := mi.Infos[target.SetM(*market)]
+		return ok, nil
+	}
+	for pm := range mi.Infos {
+		if pm.Pair() == target {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// exchange of WithPlatform, or the first one listing the pair
+func (ae *AggregateEx) route(ctx context.Context, market fintypes.Market, target fintypes.Pair) (Ex, error) {
+	if p, ok := ctx.Value(aggregatePlatformKey{}).(fintypes.Platform); ok {
+		return ae.ex(p)
+	}
+	for _, e := range ae.exs {
+		ok, err := ae.lists(ctx, e, &market, target)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return e, nil
+		}
+	}
+	return nil, errors.Wrapf(fintypes.ErrInvalidPair, "%s %s not found in %s", market, target, ae.property.Name)
+}
+
+// exchanges of WithPlatform or all, exchanges not listing target are skipped if target is not nil
+func (ae *AggregateEx) filter(ctx context.Context, market *fintypes.Market, target *fintypes.Pair) ([]Ex, error) {
+	exs, err := ae.selected(ctx)
+	if err != nil || target == nil || len(exs) == 1 {
+		return exs, err
+	}
+	var r []Ex
+	for _, e := range exs {
+		ok, err := ae.lists(ctx, e, market, *target)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			r = append(r, e)
+		}
+	}
+	return r, nil
+}
+
+// market info of every exchange, keyed by platform
+func (ae *AggregateEx) GetMarketInfos(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.Platform]*fintypes.MarketInfo, error) {
+	r := map[fintypes.Platform]*fintypes.MarketInfo{}
+	for _, e := range ae.exs {
+		mi, err := e.GetMarketInfoContext(ctx, ignorePairsNotFound)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get market info of %s", e.Property().Name)
+		}
+		r[e.Property().Name] = mi
+		ae.mu.Lock()
+		ae.marketInfos[e.Property().Name] = mi
+		ae.mu.Unlock()
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	return ae.GetMarketInfoContext(context.Background(), ignorePairsNotFound)
+}
+
+// union of market infos, PairM listed by more than one exchange is an error
+func (ae *AggregateEx) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	mis, err := ae.GetMarketInfos(ctx, ignorePairsNotFound)
+	if err != nil {
+		return nil, err
+	}
+	r := &fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+	owners := map[fintypes.PairM]fintypes.Platform{}
+	for _, e := range ae.exs {
+		name := e.Property().Name
+		for pm, info := range mis[name].Infos {
+			if owner, ok := owners[pm]; ok {
+				return nil, gerror.Errorf("%s is listed by both %s and %s, use GetMarketInfos", pm, owner, name)
+			}
+			owners[pm] = name
+			r.Infos[pm] = info
+		}
+	}
+	return r, nil
+}
+
+// pairs listed by more than one exchange, like fintypes.FindSamePairs
+func (ae *AggregateEx) SamePairs(ctx context.Context) (map[fintypes.Pair][]fintypes.Platform, error) {
+	pairs := map[fintypes.Platform][]fintypes.Pair{}
+	for _, e := range ae.exs {
+		mi, err := ae.marketInfo(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+		pairs[e.Property().Name] = mi.Pairs()
+	}
+	return fintypes.FindSamePairs(pairs), nil
+}
+
+// account of every exchange, keyed by platform
+func (ae *AggregateEx) GetAccounts(ctx context.Context) (map[fintypes.Platform]*fintypes.Account, error) {
+	exs, err := ae.selected(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := map[fintypes.Platform]*fintypes.Account{}
+	for _, e := range exs {
+		acc, err := e.GetAccountContext(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get account of %s", e.Property().Name)
+		}
+		r[e.Property().Name] = acc
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) GetAccount() (*fintypes.Account, error) {
+	return ae.GetAccountContext(context.Background())
+}
+
+func (ae *AggregateEx) GetAccountContext(ctx context.Context) (*fintypes.Account, error) {
+	accs, err := ae.GetAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := &fintypes.Account{}
+	for _, e := range ae.exs {
+		if acc, ok := accs[e.Property().Name]; ok {
+			r.AddAccount(*acc)
+		}
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) GetDepth(market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	return ae.GetDepthContext(context.Background(), market, target)
+}
+
+func (ae *AggregateEx) GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetDepthContext(ctx, market, target)
+}
+
+func (ae *AggregateEx) GetKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return ae.GetKlineContext(context.Background(), market, target, period, since)
+}
+
+func (ae *AggregateEx) GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetKlineContext(ctx, market, target, period, since)
+}
+
+// ticks of every exchange, keyed by platform
+func (ae *AggregateEx) GetTicksByPlatform(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.Platform]map[fintypes.PairM]fintypes.Tick, error) {
+	exs, err := ae.selected(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := map[fintypes.Platform]map[fintypes.PairM]fintypes.Tick{}
+	for _, e := range exs {
+		ticks, err := e.GetTicksContext(ctx, ignorePairsNotFound)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get ticks of %s", e.Property().Name)
+		}
+		r[e.Property().Name] = ticks
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) GetTicks(ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	return ae.GetTicksContext(context.Background(), ignorePairsNotFound)
+}
+
+// union of ticks of exchanges selected by WithPlatform or all, PairM listed by more than one exchange is an error
+func (ae *AggregateEx) GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	ticks, err := ae.GetTicksByPlatform(ctx, ignorePairsNotFound)
+	if err != nil {
+		return nil, err
+	}
+	r := map[fintypes.PairM]fintypes.Tick{}
+	owners := map[fintypes.PairM]fintypes.Platform{}
+	for _, e := range ae.exs {
+		name := e.Property().Name
+		for pm, tick := range ticks[name] {
+			if owner, ok := owners[pm]; ok {
+				return nil, gerror.Errorf("%s is listed by both %s and %s, use GetTicksByPlatform", pm, owner, name)
+			}
+			owners[pm] = name
+			r[pm] = tick
+		}
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) GetBorrowable(margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	return ae.GetBorrowableContext(context.Background(), margin, asset)
+}
+
+func (ae *AggregateEx) GetBorrowableContext(ctx context.Context, margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	e, err := ae.single(ctx)
+	if err != nil {
+		return gdecimal.Zero, err
+	}
+	return e.GetBorrowableContext(ctx, margin, asset)
+}
+
+func (ae *AggregateEx) Borrow(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return ae.BorrowContext(context.Background(), margin, asset, amount)
+}
+
+func (ae *AggregateEx) BorrowContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	e, err := ae.single(ctx)
+	if err != nil {
+		return err
+	}
+	return e.BorrowContext(ctx, margin, asset, amount)
+}
+
+func (ae *AggregateEx) Repay(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return ae.RepayContext(context.Background(), margin, asset, amount)
+}
+
+func (ae *AggregateEx) RepayContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	e, err := ae.single(ctx)
+	if err != nil {
+		return err
+	}
+	return e.RepayContext(ctx, margin, asset, amount)
+}
+
+func (ae *AggregateEx) Transfer(asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	return ae.TransferContext(context.Background(), asset, amount, from, to)
+}
+
+func (ae *AggregateEx) TransferContext(ctx context.Context, asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	e, err := ae.single(ctx)
+	if err != nil {
+		return err
+	}
+	return e.TransferContext(ctx, asset, amount, from, to)
+}
+
+func (ae *AggregateEx) Trade(market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, unitAmount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return ae.TradeContext(context.Background(), market, margin, leverage, target, side, orderType, unitAmount, price, stopPrice)
+}
+
+func (ae *AggregateEx) TradeContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, unitAmount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	id, err := e.TradeContext(ctx, market, margin, leverage, target, side, orderType, unitAmount, price, stopPrice)
+	if err != nil {
+		return nil, err
+	}
+	r := aggregateOrderId(e.Property().Name, *id)
+	return &r, nil
+}
+
+func (ae *AggregateEx) TradeEx(req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	return ae.TradeExContext(context.Background(), req)
+}
+
+func (ae *AggregateEx) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	e, err := ae.route(ctx, req.Market, req.Pair)
+	if err != nil {
+		return nil, err
+	}
+	id, err := e.TradeExContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	r := aggregateOrderId(e.Property().Name, *id)
+	return &r, nil
+}
+
+func (ae *AggregateEx) aggregateOrders(platform fintypes.Platform, orders []fintypes.Order) []fintypes.Order {
+	for i := range orders {
+		orders[i].Id = aggregateOrderId(platform, orders[i].Id)
+	}
+	return orders
+}
+
+func (ae *AggregateEx) GetAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	return ae.GetAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (ae *AggregateEx) GetAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	exs, err := ae.filter(ctx, &market, &target)
+	if err != nil {
+		return nil, err
+	}
+	var r []fintypes.Order
+	for _, e := range exs {
+		orders, err := e.GetAllOrdersContext(ctx, market, margin, target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get orders of %s", e.Property().Name)
+		}
+		r = append(r, ae.aggregateOrders(e.Property().Name, orders)...)
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	return ae.GetOpenOrdersContext(context.Background(), market, margin, target)
+}
+
+func (ae *AggregateEx) GetOpenOrdersContext(ctx context.Context, market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	exs, err := ae.filter(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	var r []fintypes.Order
+	for _, e := range exs {
+		orders, err := e.GetOpenOrdersContext(ctx, market, margin, target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get open orders of %s", e.Property().Name)
+		}
+		r = append(r, ae.aggregateOrders(e.Property().Name, orders)...)
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) GetOrder(id fintypes.OrderId) (*fintypes.Order, error) {
+	return ae.GetOrderContext(context.Background(), id)
+}
+
+func (ae *AggregateEx) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	platform, orgId, err := SplitAggregateOrderId(id)
+	if err != nil {
+		return nil, err
+	}
+	e, err := ae.ex(platform)
+	if err != nil {
+		return nil, err
+	}
+	od, err := e.GetOrderContext(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	od.Id = aggregateOrderId(platform, od.Id)
+	return od, nil
+}
+
+func (ae *AggregateEx) CancelOrder(id fintypes.OrderId) error {
+	return ae.CancelOrderContext(context.Background(), id)
+}
+
+func (ae *AggregateEx) CancelOrderContext(ctx context.Context, id fintypes.OrderId) error {
+	platform, orgId, err := SplitAggregateOrderId(id)
+	if err != nil {
+		return err
+	}
+	e, err := ae.ex(platform)
+	if err != nil {
+		return err
+	}
+	return e.CancelOrderContext(ctx, orgId)
+}
+
+func (ae *AggregateEx) GetOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	return ae.GetOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (ae *AggregateEx) GetOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	od, err := e.GetOrderByClientIdContext(ctx, market, margin, target, clientId)
+	if err != nil {
+		return nil, err
+	}
+	od.Id = aggregateOrderId(e.Property().Name, od.Id)
+	return od, nil
+}
+
+func (ae *AggregateEx) CancelOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	return ae.CancelOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (ae *AggregateEx) CancelOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return err
+	}
+	return e.CancelOrderByClientIdContext(ctx, market, margin, target, clientId)
+}
+
+func (ae *AggregateEx) TradeBatch(reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	return ae.TradeBatchContext(context.Background(), reqs)
+}
+
+// requests are grouped by routed exchange, every group is sent as one batch
+func (ae *AggregateEx) TradeBatchContext(ctx context.Context, reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	r := make([]fintypes.OrderResult, len(reqs))
+	groups := map[fintypes.Platform][]int{}
+	for i, req := range reqs {
+		e, err := ae.route(ctx, req.Market, req.Pair)
+		if err != nil {
+			r[i].Err = err
+			continue
+		}
+		groups[e.Property().Name] = append(groups[e.Property().Name], i)
+	}
+	for _, e := range ae.exs {
+		idx, ok := groups[e.Property().Name]
+		if !ok {
+			continue
+		}
+		var sub []fintypes.TradeRequest
+		for _, i := range idx {
+			sub = append(sub, reqs[i])
+		}
+		results, err := e.TradeBatchContext(ctx, sub)
+		if err == nil && len(results) != len(sub) {
+			err = errors.Errorf("%s returned %d results of %d orders", e.Property().Name, len(results), len(sub))
+		}
+		for k, i := range idx {
+			if err != nil {
+				r[i].Err = err
+			} else {
+				r[i] = results[k]
+				r[i].Id = aggregateOrderId(e.Property().Name, r[i].Id)
+			}
+		}
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) CancelOrders(ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	return ae.CancelOrdersContext(context.Background(), ids)
+}
+
+// ids are grouped by platform, every group is sent as one batch
+func (ae *AggregateEx) CancelOrdersContext(ctx context.Context, ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	r := make([]fintypes.OrderResult, len(ids))
+	groups := map[fintypes.Platform][]int{}
+	orgIds := make([]fintypes.OrderId, len(ids))
+	for i, id := range ids {
+		r[i].Id = id
+		platform, orgId, err := SplitAggregateOrderId(id)
+		if err == nil {
+			_, err = ae.ex(platform)
+		}
+		if err != nil {
+			r[i].Err = err
+			continue
+		}
+		orgIds[i] = orgId
+		groups[platform] = append(groups[platform], i)
+	}
+	for _, e := range ae.exs {
+		idx, ok := groups[e.Property().Name]
+		if !ok {
+			continue
+		}
+		var sub []fintypes.OrderId
+		for _, i := range idx {
+			sub = append(sub, orgIds[i])
+		}
+		results, err := e.CancelOrdersContext(ctx, sub)
+		if err == nil && len(results) != len(sub) {
+			err = errors.Errorf("%s returned %d results of %d orders", e.Property().Name, len(results), len(sub))
+		}
+		for k, i := range idx {
+			if err != nil {
+				r[i].Err = err
+			} else {
+				r[i].Err = results[k].Err
+			}
+		}
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) CancelAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	return ae.CancelAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (ae *AggregateEx) CancelAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	exs, err := ae.filter(ctx, &market, &target)
+	if err != nil {
+		return nil, err
+	}
+	var r []fintypes.OrderResult
+	for _, e := range exs {
+		results, err := e.CancelAllOrdersContext(ctx, market, margin, target)
+		if err != nil {
+			return r, errors.Wrapf(err, "cancel orders of %s", e.Property().Name)
+		}
+		for _, v := range results {
+			v.Id = aggregateOrderId(e.Property().Name, v.Id)
+			r = append(r, v)
+		}
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) GetPositions(market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return ae.GetPositionsContext(context.Background(), market, target)
+}
+
+func (ae *AggregateEx) GetPositionsContext(ctx context.Context, market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	exs, err := ae.filter(ctx, &market, target)
+	if err != nil {
+		return nil, err
+	}
+	var r []fintypes.Position
+	for _, e := range exs {
+		positions, err := e.GetPositionsContext(ctx, market, target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get positions of %s", e.Property().Name)
+		}
+		r = append(r, positions...)
+	}
+	return r, nil
+}
+
+func (ae *AggregateEx) SetLeverage(market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return ae.SetLeverageContext(context.Background(), market, target, leverage)
+}
+
+func (ae *AggregateEx) SetLeverageContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, leverage int) error {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return err
+	}
+	return e.SetLeverageContext(ctx, market, target, leverage)
+}
+
+func (ae *AggregateEx) SetMarginType(market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return ae.SetMarginTypeContext(context.Background(), market, target, margin)
+}
+
+func (ae *AggregateEx) SetMarginTypeContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return err
+	}
+	return e.SetMarginTypeContext(ctx, market, target, margin)
+}
+
+func (ae *AggregateEx) ClosePosition(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return ae.ClosePositionContext(context.Background(), market, margin, target)
+}
+
+func (ae *AggregateEx) ClosePositionContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	id, err := e.ClosePositionContext(ctx, market, margin, target)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	r := aggregateOrderId(e.Property().Name, *id)
+	return &r, nil
+}
+
+func (ae *AggregateEx) GetFundingRates(market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return ae.GetFundingRatesContext(context.Background(), market, target, since)
+}
+
+func (ae *AggregateEx) GetFundingRatesContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetFundingRatesContext(ctx, market, target, since)
+}
+
+func (ae *AggregateEx) GetMarkPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return ae.GetMarkPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (ae *AggregateEx) GetMarkPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetMarkPriceKlineContext(ctx, market, target, period, since)
+}
+
+func (ae *AggregateEx) GetIndexPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return ae.GetIndexPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (ae *AggregateEx) GetIndexPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetIndexPriceKlineContext(ctx, market, target, period, since)
+}
+
+func (ae *AggregateEx) GetOpenInterests(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return ae.GetOpenInterestsContext(context.Background(), market, target, period, since)
+}
+
+func (ae *AggregateEx) GetOpenInterestsContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	e, err := ae.route(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetOpenInterestsContext(ctx, market, target, period, since)
+}
+
+func (ae *AggregateEx) GetDepositAddress(asset, network string) (*fintypes.DepositAddress, error) {
+	return ae.GetDepositAddressContext(context.Background(), asset, network)
+}
+
+func (ae *AggregateEx) GetDepositAddressContext(ctx context.Context, asset, network string) (*fintypes.DepositAddress, error) {
+	e, err := ae.single(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetDepositAddressContext(ctx, asset, network)
+}
+
+func (ae *AggregateEx) Withdraw(req fintypes.WithdrawRequest) (string, error) {
+	return ae.WithdrawContext(context.Background(), req)
+}
+
+func (ae *AggregateEx) WithdrawContext(ctx context.Context, req fintypes.WithdrawRequest) (string, error) {
+	e, err := ae.single(ctx)
+	if err != nil {
+		return "", err
+	}
+	return e.WithdrawContext(ctx, req)
+}
+
+func (ae *AggregateEx) GetDeposits(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return ae.GetDepositsContext(context.Background(), asset, since)
+}
+
+func (ae *AggregateEx) GetDepositsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	e, err := ae.single(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetDepositsContext(ctx, asset, since)
+}
+
+func (ae *AggregateEx) GetWithdrawals(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return ae.GetWithdrawalsContext(context.Background(), asset, since)
+}
+
+func (ae *AggregateEx) GetWithdrawalsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	e, err := ae.single(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetWithdrawalsContext(ctx, asset, since)
+}
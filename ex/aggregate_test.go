@@ -0,0 +1,174 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"strconv"
+	"testing"
+)
+
+// lists pairs with the same last price, account holds 1 of every asset
+type testVenueEx struct {
+	Ex
+	property fintypes.ExProperty
+	pairs    []fintypes.Pair
+	last     gdecimal.Decimal
+	orders   map[string]fintypes.Order
+}
+
+func newTestVenueEx(platform fintypes.Platform, last int64, pairs ...fintypes.Pair) *testVenueEx {
+	return &testVenueEx{property: fintypes.ExProperty{Name: platform}, pairs: pairs, last: gdecimal.NewFromInt(last), orders: map[string]fintypes.Order{}}
+}
+
+func (e *testVenueEx) Property() *fintypes.ExProperty {
+	return &e.property
+}
+
+func (e *testVenueEx) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	mi := &fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+	for _, p := range e.pairs {
+		mi.Infos[p.SetM(fintypes.MarketSpot)] = fintypes.PairInfo{Enabled: true}
+	}
+	return mi, nil
+}
+
+func (e *testVenueEx) GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	r := map[fintypes.PairM]fintypes.Tick{}
+	for _, p := range e.pairs {
+		r[p.SetM(fintypes.MarketSpot)] = fintypes.Tick{Last: e.last}
+	}
+	return r, nil
+}
+
+func (e *testVenueEx) GetAccountContext(ctx context.Context) (*fintypes.Account, error) {
+	acc := &fintypes.Account{}
+	for _, p := range e.pairs {
+		acc.AddFree(fintypes.AssetProperty{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Asset: p.Unit()}, gdecimal.One)
+	}
+	return acc, nil
+}
+
+func (e *testVenueEx) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	id := fintypes.NewOrderId(req.Market, req.Margin, req.Pair, strconv.Itoa(len(e.orders)+1))
+	e.orders[id.StrId()] = fintypes.Order{Id: id, Pair: req.Pair, Status: fintypes.OrderStatusNew}
+	return &id, nil
+}
+
+func (e *testVenueEx) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	od, ok := e.orders[id.StrId()]
+	if !ok || od.Id != id {
+		return nil, fintypes.ErrOrderNotFound
+	}
+	return &od, nil
+}
+
+func (e *testVenueEx) CancelOrdersContext(ctx context.Context, ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	var r []fintypes.OrderResult
+	for _, id := range ids {
+		od, ok := e.orders[id.StrId()]
+		if !ok {
+			r = append(r, fintypes.OrderResult{Id: id, Err: fintypes.ErrOrderNotFound})
+			continue
+		}
+		od.Status = fintypes.OrderStatusCanceled
+		e.orders[id.StrId()] = od
+		r = append(r, fintypes.OrderResult{Id: id})
+	}
+	return r, nil
+}
+
+func TestAggregateEx(t *testing.T) {
+	btc, eth, ltc := fintypes.BTC.Against(fintypes.USDT), fintypes.ETH.Against(fintypes.USDT), fintypes.LTC.Against(fintypes.USDT)
+	bn := newTestVenueEx(fintypes.Binance, 100, btc, eth)
+	hb := newTestVenueEx(fintypes.Huobi, 200, btc, ltc)
+	ae, err := NewAggregateEx(bn, hb)
+	gtest.Assert(t, err)
+	var _ Ex = ae
+	if _, err := NewAggregateEx(bn, bn); err == nil {
+		t.Errorf("duplicate exchanges should be rejected")
+	}
+
+	// union fails if a pair is listed by both, by platform ones keep all
+	if _, err := ae.GetTicks(true); err == nil {
+		t.Errorf("ticks of %s listed by both should not be merged", btc)
+	}
+	if _, err := ae.GetMarketInfo(true); err == nil {
+		t.Errorf("market info of %s listed by both should not be merged", btc)
+	}
+	byPlatform, err := ae.GetTicksByPlatform(context.Background(), true)
+	gtest.Assert(t, err)
+	if !byPlatform[fintypes.Binance][btc.SetM(fintypes.MarketSpot)].Last.Equal(gdecimal.NewFromInt(100)) ||
+		!byPlatform[fintypes.Huobi][btc.SetM(fintypes.MarketSpot)].Last.Equal(gdecimal.NewFromInt(200)) {
+		t.Errorf("wrong ticks by platform %v", byPlatform)
+	}
+	ticks, err := ae.GetTicksContext(WithPlatform(context.Background(), fintypes.Huobi), true)
+	gtest.Assert(t, err)
+	if len(ticks) != 2 || !ticks[btc.SetM(fintypes.MarketSpot)].Last.Equal(gdecimal.NewFromInt(200)) {
+		t.Errorf("wrong huobi ticks %v", ticks)
+	}
+	disjoint, err := NewAggregateEx(newTestVenueEx(fintypes.Binance, 100, eth), newTestVenueEx(fintypes.Huobi, 200, ltc))
+	gtest.Assert(t, err)
+	mi, err := disjoint.GetMarketInfo(true)
+	gtest.Assert(t, err)
+	if len(mi.Infos) != 2 {
+		t.Errorf("2 pairs expected, but %d", len(mi.Infos))
+	}
+	same, err := ae.SamePairs(context.Background())
+	gtest.Assert(t, err)
+	if len(same) != 1 || len(same[btc]) != 2 {
+		t.Errorf("only %s should be listed by both, but %v", btc, same)
+	}
+
+	// balances of the same asset are summed
+	acc, err := ae.GetAccount()
+	gtest.Assert(t, err)
+	for _, b := range acc.Balances {
+		if b.Asset == fintypes.BTC.String() && !b.Free.Equal(gdecimal.NewFromInt(2)) {
+			t.Errorf("free BTC should be 2, but %s", b.Free)
+		}
+	}
+
+	// routing and namespaced order ids
+	req := fintypes.TradeRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: ltc, Side: fintypes.OrderSideBuyLong, Type: fintypes.OrderTypeMarket, Amount: gdecimal.One}
+	idLtc, err := ae.TradeEx(req)
+	gtest.Assert(t, err)
+	req.Pair = btc
+	idBtc, err := ae.TradeExContext(WithPlatform(context.Background(), fintypes.Huobi), req)
+	gtest.Assert(t, err)
+	if idLtc.StrId() != "huobi@1" || idBtc.StrId() != "huobi@2" || idBtc.Pair() != btc {
+		t.Errorf("wrong order ids %s %s", idLtc, idBtc)
+	}
+	platform, orgId, err := SplitAggregateOrderId(*idBtc)
+	gtest.Assert(t, err)
+	if platform != fintypes.Huobi || orgId.StrId() != "2" {
+		t.Errorf("wrong split %s %s", platform, orgId)
+	}
+	req.Pair = eth
+	idEth, err := ae.TradeEx(req)
+	gtest.Assert(t, err)
+	od, err := ae.GetOrder(*idEth)
+	gtest.Assert(t, err)
+	if od.Id != *idEth || len(bn.orders) != 1 {
+		t.Errorf("order should be placed in binance, but %s", od.Id)
+	}
+	results, err := ae.CancelOrders([]fintypes.OrderId{*idLtc, *idEth, fintypes.NewOrderId(fintypes.MarketSpot, fintypes.MarginNo, btc, "1")})
+	gtest.Assert(t, err)
+	if results[0].Err != nil || results[1].Err != nil || results[2].Err == nil || results[1].Id != *idEth {
+		t.Errorf("wrong cancel results %v", results)
+	}
+	if hb.orders["1"].Status != fintypes.OrderStatusCanceled || bn.orders["1"].Status != fintypes.OrderStatusCanceled {
+		t.Errorf("orders should be canceled")
+	}
+
+	// errors
+	req.Pair = fintypes.DOGE.Against(fintypes.USDT)
+	if _, err := ae.TradeEx(req); !errors.Is(err, fintypes.ErrInvalidPair) {
+		t.Errorf("invalid pair error expected, but %v", err)
+	}
+	if _, err := ae.GetDepositAddress(fintypes.BTC.String(), ""); err == nil {
+		t.Errorf("ambiguous platform should be reported")
+	}
+}
@@ -1,11 +1,17 @@
 package ex
 
 import (
+	"context"
+	"github.com/foxtrader/gofin/ex/backtest"
 	"github.com/foxtrader/gofin/ex/binance"
+	"github.com/foxtrader/gofin/ex/huobi"
+	"github.com/foxtrader/gofin/ex/kraken"
+	"github.com/foxtrader/gofin/ex/paper"
 	"github.com/foxtrader/gofin/fintypes"
 	"github.com/shawnwyckoff/gopkg/apputil/gerror"
 	"github.com/shawnwyckoff/gopkg/container/gdecimal"
 	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"net/http"
 	"strings"
 	"time"
 )
@@ -14,6 +20,8 @@ type (
 
 	// Ex interface
 	Ex interface {
+		ExContext
+
 		// exchange custom properties
 		Property() *fintypes.ExProperty
 
@@ -48,6 +56,10 @@ type (
 		// price: when market-buy/market-sell, price will be ignored
 		Trade(market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, unitAmount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error)
 
+		// Trade with time in force, client order id and extended order types like OCO and trailing stop, unsupported ones return error
+		// use TradeIdempotent to retry safely with client order id when the result is unknown
+		TradeEx(req fintypes.TradeRequest) (*fintypes.OrderId, error)
+
 		// get all my history orders' info
 		GetAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error)
 
@@ -60,17 +72,187 @@ type (
 		// cancel unfinished order by id
 		CancelOrder(id fintypes.OrderId) error
 
+		// get order info by client order id set in TradeRequest, error wraps fintypes.ErrOrderNotFound if not found
+		GetOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error)
+
+		// cancel unfinished order by client order id
+		CancelOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error
+
+		// place orders in batch, native batch api is used if supported, otherwise orders are placed one by one
+		// results are in the same order as reqs, failure of single order or single request is reported in results,
+		// error is returned only if the whole batch is invalid
+		TradeBatch(reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error)
+
+		// cancel unfinished orders in batch, results are in the same order as ids
+		CancelOrders(ids []fintypes.OrderId) ([]fintypes.OrderResult, error)
+
+		// cancel all unfinished orders of the pair, results of orders tried to cancel are returned
+		CancelAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error)
+
+		// get open positions of contract market, positions of all pairs are returned if target is nil
+		GetPositions(market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error)
+
+		// set leverage of contract pair, it works for both new orders and open position
+		SetLeverage(market fintypes.Market, target fintypes.Pair, leverage int) error
+
+		// switch isolated/cross margin of contract pair, exchanges may reject it when there is open position or order
+		SetMarginType(market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error
+
+		// close position of contract pair by reduce only market order, nil OrderId is returned if there is no position
+		ClosePosition(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error)
+
+		// get funding rate history of perp pair, since is the begin time, the latest ones are returned if it is nil
+		GetFundingRates(market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error)
+
+		// get mark price candle bars of contract pair, volume is always zero
+		GetMarkPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error)
+
+		// get index price candle bars of contract pair, volume is always zero
+		GetIndexPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error)
+
+		// get open interest history of contract pair, period is the statistic interval
+		GetOpenInterests(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error)
+
+		// get deposit address of asset on network, network is exchange specific, empty network means the default one
+		GetDepositAddress(asset, network string) (*fintypes.DepositAddress, error)
+
+		// withdraw asset to address, withdrawal id is returned, the withdrawal is processed asynchronously
+		Withdraw(req fintypes.WithdrawRequest) (string, error)
+
+		// get deposit history of asset since time, empty asset means all assets
+		GetDeposits(asset string, since *time.Time) ([]fintypes.WalletRecord, error)
+
+		// get withdrawal history of asset since time, empty asset means all assets
+		GetWithdrawals(asset string, since *time.Time) ([]fintypes.WalletRecord, error)
+
 		// get exchange match results history, not history of current account but whole market
 		//GetFills(market Market, target Pair, since Since) ([]Fill, error)
 	}
+
+	// context-aware variants of Ex methods, ctx is passed to underlying http requests,
+	// methods of Ex are the same as calling these methods with context.Background()
+	ExContext interface {
+		GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error)
+		GetAccountContext(ctx context.Context) (*fintypes.Account, error)
+		GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error)
+		GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error)
+		GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error)
+		GetBorrowableContext(ctx context.Context, margin fintypes.Margin, asset string) (gdecimal.Decimal, error)
+		BorrowContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error
+		RepayContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error
+		TransferContext(ctx context.Context, asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error
+		TradeContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, unitAmount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error)
+		TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error)
+		GetAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error)
+		GetOpenOrdersContext(ctx context.Context, market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error)
+		GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error)
+		CancelOrderContext(ctx context.Context, id fintypes.OrderId) error
+		GetOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error)
+		CancelOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error
+		TradeBatchContext(ctx context.Context, reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error)
+		CancelOrdersContext(ctx context.Context, ids []fintypes.OrderId) ([]fintypes.OrderResult, error)
+		CancelAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error)
+		GetPositionsContext(ctx context.Context, market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error)
+		SetLeverageContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, leverage int) error
+		SetMarginTypeContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error
+		ClosePositionContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error)
+		GetFundingRatesContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error)
+		GetMarkPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error)
+		GetIndexPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error)
+		GetOpenInterestsContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error)
+		GetDepositAddressContext(ctx context.Context, asset, network string) (*fintypes.DepositAddress, error)
+		WithdrawContext(ctx context.Context, req fintypes.WithdrawRequest) (string, error)
+		GetDepositsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error)
+		GetWithdrawalsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error)
+	}
+
+	// BaseUrlSetter is implemented by exchanges whose rest api endpoints can be redirected, use SetBaseUrl to call it
+	BaseUrlSetter interface {
+		SetBaseUrl(market fintypes.Market, baseUrl string) error
+	}
+
+	// TransportSetter is implemented by exchanges whose rest requests can be sent by custom transport, use SetTransport to call it
+	TransportSetter interface {
+		SetTransport(rt http.RoundTripper)
+	}
+
+	// FeeScheduleGetter is implemented by exchanges which provide account commission rates, use GetFeeSchedule to call it
+	FeeScheduleGetter interface {
+		GetFeeScheduleContext(ctx context.Context) (*fintypes.FeeSchedule, error)
+	}
 )
 
 // email is required in living trading, but not required in kline spider
+// use AsStreamer to get streaming api of returned exchange
 func NewEx(name fintypes.Platform, apiKey, apiSecret, proxy string, c gtime.Clock, email string) (Ex, error) {
 	switch strings.ToLower(name.String()) {
 	case strings.ToLower(fintypes.Binance.String()):
 		return binance.New(apiKey, apiSecret, proxy, c, email)
+	case strings.ToLower(fintypes.Huobi.String()):
+		hb, err := huobi.New(apiKey, apiSecret, proxy, c, email)
+		if err != nil {
+			return nil, err
+		}
+		return hb, nil
+	case strings.ToLower(fintypes.Kraken.String()):
+		kr, err := kraken.New(apiKey, apiSecret, proxy, c, email)
+		if err != nil {
+			return nil, err
+		}
+		return kr, nil
 	default:
 		return nil, gerror.Errorf("unsupported exchange(%s)", name.String())
 	}
 }
+
+// redirect rest api of exchange created by NewEx, like to a local simulator or testnet
+func SetBaseUrl(e Ex, market fintypes.Market, baseUrl string) error {
+	s, ok := e.(BaseUrlSetter)
+	if !ok {
+		return gerror.Errorf("%s doesn't support base url setting", e.Property().Name)
+	}
+	return s.SetBaseUrl(market, baseUrl)
+}
+
+// send rest requests of exchange created by NewEx by rt instead of proxy, like httpreplay.Transport in tests
+func SetTransport(e Ex, rt http.RoundTripper) error {
+	s, ok := e.(TransportSetter)
+	if !ok {
+		return gerror.Errorf("%s doesn't support transport setting", e.Property().Name)
+	}
+	s.SetTransport(rt)
+	return nil
+}
+
+// fee schedule of account, static fees in market info are used if exchange doesn't implement FeeScheduleGetter
+func GetFeeSchedule(ctx context.Context, e Ex) (*fintypes.FeeSchedule, error) {
+	if s, ok := e.(FeeScheduleGetter); ok {
+		return s.GetFeeScheduleContext(ctx)
+	}
+	mi, err := e.GetMarketInfoContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return fintypes.NewFeeScheduleFromMarketInfo(e.Property().Name, mi), nil
+}
+
+// paper trading exchange, orders are matched against depth of feed with a virtual account
+func NewPaperEx(feed Ex, init *fintypes.Account) (Ex, error) {
+	if feed == nil {
+		return nil, gerror.Errorf("nil feed exchange")
+	}
+	pe, err := paper.New(feed, init, feed.Property().Clock)
+	if err != nil {
+		return nil, err
+	}
+	return pe, nil
+}
+
+// backtest exchange, orders are matched against historical klines, simulated time is controlled by c
+func NewBackTestEx(name fintypes.Platform, klines []*fintypes.Kline, mi *fintypes.MarketInfo, init *fintypes.Account, c gtime.Clock) (Ex, error) {
+	bt, err := backtest.New(name, klines, mi, init, c)
+	if err != nil {
+		return nil, err
+	}
+	return bt, nil
+}
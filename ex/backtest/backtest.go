@@ -0,0 +1,919 @@
+package backtest
+
+/**
+BackTestEx，回测交易所
+
+Driven by historical klines and a controllable clock, the same strategy code can run against history and living exchanges.
+The clock is owned by the caller, every API only sees bars closed before Clock.Now(), which avoids look-ahead bias.
+
+Matching rules by bar OHLC:
+market order: filled immediately at the close of the latest closed bar, taker fee.
+limit order: buy filled when bar.L <= price, sell filled when bar.H >= price, at the better one of price and bar.O, maker fee.
+stop-limit order: triggered when bar.H >= stop price (buy) or bar.L <= stop price (sell), then works like a limit order.
+
+NOTE:
+volume of bars is NOT considered, orders are always filled entirely.
+only spot market (with or without margin) is supported for now.
+fee is charged in the asset received, like binance does.
+ctx of XxxContext APIs is ignored because nothing is requested remotely.
+*/
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	order struct {
+		fintypes.Order
+		locked    gdecimal.Decimal // remaining locked amount of the paying asset
+		triggered bool             // whether stop price of stop-limit order is reached
+		checked   time.Time        // open time of the last bar checked
+	}
+
+	Client struct {
+		property          fintypes.ExProperty
+		mu                sync.Mutex
+		klines            map[fintypes.PairM]*fintypes.Kline
+		marketInfo        fintypes.MarketInfo
+		account           *fintypes.Account
+		orders            []*order
+		nextId            int64
+		interestRateDaily gdecimal.Decimal
+		maxMarginLeverage int
+		feeSchedule       *fintypes.FeeSchedule // nil means fees in market info
+	}
+)
+
+var (
+	DefaultInterestRateDaily = gdecimal.NewFromFloat64(0.0002)
+	DefaultMaxMarginLeverage = 3
+	MaxKlineSize             = 1000 // max bars returned by GetKline, same as binance
+)
+
+// name: platform to simulate
+// klines: historical klines, only one kline for every PairM, and kline with min period is recommended
+// mi: market info, fees and steps of pairs
+// init: initial virtual account, it will be copied
+// c: simulated clock, it is controlled by caller
+func New(name fintypes.Platform, klines []*fintypes.Kline, mi *fintypes.MarketInfo, init *fintypes.Account, c gtime.Clock) (*Client, error) {
+	if c == nil {
+		return nil, gerror.Errorf("nil clock")
+	}
+	if len(klines) == 0 {
+		return nil, gerror.Errorf("no klines")
+	}
+
+	bt := &Client{}
+	bt.klines = map[fintypes.PairM]*fintypes.Kline{}
+	bt.marketInfo = fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+	bt.property = fintypes.ExProperty{
+		Name:            name,
+		MaxDepth:        1,
+		PairDelimiter:   "/",
+		PairNormalOrder: true,
+		PairUpperCase:   true,
+		Periods:         map[fintypes.Period]string{},
+		MarketEnabled:   map[fintypes.Market]bool{},
+		Clock:           c,
+		IsBackTestEx:    true,
+		TradeBeginTime:  gtime.ZeroTime,
+	}
+
+	for _, k := range klines {
+		if k == nil || k.Len() == 0 {
+			continue
+		}
+		if err := k.Pair.Verify(); err != nil {
+			return nil, err
+		}
+		pm := k.Pair.Pair().SetM(k.Pair.M())
+		if _, ok := bt.klines[pm]; ok {
+			return nil, gerror.Errorf("duplicated kline of %s", pm.String())
+		}
+		k.Sort()
+		bt.klines[pm] = k
+
+		// every period not less than the kline's period can be converted
+		for _, p := range fintypes.AllPeriods {
+			if p.ToSeconds() >= k.Pair.I().ToSeconds() {
+				bt.property.Periods[p] = p.String()
+			}
+		}
+		bt.property.MarketEnabled[k.Pair.M()] = true
+		if first := k.Items[0].T; bt.property.TradeBeginTime.Equal(gtime.ZeroTime) || first.Before(bt.property.TradeBeginTime) {
+			bt.property.TradeBeginTime = first
+		}
+		bt.marketInfo.Infos[pm] = fintypes.PairInfo{Enabled: true}
+	}
+	if mi != nil {
+		for pm, info := range mi.Infos {
+			if _, ok := bt.klines[pm]; ok {
+				bt.marketInfo.Infos[pm] = info
+			}
+		}
+	}
+
+	if init != nil {
+		bt.account = init.Clone()
+	} else {
+		bt.account = fintypes.NewEmptyAccount()
+	}
+	bt.nextId = 1
+	bt.interestRateDaily = DefaultInterestRateDaily
+	bt.maxMarginLeverage = DefaultMaxMarginLeverage
+	return bt, nil
+}
+
+func (bt *Client) SetInterestRateDaily(rate gdecimal.Decimal) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	bt.interestRateDaily = rate
+}
+
+// fees are calculated by fs instead of MakerFee/TakerFee in market info, like VIP tiers and BNB discount of binance.
+// fee is paid in discount asset only if its price is known by spot kline of DiscountAsset/quote and its balance is enough.
+func (bt *Client) SetFeeSchedule(fs *fintypes.FeeSchedule) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	bt.feeSchedule = fs
+}
+
+func (bt *Client) Property() *fintypes.ExProperty {
+	return &bt.property
+}
+
+func (bt *Client) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	return bt.GetMarketInfoContext(context.Background(), ignorePairsNotFound)
+}
+
+func (bt *Client) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	res := &fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+	for pm, info := range bt.marketInfo.Infos {
+		res.Infos[pm] = info
+	}
+	return res, nil
+}
+
+func (bt *Client) GetAccount() (*fintypes.Account, error) {
+	return bt.GetAccountContext(context.Background())
+}
+
+func (bt *Client) GetAccountContext(ctx context.Context) (*fintypes.Account, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if err := bt.match(); err != nil {
+		return nil, err
+	}
+	return bt.account.Clone(), nil
+}
+
+// depth is simulated by the latest closed bar, close price in both sides and bar volume as amount
+func (bt *Client) GetDepth(market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	return bt.GetDepthContext(context.Background(), market, target)
+}
+
+func (bt *Client) GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	bar, err := bt.lastBar(target.SetM(market))
+	if err != nil {
+		return nil, err
+	}
+	res := &fintypes.Depth{}
+	res.Time = bt.property.Clock.Now()
+	res.Buys = fintypes.OrderBookList{{Price: bar.C, Amount: bar.V}}
+	res.Sells = fintypes.OrderBookList{{Price: bar.C, Amount: bar.V}}
+	return res, nil
+}
+
+// returns MaxKlineSize bars at most from since, or the latest MaxKlineSize bars if since is nil
+func (bt *Client) GetKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return bt.GetKlineContext(context.Background(), market, target, period, since)
+}
+
+func (bt *Client) GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	base, ok := bt.klines[target.SetM(market)]
+	if !ok {
+		return nil, gerror.Errorf("kline of %s not found", target.SetM(market).String())
+	}
+	if _, err := period.CustomFormat(&bt.property); err != nil {
+		return nil, err
+	}
+
+	visible := bt.visible(base)
+	r, err := visible.ToPeriod(period, fintypes.DefaultPeriodRoundConfig)
+	if err != nil {
+		return nil, err
+	}
+	if since != nil {
+		r = r.SliceAfterEqual(*since)
+		if r.Len() > MaxKlineSize {
+			r = r.SliceBetweenId(0, MaxKlineSize-1)
+		}
+	} else {
+		r = r.SliceTail(MaxKlineSize)
+	}
+
+	res := fintypes.NewKline(target.SetI(period).SetM(market).SetP(bt.property.Name), append([]fintypes.Bar(nil), r.Items...))
+	return res, nil
+}
+
+func (bt *Client) GetTicks(ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	return bt.GetTicksContext(context.Background(), ignorePairsNotFound)
+}
+
+func (bt *Client) GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	res := make(map[fintypes.PairM]fintypes.Tick)
+	now := bt.property.Clock.Now()
+	for pm := range bt.klines {
+		bar, err := bt.lastBar(pm)
+		if err != nil {
+			continue // not started yet at simulated time
+		}
+		res[pm] = fintypes.Tick{
+			Time:   now,
+			Last:   bar.C,
+			Buy:    bar.C,
+			Sell:   bar.C,
+			High:   bar.H,
+			Low:    bar.L,
+			Volume: bar.V,
+		}
+	}
+	return res, nil
+}
+
+// borrowable = net asset amount * (max leverage - 1) - borrowed
+func (bt *Client) GetBorrowable(margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	return bt.GetBorrowableContext(context.Background(), margin, asset)
+}
+
+func (bt *Client) GetBorrowableContext(ctx context.Context, margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	return bt.borrowable(margin, asset)
+}
+
+func (bt *Client) borrowable(margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	if margin != fintypes.MarginCross && margin != fintypes.MarginIsolated {
+		return gdecimal.Zero, gerror.Errorf("unsupported margin(%s)", margin)
+	}
+	amount := bt.account.GetAmountByProperty(fintypes.NewAP(fintypes.MarketSpot, margin, asset))
+	r := amount.Net().MulInt(bt.maxMarginLeverage - 1).Sub(amount.Borrowed)
+	if !r.IsPositive() {
+		return gdecimal.Zero, nil
+	}
+	return r, nil
+}
+
+func (bt *Client) Borrow(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return bt.BorrowContext(context.Background(), margin, asset, amount)
+}
+
+func (bt *Client) BorrowContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	borrowable, err := bt.borrowable(margin, asset)
+	if err != nil {
+		return err
+	}
+	if amount.GreaterThan(borrowable) {
+		return gerror.Errorf("borrow %s %s exceeds borrowable %s", amount.String(), asset, borrowable.String())
+	}
+	return bt.account.Borrow(bt.property.Clock.Now(), margin, asset, amount)
+}
+
+func (bt *Client) Repay(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return bt.RepayContext(context.Background(), margin, asset, amount)
+}
+
+func (bt *Client) RepayContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	return bt.account.Repay(bt.property.Clock.Now(), bt.interestRateDaily, margin, asset, amount)
+}
+
+func (bt *Client) Transfer(asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	return bt.TransferContext(context.Background(), asset, amount, from, to)
+}
+
+func (bt *Client) TransferContext(ctx context.Context, asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	return bt.account.Transfer(asset, amount, from, to)
+}
+
+func (bt *Client) Trade(market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return bt.TradeContext(context.Background(), market, margin, leverage, target, side, orderType, amount, price, stopPrice)
+}
+
+func (bt *Client) TradeContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return bt.TradeExContext(ctx, fintypes.TradeRequest{Market: market, Margin: margin, Leverage: leverage, Pair: target, Side: side, Type: orderType, Amount: amount, Price: price, StopPrice: stopPrice})
+}
+
+func (bt *Client) TradeEx(req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	return bt.TradeExContext(context.Background(), req)
+}
+
+// only GTC limit, market and stop-limit orders are supported
+func (bt *Client) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	market, margin, leverage, target, side, orderType := req.Market, req.Margin, req.Leverage, req.Pair, req.Side, req.Type
+	amount, price, stopPrice := req.Amount, req.Price, req.StopPrice
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if market != fintypes.MarketSpot {
+		return nil, gerror.Errorf("backtest exchange doesn't support Market(%s)", market)
+	}
+	if err := margin.Verify(); err != nil {
+		return nil, err
+	}
+	if err := side.Verify(); err != nil {
+		return nil, err
+	}
+	if !orderType.IsLimit() && !orderType.IsMarket() && !orderType.IsStopLimit() {
+		return nil, gerror.Errorf("unsupported OrderType(%s)", orderType)
+	}
+	if !amount.IsPositive() {
+		return nil, gerror.Errorf("invalid amount %s", amount.String())
+	}
+	if !orderType.IsMarket() && !price.IsPositive() {
+		return nil, gerror.Errorf("invalid price %s", price.String())
+	}
+	if orderType.IsStopLimit() && !stopPrice.IsPositive() {
+		return nil, gerror.Errorf("invalid stop price %s", stopPrice.String())
+	}
+	if req.TimeInForce != fintypes.TimeInForceError && req.TimeInForce != fintypes.TimeInForceGTC {
+		return nil, gerror.Errorf("backtest exchange doesn't support TimeInForce(%s)", req.TimeInForce)
+	}
+	if req.ReduceOnly {
+		return nil, gerror.Errorf("backtest exchange doesn't support reduce only order")
+	}
+
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	// client order id is unique in backtest exchange, whether the order is finished or not
+	if req.ClientId != "" {
+		if err := fintypes.VerifyClientId(req.ClientId); err != nil {
+			return nil, err
+		}
+		if _, err := bt.findClientOrder(market, margin, target, req.ClientId); err == nil {
+			return nil, errors.Wrapf(fintypes.ErrDuplicateOrder, "client order id(%s)", req.ClientId)
+		}
+	}
+
+	// settle orders before the new one, so that balance is up to date
+	if err := bt.match(); err != nil {
+		return nil, err
+	}
+	last, err := bt.lastBar(target.SetM(market))
+	if err != nil {
+		return nil, err
+	}
+
+	// round amount and prices by PairInfo, market order is checked by close price of the latest bar
+	refPrice := price
+	if orderType.IsMarket() {
+		refPrice = last.C
+	}
+	amount, refPrice, stopPrice, err = bt.marketInfo.NormalizeOrder(target.SetM(market), orderType, amount, refPrice, stopPrice)
+	if err != nil {
+		return nil, err
+	}
+	if !orderType.IsMarket() {
+		price = refPrice
+	}
+
+	// calculate the asset and amount to lock
+	payAsset, payAmount := target.Quote(), amount.Mul(price)
+	if side.IsSell() {
+		payAsset, payAmount = target.Unit(), amount
+	} else if orderType.IsMarket() {
+		payAmount = amount.Mul(last.C)
+	}
+	free := bt.account.GetAmountByProperty(fintypes.NewAP(market, margin, payAsset)).Free
+	if free.LessThan(payAmount) {
+		return nil, errors.Wrapf(fintypes.ErrInsufficientBalance, "%s %s required, but %s free", payAmount.String(), payAsset, free.String())
+	}
+	if err := bt.account.Lock(market, margin, payAsset, payAmount); err != nil {
+		return nil, err
+	}
+
+	od := &order{}
+	od.Id = fintypes.NewOrderId(market, margin, target, strconv.FormatInt(bt.nextId, 10))
+	od.ClientId = req.ClientId
+	od.Time = bt.property.Clock.Now()
+	od.Market = market
+	od.Margin = margin
+	od.Leverage = leverage
+	od.Pair = target
+	od.Side = side
+	od.Type = orderType
+	od.Status = fintypes.OrderStatusNew
+	od.StopPrice = stopPrice
+	od.Price = price
+	od.Amount = amount
+	od.locked = payAmount
+	od.checked = last.T
+	bt.nextId++
+	bt.orders = append(bt.orders, od)
+
+	if orderType.IsMarket() {
+		if err := bt.fill(od, last.C, true); err != nil {
+			return nil, err
+		}
+	}
+
+	res := od.Id
+	return &res, nil
+}
+
+func (bt *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	return bt.GetAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (bt *Client) GetAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if err := bt.match(); err != nil {
+		return nil, err
+	}
+	var r []fintypes.Order
+	for _, od := range bt.orders {
+		if od.Market == market && od.Margin == margin && od.Pair == target {
+			r = append(r, od.Order)
+		}
+	}
+	return r, nil
+}
+
+func (bt *Client) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	return bt.GetOpenOrdersContext(context.Background(), market, margin, target)
+}
+
+func (bt *Client) GetOpenOrdersContext(ctx context.Context, market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if err := bt.match(); err != nil {
+		return nil, err
+	}
+	var r []fintypes.Order
+	for _, od := range bt.orders {
+		if od.Status.End() {
+			continue
+		}
+		if market != nil && od.Market != *market {
+			continue
+		}
+		if margin != nil && od.Margin != *margin {
+			continue
+		}
+		if target != nil && od.Pair != *target {
+			continue
+		}
+		r = append(r, od.Order)
+	}
+	return r, nil
+}
+
+func (bt *Client) GetOrder(id fintypes.OrderId) (*fintypes.Order, error) {
+	return bt.GetOrderContext(context.Background(), id)
+}
+
+func (bt *Client) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	if err := id.Verify(); err != nil {
+		return nil, err
+	}
+
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if err := bt.match(); err != nil {
+		return nil, err
+	}
+	od, err := bt.findOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	res := od.Order
+	return &res, nil
+}
+
+func (bt *Client) CancelOrder(id fintypes.OrderId) error {
+	return bt.CancelOrderContext(context.Background(), id)
+}
+
+func (bt *Client) CancelOrderContext(ctx context.Context, id fintypes.OrderId) error {
+	if err := id.Verify(); err != nil {
+		return err
+	}
+
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	// the order may be filled by bars before now
+	if err := bt.match(); err != nil {
+		return err
+	}
+	od, err := bt.findOrder(id)
+	if err != nil {
+		return err
+	}
+	if od.Status.End() {
+		return gerror.Errorf("OrderId(%s) is already %s", id.String(), od.Status)
+	}
+	return bt.finish(od, fintypes.OrderStatusCanceled)
+}
+
+func (bt *Client) GetOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	return bt.GetOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (bt *Client) GetOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if err := bt.match(); err != nil {
+		return nil, err
+	}
+	od, err := bt.findClientOrder(market, margin, target, clientId)
+	if err != nil {
+		return nil, err
+	}
+	res := od.Order
+	return &res, nil
+}
+
+func (bt *Client) CancelOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	return bt.CancelOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (bt *Client) CancelOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	// the order may be filled by bars before now
+	if err := bt.match(); err != nil {
+		return err
+	}
+	od, err := bt.findClientOrder(market, margin, target, clientId)
+	if err != nil {
+		return err
+	}
+	if od.Status.End() {
+		return gerror.Errorf("client order id(%s) is already %s", clientId, od.Status)
+	}
+	return bt.finish(od, fintypes.OrderStatusCanceled)
+}
+
+// orders are placed one by one
+func (bt *Client) TradeBatch(reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	return bt.TradeBatchContext(context.Background(), reqs)
+}
+
+func (bt *Client) TradeBatchContext(ctx context.Context, reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(reqs))
+	for i, req := range reqs {
+		id, err := bt.TradeExContext(ctx, req)
+		if err != nil {
+			res[i].Err = err
+			continue
+		}
+		res[i].Id = *id
+	}
+	return res, nil
+}
+
+func (bt *Client) CancelOrders(ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	return bt.CancelOrdersContext(context.Background(), ids)
+}
+
+func (bt *Client) CancelOrdersContext(ctx context.Context, ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(ids))
+	for i, id := range ids {
+		res[i] = fintypes.OrderResult{Id: id, Err: bt.CancelOrderContext(ctx, id)}
+	}
+	return res, nil
+}
+
+func (bt *Client) CancelAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	return bt.CancelAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (bt *Client) CancelAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	ods, err := bt.GetOpenOrdersContext(ctx, &market, &margin, &target)
+	if err != nil {
+		return nil, err
+	}
+	var ids []fintypes.OrderId
+	for _, od := range ods {
+		ids = append(ids, od.Id)
+	}
+	return bt.CancelOrdersContext(ctx, ids)
+}
+
+func (bt *Client) findClientOrder(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*order, error) {
+	for _, od := range bt.orders {
+		if od.ClientId == clientId && od.Market == market && od.Margin == margin && od.Pair == target {
+			return od, nil
+		}
+	}
+	return nil, errors.Wrapf(fintypes.ErrOrderNotFound, "client order id(%s)", clientId)
+}
+
+func (bt *Client) findOrder(id fintypes.OrderId) (*order, error) {
+	for _, od := range bt.orders {
+		if od.Id == id {
+			return od, nil
+		}
+	}
+	return nil, errors.Wrapf(fintypes.ErrOrderNotFound, "order id(%s)", id.String())
+}
+
+// bars closed before simulated time
+func (bt *Client) visible(k *fintypes.Kline) *fintypes.Kline {
+	return k.SliceBeforeEqual(bt.property.Clock.Now().Add(-k.Pair.I().ToDuration()))
+}
+
+func (bt *Client) lastBar(pm fintypes.PairM) (fintypes.Bar, error) {
+	k, ok := bt.klines[pm]
+	if !ok {
+		return fintypes.Bar{}, gerror.Errorf("kline of %s not found", pm.String())
+	}
+	bar, ok := bt.visible(k).Last()
+	if !ok {
+		return fintypes.Bar{}, gerror.Errorf("no bar of %s before %s", pm.String(), bt.property.Clock.Now().String())
+	}
+	return bar, nil
+}
+
+// match all unfinished orders with bars closed after last check, caller must hold the lock
+func (bt *Client) match() error {
+	for _, od := range bt.orders {
+		if od.Status.End() {
+			continue
+		}
+		k, ok := bt.klines[od.Pair.SetM(od.Market)]
+		if !ok {
+			continue
+		}
+		for _, bar := range bt.visible(k).SliceAfter(od.checked).Items {
+			od.checked = bar.T
+			if err := bt.matchBar(od, bar); err != nil {
+				return err
+			}
+			if od.Status.End() {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (bt *Client) matchBar(od *order, bar fintypes.Bar) error {
+	if od.Type.IsStopLimit() && !od.triggered {
+		if od.Side.IsBuy() && bar.H.GreaterThanOrEqual(od.StopPrice) {
+			od.triggered = true
+			// stop price reached in this bar, fill it if limit price allows
+			dealPrice := gdecimal.Max(od.StopPrice, bar.O)
+			if dealPrice.LessThanOrEqual(od.Price) {
+				return bt.fill(od, dealPrice, true)
+			}
+		} else if od.Side.IsSell() && bar.L.LessThanOrEqual(od.StopPrice) {
+			od.triggered = true
+			dealPrice := gdecimal.Min(od.StopPrice, bar.O)
+			if dealPrice.GreaterThanOrEqual(od.Price) {
+				return bt.fill(od, dealPrice, true)
+			}
+		}
+		return nil
+	}
+
+	if od.Side.IsBuy() && bar.L.LessThanOrEqual(od.Price) {
+		return bt.fill(od, gdecimal.Min(od.Price, bar.O), false)
+	}
+	if od.Side.IsSell() && bar.H.GreaterThanOrEqual(od.Price) {
+		return bt.fill(od, gdecimal.Max(od.Price, bar.O), false)
+	}
+	return nil
+}
+
+// fill the whole order at dealPrice and update account
+func (bt *Client) fill(od *order, dealPrice gdecimal.Decimal, taker bool) error {
+	dealUnit := od.Amount
+	dealQuote := dealUnit.Mul(dealPrice)
+	fee, err := bt.fee(od, dealUnit, dealPrice, taker)
+	if err != nil {
+		return err
+	}
+	unitAP := fintypes.NewAP(od.Market, od.Margin, od.Pair.Unit())
+	quoteAP := fintypes.NewAP(od.Market, od.Margin, od.Pair.Quote())
+	feeAP := fintypes.NewAP(od.Market, od.Margin, fee.Asset)
+
+	if od.Side.IsBuy() {
+		// market buy may cost more than locked, the difference is paid by free balance
+		spend := gdecimal.Min(dealQuote, od.locked)
+		extra := dealQuote.Sub(spend)
+		if free := bt.account.GetAmountByProperty(quoteAP).Free; free.LessThan(extra) {
+			return bt.finish(od, fintypes.OrderStatusRejected)
+		}
+		bt.account.AddLock(quoteAP, gdecimal.Zero.Sub(spend))
+		bt.account.AddFree(quoteAP, gdecimal.Zero.Sub(extra))
+		bt.account.AddFree(unitAP, dealUnit)
+		od.locked = od.locked.Sub(spend)
+	} else {
+		bt.account.AddLock(unitAP, gdecimal.Zero.Sub(dealUnit))
+		bt.account.AddFree(quoteAP, dealQuote)
+		od.locked = od.locked.Sub(dealUnit)
+	}
+	bt.account.AddFree(feeAP, gdecimal.Zero.Sub(fee.Amount))
+	od.Fee = fee.Amount
+	od.DealAmount = dealUnit
+	od.AvgPrice = dealPrice
+	return bt.finish(od, fintypes.OrderStatusFilled)
+}
+
+// set final status and unlock the rest of paying asset
+func (bt *Client) finish(od *order, status fintypes.OrderStatus) error {
+	if od.locked.IsPositive() {
+		payAsset := od.Pair.Quote()
+		if od.Side.IsSell() {
+			payAsset = od.Pair.Unit()
+		}
+		if err := bt.account.Unlock(fintypes.NewAP(od.Market, od.Margin, payAsset), od.locked); err != nil {
+			return err
+		}
+		od.locked = gdecimal.Zero
+	}
+	od.Status = status
+	return nil
+}
+
+// fee of filled order, buyer pays in unit and seller pays in quote if no fee schedule set
+func (bt *Client) fee(od *order, dealUnit, dealPrice gdecimal.Decimal, taker bool) (*fintypes.Fee, error) {
+	pm := od.Pair.SetM(od.Market)
+	if bt.feeSchedule == nil {
+		rate := bt.feeRate(pm, taker)
+		if od.Side.IsBuy() {
+			return &fintypes.Fee{Asset: od.Pair.Unit(), Amount: dealUnit.Mul(rate)}, nil
+		}
+		return &fintypes.Fee{Asset: od.Pair.Quote(), Amount: dealUnit.Mul(dealPrice).Mul(rate)}, nil
+	}
+
+	fs := *bt.feeSchedule
+	if fs.PayInDiscountAsset && fs.DiscountAsset != "" {
+		if bar, err := bt.lastBar(fintypes.NewPair(fs.DiscountAsset, od.Pair.Quote()).SetM(fintypes.MarketSpot)); err == nil && bar.C.IsPositive() {
+			fee, err := fs.Calc(pm, od.Side, dealPrice, dealUnit, taker, bar.C)
+			if err != nil {
+				return nil, err
+			}
+			if !bt.account.GetAmountByProperty(fintypes.NewAP(od.Market, od.Margin, fs.DiscountAsset)).Free.LessThan(fee.Amount) {
+				return fee, nil
+			}
+		}
+		fs.PayInDiscountAsset = false // paid in regular asset like binance does if discount asset is not enough
+	}
+	return fs.Calc(pm, od.Side, dealPrice, dealUnit, taker, gdecimal.Zero)
+}
+
+func (bt *Client) feeRate(pm fintypes.PairM, taker bool) gdecimal.Decimal {
+	if taker {
+		fee, _ := bt.marketInfo.GetTakerFee(pm)
+		return fee
+	}
+	fee, _ := bt.marketInfo.GetMakerFee(pm)
+	return fee
+}
+
+// position management is not supported, because only spot market is supported
+func (bt *Client) GetPositions(market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return bt.GetPositionsContext(context.Background(), market, target)
+}
+
+func (bt *Client) GetPositionsContext(ctx context.Context, market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) SetLeverage(market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return bt.SetLeverageContext(context.Background(), market, target, leverage)
+}
+
+func (bt *Client) SetLeverageContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) SetMarginType(market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return bt.SetMarginTypeContext(context.Background(), market, target, margin)
+}
+
+func (bt *Client) SetMarginTypeContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) ClosePosition(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return bt.ClosePositionContext(context.Background(), market, margin, target)
+}
+
+func (bt *Client) ClosePositionContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+// contract market data is not supported, because only spot market is supported
+func (bt *Client) GetFundingRates(market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return bt.GetFundingRatesContext(context.Background(), market, target, since)
+}
+
+func (bt *Client) GetFundingRatesContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) GetMarkPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return bt.GetMarkPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (bt *Client) GetMarkPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) GetIndexPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return bt.GetIndexPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (bt *Client) GetIndexPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) GetOpenInterests(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return bt.GetOpenInterestsContext(context.Background(), market, target, period, since)
+}
+
+func (bt *Client) GetOpenInterestsContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+// wallet api is not supported, because there is no real wallet
+func (bt *Client) GetDepositAddress(asset, network string) (*fintypes.DepositAddress, error) {
+	return bt.GetDepositAddressContext(context.Background(), asset, network)
+}
+
+func (bt *Client) GetDepositAddressContext(ctx context.Context, asset, network string) (*fintypes.DepositAddress, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) Withdraw(req fintypes.WithdrawRequest) (string, error) {
+	return bt.WithdrawContext(context.Background(), req)
+}
+
+func (bt *Client) WithdrawContext(ctx context.Context, req fintypes.WithdrawRequest) (string, error) {
+	return "", fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) GetDeposits(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return bt.GetDepositsContext(context.Background(), asset, since)
+}
+
+func (bt *Client) GetDepositsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (bt *Client) GetWithdrawals(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return bt.GetWithdrawalsContext(context.Background(), asset, since)
+}
+
+func (bt *Client) GetWithdrawalsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
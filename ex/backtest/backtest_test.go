@@ -0,0 +1,142 @@
+package backtest
+
+import (
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	gtime.Clock
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestClient(t *testing.T) (*Client, *testClock, time.Time) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := func(f float64) gdecimal.Decimal { return gdecimal.NewFromFloat64(f) }
+	bars := []fintypes.Bar{
+		{T: t0, O: d(100), H: d(101), L: d(99), C: d(100), V: d(10)},
+		{T: t0.Add(time.Minute), O: d(100), H: d(103), L: d(100), C: d(102), V: d(10)},
+		{T: t0.Add(2 * time.Minute), O: d(102), H: d(102), L: d(97), C: d(98), V: d(10)},
+		{T: t0.Add(3 * time.Minute), O: d(98), H: d(99), L: d(96), C: d(97), V: d(10)},
+		{T: t0.Add(4 * time.Minute), O: d(97), H: d(105), L: d(97), C: d(104), V: d(10)},
+	}
+	pair := fintypes.BTC.Against(fintypes.USDT).SetI(fintypes.Period1Min).SetM(fintypes.MarketSpot).SetP(fintypes.Binance)
+	k := fintypes.NewKline(pair, bars)
+
+	mi := &fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+	mi.Infos[fintypes.PairM("BTC/USDT.spot")] = fintypes.PairInfo{Enabled: true, MakerFee: d(0.001), TakerFee: d(0.001)}
+
+	init := fintypes.NewEmptyAccount()
+	init.SetFreeAmount(fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "USDT"), d(1000))
+
+	c := &testClock{Clock: gtime.GetSysClock(), now: t0.Add(2 * time.Minute)}
+	bt, err := New(fintypes.Binance, []*fintypes.Kline{k}, mi, init, c)
+	gtest.Assert(t, err)
+	return bt, c, t0
+}
+
+func TestClient_GetKline(t *testing.T) {
+	bt, c, t0 := newTestClient(t)
+
+	// only closed bars are visible
+	k, err := bt.GetKline(fintypes.MarketSpot, fintypes.BTC.Against(fintypes.USDT), fintypes.Period1Min, nil)
+	gtest.Assert(t, err)
+	if k.Len() != 2 {
+		gtest.PrintlnExit(t, "expect 2 bars but got %d", k.Len())
+	}
+	ticks, err := bt.GetTicks(false)
+	gtest.Assert(t, err)
+	if ticks[fintypes.PairM("BTC/USDT.spot")].Last.String() != "102" {
+		gtest.PrintlnExit(t, "tick error %s", ticks[fintypes.PairM("BTC/USDT.spot")].Last.String())
+	}
+
+	// since filter
+	c.now = t0.Add(5 * time.Minute)
+	since := t0.Add(3 * time.Minute)
+	k, err = bt.GetKline(fintypes.MarketSpot, fintypes.BTC.Against(fintypes.USDT), fintypes.Period1Min, &since)
+	gtest.Assert(t, err)
+	if k.Len() != 2 {
+		gtest.PrintlnExit(t, "expect 2 bars since %s but got %d", since.String(), k.Len())
+	}
+
+	// bigger period
+	k, err = bt.GetKline(fintypes.MarketSpot, fintypes.BTC.Against(fintypes.USDT), fintypes.Period5Min, nil)
+	gtest.Assert(t, err)
+	if k.Len() != 1 || k.Items[0].H.String() != "105" || k.Items[0].L.String() != "96" {
+		gtest.PrintlnExit(t, "5min kline error")
+	}
+}
+
+func TestClient_Trade(t *testing.T) {
+	bt, c, t0 := newTestClient(t)
+	usdt := fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "USDT")
+	btc := fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "BTC")
+	target := fintypes.BTC.Against(fintypes.USDT)
+
+	// limit buy below market waits for a bar touching the price
+	id, err := bt.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, target, fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit, gdecimal.NewFromInt(1), gdecimal.NewFromInt(98), gdecimal.Zero)
+	gtest.Assert(t, err)
+	od, err := bt.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusNew {
+		gtest.PrintlnExit(t, "limit order should not be filled, but %s", od.Status)
+	}
+
+	c.now = t0.Add(3 * time.Minute)
+	od, err = bt.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusFilled || od.AvgPrice.String() != "98" {
+		gtest.PrintlnExit(t, "limit order should be filled, but %s", od.String())
+	}
+
+	// market sell at close of the latest closed bar
+	_, err = bt.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, target, fintypes.OrderSideSellShort, fintypes.OrderTypeMarket, gdecimal.NewFromFloat64(0.5), gdecimal.Zero, gdecimal.Zero)
+	gtest.Assert(t, err)
+	acc, err := bt.GetAccount()
+	gtest.Assert(t, err)
+	if acc.GetAmountByProperty(usdt).Free.String() != "950.951" || acc.GetAmountByProperty(btc).Free.String() != "0.499" {
+		gtest.PrintlnExit(t, "balance error %s", acc.String())
+	}
+
+	// stop-limit buy triggered by bar high
+	id, err = bt.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, target, fintypes.OrderSideBuyLong, fintypes.OrderTypeStopLimit, gdecimal.NewFromFloat64(0.1), gdecimal.NewFromInt(106), gdecimal.NewFromInt(104))
+	gtest.Assert(t, err)
+	c.now = t0.Add(5 * time.Minute)
+	od, err = bt.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusFilled || od.AvgPrice.String() != "104" {
+		gtest.PrintlnExit(t, "stop-limit order should be filled, but %s", od.String())
+	}
+	acc, err = bt.GetAccount()
+	gtest.Assert(t, err)
+	if acc.GetAmountByProperty(usdt).Free.String() != "940.551" || !acc.GetAmountByProperty(usdt).Locked.IsZero() || acc.GetAmountByProperty(btc).Free.String() != "0.5989" {
+		gtest.PrintlnExit(t, "balance error %s", acc.String())
+	}
+}
+
+func TestClient_SetFeeSchedule(t *testing.T) {
+	bt, _, _ := newTestClient(t)
+	bt.SetFeeSchedule(&fintypes.FeeSchedule{
+		MarketOverrides:    map[fintypes.Market]fintypes.FeeRate{fintypes.MarketSpot: {Maker: gdecimal.Zero, Taker: gdecimal.NewFromFloat64(0.002)}},
+		DiscountAsset:      "BNB",
+		PayInDiscountAsset: true,
+	})
+
+	// no BNB price in backtest, fee is paid in BTC
+	_, err := bt.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, fintypes.BTC.Against(fintypes.USDT), fintypes.OrderSideBuyLong, fintypes.OrderTypeMarket, gdecimal.One, gdecimal.Zero, gdecimal.Zero)
+	gtest.Assert(t, err)
+	acc, err := bt.GetAccount()
+	gtest.Assert(t, err)
+	if acc.GetAmountByProperty(fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "BTC")).Free.String() != "0.998" ||
+		acc.GetAmountByProperty(fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "USDT")).Free.String() != "898" {
+		gtest.PrintlnExit(t, "balance error %s", acc.String())
+	}
+}
@@ -6,6 +6,9 @@ package binance
 
 注意，Margin和Spot是共享的盘口和K线，所以二者是一回事
 
+order types & time in force are mapped by ExProperty.OrderTypes & ExProperty.TimeInForces for spot, and perpOrderTypes for futures.
+spot post only order is LIMIT_MAKER, OCO is spot only, trailing stop is futures only.
+
 time in force : 订单的有效时间.
 它的选项有:
 Day (9:30am-4pm)
@@ -22,6 +25,7 @@ import (
 	"fmt"
 	"github.com/adshao/go-binance"
 	"github.com/adshao/go-binance/futures"
+	"github.com/foxtrader/gofin/ex/ratelimit"
 	"github.com/foxtrader/gofin/fintypes"
 	"github.com/pkg/errors"
 	"github.com/shawnwyckoff/gopkg/apputil/gerror"
@@ -29,11 +33,27 @@ import (
 	"github.com/shawnwyckoff/gopkg/container/gnum"
 	"github.com/shawnwyckoff/gopkg/net/ghttp"
 	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// order types of futures, names of stop_limit and take_profit are different from spot
+var perpOrderTypes = map[fintypes.OrderType]futures.OrderType{
+	fintypes.OrderTypeLimit:        futures.OrderTypeLimit,
+	fintypes.OrderTypeMarket:       futures.OrderTypeMarket,
+	fintypes.OrderTypeStopLimit:    futures.OrderTypeStop,
+	fintypes.OrderTypeTakeProfit:   futures.OrderTypeTakeProfit,
+	fintypes.OrderTypeTrailingStop: futures.OrderTypeTrailingStopMarket,
+}
+
+// max orders in one request of perp batch apis
+const (
+	perpBatchTradeSize  = 5
+	perpBatchCancelSize = 10
+)
+
 type Client struct {
 	in               *binance.Client
 	inPerp           *futures.Client
@@ -42,6 +62,7 @@ type Client struct {
 	property         fintypes.ExProperty
 	marketInfoCache  fintypes.MarketInfo
 	marketInfoUpdate time.Time
+	wsHosts          map[fintypes.Market]string // raw stream urls of user data and depth diff streams
 }
 
 // email is required in living trading, but not required in kline spider
@@ -73,6 +94,15 @@ func New(accessKey, secretKey, proxy string, c gtime.Clock, email string) (*Clie
 	cc.Periods[fintypes.Period1Day] = "1d"
 	cc.Periods[fintypes.Period1Week] = "1w"
 	cc.Periods[fintypes.Period1MonthFUZZY] = "1M"
+	cc.OrderTypes = map[fintypes.OrderType]string{
+		fintypes.OrderTypeLimit:      string(binance.OrderTypeLimit),
+		fintypes.OrderTypeMarket:     string(binance.OrderTypeMarket),
+		fintypes.OrderTypeStopLimit:  string(binance.OrderTypeStopLossLimit),
+		fintypes.OrderTypeTakeProfit: string(binance.OrderTypeTakeProfitLimit),
+	}
+	cc.TimeInForces = map[fintypes.TimeInForce]string{
+		fintypes.TimeInForceGTC: "GTC", fintypes.TimeInForceIOC: "IOC", fintypes.TimeInForceFOK: "FOK", fintypes.TimeInForceGTX: "GTX",
+	}
 	cc.MarketEnabled = map[fintypes.Market]bool{}
 	cc.MarketEnabled[fintypes.MarketSpot] = true
 	cc.MarketEnabled[fintypes.MarketPerp] = true
@@ -89,9 +119,18 @@ func New(accessKey, secretKey, proxy string, c gtime.Clock, email string) (*Clie
 			return nil, err
 		}
 	}
+	// clients are limited after proxy set, requests of all clients with the same access key share the same budgets
+	limiter := ratelimit.New(rateLimitRules(accessKey, cc.RateLimits)...)
+	noWait := func() bool { return ex.property.RateLimitNoWait }
+	ex.in.HTTPClient = ratelimit.Wrap(ex.in.HTTPClient, limiter, spotCost, noWait)
+	ex.inPerp.HTTPClient = ratelimit.Wrap(ex.inPerp.HTTPClient, limiter, perpCost, noWait)
 	ex.name = fintypes.Binance
 	ex.property = cc
 	ex.marketInfoUpdate = gtime.ZeroTime
+	ex.wsHosts = map[fintypes.Market]string{}
+	for market, host := range wsBaseUrls {
+		ex.wsHosts[market] = host
+	}
 	ex.property.Email = email
 	if c == nil {
 		ex.property.Clock = gtime.GetSysClock()
@@ -117,6 +156,7 @@ func (ex *Client) binanceOrderToApiOrder(accType fintypes.Market, margin fintype
 	res.Margin = margin
 	res.Time = gtime.EpochMillisToTime(src.Time)
 	res.Id = fintypes.NewOrderId(accType, margin, p, gnum.ToString(src.OrderID))
+	res.ClientId = src.ClientOrderID
 	if src.StopPrice != "" {
 		res.StopPrice, err = gdecimal.NewFromString(src.StopPrice)
 		if err != nil {
@@ -139,14 +179,9 @@ func (ex *Client) binanceOrderToApiOrder(accType fintypes.Market, margin fintype
 	} else {
 		return nil, errors.Errorf("unsupported OrderSide(%s)", src.Side)
 	}
-	if src.Type == binance.OrderTypeLimit {
-		res.Type = fintypes.OrderTypeLimit
-	} else if src.Type == binance.OrderTypeStopLossLimit {
-		res.Type = fintypes.OrderTypeStopLimit
-	} else if src.Type == binance.OrderTypeMarket {
-		res.Type = fintypes.OrderTypeMarket
-	} else {
-		return nil, errors.Errorf("unsupported OrderType(%s)", src.Type)
+	res.Type, res.TimeInForce, err = ex.binanceToType(src.Type, src.TimeInForce)
+	if err != nil {
+		return nil, err
 	}
 
 	switch src.Status {
@@ -172,6 +207,16 @@ func (ex *Client) binanceOrderToApiOrder(accType fintypes.Market, margin fintype
 	if err != nil {
 		return nil, err
 	}
+	// 没有avgPrice, 用成交额/成交量计算
+	if src.CummulativeQuoteQuantity != "" && res.DealAmount.IsPositive() {
+		quote, err := gdecimal.NewFromString(src.CummulativeQuoteQuantity)
+		if err != nil {
+			return nil, err
+		}
+		if quote.IsPositive() {
+			res.AvgPrice = quote.Div(res.DealAmount)
+		}
+	}
 	return &res, nil
 }
 
@@ -220,6 +265,7 @@ func (ex *Client) binancePerpOrderToApiOrder(market fintypes.Market, margin fint
 	res.Margin = margin
 	res.Time = gtime.EpochMillisToTime(src.Time)
 	res.Id = fintypes.NewOrderId(market, margin, pair, gnum.ToString(src.OrderID))
+	res.ClientId = src.ClientOrderID
 	if src.StopPrice != "" {
 		res.StopPrice, err = gdecimal.NewFromString(src.StopPrice)
 		if err != nil {
@@ -242,14 +288,9 @@ func (ex *Client) binancePerpOrderToApiOrder(market fintypes.Market, margin fint
 	} else {
 		return nil, errors.Errorf("unsupported OrderSide(%s)", src.Side)
 	}
-	if src.Type == futures.OrderTypeLimit {
-		res.Type = fintypes.OrderTypeLimit
-	} else if src.Type == futures.OrderTypeStop {
-		res.Type = fintypes.OrderTypeStopLimit
-	} else if src.Type == futures.OrderTypeMarket {
-		res.Type = fintypes.OrderTypeMarket
-	} else {
-		return nil, errors.Errorf("unsupported OrderType(%s)", src.Type)
+	res.Type, res.TimeInForce, err = ex.binanceContractToType(src.Type, src.TimeInForce)
+	if err != nil {
+		return nil, err
 	}
 
 	switch src.Status {
@@ -280,17 +321,53 @@ func (ex *Client) Property() *fintypes.ExProperty {
 	return &ex.property
 }
 
+// redirect rest api of market to baseUrl, like a local simulator or testnet, margin shares the base url of spot
+func (ex *Client) SetBaseUrl(market fintypes.Market, baseUrl string) error {
+	switch market {
+	case fintypes.MarketSpot:
+		ex.in.BaseURL = strings.TrimSuffix(baseUrl, "/")
+	case fintypes.MarketPerp:
+		ex.inPerp.BaseURL = strings.TrimSuffix(baseUrl, "/")
+	default:
+		return gerror.Errorf("binance doesn't support Market(%s)", market)
+	}
+	return nil
+}
+
+// redirect raw streams of market to wsBaseUrl which ends with '/ws/'
+// NOTE: kline, depth, tick and fills streams are served by go-binance with its global endpoints, they are not redirected
+func (ex *Client) SetWsBaseUrl(market fintypes.Market, wsBaseUrl string) error {
+	if _, ok := wsBaseUrls[market]; !ok {
+		return gerror.Errorf("binance doesn't support stream of Market(%s)", market)
+	}
+	if !strings.HasSuffix(wsBaseUrl, "/") {
+		wsBaseUrl += "/"
+	}
+	ex.wsHosts[market] = wsBaseUrl
+	return nil
+}
+
+// send rest requests by rt instead of proxy, like a recording or replaying transport in tests, rate limit is kept
+func (ex *Client) SetTransport(rt http.RoundTripper) {
+	ratelimit.SetBase(ex.in.HTTPClient, rt)
+	ratelimit.SetBase(ex.inPerp.HTTPClient, rt)
+}
+
 func (ex *Client) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	return ex.GetMarketInfoContext(context.Background(), ignorePairsNotFound)
+}
+
+func (ex *Client) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
 	mi := fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
 
-	exInfo, err := ex.in.NewExchangeInfoService().Do(context.Background())
+	exInfo, err := ex.in.NewExchangeInfoService().Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, parseErr(err)
 	}
 
-	exInfoPerp, err := ex.inPerp.NewExchangeInfoService().Do(context.Background())
+	exInfoPerp, err := ex.inPerp.NewExchangeInfoService().Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, parseErr(err)
 	}
 
 	// process spot market info
@@ -332,11 +409,19 @@ func (ex *Client) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo,
 				}
 			}
 			if ft, ok := filterMap["filterType"]; ok && ft == "PRICE_FILTER" {
-				spotInfo.QuoteStep, err = gdecimal.NewFromString(filterMap["minPrice"].(string))
+				spotInfo.QuoteStep, err = gdecimal.NewFromString(filterMap["tickSize"].(string)) // tickSize is price step, minPrice is not
 				if err != nil {
 					return nil, err
 				}
 			}
+			if ft, ok := filterMap["filterType"]; ok && (ft == "MIN_NOTIONAL" || ft == "NOTIONAL") {
+				if v, ok := filterMap["minNotional"].(string); ok {
+					spotInfo.MinNotional, err = gdecimal.NewFromString(v)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
 		}
 		mi.Infos[p.SetM(fintypes.MarketSpot)] = spotInfo
 	}
@@ -383,11 +468,19 @@ func (ex *Client) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo,
 				}
 			}
 			if ft, ok := filterMap["filterType"]; ok && ft == "PRICE_FILTER" {
-				perpInfo.QuoteStep, err = gdecimal.NewFromString(filterMap["minPrice"].(string))
+				perpInfo.QuoteStep, err = gdecimal.NewFromString(filterMap["tickSize"].(string)) // tickSize is price step, minPrice is not
 				if err != nil {
 					return nil, err
 				}
 			}
+			if ft, ok := filterMap["filterType"]; ok && ft == "MIN_NOTIONAL" {
+				if v, ok := filterMap["notional"].(string); ok {
+					perpInfo.MinNotional, err = gdecimal.NewFromString(v)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
 		}
 
 		// insert perp market info into result
@@ -405,18 +498,22 @@ func (ex *Client) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo,
 // 注意，币安只有全仓现货杠杆，没有逐仓现货杠杆
 // binance account API support total balance in BTC, but doesn't return total balance in fiat, you need to calculate it by yourself
 func (ex *Client) GetAccount() (*fintypes.Account, error) {
+	return ex.GetAccountContext(context.Background())
+}
 
-	spotAcc, err := ex.in.NewGetAccountService().Do(context.Background())
+func (ex *Client) GetAccountContext(ctx context.Context) (*fintypes.Account, error) {
+
+	spotAcc, err := ex.in.NewGetAccountService().Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, parseErr(err)
 	}
 
-	marginAcc, err := ex.in.NewGetMarginAccountService().Do(context.Background())
+	marginAcc, err := ex.in.NewGetMarginAccountService().Do(ctx)
 	if err != nil && !strings.Contains(err.Error(), "code=-3003,") { // margin account doesn't enabled error message: <APIError> code=-3003, msg=Margin account does not exist.
-		return nil, err
+		return nil, parseErr(err)
 	}
 
-	/*perpAcc, err := ex.inPerp.NewGetAccountService().Do(context.Background())
+	/*perpAcc, err := ex.inPerp.NewGetAccountService().Do(ctx)
 	if err != nil {
 		return nil, err
 	}*/
@@ -539,16 +636,35 @@ func (ex *Client) GetAccount() (*fintypes.Account, error) {
 }
 
 func (ex *Client) GetDepth(market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	return ex.GetDepthContext(context.Background(), market, target)
+}
+
+func (ex *Client) GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	depth, _, err := ex.getDepth(ctx, market, target, 0)
+	return depth, err
+}
+
+// deepest order books snapshot with last update id, used to seed local depth
+func (ex *Client) GetDepthSnapshot(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, int64, error) {
+	return ex.getDepth(ctx, market, target, snapshotDepthLimit)
+}
+
+// limit <= 0 means default limit of binance
+func (ex *Client) getDepth(ctx context.Context, market fintypes.Market, target fintypes.Pair, limit int) (*fintypes.Depth, int64, error) {
 	if err := target.Verify(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	depth := &binance.DepthResponse{}
 	err := error(nil)
 	if market == fintypes.MarketPerp {
-		depthPerp, err := ex.inPerp.NewDepthService().Symbol(target.CustomFormat(ex.Property())).Do(context.Background())
+		svc := ex.inPerp.NewDepthService().Symbol(target.CustomFormat(ex.Property()))
+		if limit > 0 {
+			svc.Limit(limit)
+		}
+		depthPerp, err := svc.Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, 0, parseErr(err)
 		}
 
 		// convert futures.DepthResponse to binance.DepthResponse
@@ -560,9 +676,13 @@ func (ex *Client) GetDepth(market fintypes.Market, target fintypes.Pair) (*finty
 			depth.Bids = append(depth.Bids, binance.Bid{Price: v.Price, Quantity: v.Quantity})
 		}
 	} else {
-		depth, err = ex.in.NewDepthService().Symbol(target.CustomFormat(ex.Property())).Do(context.Background())
+		svc := ex.in.NewDepthService().Symbol(target.CustomFormat(ex.Property()))
+		if limit > 0 {
+			svc.Limit(limit)
+		}
+		depth, err = svc.Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, 0, parseErr(err)
 		}
 	}
 
@@ -572,11 +692,11 @@ func (ex *Client) GetDepth(market fintypes.Market, target fintypes.Pair) (*finty
 		item := fintypes.OrderBook{}
 		item.Price, err = gdecimal.NewFromString(v.Price)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		item.Amount, err = gdecimal.NewFromString(v.Quantity)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		res.Buys = append(res.Buys, item)
 	}
@@ -584,27 +704,31 @@ func (ex *Client) GetDepth(market fintypes.Market, target fintypes.Pair) (*finty
 		item := fintypes.OrderBook{}
 		item.Price, err = gdecimal.NewFromString(v.Price)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		item.Amount, err = gdecimal.NewFromString(v.Quantity)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		res.Sells = append(res.Sells, item)
 	}
 
-	return &res, nil
+	return &res, depth.LastUpdateID, nil
 }
 
 func (ex *Client) GetTicks(ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
-	ticks, err := ex.in.NewListPricesService().Do(context.Background())
+	return ex.GetTicksContext(context.Background(), ignorePairsNotFound)
+}
+
+func (ex *Client) GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	ticks, err := ex.in.NewListPricesService().Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, parseErr(err)
 	}
 
-	ticksPerp, err := ex.inPerp.NewListPricesService().Do(context.Background())
+	ticksPerp, err := ex.inPerp.NewListPricesService().Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, parseErr(err)
 	}
 
 	res := make(map[fintypes.PairM]fintypes.Tick)
@@ -650,6 +774,10 @@ func (ex *Client) GetTicks(ignorePairsNotFound bool) (map[fintypes.PairM]fintype
 }
 
 func (ex *Client) GetKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return ex.GetKlineContext(context.Background(), market, target, period, since)
+}
+
+func (ex *Client) GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
 	if err := target.Verify(); err != nil {
 		return nil, err
 	}
@@ -674,15 +802,15 @@ func (ex *Client) GetKline(market fintypes.Market, target fintypes.Pair, period
 	// download kline
 	if market == fintypes.MarketSpot {
 		ks, err = ex.in.NewKlinesService().Symbol(target.CustomFormat(ex.Property())).
-			Interval(binancePeriod).StartTime(gtime.TimeToEpochMillis(*since)).Limit(1000 /*max limit is 1000*/).Do(context.Background())
+			Interval(binancePeriod).StartTime(gtime.TimeToEpochMillis(*since)).Limit(1000 /*max limit is 1000*/).Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 	} else if market == fintypes.MarketPerp {
 		ksPerp, err := ex.inPerp.NewKlinesService().Symbol(target.CustomFormat(ex.Property())).
-			Interval(binancePeriod).StartTime(gtime.TimeToEpochMillis(*since)).Limit(1000 /*max limit is 1000*/).Do(context.Background())
+			Interval(binancePeriod).StartTime(gtime.TimeToEpochMillis(*since)).Limit(1000 /*max limit is 1000*/).Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 
 		// convert []*futures.K to []*binance.K
@@ -747,7 +875,7 @@ func (ex *Client) GetKline(market fintypes.Market, target fintypes.Pair, period
 		2019-03-13 04:07:00 +0000 UTC 0.001 0.001 0.001 0.001 0
 		2019-03-13 04:08:00 +0000 UTC 0.001 0.001 0.001 0.001 0
 	*/
-	if since.Unix() <= ex.Property().TradeBeginTime.Unix() {
+	if since.Unix() <= ex.Property().TradeBeginTime.Unix() && len(r.Items) >= 2 {
 		if r.Items[1].T.Sub(r.Items[0].T) != period.ToDuration() {
 			r.Items = r.Items[1:]
 		}
@@ -800,7 +928,7 @@ func (ex *Client) GetFills(market fintypes.Market, target fintypes.Pair, since f
 			fills, err = ex.in.NewHistoricalTradesService().Symbol(target.CustomFormat(ex.Property())).Do(context.Background())
 		}
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 		for _, v := range fills {
 			item := fintypes.Fill{}
@@ -830,7 +958,7 @@ func (ex *Client) GetFills(market fintypes.Market, target fintypes.Pair, since f
 			fillsPerp, err = ex.inPerp.NewHistoricalTradesService().Symbol(target.CustomFormat(ex.Property())).Do(context.Background())
 		}
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 		for _, v := range fillsPerp {
 			item := fintypes.Fill{}
@@ -858,39 +986,55 @@ func (ex *Client) GetFills(market fintypes.Market, target fintypes.Pair, since f
 }
 
 func (ex *Client) GetBorrowable(margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	return ex.GetBorrowableContext(context.Background(), margin, asset)
+}
+
+func (ex *Client) GetBorrowableContext(ctx context.Context, margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
 	if margin != fintypes.MarginCross {
 		return gdecimal.N0, gerror.Errorf("unsupported margin(%s)", margin)
 	}
-	maxBorrowable, err := ex.in.NewGetMaxBorrowableService().Asset(asset).Do(context.Background())
+	maxBorrowable, err := ex.in.NewGetMaxBorrowableService().Asset(asset).Do(ctx)
 	if err != nil {
-		return gdecimal.Zero, err
+		return gdecimal.Zero, parseErr(err)
 	}
 	return gdecimal.NewFromString(maxBorrowable.Amount)
 }
 
 func (ex *Client) Borrow(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return ex.BorrowContext(context.Background(), margin, asset, amount)
+}
+
+func (ex *Client) BorrowContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
 	if margin != fintypes.MarginCross {
 		return gerror.Errorf("unsupported margin(%s)", margin)
 	}
-	_, err := ex.in.NewMarginLoanService().Asset(asset).Amount(amount.String()).Do(context.Background())
+	_, err := ex.in.NewMarginLoanService().Asset(asset).Amount(amount.String()).Do(ctx)
 	if err != nil {
-		return err
+		return parseErr(err)
 	}
 	return nil
 }
 
 func (ex *Client) Repay(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return ex.RepayContext(context.Background(), margin, asset, amount)
+}
+
+func (ex *Client) RepayContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
 	if margin != fintypes.MarginCross {
 		return gerror.Errorf("unsupported margin(%s)", margin)
 	}
-	_, err := ex.in.NewMarginRepayService().Asset(asset).Amount(amount.String()).Do(context.Background())
+	_, err := ex.in.NewMarginRepayService().Asset(asset).Amount(amount.String()).Do(ctx)
 	if err != nil {
-		return err
+		return parseErr(err)
 	}
 	return nil
 }
 
 func (ex *Client) Transfer(asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	return ex.TransferContext(context.Background(), asset, amount, from, to)
+}
+
+func (ex *Client) TransferContext(ctx context.Context, asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
 	saFrom, err := from.Parse()
 	if err != nil {
 		return err
@@ -914,8 +1058,8 @@ func (ex *Client) Transfer(asset string, amount gdecimal.Decimal, from, to finty
 		} else {
 			mts = mts.Type(binance.MarginTransferTypeToMargin)
 		}
-		_, err := mts.Do(context.Background())
-		return err
+		_, err := mts.Do(ctx)
+		return parseErr(err)
 	} else if (fromMarket == fintypes.MarketSpot && toMarket == fintypes.MarketFuture) || (fromMarket == fintypes.MarketFuture && toMarket == fintypes.MarketSpot) {
 		mts := ex.in.NewFuturesTransferService().Asset(asset).Amount(amount.String())
 		if toMarket == fintypes.MarketSpot {
@@ -923,14 +1067,14 @@ func (ex *Client) Transfer(asset string, amount gdecimal.Decimal, from, to finty
 		} else {
 			mts = mts.Type(binance.FuturesTransferTypeToFutures)
 		}
-		_, err := mts.Do(context.Background())
-		return err
+		_, err := mts.Do(ctx)
+		return parseErr(err)
 	} else {
 		return gerror.Errorf("unsupported transfer %s -> %s", fromMarket, toMarket)
 	}
 }
 
-func (ex *Client) typeSideToBinance(side fintypes.OrderSide, orderType fintypes.OrderType) (binance.SideType, binance.OrderType, error) {
+func (ex *Client) typeSideToBinance(side fintypes.OrderSide, orderType fintypes.OrderType, tif fintypes.TimeInForce) (binance.SideType, binance.OrderType, error) {
 	resSide := binance.SideTypeBuy
 	resType := binance.OrderTypeLimit
 	if side == fintypes.OrderSideBuyLong {
@@ -940,15 +1084,14 @@ func (ex *Client) typeSideToBinance(side fintypes.OrderSide, orderType fintypes.
 	} else {
 		return resSide, resType, errors.Errorf("unsupported OrderSide(%s)", side)
 	}
-	if orderType == fintypes.OrderTypeLimit {
-		resType = binance.OrderTypeLimit
-	} else if orderType == fintypes.OrderTypeMarket {
-		resType = binance.OrderTypeMarket
-	} else if orderType == fintypes.OrderTypeStopLimit {
-		resType = binance.OrderTypeStopLossLimit
-	} else {
+	if _, ok := ex.property.OrderTypes[orderType]; !ok {
 		return resSide, resType, errors.Errorf("unsupported OrderType(%s)", orderType)
 	}
+	resType = binance.OrderType(orderType.CustomFormat(ex.Property()))
+	// spot has no GTX, post only order is LIMIT_MAKER
+	if orderType.IsLimit() && tif.IsPostOnly() {
+		resType = binance.OrderTypeLimitMaker
+	}
 
 	return resSide, resType, nil
 }
@@ -963,67 +1106,126 @@ func (ex *Client) typeSideToBinanceContract(side fintypes.OrderSide, orderType f
 	} else {
 		return resSide, resType, errors.Errorf("unsupported OrderSide(%s)", side)
 	}
-	if orderType == fintypes.OrderTypeLimit {
-		resType = futures.OrderTypeLimit
-	} else if orderType == fintypes.OrderTypeMarket {
-		resType = futures.OrderTypeMarket
-	} else {
+	resType, ok := perpOrderTypes[orderType]
+	if !ok {
 		return resSide, resType, errors.Errorf("unsupported OrderType(%s)", orderType)
 	}
 
 	return resSide, resType, nil
 }
 
-func (ex *Client) Trade(market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
-	if err := target.Verify(); err != nil {
-		return nil, err
+// parse spot order type and time in force, LIMIT_MAKER is post only limit order
+func (ex *Client) binanceToType(orderType binance.OrderType, tif binance.TimeInForceType) (fintypes.OrderType, fintypes.TimeInForce, error) {
+	resTif := fintypes.TimeInForceError
+	for k, v := range ex.property.TimeInForces {
+		if v == string(tif) {
+			resTif = k
+		}
+	}
+	if orderType == binance.OrderTypeLimitMaker {
+		return fintypes.OrderTypeLimit, fintypes.TimeInForceGTX, nil
+	}
+	for k, v := range ex.property.OrderTypes {
+		if v == string(orderType) {
+			return k, resTif, nil
+		}
 	}
+	return fintypes.OrderTypeError, resTif, errors.Errorf("unsupported OrderType(%s)", orderType)
+}
 
-	// get market info if necessary
-	if ex.marketInfoCache.Infos == nil || ex.property.Clock.Now().Sub(ex.marketInfoUpdate) > gtime.Day {
-		_, err := ex.GetMarketInfo(true) // it will get and cache market info
-		if err != nil {
-			return nil, err
+// parse futures order type and time in force
+func (ex *Client) binanceContractToType(orderType futures.OrderType, tif futures.TimeInForceType) (fintypes.OrderType, fintypes.TimeInForce, error) {
+	resTif := fintypes.TimeInForceError
+	for k, v := range ex.property.TimeInForces {
+		if v == string(tif) {
+			resTif = k
 		}
 	}
-	/*pairMi, ok := ex.marketInfoCache.Infos[target.SetM(market)]
-	if !ok {
-		return nil, errors.Errorf("market info required for pair(%s)", target.String())
-	}*/
+	for k, v := range perpOrderTypes {
+		if v == orderType {
+			return k, resTif, nil
+		}
+	}
+	return fintypes.OrderTypeError, resTif, errors.Errorf("unsupported OrderType(%s)", orderType)
+}
+
+func (ex *Client) Trade(market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return ex.TradeContext(context.Background(), market, margin, leverage, target, side, orderType, amount, price, stopPrice)
+}
+
+func (ex *Client) TradeContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return ex.TradeExContext(ctx, fintypes.TradeRequest{Market: market, Margin: margin, Leverage: leverage, Pair: target, Side: side, Type: orderType, Amount: amount, Price: price, StopPrice: stopPrice})
+}
+
+func (ex *Client) TradeEx(req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	return ex.TradeExContext(context.Background(), req)
+}
+
+// spot supports limit, market, stop_limit, take_profit and oco(no margin), perp supports limit, market, stop_limit, take_profit and trailing_stop
+func (ex *Client) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	market, margin, target := req.Market, req.Margin, req.Pair
+	amount, price, stopPrice, err := ex.normalizeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	tif := req.TimeInForce
+	if tif == fintypes.TimeInForceError {
+		tif = fintypes.TimeInForceGTC
+	}
+	symbol := target.CustomFormat(ex.Property())
 
 	// process spot and margin trade request
 	if market == fintypes.MarketSpot {
+		if req.Type.IsOCO() {
+			return ex.tradeOCO(ctx, req, amount, price, stopPrice)
+		}
+
 		// parse side and type
-		bncSide, bncOt, err := ex.typeSideToBinance(side, orderType)
+		bncSide, bncOt, err := ex.typeSideToBinance(req.Side, req.Type, tif)
 		if err != nil {
 			return nil, err
 		}
+		// LIMIT_MAKER doesn't accept timeInForce
+		bncTif := binance.TimeInForceType(tif.CustomFormat(ex.Property()))
+		withTif := req.Type.HasPrice() && bncOt != binance.OrderTypeLimitMaker
 
 		od := &binance.CreateOrderResponse{}
 		if margin == fintypes.MarginNo {
-			cos := ex.in.NewCreateOrderService().Symbol(target.CustomFormat(ex.Property())).Side(bncSide).Type(bncOt).TimeInForce(binance.TimeInForceTypeGTC).Quantity(amount. /*.Trunc2(pairMi.UnitMin, pairMi.UnitStep.Float64())*/ String())
-			if orderType.IsLimit() {
-				cos = cos.Price(price. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String())
+			cos := ex.in.NewCreateOrderService().Symbol(symbol).Side(bncSide).Type(bncOt).Quantity(amount.String())
+			if withTif {
+				cos = cos.TimeInForce(bncTif)
 			}
-			if orderType.IsStopLimit() {
-				cos = cos.Price(price. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String()).StopPrice(stopPrice. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String())
+			if req.Type.HasPrice() {
+				cos = cos.Price(price.String())
 			}
-			od, err = cos.Do(context.Background())
+			if req.Type.HasStopPrice() {
+				cos = cos.StopPrice(stopPrice.String())
+			}
+			if req.ClientId != "" {
+				cos = cos.NewClientOrderID(req.ClientId)
+			}
+			od, err = cos.Do(ctx)
 		} else if margin == fintypes.MarginCross {
-			cos := ex.in.NewCreateMarginOrderService().Symbol(target.CustomFormat(ex.Property())).Side(bncSide).Type(bncOt).TimeInForce(binance.TimeInForceTypeGTC).Quantity(amount. /*.Trunc2(pairMi.UnitMin, pairMi.UnitStep.Float64())*/ String())
-			if orderType.IsLimit() {
-				cos = cos.Price(price. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String())
+			cos := ex.in.NewCreateMarginOrderService().Symbol(symbol).Side(bncSide).Type(bncOt).Quantity(amount.String())
+			if withTif {
+				cos = cos.TimeInForce(bncTif)
+			}
+			if req.Type.HasPrice() {
+				cos = cos.Price(price.String())
+			}
+			if req.Type.HasStopPrice() {
+				cos = cos.StopPrice(stopPrice.String())
 			}
-			if orderType.IsStopLimit() {
-				cos = cos.Price(price. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String()).StopPrice(stopPrice. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String())
+			if req.ClientId != "" {
+				cos = cos.NewClientOrderID(req.ClientId)
 			}
-			od, err = cos.Do(context.Background())
+			od, err = cos.Do(ctx)
 		} else {
 			return nil, gerror.Errorf("binance doesn't support Market(%s) & Margin(%s)", market, margin)
 		}
 
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 		res := fintypes.NewOrderId(market, margin, target, gnum.ToString(od.OrderID))
 		return &res, nil
@@ -1031,51 +1233,156 @@ func (ex *Client) Trade(market fintypes.Market, margin fintypes.Margin, leverage
 
 	// process perp trade request
 	if market == fintypes.MarketPerp {
-		// parse side and type
-		bncSide, bncOt, err := ex.typeSideToBinanceContract(side, orderType)
-		if err != nil {
+		if err := ex.preparePerp(ctx, symbol, margin, req.Leverage); err != nil {
 			return nil, err
 		}
 
-		// 修改仓位模式
-		marginType := futures.MarginTypeIsolated
-		if margin == fintypes.MarginIsolated {
-			marginType = futures.MarginTypeIsolated
-		} else if margin == fintypes.MarginCross {
-			marginType = futures.MarginTypeCrossed
-		} else {
-			return nil, gerror.Errorf("Margin(%s) not supported in SetPosition", margin)
-		}
-		if err := ex.inPerp.NewChangeMarginTypeService().Symbol(target.CustomFormat(ex.Property())).MarginType(marginType).Do(context.Background()); err != nil {
+		// 下单
+		cos, err := ex.perpOrderService(req, amount, price, stopPrice)
+		if err != nil {
 			return nil, err
 		}
+		od, err := cos.Do(ctx)
+		if err != nil {
+			return nil, parseErr(err)
+		}
+		res := fintypes.NewOrderId(market, margin, target, gnum.ToString(od.OrderID))
+		return &res, nil
+	}
+
+	return nil, gerror.Errorf("invalid Market(%s) in SetPosition", market)
+}
 
-		// 修改杠杆倍数
-		_, err = ex.inPerp.NewChangeLeverageService().Symbol(target.CustomFormat(ex.Property())).Leverage(leverage).Do(context.Background())
+// verify request, round amount and prices by filters LOT_SIZE and PRICE_FILTER, check min amount and MIN_NOTIONAL before sending
+func (ex *Client) normalizeRequest(ctx context.Context, req fintypes.TradeRequest) (amount, price, stopPrice gdecimal.Decimal, err error) {
+	if err := req.Verify(); err != nil {
+		return amount, price, stopPrice, err
+	}
+
+	// get market info if necessary
+	if ex.marketInfoCache.Infos == nil || ex.property.Clock.Now().Sub(ex.marketInfoUpdate) > gtime.Day {
+		_, err := ex.GetMarketInfoContext(ctx, true) // it will get and cache market info
 		if err != nil {
-			return nil, err
+			return amount, price, stopPrice, err
 		}
+	}
+	return ex.marketInfoCache.NormalizeOrder(req.Pair.SetM(req.Market), req.Type, req.Amount, req.Price, req.StopPrice)
+}
 
-		// 下单
-		cos := ex.inPerp.NewCreateOrderService().Symbol(target.CustomFormat(ex.Property())).Side(bncSide).Type(bncOt).TimeInForce(futures.TimeInForceTypeGTC).Quantity(amount. /*.Trunc2(pairMi.UnitMin, pairMi.UnitStep.Float64())*/ String())
-		if orderType.IsLimit() {
-			cos = cos.Price(price. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String())
+// set margin type and leverage of perp symbol before placing orders
+func (ex *Client) preparePerp(ctx context.Context, symbol string, margin fintypes.Margin, leverage int) error {
+	if err := ex.setMarginType(ctx, symbol, margin); err != nil {
+		return err
+	}
+	return ex.setLeverage(ctx, symbol, leverage)
+}
+
+// 修改仓位模式
+func (ex *Client) setMarginType(ctx context.Context, symbol string, margin fintypes.Margin) error {
+	marginType := futures.MarginTypeIsolated
+	if margin == fintypes.MarginIsolated {
+		marginType = futures.MarginTypeIsolated
+	} else if margin == fintypes.MarginCross {
+		marginType = futures.MarginTypeCrossed
+	} else {
+		return gerror.Errorf("Margin(%s) not supported in SetPosition", margin)
+	}
+	if err := ex.inPerp.NewChangeMarginTypeService().Symbol(symbol).MarginType(marginType).Do(ctx); err != nil {
+		// binance rejects it with -4046 if margin type is not changed
+		var exErr *fintypes.ExError
+		if err = parseErr(err); errors.As(err, &exErr) && exErr.Code == "-4046" {
+			return nil
 		}
-		if orderType.IsStopLimit() {
-			cos = cos.Price(price. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String()).StopPrice(stopPrice. /*.Trunc2(pairMi.QuoteStep, pairMi.QuoteStep.Float64())*/ String())
+		return err
+	}
+	return nil
+}
+
+// 修改杠杆倍数
+func (ex *Client) setLeverage(ctx context.Context, symbol string, leverage int) error {
+	if leverage <= 0 {
+		return gerror.Errorf("invalid leverage %d", leverage)
+	}
+	if _, err := ex.inPerp.NewChangeLeverageService().Symbol(symbol).Leverage(leverage).Do(ctx); err != nil {
+		return parseErr(err)
+	}
+	return nil
+}
+
+// create order service of normalized perp order, it is the same in single and batch placing
+func (ex *Client) perpOrderService(req fintypes.TradeRequest, amount, price, stopPrice gdecimal.Decimal) (*futures.CreateOrderService, error) {
+	// parse side and type
+	bncSide, bncOt, err := ex.typeSideToBinanceContract(req.Side, req.Type)
+	if err != nil {
+		return nil, err
+	}
+	tif := req.TimeInForce
+	if tif == fintypes.TimeInForceError {
+		tif = fintypes.TimeInForceGTC
+	}
+
+	cos := ex.inPerp.NewCreateOrderService().Symbol(req.Pair.CustomFormat(ex.Property())).Side(bncSide).Type(bncOt).Quantity(amount.String())
+	if req.Type.HasPrice() {
+		cos = cos.TimeInForce(futures.TimeInForceType(tif.CustomFormat(ex.Property()))).Price(price.String())
+	}
+	if req.Type.HasStopPrice() {
+		cos = cos.StopPrice(stopPrice.String())
+	}
+	if req.Type.IsTrailingStop() {
+		cos = cos.CallbackRate(req.CallbackRate.String())
+		if stopPrice.IsPositive() {
+			cos = cos.ActivationPrice(stopPrice.String())
 		}
-		od, err := cos.Do(context.Background())
+	}
+	if req.ClientId != "" {
+		cos = cos.NewClientOrderID(req.ClientId)
+	}
+	if req.ReduceOnly {
+		cos = cos.ReduceOnly(true)
+	}
+	return cos, nil
+}
+
+// OCO of spot market without margin, returned OrderId is the limit maker leg, canceling either leg cancels the whole order list
+func (ex *Client) tradeOCO(ctx context.Context, req fintypes.TradeRequest, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	if req.Margin != fintypes.MarginNo {
+		return nil, gerror.Errorf("binance OCO order doesn't support Margin(%s)", req.Margin)
+	}
+	bncSide, _, err := ex.typeSideToBinance(req.Side, fintypes.OrderTypeLimit, fintypes.TimeInForceGTC)
+	if err != nil {
+		return nil, err
+	}
+	symbol := req.Pair.CustomFormat(ex.Property())
+	svc := ex.in.NewCreateOCOService().Symbol(symbol).Side(bncSide).Quantity(amount.String()).Price(price.String()).StopPrice(stopPrice.String())
+	if req.StopLimitPrice.IsPositive() {
+		_, stopLimitPrice, _, err := ex.marketInfoCache.NormalizeOrder(req.Pair.SetM(req.Market), fintypes.OrderTypeLimit, amount, req.StopLimitPrice, gdecimal.Zero)
 		if err != nil {
 			return nil, err
 		}
-		res := fintypes.NewOrderId(market, margin, target, gnum.ToString(od.OrderID))
-		return &res, nil
+		svc = svc.StopLimitPrice(stopLimitPrice.String()).StopLimitTimeInForce(binance.TimeInForceTypeGTC)
 	}
-
-	return nil, gerror.Errorf("invalid Market(%s) in SetPosition", market)
+	// client id is set to limit maker leg, whose order id is returned
+	if req.ClientId != "" {
+		svc = svc.LimitClientOrderID(req.ClientId)
+	}
+	resp, err := svc.Do(ctx)
+	if err != nil {
+		return nil, parseErr(err)
+	}
+	for _, v := range resp.OrderReports {
+		if v.Type == binance.OrderTypeLimitMaker {
+			res := fintypes.NewOrderId(req.Market, req.Margin, req.Pair, gnum.ToString(v.OrderID))
+			return &res, nil
+		}
+	}
+	return nil, gerror.Errorf("limit maker order not found in OCO response of %s", symbol)
 }
 
 func (ex *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	return ex.GetAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (ex *Client) GetAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
 	if err := target.Verify(); err != nil {
 		return nil, err
 	}
@@ -1083,9 +1390,9 @@ func (ex *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, t
 	var r []fintypes.Order
 
 	if market == fintypes.MarketSpot && margin == fintypes.MarginNo {
-		spotOpenOrders, err := ex.in.NewListOrdersService().Symbol(target.CustomFormat(ex.Property())).Do(context.Background())
+		spotOpenOrders, err := ex.in.NewListOrdersService().Symbol(target.CustomFormat(ex.Property())).Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 		for _, o := range spotOpenOrders {
 			item, err := ex.binanceOrderToApiOrder(fintypes.MarketSpot, margin, o)
@@ -1095,9 +1402,9 @@ func (ex *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, t
 			r = append(r, *item)
 		}
 	} else if market == fintypes.MarketSpot && margin != fintypes.MarginNo {
-		marginOpenOrders, err := ex.in.NewListMarginOrdersService().Symbol(target.CustomFormat(ex.Property())).Do(context.Background())
+		marginOpenOrders, err := ex.in.NewListMarginOrdersService().Symbol(target.CustomFormat(ex.Property())).Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 		for _, o := range marginOpenOrders {
 			item, err := ex.binanceOrderToApiOrder(fintypes.MarketSpot, margin, ex.binanceMarginAllOrderToOrder(o))
@@ -1107,9 +1414,9 @@ func (ex *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, t
 			r = append(r, *item)
 		}
 	} else if market == fintypes.MarketPerp {
-		perpOpenOrders, err := ex.inPerp.NewListOrdersService().Symbol(target.CustomFormat(ex.Property())).Do(context.Background())
+		perpOpenOrders, err := ex.inPerp.NewListOrdersService().Symbol(target.CustomFormat(ex.Property())).Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 		for _, o := range perpOpenOrders {
 			item, err := ex.binancePerpOrderToApiOrder(fintypes.MarketPerp, margin, o)
@@ -1126,6 +1433,10 @@ func (ex *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, t
 }
 
 func (ex *Client) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	return ex.GetOpenOrdersContext(context.Background(), market, margin, target)
+}
+
+func (ex *Client) GetOpenOrdersContext(ctx context.Context, market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
 	if target != nil {
 		if err := target.Verify(); err != nil {
 			return nil, err
@@ -1141,9 +1452,9 @@ func (ex *Client) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin
 			if target != nil {
 				svc = svc.Symbol(target.CustomFormat(ex.Property()))
 			}
-			spotOpenOrders, err := svc.Do(context.Background())
+			spotOpenOrders, err := svc.Do(ctx)
 			if err != nil {
-				return nil, err
+				return nil, parseErr(err)
 			}
 			for _, o := range spotOpenOrders {
 				item, err := ex.binanceOrderToApiOrder(fintypes.MarketSpot, fintypes.MarginNo, o) // 无杠杆现货，可以固化用MarginNo
@@ -1162,9 +1473,9 @@ func (ex *Client) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin
 			if target != nil {
 				svc = svc.Symbol(target.CustomFormat(ex.Property()))
 			}
-			marginOpenOrders, err := svc.Do(context.Background())
+			marginOpenOrders, err := svc.Do(ctx)
 			if err != nil {
-				return nil, err
+				return nil, parseErr(err)
 			}
 			for _, o := range marginOpenOrders {
 				item, err := ex.binanceOrderToApiOrder(fintypes.MarketSpot, fintypes.MarginCross, o) // FIXME binance现货杠杆现在全部是全仓，所以用MarginCross
@@ -1176,25 +1487,29 @@ func (ex *Client) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin
 		}
 	}
 
-	// FIXME 目前的账户获取永续挂单会报错<APIError> code=-2015, msg=Invalid API-key, IP, or permissions for action, request ip: **。**。**。**
 	// 永续合约
-	/*if market == nil || *market == fintypes.MarketPerp {
+	// NOTE: api key without futures permission is rejected with -2015 (ErrInvalidApiKey)
+	if market == nil || *market == fintypes.MarketPerp {
+		perpMargin := fintypes.MarginIsolated // FIXME binance永续订单不返回仓位模式，默认逐仓
+		if margin != nil && *margin != fintypes.MarginNo {
+			perpMargin = *margin
+		}
 		svc := ex.inPerp.NewListOpenOrdersService()
 		if target != nil {
 			svc = svc.Symbol(target.CustomFormat(ex.Property()))
 		}
-		perpOpenOrders, err := svc.Do(context.Background())
+		perpOpenOrders, err := svc.Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 		for _, o := range perpOpenOrders {
-			item, err := ex.binancePerpOrderToApiOrder(fintypes.MarketPerp, fintypes.MarginIsolated, o) // FIXME binance永续现在全部是逐仓，所以用MarginIsolated，这里很可能不对！
+			item, err := ex.binancePerpOrderToApiOrder(fintypes.MarketPerp, perpMargin, o)
 			if err != nil {
 				return nil, err
 			}
 			r = append(r, *item)
 		}
-	}*/
+	}
 	return r, nil
 }
 
@@ -1249,6 +1564,10 @@ func (ex *Client) GetOpenOrders(market fintypes.Market, margin fintypes.Margin,
 }*/
 
 func (ex *Client) GetOrder(id fintypes.OrderId) (*fintypes.Order, error) {
+	return ex.GetOrderContext(context.Background(), id)
+}
+
+func (ex *Client) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
 	if err := id.Verify(); err != nil {
 		return nil, err
 	}
@@ -1264,32 +1583,75 @@ func (ex *Client) GetOrder(id fintypes.OrderId) (*fintypes.Order, error) {
 	if margin == fintypes.MarginError {
 		return nil, gerror.Errorf("OrderId(%s) in CancelOrder required margin member", id.String())
 	}
+	return ex.getOrder(ctx, market, margin, id.Pair(), int64Id, "")
+}
+
+func (ex *Client) GetOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	return ex.GetOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (ex *Client) GetOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if err := fintypes.VerifyClientId(clientId); err != nil {
+		return nil, err
+	}
+	return ex.getOrder(ctx, market, margin, target, 0, clientId)
+}
+
+// get order by order id, or by client order id if it is not empty
+func (ex *Client) getOrder(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, orderId int64, clientId string) (*fintypes.Order, error) {
+	symbol := target.CustomFormat(ex.Property())
 
 	// perp market
 	if market == fintypes.MarketPerp {
-		odPerp, err := ex.inPerp.NewGetOrderService().Symbol(id.Pair().CustomFormat(ex.Property())).OrderID(int64Id).Do(context.Background())
+		svc := ex.inPerp.NewGetOrderService().Symbol(symbol)
+		if clientId != "" {
+			svc = svc.OrigClientOrderID(clientId)
+		} else {
+			svc = svc.OrderID(orderId)
+		}
+		odPerp, err := svc.Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, parseErr(err)
 		}
 		return ex.binancePerpOrderToApiOrder(market, margin, odPerp)
 	}
 
 	// spot market
+	var err error
 	od := &binance.Order{}
 	if market == fintypes.MarketSpot && margin == fintypes.MarginNo {
-		od, err = ex.in.NewGetOrderService().Symbol(id.Pair().CustomFormat(ex.Property())).OrderID(int64Id).Do(context.Background())
+		svc := ex.in.NewGetOrderService().Symbol(symbol)
+		if clientId != "" {
+			svc = svc.OrigClientOrderID(clientId)
+		} else {
+			svc = svc.OrderID(orderId)
+		}
+		od, err = svc.Do(ctx)
 	} else if market == fintypes.MarketSpot && margin != fintypes.MarginNo {
-		od, err = ex.in.NewGetMarginOrderService().Symbol(id.Pair().CustomFormat(ex.Property())).OrderID(int64Id).Do(context.Background())
+		svc := ex.in.NewGetMarginOrderService().Symbol(symbol)
+		if clientId != "" {
+			svc = svc.OrigClientOrderID(clientId)
+		} else {
+			svc = svc.OrderID(orderId)
+		}
+		od, err = svc.Do(ctx)
 	} else {
 		err = gerror.Errorf("unsupported Market(%s)", market)
 	}
 	if err != nil {
-		return nil, err
+		return nil, parseErr(err)
 	}
 	return ex.binanceOrderToApiOrder(market, margin, od)
 }
 
 func (ex *Client) CancelOrder(id fintypes.OrderId) error {
+	return ex.CancelOrderContext(context.Background(), id)
+}
+
+func (ex *Client) CancelOrderContext(ctx context.Context, id fintypes.OrderId) error {
 	// check input param
 	if err := id.Verify(); err != nil {
 		return err
@@ -1306,296 +1668,556 @@ func (ex *Client) CancelOrder(id fintypes.OrderId) error {
 	if err != nil {
 		return err
 	}
+	return ex.cancelOrder(ctx, market, margin, id.Pair(), int64Id, "")
+}
+
+func (ex *Client) CancelOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	return ex.CancelOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (ex *Client) CancelOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	if err := target.Verify(); err != nil {
+		return err
+	}
+	if err := fintypes.VerifyClientId(clientId); err != nil {
+		return err
+	}
+	return ex.cancelOrder(ctx, market, margin, target, 0, clientId)
+}
+
+// cancel order by order id, or by client order id if it is not empty
+func (ex *Client) cancelOrder(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, orderId int64, clientId string) error {
+	symbol := target.CustomFormat(ex.Property())
 
+	var err error
 	if market == fintypes.MarketSpot && margin == fintypes.MarginNo {
-		_, err = ex.in.NewCancelOrderService().Symbol(id.Pair().CustomFormat(ex.Property())).OrderID(int64Id).Do(context.Background())
+		svc := ex.in.NewCancelOrderService().Symbol(symbol)
+		if clientId != "" {
+			svc = svc.OrigClientOrderID(clientId)
+		} else {
+			svc = svc.OrderID(orderId)
+		}
+		_, err = svc.Do(ctx)
 	} else if market == fintypes.MarketSpot && margin != fintypes.MarginNo {
-		_, err = ex.in.NewCancelMarginOrderService().Symbol(id.Pair().CustomFormat(ex.Property())).OrderID(int64Id).Do(context.Background())
+		svc := ex.in.NewCancelMarginOrderService().Symbol(symbol)
+		if clientId != "" {
+			svc = svc.OrigClientOrderID(clientId)
+		} else {
+			svc = svc.OrderID(orderId)
+		}
+		_, err = svc.Do(ctx)
 	} else if market == fintypes.MarketPerp {
-		_, err = ex.inPerp.NewCancelOrderService().Symbol(id.Pair().CustomFormat(ex.Property())).OrderID(int64Id).Do(context.Background())
+		svc := ex.inPerp.NewCancelOrderService().Symbol(symbol)
+		if clientId != "" {
+			svc = svc.OrigClientOrderID(clientId)
+		} else {
+			svc = svc.OrderID(orderId)
+		}
+		_, err = svc.Do(ctx)
 	} else {
 		err = gerror.Errorf("unsupported Market/Margin(%s,%s)", market, margin)
 	}
 	if err != nil {
-		return err
+		return parseErr(err)
 	}
 	return nil
 }
 
-/*
-type MarketStat struct {
-	OpenTime    time.Time `json:"OpenTime"`
-	CloseTime   time.Time `json:"CloseTime"`
-	O        decimals.Decimal   `json:"O"`
-	H        decimals.Decimal   `json:"H"`
-	L         decimals.Decimal   `json:"L"`
-	Last        decimals.Decimal   `json:"Last"`
-	UnitVolume  decimals.Decimal   `json:"UnitVolume"`
-	QuoteVolume decimals.Decimal   `json:"QuoteVolume"`
-	BidPrice    decimals.Decimal   `json:"b"`
-	BidQty      decimals.Decimal   `json:"B"`
-	AskPrice    decimals.Decimal   `json:"a"`
-	AskQty      decimals.Decimal   `json:"A"`
-}
-
-type MarketStats struct {
-	T  time.Time
-	items map[PairExt]MarketStat
-}
-
-func newMarketStats() *MarketStats {
-	r := MarketStats{
-		items: make(map[PairExt]MarketStat),
-	}
-	return &r
-}
-
-func (ex *Client) SubMarketStat(interval time.Duration) (retC chan *MarketStats, doneC, stopC chan struct{}, errC chan error, err error) {
-	retC = make(chan *MarketStats, 1024)
-	errC = make(chan error, 1024)
-	lastSnapTime := clock.ZeroTime
-
-	// 最近的24小时的"K线"，但是由于起止时间是滑动的，所以只能用作获取实时Tick
-	doneC, stopC, err = binance.WsAllMarketsStatServe(
-		func(event binance.WsAllMarketsStatEvent) {
-			if ex.property.Clock.Now().Sub(lastSnapTime) < interval {
-				return
+// perp orders are placed by batch api, 5 orders per request, spot and margin orders are placed one by one because binance has no batch api for them.
+// margin type and leverage are set per symbol before placing, so perp orders of the same symbol should have the same margin and leverage
+func (ex *Client) TradeBatch(reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	return ex.TradeBatchContext(context.Background(), reqs)
+}
+
+func (ex *Client) TradeBatchContext(ctx context.Context, reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(reqs))
+
+	var perpIdx []int
+	var perpSvcs []*futures.CreateOrderService
+	prepared := map[string]error{} // result of preparePerp by symbol, margin and leverage
+	for i, req := range reqs {
+		if req.Market != fintypes.MarketPerp {
+			id, err := ex.TradeExContext(ctx, req)
+			if err != nil {
+				res[i].Err = err
+				continue
 			}
+			res[i].Id = *id
+			continue
+		}
 
-			ms := newMarketStats()
-			for _, v := range event {
-				pair, err := ParsePairCustom(v.Symbol, ex.Property())
-				if err != nil {
-					errC <- err
-					continue
-				}
-				open, err := decimals.NewFromString(v.OpenPrice)
-				if err != nil {
-					errC <- err
-					continue
-				}
-				high, err := decimals.NewFromString(v.HighPrice)
-				if err != nil {
-					errC <- err
-					continue
-				}
-				low, err := decimals.NewFromString(v.LowPrice)
-				if err != nil {
-					errC <- err
-					continue
-				}
-				last, err := decimals.NewFromString(v.LastPrice)
-				if err != nil {
-					errC <- err
-					continue
-				}
-				/*unitAvgPrice, err := strconv.ParseFloat(v.WeightedAvgPrice, 64)
-				if err != nil {
-					errC <- err
-					continue
-				}*/ /*
-				unitVolume, err := decimals.NewFromString(v.BaseVolume)
-				if err != nil {
-					errC <- err
-					continue
-				}
-				quoteVolume, err := decimals.NewFromString(v.QuoteVolume)
-				if err != nil {
-					errC <- err
-					continue
-				}
-				bidPrice, err := decimals.NewFromString(v.BidPrice)
-				if err != nil {
-					errC <- err
-					continue
-				}
-				bidQty, err := decimals.NewFromString(v.BidQty)
+		amount, price, stopPrice, err := ex.normalizeRequest(ctx, req)
+		if err != nil {
+			res[i].Err = err
+			continue
+		}
+		symbol := req.Pair.CustomFormat(ex.Property())
+		key := fmt.Sprintf("%s-%s-%d", symbol, req.Margin, req.Leverage)
+		if _, ok := prepared[key]; !ok {
+			prepared[key] = ex.preparePerp(ctx, symbol, req.Margin, req.Leverage)
+		}
+		if prepared[key] != nil {
+			res[i].Err = prepared[key]
+			continue
+		}
+		cos, err := ex.perpOrderService(req, amount, price, stopPrice)
+		if err != nil {
+			res[i].Err = err
+			continue
+		}
+		perpIdx = append(perpIdx, i)
+		perpSvcs = append(perpSvcs, cos)
+	}
+
+	for start := 0; start < len(perpIdx); start += perpBatchTradeSize {
+		end := start + perpBatchTradeSize
+		if end > len(perpIdx) {
+			end = len(perpIdx)
+		}
+		resp, err := ex.inPerp.NewCreateBatchOrdersService().OrderList(perpSvcs[start:end]).Do(ctx)
+		if err != nil {
+			for _, i := range perpIdx[start:end] {
+				res[i].Err = parseErr(err)
+			}
+			continue
+		}
+		// errors are in the same order as request, orders are aligned with them too (nil for failed ones),
+		// but old sdk versions drop failed ones from orders, then successful ones are taken one by one
+		aligned := len(resp.Orders) == end-start
+		k := 0
+		for j, i := range perpIdx[start:end] {
+			if j < len(resp.Errors) && resp.Errors[j] != nil {
+				res[i].Err = parseErr(resp.Errors[j])
+				continue
+			}
+			n := k
+			if aligned {
+				n = j
+			}
+			if n >= len(resp.Orders) || resp.Orders[n] == nil {
+				res[i].Err = gerror.Errorf("order %d not found in batch response", j)
+				continue
+			}
+			res[i].Id = fintypes.NewOrderId(fintypes.MarketPerp, reqs[i].Margin, reqs[i].Pair, gnum.ToString(resp.Orders[n].OrderID))
+			k++
+		}
+	}
+	return res, nil
+}
+
+// perp orders of the same symbol are canceled by batch api, 10 orders per request, spot and margin orders are canceled one by one
+func (ex *Client) CancelOrders(ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	return ex.CancelOrdersContext(context.Background(), ids)
+}
+
+func (ex *Client) CancelOrdersContext(ctx context.Context, ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(ids))
+
+	var symbols []string
+	perpIdx := map[string][]int{}
+	for i, id := range ids {
+		res[i].Id = id
+		if err := id.Verify(); err != nil {
+			res[i].Err = err
+			continue
+		}
+		if id.Market() != fintypes.MarketPerp {
+			res[i].Err = ex.CancelOrderContext(ctx, id)
+			continue
+		}
+		symbol := id.Pair().CustomFormat(ex.Property())
+		if _, ok := perpIdx[symbol]; !ok {
+			symbols = append(symbols, symbol)
+		}
+		perpIdx[symbol] = append(perpIdx[symbol], i)
+	}
+
+	for _, symbol := range symbols {
+		idx := perpIdx[symbol]
+		for start := 0; start < len(idx); start += perpBatchCancelSize {
+			end := start + perpBatchCancelSize
+			if end > len(idx) {
+				end = len(idx)
+			}
+			var int64Ids []int64
+			for _, i := range idx[start:end] {
+				int64Id, err := strconv.ParseInt(ids[i].StrId(), 10, 64)
 				if err != nil {
-					errC <- err
+					res[i].Err = err
 					continue
 				}
-				askPrice, err := decimals.NewFromString(v.AskPrice)
-				if err != nil {
-					errC <- err
+				int64Ids = append(int64Ids, int64Id)
+			}
+			if len(int64Ids) == 0 {
+				continue
+			}
+			resp, err := ex.inPerp.NewCancelMultipleOrdersService().Symbol(symbol).OrderIDList(int64Ids).Do(ctx)
+			// failed items of response have no order id, their error details are dropped by sdk
+			canceled := map[string]bool{}
+			for _, v := range resp {
+				canceled[gnum.ToString(v.OrderID)] = true
+			}
+			for _, i := range idx[start:end] {
+				if res[i].Err != nil {
 					continue
 				}
-				askQty, err := decimals.NewFromString(v.AskQty)
 				if err != nil {
-					errC <- err
-					continue
+					res[i].Err = parseErr(err)
+				} else if !canceled[ids[i].StrId()] {
+					res[i].Err = gerror.Errorf("OrderId(%s) is not canceled", ids[i].String())
 				}
-				item := MarketStat{
-					OpenTime:    clock.EpochMillisToTime(v.OpenTime),
-					CloseTime:   clock.EpochMillisToTime(v.CloseTime),
-					O:        open,
-					H:        high,
-					L:         low,
-					Last:        last,
-					UnitVolume:  unitVolume,
-					QuoteVolume: quoteVolume,
-					BidPrice:    bidPrice,
-					BidQty:      bidQty,
-					AskPrice:    askPrice,
-					AskQty:      askQty,
-				}
-				ms.T = clock.EpochMillisToTime(v.T)
-				ms.items[pair.SetP(Binance).SetM(MarketSpot)] = item
 			}
+		}
+	}
+	return res, nil
+}
+
+// spot and perp orders are canceled by cancel-all api, margin orders are canceled one by one
+func (ex *Client) CancelAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	return ex.CancelAllOrdersContext(context.Background(), market, margin, target)
+}
 
-			lastSnapTime = ex.property.Clock.Now()
-			retC <- ms
-		},
-		func(err error) {
-			errC <- err
-		},
-	)
+func (ex *Client) CancelAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	symbol := target.CustomFormat(ex.Property())
+
+	if market == fintypes.MarketSpot && margin == fintypes.MarginNo {
+		resp, err := ex.in.NewCancelOpenOrdersService().Symbol(symbol).Do(ctx)
+		if err != nil {
+			return nil, parseErr(err)
+		}
+		var res []fintypes.OrderResult
+		for _, v := range resp.Orders {
+			res = append(res, fintypes.OrderResult{Id: fintypes.NewOrderId(market, margin, target, gnum.ToString(v.OrderID))})
+		}
+		return res, nil
+	}
 
+	// cancel-all api of perp returns nothing but error, so open orders are got before canceling as results
+	ods, err := ex.GetOpenOrdersContext(ctx, &market, &margin, &target)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, err
+	}
+	if market == fintypes.MarketPerp {
+		if err := ex.inPerp.NewCancelAllOpenOrdersService().Symbol(symbol).Do(ctx); err != nil {
+			return nil, parseErr(err)
+		}
+		var res []fintypes.OrderResult
+		for _, od := range ods {
+			res = append(res, fintypes.OrderResult{Id: od.Id})
+		}
+		return res, nil
+	}
+	var ids []fintypes.OrderId
+	for _, od := range ods {
+		ids = append(ids, od.Id)
 	}
-	return retC, doneC, stopC, errC, nil
+	return ex.CancelOrdersContext(ctx, ids)
 }
 
-func (ex *Client) SubKline(pair PairExt, period Period) (retC chan *Bar, doneC, stopC chan struct{}, errC chan error, err error) {
-	retC = make(chan *Bar, 1024)
-	errC = make(chan error, 1024)
-	periodStr, err := period.CustomFormat(ex.Property())
+func (ex *Client) binancePositionToApiPosition(src *futures.PositionRisk) (*fintypes.Position, error) {
+	if src == nil {
+		return nil, errors.Errorf("nil input futures.PositionRisk")
+	}
+	pair, err := fintypes.ParsePairCustom(src.Symbol, ex.Property())
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, err
 	}
 
-	doneC, stopC, err = binance.WsKlineServe(
-		pair.Pair().CustomFormat(ex.Property()),
-		periodStr,
-		func(event *binance.WsKlineEvent) {
-			if !event.K.IsFinal {
-				return
-			}
-			open, err := decimals.NewFromString(event.K.O)
-			if err != nil {
-				errC <- err
-				return
-			}
-			high, err := decimals.NewFromString(event.K.H)
-			if err != nil {
-				errC <- err
-				return
-			}
-			low, err := decimals.NewFromString(event.K.L)
-			if err != nil {
-				errC <- err
-				return
-			}
-			_close, err := decimals.NewFromString(event.K.C)
-			if err != nil {
-				errC <- err
-				return
-			}
-			unitVolume, err := decimals.NewFromString(event.K.V)
-			if err != nil {
-				errC <- err
-				return
-			}
-			/*quoteVolume, err := strconv.ParseFloat(event.Bar.V, 64)
-			if err != nil {
-				errC <- err
-				return
-			}*/ /*
-			item := Bar{
-				T: clock.EpochMillisToTime(event.K.StartTime),
-				//CloseTime:xclock.EpochMillisToTime(v.CloseTime),
-				O:   open,
-				H:   high,
-				L:    low,
-				C:  _close,
-				V: unitVolume,
-				//QuoteVolume:quoteVolume,
-			}
-			retC <- &item
-		},
-		func(err error) {
-			errC <- err
-		},
-	)
+	res := &fintypes.Position{Market: fintypes.MarketPerp, Pair: pair, Time: ex.property.Clock.Now()}
+	if src.MarginType == "cross" {
+		res.Margin = fintypes.MarginCross
+	} else if src.MarginType == "isolated" {
+		res.Margin = fintypes.MarginIsolated
+	} else {
+		return nil, errors.Errorf("unsupported margin type(%s)", src.MarginType)
+	}
+	res.Leverage, err = strconv.Atoi(src.Leverage)
+	if err != nil {
+		return nil, err
+	}
+	// negative amount means short position in one-way mode
+	amount, err := gdecimal.NewFromString(src.PositionAmt)
+	if err != nil {
+		return nil, err
+	}
+	res.Side = fintypes.OrderSideBuyLong
+	if amount.LessThan(gdecimal.Zero) {
+		res.Side = fintypes.OrderSideSellShort
+	}
+	res.Amount = amount.Abs()
+	if res.EntryPrice, err = gdecimal.NewFromString(src.EntryPrice); err != nil {
+		return nil, err
+	}
+	if res.MarkPrice, err = gdecimal.NewFromString(src.MarkPrice); err != nil {
+		return nil, err
+	}
+	if res.LiquidationPrice, err = gdecimal.NewFromString(src.LiquidationPrice); err != nil {
+		return nil, err
+	}
+	if res.UnrealizedPnl, err = gdecimal.NewFromString(src.UnRealizedProfit); err != nil {
+		return nil, err
+	}
+	if src.IsolatedMargin != "" {
+		if res.IsolatedMargin, err = gdecimal.NewFromString(src.IsolatedMargin); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// get open positions of USDⓈ-M futures, one-way position mode is required
+func (ex *Client) GetPositions(market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return ex.GetPositionsContext(context.Background(), market, target)
+}
 
+func (ex *Client) GetPositionsContext(ctx context.Context, market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	if market != fintypes.MarketPerp {
+		return nil, gerror.Errorf("binance doesn't support positions of Market(%s)", market)
+	}
+	svc := ex.inPerp.NewGetPositionRiskService()
+	if target != nil {
+		if err := target.Verify(); err != nil {
+			return nil, err
+		}
+		svc = svc.Symbol(target.CustomFormat(ex.Property()))
+	}
+	risks, err := svc.Do(ctx)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, parseErr(err)
 	}
-	return retC, doneC, stopC, errC, nil
-}*/
 
-/*
-func (ex *Client) SubDepth(pair PairExt, period Period) (retC chan *Depth, doneC, stopC chan struct{}, errC chan error, err error) {
-	retC = make(chan *Bar, 1024)
-	errC = make(chan error, 1024)
-	periodStr, err := period.CustomFormat(ex.Property())
+	// binance returns all symbols, including those without position
+	var res []fintypes.Position
+	for _, v := range risks {
+		p, err := ex.binancePositionToApiPosition(v)
+		if err != nil {
+			return nil, err
+		}
+		if p.Amount.IsPositive() {
+			res = append(res, *p)
+		}
+	}
+	return res, nil
+}
+
+func (ex *Client) SetLeverage(market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return ex.SetLeverageContext(context.Background(), market, target, leverage)
+}
+
+func (ex *Client) SetLeverageContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, leverage int) error {
+	if err := target.Verify(); err != nil {
+		return err
+	}
+	if market != fintypes.MarketPerp {
+		return gerror.Errorf("binance doesn't support leverage of Market(%s)", market)
+	}
+	return ex.setLeverage(ctx, target.CustomFormat(ex.Property()), leverage)
+}
+
+// binance rejects it if there is open position or order of the symbol
+func (ex *Client) SetMarginType(market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return ex.SetMarginTypeContext(context.Background(), market, target, margin)
+}
+
+func (ex *Client) SetMarginTypeContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	if err := target.Verify(); err != nil {
+		return err
+	}
+	if market != fintypes.MarketPerp {
+		return gerror.Errorf("binance doesn't support margin type of Market(%s)", market)
+	}
+	return ex.setMarginType(ctx, target.CustomFormat(ex.Property()), margin)
+}
+
+// close position by reduce only market order, margin type and leverage are not changed
+func (ex *Client) ClosePosition(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return ex.ClosePositionContext(context.Background(), market, margin, target)
+}
+
+func (ex *Client) ClosePositionContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	ps, err := ex.GetPositionsContext(ctx, market, &target)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return nil, nil
+	}
+	p := ps[0]
+	if p.Margin != margin {
+		return nil, gerror.Errorf("position of %s is Margin(%s), not %s", target.String(), p.Margin, margin)
 	}
-	binance.WsAllMarketsStatServe()
 
-	doneC, stopC, err = binance.WsDepthServe(
-		pair.Pair().CustomFormat(ex.Property()),
-		func(event *binance.WsDepthEvent) {
-			if !event..IsFinal {
-				return
-			}
-			open, err := decimals.NewFromString(event.K.O)
-			if err != nil {
-				errC <- err
-				return
-			}
-			high, err := decimals.NewFromString(event.K.H)
-			if err != nil {
-				errC <- err
-				return
-			}
-			low, err := decimals.NewFromString(event.K.L)
-			if err != nil {
-				errC <- err
-				return
-			}
-			_close, err := decimals.NewFromString(event.K.C)
-			if err != nil {
-				errC <- err
-				return
-			}
-			unitVolume, err := decimals.NewFromString(event.K.V)
-			if err != nil {
-				errC <- err
-				return
-			}
-			/*quoteVolume, err := strconv.ParseFloat(event.Bar.V, 64)
-			if err != nil {
-				errC <- err
-				return
-			}*/ /*
-			item := Bar{
-				T: clock.EpochMillisToTime(event.K.StartTime),
-				//CloseTime:xclock.EpochMillisToTime(v.CloseTime),
-				O:   open,
-				H:   high,
-				L:    low,
-				C:  _close,
-				V: unitVolume,
-				//QuoteVolume:quoteVolume,
-			}
-			retC <- &item
-		},
-		func(err error) {
-			errC <- err
-		},
-	)
+	req := fintypes.TradeRequest{Market: market, Margin: margin, Leverage: p.Leverage, Pair: target, Side: p.CloseSide(), Type: fintypes.OrderTypeMarket, Amount: p.Amount, ReduceOnly: true}
+	cos, err := ex.perpOrderService(req, p.Amount, gdecimal.Zero, gdecimal.Zero)
+	if err != nil {
+		return nil, err
+	}
+	od, err := cos.Do(ctx)
+	if err != nil {
+		return nil, parseErr(err)
+	}
+	res := fintypes.NewOrderId(market, margin, target, gnum.ToString(od.OrderID))
+	return &res, nil
+}
+
+// get funding rate history of USDⓈ-M futures, 1000 records at most
+func (ex *Client) GetFundingRates(market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return ex.GetFundingRatesContext(context.Background(), market, target, since)
+}
 
+func (ex *Client) GetFundingRatesContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if market != fintypes.MarketPerp {
+		return nil, gerror.Errorf("binance doesn't support funding rate of Market(%s)", market)
+	}
+
+	svc := ex.inPerp.NewFundingRateService().Symbol(target.CustomFormat(ex.Property())).Limit(1000 /*max limit is 1000*/)
+	if since != nil {
+		svc = svc.StartTime(gtime.TimeToEpochMillis(*since))
+	}
+	rates, err := svc.Do(ctx)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, parseErr(err)
 	}
-	return retC, doneC, stopC, errC, nil
-}*/
+
+	r := &fintypes.FundingRates{Pair: target.SetM(market).SetP(fintypes.Binance)}
+	for _, v := range rates {
+		rate, err := gdecimal.NewFromString(v.FundingRate)
+		if err != nil {
+			return nil, err
+		}
+		r.Items = append(r.Items, fintypes.FundingRate{T: gtime.EpochMillisToTime(v.FundingTime), Rate: rate})
+	}
+	r.Sort()
+	return r, nil
+}
+
+// get mark price klines of USDⓈ-M futures, 1000 bars at most
+func (ex *Client) GetMarkPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return ex.GetMarkPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (ex *Client) GetMarkPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return ex.getPerpPriceKline(ctx, market, target, period, since, false)
+}
+
+// get index price klines of USDⓈ-M futures, 1000 bars at most
+func (ex *Client) GetIndexPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return ex.GetIndexPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (ex *Client) GetIndexPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return ex.getPerpPriceKline(ctx, market, target, period, since, true)
+}
+
+// mark price klines, or index price klines if index is true
+func (ex *Client) getPerpPriceKline(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time, index bool) (*fintypes.Kline, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if market != fintypes.MarketPerp {
+		return nil, gerror.Errorf("binance doesn't support mark/index price of Market(%s)", market)
+	}
+	binancePeriod, err := period.CustomFormat(ex.Property())
+	if err != nil {
+		return nil, err
+	}
+	symbol := target.CustomFormat(ex.Property())
+
+	// nil since means the latest page, like GetKline
+	var ks []*futures.Kline
+	if index {
+		svc := ex.inPerp.NewIndexPriceKlinesService().Pair(symbol).Interval(binancePeriod).Limit(1000)
+		if since != nil {
+			svc = svc.StartTime(gtime.TimeToEpochMillis(*since))
+		}
+		ks, err = svc.Do(ctx)
+	} else {
+		svc := ex.inPerp.NewMarkPriceKlinesService().Symbol(symbol).Interval(binancePeriod).Limit(1000)
+		if since != nil {
+			svc = svc.StartTime(gtime.TimeToEpochMillis(*since))
+		}
+		ks, err = svc.Do(ctx)
+	}
+	if err != nil {
+		return nil, parseErr(err)
+	}
+
+	r := new(fintypes.Kline)
+	r.Pair = target.SetI(period).SetM(market).SetP(fintypes.Binance)
+	for _, v := range ks {
+		item := fintypes.Bar{T: gtime.EpochMillisToTime(v.OpenTime), V: gdecimal.Zero}
+		if item.O, err = gdecimal.NewFromString(v.Open); err != nil {
+			return nil, err
+		}
+		if item.H, err = gdecimal.NewFromString(v.High); err != nil {
+			return nil, err
+		}
+		if item.L, err = gdecimal.NewFromString(v.Low); err != nil {
+			return nil, err
+		}
+		if item.C, err = gdecimal.NewFromString(v.Close); err != nil {
+			return nil, err
+		}
+		r.Items = append(r.Items, item)
+	}
+	r.Sort()
+	return r, nil
+}
+
+// get open interest history of USDⓈ-M futures, binance keeps the latest 30 days only, 500 records at most
+func (ex *Client) GetOpenInterests(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return ex.GetOpenInterestsContext(context.Background(), market, target, period, since)
+}
+
+func (ex *Client) GetOpenInterestsContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if market != fintypes.MarketPerp {
+		return nil, gerror.Errorf("binance doesn't support open interest of Market(%s)", market)
+	}
+	binancePeriod, err := period.CustomFormat(ex.Property())
+	if err != nil {
+		return nil, err
+	}
+
+	svc := ex.inPerp.NewOpenInterestStatisticsService().Symbol(target.CustomFormat(ex.Property())).Period(binancePeriod).Limit(500 /*max limit is 500*/)
+	if since != nil {
+		svc = svc.StartTime(gtime.TimeToEpochMillis(*since))
+	}
+	stats, err := svc.Do(ctx)
+	if err != nil {
+		return nil, parseErr(err)
+	}
+
+	r := &fintypes.OpenInterests{Pair: target.SetI(period).SetM(market).SetP(fintypes.Binance)}
+	for _, v := range stats {
+		item := fintypes.OpenInterest{T: gtime.EpochMillisToTime(v.Timestamp)}
+		if item.Amount, err = gdecimal.NewFromString(v.SumOpenInterest); err != nil {
+			return nil, err
+		}
+		if item.Value, err = gdecimal.NewFromString(v.SumOpenInterestValue); err != nil {
+			return nil, err
+		}
+		r.Items = append(r.Items, item)
+	}
+	r.Sort()
+	return r, nil
+}
 
 // get agg fills by option
 // API limit: 1 hour duration max, 1000 IdLimit max
 func (ex *Client) GetAggFills(pair fintypes.Pair, option *fintypes.FillOption) ([]fintypes.Fill, error) {
+	return ex.GetAggFillsContext(context.Background(), pair, option)
+}
+
+func (ex *Client) GetAggFillsContext(ctx context.Context, pair fintypes.Pair, option *fintypes.FillOption) ([]fintypes.Fill, error) {
 	if err := pair.Verify(); err != nil {
 		return nil, err
 	}
@@ -1610,16 +2232,16 @@ func (ex *Client) GetAggFills(pair fintypes.Pair, option *fintypes.FillOption) (
 
 	if option != nil {
 		if option.BeginId > 0 {
-			fills, err = ex.in.NewAggTradesService().Symbol(pair.CustomFormat(ex.Property())).FromID(option.BeginId).Limit(int(option.IdLimit)).Do(context.Background())
+			fills, err = ex.in.NewAggTradesService().Symbol(pair.CustomFormat(ex.Property())).FromID(option.BeginId).Limit(int(option.IdLimit)).Do(ctx)
 		} else {
-			fills, err = ex.in.NewAggTradesService().Symbol(pair.CustomFormat(ex.Property())).StartTime(gtime.TimeToEpochMillis(option.BeginTime)).EndTime(gtime.TimeToEpochMillis(option.BeginTime.Add(option.TimeDuration))).Do(context.Background())
+			fills, err = ex.in.NewAggTradesService().Symbol(pair.CustomFormat(ex.Property())).StartTime(gtime.TimeToEpochMillis(option.BeginTime)).EndTime(gtime.TimeToEpochMillis(option.BeginTime.Add(option.TimeDuration))).Do(ctx)
 		}
 	} else {
-		fills, err = ex.in.NewAggTradesService().Symbol(pair.CustomFormat(ex.Property())).Do(context.Background())
+		fills, err = ex.in.NewAggTradesService().Symbol(pair.CustomFormat(ex.Property())).Do(ctx)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, parseErr(err)
 	}
 
 	var r []fintypes.Fill
@@ -1645,12 +2267,176 @@ func (ex *Client) GetAggFills(pair fintypes.Pair, option *fintypes.FillOption) (
 	return r, nil
 }
 
-func (ex *Client) GetDepositAddresses() (map[string]string, error) {
-	address, err := ex.in.NewGetDepositAddressService().Asset("ETH").Do(context.Background())
+// get deposit address of asset, network like "ETH", "BSC", "TRX", empty network means the default one
+func (ex *Client) GetDepositAddress(asset, network string) (*fintypes.DepositAddress, error) {
+	return ex.GetDepositAddressContext(context.Background(), asset, network)
+}
+
+func (ex *Client) GetDepositAddressContext(ctx context.Context, asset, network string) (*fintypes.DepositAddress, error) {
+	if asset == "" {
+		return nil, gerror.Errorf("empty deposit asset")
+	}
+	svc := ex.in.NewGetDepositAddressService().Coin(strings.ToUpper(asset))
+	if network != "" {
+		svc = svc.Network(strings.ToUpper(network))
+	}
+	addr, err := svc.Do(ctx)
 	if err != nil {
-		fmt.Println(err)
-		return nil, err
+		return nil, parseErr(err)
+	}
+	return &fintypes.DepositAddress{
+		Asset:   strings.ToUpper(addr.Coin),
+		Network: strings.ToUpper(network),
+		Address: addr.Address,
+		Tag:     addr.Tag,
+	}, nil
+}
+
+// withdrawal address must be in whitelist if whitelist is enabled in api key settings
+func (ex *Client) Withdraw(req fintypes.WithdrawRequest) (string, error) {
+	return ex.WithdrawContext(context.Background(), req)
+}
+
+func (ex *Client) WithdrawContext(ctx context.Context, req fintypes.WithdrawRequest) (string, error) {
+	if err := req.Verify(); err != nil {
+		return "", err
+	}
+	svc := ex.in.NewCreateWithdrawService().Coin(strings.ToUpper(req.Asset)).Address(req.Address).Amount(req.Amount.String())
+	if req.Network != "" {
+		svc = svc.Network(strings.ToUpper(req.Network))
+	}
+	if req.Tag != "" {
+		svc = svc.AddressTag(req.Tag)
+	}
+	if req.ClientId != "" {
+		svc = svc.WithdrawOrderID(req.ClientId)
+	}
+	resp, err := svc.Do(ctx)
+	if err != nil {
+		return "", parseErr(err)
+	}
+	return resp.ID, nil
+}
+
+// get deposit history, binance returns 90 days at most since the time
+func (ex *Client) GetDeposits(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return ex.GetDepositsContext(context.Background(), asset, since)
+}
+
+func (ex *Client) GetDepositsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	svc := ex.in.NewListDepositsService()
+	if asset != "" {
+		svc = svc.Coin(strings.ToUpper(asset))
+	}
+	if since != nil {
+		svc = svc.StartTime(gtime.TimeToEpochMillis(*since))
+	}
+	deposits, err := svc.Do(ctx)
+	if err != nil {
+		return nil, parseErr(err)
+	}
+
+	var r []fintypes.WalletRecord
+	for _, v := range deposits {
+		item := fintypes.WalletRecord{
+			Id:      v.ID,
+			Time:    gtime.EpochMillisToTime(v.InsertTime),
+			Asset:   strings.ToUpper(v.Coin),
+			Network: v.Network,
+			Address: v.Address,
+			Tag:     v.AddressTag,
+			Fee:     gdecimal.Zero,
+			TxId:    v.TxID,
+			Status:  binanceDepositStatusToApiStatus(v.Status),
+		}
+		if item.Status == fintypes.WalletStatusError {
+			return nil, gerror.Errorf("unknown binance deposit status %d", v.Status)
+		}
+		item.Amount, err = gdecimal.NewFromString(v.Amount)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, item)
+	}
+	return r, nil
+}
+
+// get withdrawal history, binance returns 90 days at most since the time
+func (ex *Client) GetWithdrawals(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return ex.GetWithdrawalsContext(context.Background(), asset, since)
+}
+
+func (ex *Client) GetWithdrawalsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	svc := ex.in.NewListWithdrawsService()
+	if asset != "" {
+		svc = svc.Coin(strings.ToUpper(asset))
+	}
+	if since != nil {
+		svc = svc.StartTime(gtime.TimeToEpochMillis(*since))
+	}
+	withdraws, err := svc.Do(ctx)
+	if err != nil {
+		return nil, parseErr(err)
+	}
+
+	var r []fintypes.WalletRecord
+	for _, v := range withdraws {
+		item := fintypes.WalletRecord{
+			Id:       v.ID,
+			ClientId: v.WithdrawOrderID,
+			Asset:    strings.ToUpper(v.Coin),
+			Network:  v.Network,
+			Address:  v.Address,
+			TxId:     v.TxID,
+			Status:   binanceWithdrawStatusToApiStatus(v.Status),
+		}
+		if item.Status == fintypes.WalletStatusError {
+			return nil, gerror.Errorf("unknown binance withdraw status %d", v.Status)
+		}
+		// apply time is UTC time string like "2019-10-12 11:12:02"
+		item.Time, err = time.ParseInLocation("2006-01-02 15:04:05", v.ApplyTime, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		item.Amount, err = gdecimal.NewFromString(v.Amount)
+		if err != nil {
+			return nil, err
+		}
+		item.Fee, err = gdecimal.NewFromString(v.TransactionFee)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, item)
+	}
+	return r, nil
+}
+
+// 0:pending, 6:credited but cannot withdraw, 7:wrong deposit, 8:waiting user confirm, 1:success
+func binanceDepositStatusToApiStatus(status int) fintypes.WalletStatus {
+	switch status {
+	case 0, 8:
+		return fintypes.WalletStatusPending
+	case 1, 6:
+		return fintypes.WalletStatusSuccess
+	case 7:
+		return fintypes.WalletStatusFailed
+	default:
+		return fintypes.WalletStatusError
+	}
+}
+
+// 0:email sent, 1:cancelled, 2:awaiting approval, 3:rejected, 4:processing, 5:failure, 6:completed
+func binanceWithdrawStatusToApiStatus(status int) fintypes.WalletStatus {
+	switch status {
+	case 0, 2, 4:
+		return fintypes.WalletStatusPending
+	case 1:
+		return fintypes.WalletStatusCanceled
+	case 3, 5:
+		return fintypes.WalletStatusFailed
+	case 6:
+		return fintypes.WalletStatusSuccess
+	default:
+		return fintypes.WalletStatusError
 	}
-	fmt.Println(address)
-	return nil, nil
 }
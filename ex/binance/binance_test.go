@@ -2,18 +2,35 @@ package binance
 
 import (
 	"fmt"
+	"github.com/adshao/go-binance"
+	"github.com/adshao/go-binance/common"
+	"github.com/adshao/go-binance/futures"
 	"github.com/foxtrader/gofin/fintypes"
+	"github.com/foxtrader/gofin/httpreplay"
+	"github.com/pkg/errors"
 	"github.com/shawnwyckoff/gopkg/apputil/gtest"
 	"github.com/shawnwyckoff/gopkg/container/gdecimal"
 	"github.com/shawnwyckoff/gopkg/container/gjson"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
 
-func TestBinance_GetTicks(t *testing.T) {
-	ex, err := New("", "", "socks5://127.0.0.1:7448", nil, "")
+// client replaying responses recorded in testdata/replay, record them with GOFIN_REPLAY=record and a reachable network
+func newTestReplayClient(t *testing.T) *Client {
+	ex, err := New("", "", "", nil, "")
 	gtest.Assert(t, err)
+	rt, err := httpreplay.New("testdata/replay")
+	gtest.Assert(t, err)
+	ex.SetTransport(rt)
+	return ex
+}
+
+func TestBinance_GetTicks(t *testing.T) {
+	ex := newTestReplayClient(t)
 	ticks, err := ex.GetTicks(true)
 	gtest.Assert(t, err)
 	if len(ticks) < 100 {
@@ -53,12 +70,7 @@ func TestBinance_Trade(t *testing.T) {
 
 // TODO: finish test
 func TestBinance_GetMarketInfo(t *testing.T) {
-	bnc, err := New("", "", "socks5://127.0.0.1:7448", nil, "")
-	if err != nil {
-		t.Error(err)
-		return
-	}
-
+	bnc := newTestReplayClient(t)
 	mi, err := bnc.GetMarketInfo(true)
 	if err != nil {
 		t.Error(err)
@@ -240,11 +252,272 @@ func TestBinance_SubKline(t *testing.T) {
 }
 */
 
-func TestBinance_GetDepositAddresses(t *testing.T) {
+func TestBinance_GetDepositAddress(t *testing.T) {
 	ex, err := New("", "", "socks5://127.0.0.1:7448", nil, "")
 	if err != nil {
 		t.Error(err)
 		return
 	}
-	ex.GetDepositAddresses()
+	ex.GetDepositAddress("ETH", "ETH")
+}
+
+func TestBinance_walletStatus(t *testing.T) {
+	if binanceDepositStatusToApiStatus(6) != fintypes.WalletStatusSuccess || binanceDepositStatusToApiStatus(0) != fintypes.WalletStatusPending {
+		t.Error("binanceDepositStatusToApiStatus error")
+		return
+	}
+	if binanceWithdrawStatusToApiStatus(1) != fintypes.WalletStatusCanceled || binanceWithdrawStatusToApiStatus(5) != fintypes.WalletStatusFailed ||
+		binanceWithdrawStatusToApiStatus(6) != fintypes.WalletStatusSuccess || !binanceWithdrawStatusToApiStatus(6).End() {
+		t.Error("binanceWithdrawStatusToApiStatus error")
+		return
+	}
+	if binanceWithdrawStatusToApiStatus(100) != fintypes.WalletStatusError {
+		t.Error("unknown withdraw status should be error")
+		return
+	}
+}
+
+func TestBinance_parseWsEvents(t *testing.T) {
+	ex, err := New("", "", "", nil, "")
+	gtest.Assert(t, err)
+
+	msg := `{"e":"executionReport","E":1499405658658,"s":"ETHBTC","c":"mUvoqJxFIILMdfAW5iGSOW","S":"BUY","f":"GTC","q":"2.00000000","p":"0.10264410","P":"0.00000000","x":"TRADE","X":"PARTIALLY_FILLED","i":4293153,"l":"1.00000000","z":"1.00000000","L":"0.10264410","n":"0","N":null,"T":1499405658657,"O":1499405658657,"Z":"0.10264410","o":"LIMIT"}`
+	od, err := ex.parseWsOrder(fintypes.MarketSpot, fintypes.MarginNo, []byte(msg))
+	gtest.Assert(t, err)
+	if od == nil || od.Status != fintypes.OrderStatusPartiallyFilled || od.DealAmount.String() != "1" || od.AvgPrice.String() != "0.1026441" || od.Id.StrId() != "4293153" || od.TimeInForce != fintypes.TimeInForceGTC || od.ClientId != "mUvoqJxFIILMdfAW5iGSOW" {
+		gtest.PrintlnExit(t, "parse executionReport error %v", od)
+	}
+
+	msg = `{"e":"outboundAccountPosition","E":1564034571105,"u":1564034571073,"B":[{"a":"ETH","f":"10000.000000","l":"0.000000"}]}`
+	balances, err := ex.parseWsBalances(fintypes.MarketSpot, fintypes.MarginNo, []byte(msg))
+	gtest.Assert(t, err)
+	if len(balances) != 1 || balances[0].Asset != "ETH" || balances[0].Free.String() != "10000" {
+		gtest.PrintlnExit(t, "parse outboundAccountPosition error %v", balances)
+	}
+
+	// other events are ignored
+	od, err = ex.parseWsOrder(fintypes.MarketSpot, fintypes.MarginNo, []byte(`{"e":"balanceUpdate","E":1573200697110,"a":"BTC","d":"100.00000000","T":1573200697068}`))
+	gtest.Assert(t, err)
+	if od != nil {
+		gtest.PrintlnExit(t, "balanceUpdate should be ignored")
+	}
+}
+
+func TestBinance_binanceOrderToApiOrder(t *testing.T) {
+	ex, err := New("", "", "", nil, "")
+	gtest.Assert(t, err)
+
+	src := &binance.Order{Symbol: "ETHBTC", OrderID: 4293153, Price: "0", OrigQuantity: "2", ExecutedQuantity: "2", CummulativeQuoteQuantity: "0.2",
+		Side: binance.SideTypeBuy, Type: binance.OrderTypeMarket, Status: binance.OrderStatusTypeFilled}
+	od, err := ex.binanceOrderToApiOrder(fintypes.MarketSpot, fintypes.MarginNo, src)
+	gtest.Assert(t, err)
+	if od.AvgPrice.String() != "0.1" {
+		gtest.PrintlnExit(t, "AvgPrice should be 0.1, but %s", od.AvgPrice)
+	}
+
+	// nothing filled yet
+	src.ExecutedQuantity, src.CummulativeQuoteQuantity = "0", "0"
+	od, err = ex.binanceOrderToApiOrder(fintypes.MarketSpot, fintypes.MarginNo, src)
+	gtest.Assert(t, err)
+	if od.AvgPrice.String() != "-1" {
+		gtest.PrintlnExit(t, "AvgPrice should be -1, but %s", od.AvgPrice)
+	}
+}
+
+func TestBinance_typeSideToBinance(t *testing.T) {
+	ex, err := New("", "", "", nil, "")
+	gtest.Assert(t, err)
+
+	_, ot, err := ex.typeSideToBinance(fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit, fintypes.TimeInForceGTX)
+	gtest.Assert(t, err)
+	if ot != binance.OrderTypeLimitMaker {
+		gtest.PrintlnExit(t, "post only spot order should be LIMIT_MAKER, but got %s", ot)
+	}
+	_, ot, err = ex.typeSideToBinance(fintypes.OrderSideSellShort, fintypes.OrderTypeTakeProfit, fintypes.TimeInForceGTC)
+	gtest.Assert(t, err)
+	if ot != binance.OrderTypeTakeProfitLimit {
+		gtest.PrintlnExit(t, "take profit spot order should be TAKE_PROFIT_LIMIT, but got %s", ot)
+	}
+	if _, _, err = ex.typeSideToBinance(fintypes.OrderSideSellShort, fintypes.OrderTypeTrailingStop, fintypes.TimeInForceGTC); err == nil {
+		gtest.PrintlnExit(t, "trailing stop should not be supported in spot")
+	}
+	_, pot, err := ex.typeSideToBinanceContract(fintypes.OrderSideSellShort, fintypes.OrderTypeTrailingStop)
+	gtest.Assert(t, err)
+	if pot != futures.OrderTypeTrailingStopMarket {
+		gtest.PrintlnExit(t, "trailing stop perp order should be TRAILING_STOP_MARKET, but got %s", pot)
+	}
+
+	typ, tif, err := ex.binanceToType(binance.OrderTypeLimitMaker, "")
+	gtest.Assert(t, err)
+	if typ != fintypes.OrderTypeLimit || tif != fintypes.TimeInForceGTX {
+		gtest.PrintlnExit(t, "LIMIT_MAKER should be parsed as post only limit order, but got %s %s", typ, tif)
+	}
+	typ, tif, err = ex.binanceContractToType(futures.OrderTypeStop, futures.TimeInForceTypeIOC)
+	gtest.Assert(t, err)
+	if typ != fintypes.OrderTypeStopLimit || tif != fintypes.TimeInForceIOC {
+		gtest.PrintlnExit(t, "STOP IOC perp order parse error %s %s", typ, tif)
+	}
+}
+
+func TestBinance_parseWsDepthDiff(t *testing.T) {
+	msg := `{"e":"depthUpdate","E":1571889248277,"T":1571889248276,"s":"BTCUSDT","U":390497796,"u":390497878,"pu":390497794,"b":[["7403.89","0.002"],["7403.90","3.906"]],"a":[["7405.96","3.340"],["7406.63","0"]]}`
+	diff, err := parseWsDepthDiff([]byte(msg))
+	gtest.Assert(t, err)
+	if diff.FirstUpdateId != 390497796 || diff.LastUpdateId != 390497878 || diff.PrevLastUpdateId != 390497794 ||
+		len(diff.Buys) != 2 || len(diff.Sells) != 2 || diff.Buys[1].Price.String() != "7403.9" || !diff.Sells[1].Amount.IsZero() {
+		gtest.PrintlnExit(t, "parse depthUpdate error %v", diff)
+	}
+}
+
+func TestBinance_requestCost(t *testing.T) {
+	for _, v := range []struct {
+		method, url string
+		rule        string
+		weight      int
+		orders      bool
+	}{
+		{"GET", "https://api.binance.com/api/v3/depth?symbol=BTCUSDT&limit=1000", ruleSpotWeight, 10, false},
+		{"GET", "https://api.binance.com/api/v3/openOrders", ruleSpotWeight, 40, false},
+		{"POST", "https://api.binance.com/api/v3/order", ruleSpotWeight, 1, true},
+		{"GET", "https://api.binance.com/sapi/v1/margin/account", ruleSapiWeight, 10, false},
+		{"POST", "https://api.binance.com/api/v3/order/oco", ruleSpotOrders, 2, true},
+		{"POST", "https://api.binance.com/sapi/v1/margin/order/oco", ruleSpotOrdersDay, 2, true},
+		{"GET", "https://fapi.binance.com/fapi/v1/klines?symbol=BTCUSDT&limit=1000", rulePerpWeight, 5, false},
+		{"GET", "https://fapi.binance.com/fapi/v1/markPriceKlines?symbol=BTCUSDT&limit=1000", rulePerpWeight, 5, false},
+		{"POST", "https://fapi.binance.com/fapi/v1/order", rulePerpWeight, 1, true},
+		{"POST", "https://fapi.binance.com/fapi/v1/batchOrders", rulePerpWeight, 5, true},
+	} {
+		req, err := http.NewRequest(v.method, v.url, nil)
+		gtest.Assert(t, err)
+		cost := spotCost(req)
+		if strings.Contains(v.url, "/fapi/") {
+			cost = perpCost(req)
+		}
+		if cost[v.rule] != v.weight || (cost[ruleSpotOrders]+cost[rulePerpOrders] > 0) != v.orders {
+			gtest.PrintlnExit(t, "cost of %s %s error %v", v.method, v.url, cost)
+		}
+	}
+}
+
+func TestBinance_parseErr(t *testing.T) {
+	for _, v := range []struct {
+		err  *common.APIError
+		kind error
+	}{
+		{&common.APIError{Code: -2010, Message: "Account has insufficient balance for requested action."}, fintypes.ErrInsufficientBalance},
+		{&common.APIError{Code: -1013, Message: "Filter failure: MIN_NOTIONAL"}, fintypes.ErrMinNotional},
+		{&common.APIError{Code: -1013, Message: "Filter failure: LOT_SIZE"}, fintypes.ErrInvalidOrder},
+		{&common.APIError{Code: -2013, Message: "Order does not exist."}, fintypes.ErrOrderNotFound},
+		{&common.APIError{Code: -1021, Message: "Timestamp for this request is outside of the recvWindow."}, fintypes.ErrInvalidTimestamp},
+		{&common.APIError{Code: -1003, Message: "Too many requests."}, fintypes.ErrRateLimited},
+		{&common.APIError{Code: -2010, Message: "Duplicate order sent."}, fintypes.ErrDuplicateOrder},
+		{&common.APIError{Code: -1007, Message: "Timeout waiting for response from backend server. Send status unknown; execution status unknown."}, fintypes.ErrUnknownResult},
+	} {
+		err := parseErr(v.err)
+		if !errors.Is(err, v.kind) {
+			gtest.PrintlnExit(t, "kind of %v should be %v, but got %v", v.err, v.kind, err)
+		}
+	}
+
+	// api error wrapped by caller or transport
+	err := parseErr(errors.Wrap(&common.APIError{Code: -2019, Message: "Margin is insufficient."}, "place order"))
+	if !errors.Is(err, fintypes.ErrInsufficientBalance) {
+		gtest.PrintlnExit(t, "wrapped api error should be converted, but got %v", err)
+	}
+}
+
+func TestBinance_binancePositionToApiPosition(t *testing.T) {
+	ex, err := New("", "", "", nil, "")
+	gtest.Assert(t, err)
+
+	p, err := ex.binancePositionToApiPosition(&futures.PositionRisk{
+		Symbol:           "BTCUSDT",
+		PositionAmt:      "-0.010",
+		EntryPrice:       "7000.5",
+		MarkPrice:        "6900",
+		LiquidationPrice: "7600.2",
+		UnRealizedProfit: "1.005",
+		Leverage:         "10",
+		MarginType:       "isolated",
+		IsolatedMargin:   "8.01",
+	})
+	gtest.Assert(t, err)
+	if p.Pair != fintypes.BTC.Against(fintypes.USDT) || p.Side != fintypes.OrderSideSellShort || p.Amount.String() != "0.01" || p.Leverage != 10 || p.Margin != fintypes.MarginIsolated {
+		gtest.PrintlnExit(t, "position error %s", p.String())
+	}
+	if p.CloseSide() != fintypes.OrderSideBuyLong || p.Notional().String() != "69" || p.LiquidationPrice.String() != "7600.2" {
+		gtest.PrintlnExit(t, "position values error %s", p.String())
+	}
+}
+
+func TestBinance_TradeBatch(t *testing.T) {
+	// the second order of batch fails, the last one is rejected before sending
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fapi/v1/marginType":
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+		case "/fapi/v1/leverage":
+			_, _ = w.Write([]byte(`{"leverage":10,"maxNotionalValue":"1000000","symbol":"BTCUSDT"}`))
+		case "/fapi/v1/batchOrders":
+			_, _ = w.Write([]byte(`[{"orderId":11,"clientOrderId":"c1","symbol":"BTCUSDT","status":"NEW"},` +
+				`{"code":-2019,"msg":"Margin is insufficient."},` +
+				`{"orderId":13,"clientOrderId":"c3","symbol":"BTCUSDT","status":"NEW"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ex, err := New("access", "secret", "", nil, "")
+	gtest.Assert(t, err)
+	gtest.Assert(t, ex.SetBaseUrl(fintypes.MarketPerp, srv.URL))
+	pair := fintypes.BTC.Against(fintypes.USDT)
+	ex.marketInfoCache = fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{
+		pair.SetM(fintypes.MarketPerp): {Enabled: true, UnitMin: gdecimal.NewFromFloat64(0.001), UnitStep: gdecimal.NewFromFloat64(0.001), QuoteStep: gdecimal.NewFromFloat64(0.1)},
+	}}
+	ex.marketInfoUpdate = ex.property.Clock.Now()
+
+	var reqs []fintypes.TradeRequest
+	for i, amount := range []float64{0.01, 0.02, 0.03, 0.0001} {
+		reqs = append(reqs, fintypes.TradeRequest{Market: fintypes.MarketPerp, Margin: fintypes.MarginCross, Leverage: 10, Pair: pair,
+			Side: fintypes.OrderSideBuyLong, Type: fintypes.OrderTypeLimit, Amount: gdecimal.NewFromFloat64(amount), Price: gdecimal.NewFromInt(7000),
+			ClientId: fmt.Sprintf("c%d", i+1)})
+	}
+	res, err := ex.TradeBatch(reqs)
+	gtest.Assert(t, err)
+	if len(res) != 4 || res[0].Err != nil || res[0].Id.StrId() != "11" || res[2].Err != nil || res[2].Id.StrId() != "13" {
+		gtest.PrintlnExit(t, "successful orders of batch error %v", res)
+	}
+	if !errors.Is(res[1].Err, fintypes.ErrInsufficientBalance) || !errors.Is(res[3].Err, fintypes.ErrInvalidOrder) {
+		gtest.PrintlnExit(t, "failed orders of batch error %v %v", res[1].Err, res[3].Err)
+	}
+}
+
+func TestBinance_GetMarkPriceKline(t *testing.T) {
+	// the latest page is returned if since is nil
+	var startTimes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTimes = append(startTimes, r.URL.Query().Get("startTime"))
+		_, _ = w.Write([]byte(`[[1577836800000,"7000","7100","6900","7050","0",1577836859999,"0",0,"0","0","0"],` +
+			`[1577836860000,"7050","7060","7040","7055","0",1577836919999,"0",0,"0","0","0"]]`))
+	}))
+	defer srv.Close()
+	ex, err := New("", "", "", nil, "")
+	gtest.Assert(t, err)
+	gtest.Assert(t, ex.SetBaseUrl(fintypes.MarketPerp, srv.URL))
+
+	k, err := ex.GetMarkPriceKline(fintypes.MarketPerp, fintypes.BTC.Against(fintypes.USDT), fintypes.Period1Min, nil)
+	gtest.Assert(t, err)
+	if len(startTimes) != 1 || startTimes[0] != "" {
+		gtest.PrintlnExit(t, "startTime should not be sent if since is nil, but %v", startTimes)
+	}
+	if k.Len() != 2 || k.Items[1].C.String() != "7055" {
+		gtest.PrintlnExit(t, "mark price kline error %v", k.Items)
+	}
+	since := time.Unix(1577836800, 0)
+	_, err = ex.GetIndexPriceKline(fintypes.MarketPerp, fintypes.BTC.Against(fintypes.USDT), fintypes.Period1Min, &since)
+	gtest.Assert(t, err)
+	if len(startTimes) != 2 || startTimes[1] != "1577836800000" {
+		gtest.PrintlnExit(t, "startTime of since error %v", startTimes)
+	}
 }
@@ -0,0 +1,56 @@
+package binance
+
+import (
+	"github.com/adshao/go-binance/common"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// error codes of spot, margin and futures api, docs: https://binance-docs.github.io/apidocs/spot/en/#error-codes
+var errorKinds = map[int64]error{
+	-1006: fintypes.ErrUnknownResult,    // UNEXPECTED_RESP, execution status unknown
+	-1007: fintypes.ErrUnknownResult,    // TIMEOUT, execution status unknown
+	-1003: fintypes.ErrRateLimited,      // TOO_MANY_REQUESTS
+	-1015: fintypes.ErrRateLimited,      // TOO_MANY_ORDERS
+	-1021: fintypes.ErrInvalidTimestamp, // INVALID_TIMESTAMP
+	-1022: fintypes.ErrInvalidApiKey,    // INVALID_SIGNATURE
+	-2014: fintypes.ErrInvalidApiKey,    // BAD_API_KEY_FMT
+	-2015: fintypes.ErrInvalidApiKey,    // REJECTED_MBX_KEY
+	-1121: fintypes.ErrInvalidPair,      // BAD_SYMBOL
+	-1111: fintypes.ErrInvalidOrder,     // BAD_PRECISION
+	-2011: fintypes.ErrOrderNotFound,    // CANCEL_REJECTED, unknown order
+	-2013: fintypes.ErrOrderNotFound,    // NO_SUCH_ORDER
+	-2018: fintypes.ErrInsufficientBalance,
+	-2019: fintypes.ErrInsufficientBalance, // futures margin is insufficient
+	-3041: fintypes.ErrInsufficientBalance, // margin balance is not enough
+	-4164: fintypes.ErrMinNotional,         // futures MIN_NOTIONAL
+	-4116: fintypes.ErrDuplicateOrder,      // futures DUPLICATED_CLIENT_ORDER_ID
+}
+
+// convert go-binance api error into *fintypes.ExError, api error wrapped by others is converted too, other errors are returned as is
+func parseErr(err error) error {
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	kind := errorKinds[apiErr.Code]
+	switch apiErr.Code {
+	case -1013: // INVALID_MESSAGE, filter failures of new order
+		if strings.Contains(apiErr.Message, "NOTIONAL") {
+			kind = fintypes.ErrMinNotional
+		} else if strings.Contains(apiErr.Message, "Filter failure") {
+			kind = fintypes.ErrInvalidOrder
+		}
+	case -2010: // NEW_ORDER_REJECTED
+		if strings.Contains(strings.ToLower(apiErr.Message), "insufficient balance") {
+			kind = fintypes.ErrInsufficientBalance
+		} else if strings.Contains(apiErr.Message, "Duplicate order") {
+			kind = fintypes.ErrDuplicateOrder
+		} else {
+			kind = fintypes.ErrOrderRejected
+		}
+	}
+	return fintypes.NewExError(fintypes.Binance, strconv.FormatInt(apiErr.Code, 10), apiErr.Message, kind)
+}
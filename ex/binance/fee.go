@@ -0,0 +1,65 @@
+package binance
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"strconv"
+)
+
+/**
+币安手续费
+1. 现货按VIP等级, BNB抵扣75折, 账户接口返回的makerCommission/takerCommission是实际费率, 单位万分之一
+2. U本位合约按VIP等级, BNB抵扣9折
+*/
+
+func feeRate(maker, taker float64) fintypes.FeeRate {
+	return fintypes.FeeRate{Maker: gdecimal.NewFromFloat64(maker), Taker: gdecimal.NewFromFloat64(taker)}
+}
+
+// regular and VIP tiers of binance, spot fees also apply to margin
+func DefaultFeeSchedule() *fintypes.FeeSchedule {
+	fs := &fintypes.FeeSchedule{
+		Platform:      fintypes.Binance,
+		DiscountAsset: "BNB",
+		Discounts: map[fintypes.Market]gdecimal.Decimal{
+			fintypes.MarketSpot: gdecimal.NewFromFloat64(0.25),
+			fintypes.MarketPerp: gdecimal.NewFromFloat64(0.1),
+		},
+	}
+	for i, v := range [][4]float64{
+		{0.001, 0.001, 0.0002, 0.0004},
+		{0.0009, 0.001, 0.00016, 0.0004},
+		{0.0008, 0.001, 0.00014, 0.00035},
+		{0.0007, 0.001, 0.00012, 0.00032},
+	} {
+		fs.Tiers = append(fs.Tiers, fintypes.FeeTier{
+			Name: "VIP" + strconv.Itoa(i),
+			Rates: map[fintypes.Market]fintypes.FeeRate{
+				fintypes.MarketSpot: feeRate(v[0], v[1]),
+				fintypes.MarketPerp: feeRate(v[2], v[3]),
+			},
+		})
+	}
+	return fs
+}
+
+func (ex *Client) GetFeeSchedule() (*fintypes.FeeSchedule, error) {
+	return ex.GetFeeScheduleContext(context.Background())
+}
+
+// spot commission rates of account override the tiers, perp uses the regular tier
+func (ex *Client) GetFeeScheduleContext(ctx context.Context) (*fintypes.FeeSchedule, error) {
+	acc, err := ex.in.NewGetAccountService().Do(ctx)
+	if err != nil {
+		return nil, parseErr(err)
+	}
+	fs := DefaultFeeSchedule()
+	fs.MarketOverrides = map[fintypes.Market]fintypes.FeeRate{
+		fintypes.MarketSpot: {
+			Maker: gdecimal.NewFromInt(acc.MakerCommission).Div(gdecimal.NewFromInt(10000)),
+			Taker: gdecimal.NewFromInt(acc.TakerCommission).Div(gdecimal.NewFromInt(10000)),
+		},
+	}
+	return fs, nil
+}
@@ -0,0 +1,194 @@
+package binance
+
+import (
+	"github.com/foxtrader/gofin/ex/ratelimit"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+币安限频
+1. REQUEST_WEIGHT 按IP计算, 现货/api和/sapi和合约/fapi各自独立
+2. ORDERS 按账户计算
+超限返回429, 继续请求会返回418并封禁IP
+*/
+
+const (
+	ruleSpotWeight    = "spotWeight"
+	ruleSapiWeight    = "sapiWeight"
+	rulePerpWeight    = "perpWeight"
+	ruleSpotOrders    = "spotOrders"
+	ruleSpotOrdersDay = "spotOrdersDay"
+	rulePerpOrders    = "perpOrders"
+	rulePerpOrdersMin = "perpOrdersMin"
+)
+
+// weights of "METHOD path" or "path", default weight is 1
+var (
+	spotWeights = map[string]int{
+		"/api/v3/exchangeInfo":     10,
+		"/api/v3/account":          10,
+		"/api/v3/allOrders":        10,
+		"GET /api/v3/order":        2,
+		"/api/v3/historicalTrades": 5,
+	}
+
+	sapiWeights = map[string]int{
+		"/sapi/v1/margin/account":          10,
+		"/sapi/v1/margin/allOrders":        200,
+		"GET /sapi/v1/margin/order":        10,
+		"/sapi/v1/margin/maxBorrowable":    5,
+		"/sapi/v1/capital/deposit/address": 10,
+	}
+
+	perpWeights = map[string]int{
+		"/fapi/v2/account":          5,
+		"/fapi/v2/balance":          5,
+		"/fapi/v2/positionRisk":     5,
+		"/fapi/v1/allOrders":        5,
+		"/fapi/v1/historicalTrades": 20,
+		"/fapi/v1/aggTrades":        20,
+		"POST /fapi/v1/batchOrders": 5,
+	}
+)
+
+func rateLimitRules(accessKey string, rateLimits map[fintypes.ExApi]time.Duration) []ratelimit.Rule {
+	rules := []ratelimit.Rule{
+		{Name: ruleSpotWeight, Key: "binance/spot/weight", Limit: 1200, Interval: time.Minute},
+		{Name: ruleSapiWeight, Key: "binance/sapi/weight", Limit: 12000, Interval: time.Minute},
+		{Name: rulePerpWeight, Key: "binance/perp/weight", Limit: 2400, Interval: time.Minute},
+		{Name: ruleSpotOrders, Key: "binance/spot/orders/" + accessKey, Limit: 50, Interval: time.Second * 10},
+		{Name: ruleSpotOrdersDay, Key: "binance/spot/orders-day/" + accessKey, Limit: 160000, Interval: gtime.Day},
+		{Name: rulePerpOrders, Key: "binance/perp/orders/" + accessKey, Limit: 300, Interval: time.Second * 10},
+		{Name: rulePerpOrdersMin, Key: "binance/perp/orders-min/" + accessKey, Limit: 1200, Interval: time.Minute},
+	}
+	return append(rules, ratelimit.RulesOf(rateLimits, "binance")...)
+}
+
+func weightOf(weights map[string]int, req *http.Request) int {
+	if w, ok := weights[req.Method+" "+req.URL.Path]; ok {
+		return w
+	}
+	if w, ok := weights[req.URL.Path]; ok {
+		return w
+	}
+	return 1
+}
+
+func queryLimit(req *http.Request, defaultLimit int) int {
+	if n, err := strconv.Atoi(req.URL.Query().Get("limit")); err == nil && n > 0 {
+		return n
+	}
+	return defaultLimit
+}
+
+func exApiCost(cost ratelimit.Cost, path string) ratelimit.Cost {
+	if strings.HasSuffix(path, "/klines") {
+		cost[string(fintypes.ExApiGetKline)] = 1
+	} else if strings.HasSuffix(path, "/historicalTrades") || strings.HasSuffix(path, "/aggTrades") {
+		cost[string(fintypes.ExApiGetFill)] = 1
+	}
+	return cost
+}
+
+// weights of spot and margin requests
+func spotCost(req *http.Request) ratelimit.Cost {
+	path := req.URL.Path
+	hasSymbol := req.URL.Query().Get("symbol") != ""
+	cost := ratelimit.Cost{}
+
+	if strings.HasPrefix(path, "/sapi/") {
+		w := weightOf(sapiWeights, req)
+		if path == "/sapi/v1/margin/openOrders" && !hasSymbol {
+			w = 10
+		}
+		cost[ruleSapiWeight] = w
+		if req.Method == http.MethodPost && path == "/sapi/v1/margin/order" {
+			cost[ruleSpotOrders], cost[ruleSpotOrdersDay] = 1, 1
+		} else if req.Method == http.MethodPost && path == "/sapi/v1/margin/order/oco" {
+			cost[ruleSpotOrders], cost[ruleSpotOrdersDay] = 2, 2 // OCO is counted as 2 orders
+		}
+		return exApiCost(cost, path)
+	}
+
+	w := weightOf(spotWeights, req)
+	switch path {
+	case "/api/v3/depth":
+		if n := queryLimit(req, 100); n <= 100 {
+			w = 1
+		} else if n <= 500 {
+			w = 5
+		} else if n <= 1000 {
+			w = 10
+		} else {
+			w = 50
+		}
+	case "/api/v3/ticker/price":
+		if !hasSymbol {
+			w = 2
+		}
+	case "/api/v3/openOrders":
+		if hasSymbol {
+			w = 3
+		} else {
+			w = 40
+		}
+	}
+	cost[ruleSpotWeight] = w
+	if req.Method == http.MethodPost && path == "/api/v3/order" {
+		cost[ruleSpotOrders], cost[ruleSpotOrdersDay] = 1, 1
+	} else if req.Method == http.MethodPost && path == "/api/v3/order/oco" {
+		cost[ruleSpotOrders], cost[ruleSpotOrdersDay] = 2, 2 // OCO is counted as 2 orders
+	}
+	return exApiCost(cost, path)
+}
+
+// weights of futures requests
+func perpCost(req *http.Request) ratelimit.Cost {
+	path := req.URL.Path
+	hasSymbol := req.URL.Query().Get("symbol") != ""
+	cost := ratelimit.Cost{}
+
+	w := weightOf(perpWeights, req)
+	switch path {
+	case "/fapi/v1/depth":
+		if n := queryLimit(req, 500); n <= 50 {
+			w = 2
+		} else if n <= 100 {
+			w = 5
+		} else if n <= 500 {
+			w = 10
+		} else {
+			w = 20
+		}
+	case "/fapi/v1/klines", "/fapi/v1/markPriceKlines", "/fapi/v1/indexPriceKlines":
+		if n := queryLimit(req, 500); n < 100 {
+			w = 1
+		} else if n < 500 {
+			w = 2
+		} else if n <= 1000 {
+			w = 5
+		} else {
+			w = 10
+		}
+	case "/fapi/v1/ticker/price":
+		if !hasSymbol {
+			w = 2
+		}
+	case "/fapi/v1/openOrders":
+		if !hasSymbol {
+			w = 40
+		}
+	}
+	cost[rulePerpWeight] = w
+	if req.Method == http.MethodPost && path == "/fapi/v1/order" {
+		cost[rulePerpOrders], cost[rulePerpOrdersMin] = 1, 1
+	} else if req.Method == http.MethodPost && path == "/fapi/v1/batchOrders" {
+		cost[rulePerpOrders], cost[rulePerpOrdersMin] = perpBatchTradeSize, perpBatchTradeSize // counted as full batch, size is in body
+	}
+	return exApiCost(cost, path)
+}
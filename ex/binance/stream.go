@@ -0,0 +1,645 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/adshao/go-binance"
+	"github.com/adshao/go-binance/futures"
+	"github.com/foxtrader/gofin/ex/stream"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	streamBufferSize = 1024
+
+	// binance closes listen key which is not kept alive in 60 minutes
+	userStreamKeepalive = time.Minute * 30
+
+	// levels of partial depth stream, valid values are 5, 10, 20
+	streamDepthLevels = 20
+
+	// limit of depth snapshot which seeds local depth, max limit of futures is 1000
+	snapshotDepthLimit = 1000
+)
+
+// raw stream urls, listen key or stream name should be appended
+var wsBaseUrls = map[fintypes.Market]string{
+	fintypes.MarketSpot: "wss://stream.binance.com:9443/ws/",
+	fintypes.MarketPerp: "wss://fstream.binance.com/ws/",
+}
+
+type (
+	wsExecutionReport struct {
+		Event        string `json:"e"`
+		Symbol       string `json:"s"`
+		ClientId     string `json:"c"`
+		OrigClientId string `json:"C"` // original client id of canceled order, "c" is client id of cancel request then
+		Side         string `json:"S"`
+		Type         string `json:"o"`
+		TimeInForce  string `json:"f"`
+		Quantity     string `json:"q"`
+		Price        string `json:"p"`
+		StopPrice    string `json:"P"`
+		Status       string `json:"X"`
+		OrderId      int64  `json:"i"`
+		FilledQty    string `json:"z"`
+		FilledQuote  string `json:"Z"`
+		CreateTime   int64  `json:"O"`
+	}
+
+	wsAccountPosition struct {
+		Event    string `json:"e"`
+		Balances []struct {
+			Asset  string `json:"a"`
+			Free   string `json:"f"`
+			Locked string `json:"l"`
+		} `json:"B"`
+	}
+
+	wsPerpOrderUpdate struct {
+		Event string `json:"e"`
+		Order struct {
+			Symbol      string `json:"s"`
+			ClientId    string `json:"c"`
+			Side        string `json:"S"`
+			Type        string `json:"o"`
+			TimeInForce string `json:"f"`
+			Quantity    string `json:"q"`
+			Price       string `json:"p"`
+			AvgPrice    string `json:"ap"`
+			StopPrice   string `json:"sp"`
+			Status      string `json:"X"`
+			OrderId     int64  `json:"i"`
+			FilledQty   string `json:"z"`
+			UpdateTime  int64  `json:"T"`
+		} `json:"o"`
+	}
+
+	// spot and futures diff depth event, PrevLastUpdateId is futures only
+	wsDepthDiff struct {
+		Event            string      `json:"e"`
+		Time             int64       `json:"E"`
+		FirstUpdateId    int64       `json:"U"`
+		LastUpdateId     int64       `json:"u"`
+		PrevLastUpdateId int64       `json:"pu"`
+		Bids             [][2]string `json:"b"`
+		Asks             [][2]string `json:"a"`
+	}
+
+	wsPerpAccountUpdate struct {
+		Event   string `json:"e"`
+		Account struct {
+			Balances []struct {
+				Asset         string `json:"a"`
+				WalletBalance string `json:"wb"`
+				CrossWallet   string `json:"cw"`
+			} `json:"B"`
+		} `json:"a"`
+	}
+)
+
+func parseWsBar(t int64, o, h, l, c, v string) (fintypes.Bar, error) {
+	var err error
+	bar := fintypes.Bar{T: gtime.EpochMillisToTime(t)}
+	for _, kv := range []struct {
+		dst *gdecimal.Decimal
+		src string
+	}{{&bar.O, o}, {&bar.H, h}, {&bar.L, l}, {&bar.C, c}, {&bar.V, v}} {
+		if *kv.dst, err = gdecimal.NewFromString(kv.src); err != nil {
+			return fintypes.Bar{}, err
+		}
+	}
+	return bar, nil
+}
+
+func parseWsOrderBook(price, quantity string) (fintypes.OrderBook, error) {
+	p, err := gdecimal.NewFromString(price)
+	if err != nil {
+		return fintypes.OrderBook{}, err
+	}
+	q, err := gdecimal.NewFromString(quantity)
+	if err != nil {
+		return fintypes.OrderBook{}, err
+	}
+	return fintypes.OrderBook{Price: p, Amount: q}, nil
+}
+
+func parseWsDepthDiff(msg []byte) (*fintypes.DepthDiff, error) {
+	var event wsDepthDiff
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return nil, err
+	}
+	if event.Event != "depthUpdate" {
+		return nil, gerror.Errorf("unknown depth event(%s)", string(msg))
+	}
+	diff := &fintypes.DepthDiff{
+		Time:             gtime.EpochMillisToTime(event.Time),
+		FirstUpdateId:    event.FirstUpdateId,
+		LastUpdateId:     event.LastUpdateId,
+		PrevLastUpdateId: event.PrevLastUpdateId,
+	}
+	for _, v := range event.Bids {
+		ob, err := parseWsOrderBook(v[0], v[1])
+		if err != nil {
+			return nil, err
+		}
+		diff.Buys = append(diff.Buys, ob)
+	}
+	for _, v := range event.Asks {
+		ob, err := parseWsOrderBook(v[0], v[1])
+		if err != nil {
+			return nil, err
+		}
+		diff.Sells = append(diff.Sells, ob)
+	}
+	return diff, nil
+}
+
+// subscribe closed bars
+func (ex *Client) SubKline(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period) (<-chan fintypes.Bar, <-chan error, error) {
+	if err := target.Verify(); err != nil {
+		return nil, nil, err
+	}
+	interval, err := period.CustomFormat(ex.Property())
+	if err != nil {
+		return nil, nil, err
+	}
+	symbol := target.CustomFormat(ex.Property())
+	retC := make(chan fintypes.Bar, streamBufferSize)
+	errC := make(chan error, streamBufferSize)
+	errHandler := func(err error) { stream.SendErr(errC, err) }
+	send := func(bar fintypes.Bar, err error) {
+		if err != nil {
+			errHandler(err)
+			return
+		}
+		select {
+		case retC <- bar:
+		case <-ctx.Done():
+		}
+	}
+
+	var dial stream.DialFunc
+	switch market {
+	case fintypes.MarketSpot:
+		dial = func() (chan struct{}, chan struct{}, error) {
+			return binance.WsKlineServe(symbol, interval, func(event *binance.WsKlineEvent) {
+				if event.Kline.IsFinal {
+					send(parseWsBar(event.Kline.StartTime, event.Kline.Open, event.Kline.High, event.Kline.Low, event.Kline.Close, event.Kline.Volume))
+				}
+			}, errHandler)
+		}
+	case fintypes.MarketPerp:
+		dial = func() (chan struct{}, chan struct{}, error) {
+			return futures.WsKlineServe(symbol, interval, func(event *futures.WsKlineEvent) {
+				if event.Kline.IsFinal {
+					send(parseWsBar(event.Kline.StartTime, event.Kline.Open, event.Kline.High, event.Kline.Low, event.Kline.Close, event.Kline.Volume))
+				}
+			}, errHandler)
+		}
+	default:
+		return nil, nil, gerror.Errorf("unsupported Market(%s)", market)
+	}
+
+	if err := stream.Keep(ctx, dial, errC, func() { close(retC) }); err != nil {
+		return nil, nil, err
+	}
+	return retC, errC, nil
+}
+
+// subscribe top 20 order books
+func (ex *Client) SubDepth(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.Depth, <-chan error, error) {
+	if err := target.Verify(); err != nil {
+		return nil, nil, err
+	}
+	symbol := target.CustomFormat(ex.Property())
+	retC := make(chan fintypes.Depth, streamBufferSize)
+	errC := make(chan error, streamBufferSize)
+	errHandler := func(err error) { stream.SendErr(errC, err) }
+	send := func(dp fintypes.Depth) {
+		select {
+		case retC <- dp:
+		case <-ctx.Done():
+		}
+	}
+
+	var dial stream.DialFunc
+	switch market {
+	case fintypes.MarketSpot:
+		dial = func() (chan struct{}, chan struct{}, error) {
+			return binance.WsPartialDepthServe(symbol, strconv.Itoa(streamDepthLevels), func(event *binance.WsPartialDepthEvent) {
+				dp := fintypes.Depth{Time: ex.property.Clock.Now()}
+				for _, v := range event.Bids {
+					ob, err := parseWsOrderBook(v.Price, v.Quantity)
+					if err != nil {
+						errHandler(err)
+						return
+					}
+					dp.Buys = append(dp.Buys, ob)
+				}
+				for _, v := range event.Asks {
+					ob, err := parseWsOrderBook(v.Price, v.Quantity)
+					if err != nil {
+						errHandler(err)
+						return
+					}
+					dp.Sells = append(dp.Sells, ob)
+				}
+				send(dp)
+			}, errHandler)
+		}
+	case fintypes.MarketPerp:
+		dial = func() (chan struct{}, chan struct{}, error) {
+			return futures.WsPartialDepthServe(symbol, streamDepthLevels, func(event *futures.WsDepthEvent) {
+				dp := fintypes.Depth{Time: gtime.EpochMillisToTime(event.Time)}
+				for _, v := range event.Bids {
+					ob, err := parseWsOrderBook(v.Price, v.Quantity)
+					if err != nil {
+						errHandler(err)
+						return
+					}
+					dp.Buys = append(dp.Buys, ob)
+				}
+				for _, v := range event.Asks {
+					ob, err := parseWsOrderBook(v.Price, v.Quantity)
+					if err != nil {
+						errHandler(err)
+						return
+					}
+					dp.Sells = append(dp.Sells, ob)
+				}
+				send(dp)
+			}, errHandler)
+		}
+	default:
+		return nil, nil, gerror.Errorf("unsupported Market(%s)", market)
+	}
+
+	if err := stream.Keep(ctx, dial, errC, func() { close(retC) }); err != nil {
+		return nil, nil, err
+	}
+	return retC, errC, nil
+}
+
+// subscribe incremental depth updates every 100ms, use ex.SubLocalDepth to maintain local order books
+func (ex *Client) SubDepthDiff(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.DepthDiff, <-chan error, error) {
+	if err := target.Verify(); err != nil {
+		return nil, nil, err
+	}
+	if _, ok := ex.wsHosts[market]; !ok {
+		return nil, nil, gerror.Errorf("unsupported Market(%s)", market)
+	}
+	url := ex.wsHosts[market] + strings.ToLower(target.CustomFormat(ex.Property())) + "@depth@100ms"
+	retC := make(chan fintypes.DepthDiff, streamBufferSize)
+	errC := make(chan error, streamBufferSize)
+	errHandler := func(err error) { stream.SendErr(errC, err) }
+
+	dial := func() (chan struct{}, chan struct{}, error) {
+		return stream.Serve(url, nil, func(msg []byte) {
+			diff, err := parseWsDepthDiff(msg)
+			if err != nil {
+				errHandler(err)
+				return
+			}
+			select {
+			case retC <- *diff:
+			case <-ctx.Done():
+			}
+		}, errHandler)
+	}
+
+	if err := stream.Keep(ctx, dial, errC, func() { close(retC) }); err != nil {
+		return nil, nil, err
+	}
+	return retC, errC, nil
+}
+
+// subscribe 24 hours rolling tick, spot only
+func (ex *Client) SubTick(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.Tick, <-chan error, error) {
+	if err := target.Verify(); err != nil {
+		return nil, nil, err
+	}
+	if market != fintypes.MarketSpot {
+		return nil, nil, gerror.Errorf("unsupported Market(%s)", market)
+	}
+	symbol := target.CustomFormat(ex.Property())
+	retC := make(chan fintypes.Tick, streamBufferSize)
+	errC := make(chan error, streamBufferSize)
+	errHandler := func(err error) { stream.SendErr(errC, err) }
+
+	dial := func() (chan struct{}, chan struct{}, error) {
+		return binance.WsMarketStatServe(symbol, func(event *binance.WsMarketStatEvent) {
+			var err error
+			tick := fintypes.Tick{Time: gtime.EpochMillisToTime(event.Time)}
+			for _, kv := range []struct {
+				dst *gdecimal.Decimal
+				src string
+			}{{&tick.Last, event.LastPrice}, {&tick.Buy, event.BidPrice}, {&tick.Sell, event.AskPrice}, {&tick.High, event.HighPrice}, {&tick.Low, event.LowPrice}, {&tick.Volume, event.BaseVolume}} {
+				if *kv.dst, err = gdecimal.NewFromString(kv.src); err != nil {
+					errHandler(err)
+					return
+				}
+			}
+			select {
+			case retC <- tick:
+			case <-ctx.Done():
+			}
+		}, errHandler)
+	}
+
+	if err := stream.Keep(ctx, dial, errC, func() { close(retC) }); err != nil {
+		return nil, nil, err
+	}
+	return retC, errC, nil
+}
+
+// subscribe aggregated public trades, side is the same as GetAggFills
+func (ex *Client) SubFills(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.Fill, <-chan error, error) {
+	if err := target.Verify(); err != nil {
+		return nil, nil, err
+	}
+	symbol := target.CustomFormat(ex.Property())
+	retC := make(chan fintypes.Fill, streamBufferSize)
+	errC := make(chan error, streamBufferSize)
+	errHandler := func(err error) { stream.SendErr(errC, err) }
+	send := func(id, t int64, price, quantity string, buyerMaker bool) {
+		var err error
+		item := fintypes.Fill{Id: id, Time: gtime.EpochMillisToTime(t)}
+		if item.Price, err = gdecimal.NewFromString(price); err != nil {
+			errHandler(err)
+			return
+		}
+		if item.UnitQty, err = gdecimal.NewFromString(quantity); err != nil {
+			errHandler(err)
+			return
+		}
+		if buyerMaker {
+			item.Side = "buy"
+		} else {
+			item.Side = "sell"
+		}
+		select {
+		case retC <- item:
+		case <-ctx.Done():
+		}
+	}
+
+	var dial stream.DialFunc
+	switch market {
+	case fintypes.MarketSpot:
+		dial = func() (chan struct{}, chan struct{}, error) {
+			return binance.WsAggTradeServe(symbol, func(event *binance.WsAggTradeEvent) {
+				send(event.AggTradeID, event.TradeTime, event.Price, event.Quantity, event.IsBuyerMaker)
+			}, errHandler)
+		}
+	case fintypes.MarketPerp:
+		dial = func() (chan struct{}, chan struct{}, error) {
+			return futures.WsAggTradeServe(symbol, func(event *futures.WsAggTradeEvent) {
+				send(event.AggregateTradeID, event.TradeTime, event.Price, event.Quantity, event.Maker)
+			}, errHandler)
+		}
+	default:
+		return nil, nil, gerror.Errorf("unsupported Market(%s)", market)
+	}
+
+	if err := stream.Keep(ctx, dial, errC, func() { close(retC) }); err != nil {
+		return nil, nil, err
+	}
+	return retC, errC, nil
+}
+
+// start user data stream, returns listen key and its keepalive function
+func (ex *Client) startUserStream(ctx context.Context, market fintypes.Market, margin fintypes.Margin) (string, func() error, error) {
+	if market == fintypes.MarketSpot && margin == fintypes.MarginNo {
+		key, err := ex.in.NewStartUserStreamService().Do(ctx)
+		return key, func() error { return parseErr(ex.in.NewKeepaliveUserStreamService().ListenKey(key).Do(ctx)) }, parseErr(err)
+	} else if market == fintypes.MarketSpot && margin == fintypes.MarginCross {
+		key, err := ex.in.NewStartMarginUserStreamService().Do(ctx)
+		return key, func() error { return parseErr(ex.in.NewKeepaliveMarginUserStreamService().ListenKey(key).Do(ctx)) }, parseErr(err)
+	} else if market == fintypes.MarketPerp {
+		key, err := ex.inPerp.NewStartUserStreamService().Do(ctx)
+		return key, func() error { return parseErr(ex.inPerp.NewKeepaliveUserStreamService().ListenKey(key).Do(ctx)) }, parseErr(err)
+	}
+	return "", nil, gerror.Errorf("unsupported Market/Margin(%s,%s)", market, margin)
+}
+
+// user data stream, a new listen key is requested on every dial
+func (ex *Client) dialUserData(ctx context.Context, market fintypes.Market, margin fintypes.Margin, handler func(msg []byte), errHandler func(err error)) stream.DialFunc {
+	return func() (chan struct{}, chan struct{}, error) {
+		key, keepalive, err := ex.startUserStream(ctx, market, margin)
+		if err != nil {
+			return nil, nil, err
+		}
+		doneC, stopC, err := stream.Serve(ex.wsHosts[market]+key, nil, handler, errHandler)
+		if err != nil {
+			return nil, nil, err
+		}
+		go func() {
+			ticker := time.NewTicker(userStreamKeepalive)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-doneC:
+					return
+				case <-ticker.C:
+					if err := keepalive(); err != nil {
+						errHandler(err)
+					}
+				}
+			}
+		}()
+		return doneC, stopC, nil
+	}
+}
+
+func (ex *Client) parseWsOrder(market fintypes.Market, margin fintypes.Margin, msg []byte) (*fintypes.Order, error) {
+	if market == fintypes.MarketPerp {
+		var event wsPerpOrderUpdate
+		if err := json.Unmarshal(msg, &event); err != nil {
+			return nil, err
+		}
+		if event.Event != "ORDER_TRADE_UPDATE" {
+			return nil, nil
+		}
+		src := &futures.Order{
+			Symbol:           event.Order.Symbol,
+			OrderID:          event.Order.OrderId,
+			ClientOrderID:    event.Order.ClientId,
+			Price:            event.Order.Price,
+			OrigQuantity:     event.Order.Quantity,
+			ExecutedQuantity: event.Order.FilledQty,
+			Status:           futures.OrderStatusType(event.Order.Status),
+			Type:             futures.OrderType(event.Order.Type),
+			TimeInForce:      futures.TimeInForceType(event.Order.TimeInForce),
+			Side:             futures.SideType(event.Order.Side),
+			StopPrice:        event.Order.StopPrice,
+			Time:             event.Order.UpdateTime,
+		}
+		od, err := ex.binancePerpOrderToApiOrder(market, margin, src)
+		if err != nil {
+			return nil, err
+		}
+		if od.AvgPrice, err = gdecimal.NewFromString(event.Order.AvgPrice); err != nil {
+			return nil, err
+		}
+		return od, nil
+	}
+
+	var event wsExecutionReport
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return nil, err
+	}
+	if event.Event != "executionReport" {
+		return nil, nil
+	}
+	clientId := event.ClientId
+	if event.OrigClientId != "" {
+		clientId = event.OrigClientId
+	}
+	src := &binance.Order{
+		Symbol:                   event.Symbol,
+		OrderID:                  event.OrderId,
+		ClientOrderID:            clientId,
+		Price:                    event.Price,
+		OrigQuantity:             event.Quantity,
+		ExecutedQuantity:         event.FilledQty,
+		CummulativeQuoteQuantity: event.FilledQuote,
+		Status:                   binance.OrderStatusType(event.Status),
+		Type:                     binance.OrderType(event.Type),
+		TimeInForce:              binance.TimeInForceType(event.TimeInForce),
+		Side:                     binance.SideType(event.Side),
+		StopPrice:                event.StopPrice,
+		Time:                     event.CreateTime,
+	}
+	od, err := ex.binanceOrderToApiOrder(market, margin, src)
+	if err != nil {
+		return nil, err
+	}
+	if od.DealAmount.IsPositive() {
+		quote, err := gdecimal.NewFromString(event.FilledQuote)
+		if err != nil {
+			return nil, err
+		}
+		od.AvgPrice = quote.Div(od.DealAmount)
+	}
+	return od, nil
+}
+
+func (ex *Client) parseWsBalances(market fintypes.Market, margin fintypes.Margin, msg []byte) ([]fintypes.Balance, error) {
+	var r []fintypes.Balance
+	newBalance := func(asset, free, locked string) error {
+		b := fintypes.Balance{}
+		b.Market = market
+		b.Margin = margin
+		b.Asset = asset
+		var err error
+		if b.Free, err = gdecimal.NewFromString(free); err != nil {
+			return err
+		}
+		if b.Locked, err = gdecimal.NewFromString(locked); err != nil {
+			return err
+		}
+		b.Borrowed = gdecimal.Zero
+		b.Interest = gdecimal.Zero
+		r = append(r, b)
+		return nil
+	}
+
+	if market == fintypes.MarketPerp {
+		var event wsPerpAccountUpdate
+		if err := json.Unmarshal(msg, &event); err != nil {
+			return nil, err
+		}
+		if event.Event != "ACCOUNT_UPDATE" {
+			return nil, nil
+		}
+		for _, v := range event.Account.Balances {
+			// cross wallet balance is free, the rest of wallet balance is isolated margin
+			wallet, err := gdecimal.NewFromString(v.WalletBalance)
+			if err != nil {
+				return nil, err
+			}
+			cross, err := gdecimal.NewFromString(v.CrossWallet)
+			if err != nil {
+				return nil, err
+			}
+			if err := newBalance(v.Asset, v.CrossWallet, wallet.Sub(cross).String()); err != nil {
+				return nil, err
+			}
+		}
+		return r, nil
+	}
+
+	var event wsAccountPosition
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return nil, err
+	}
+	if event.Event != "outboundAccountPosition" {
+		return nil, nil
+	}
+	for _, v := range event.Balances {
+		if err := newBalance(v.Asset, v.Free, v.Locked); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// subscribe private order updates
+// margin of perp market is unknown in binance user data stream, so it is set by input margin
+func (ex *Client) SubOrders(ctx context.Context, market fintypes.Market, margin fintypes.Margin) (<-chan fintypes.Order, <-chan error, error) {
+	retC := make(chan fintypes.Order, streamBufferSize)
+	errC := make(chan error, streamBufferSize)
+	errHandler := func(err error) { stream.SendErr(errC, err) }
+	handler := func(msg []byte) {
+		od, err := ex.parseWsOrder(market, margin, msg)
+		if err != nil {
+			errHandler(err)
+			return
+		}
+		if od == nil {
+			return // 其他类型的事件
+		}
+		select {
+		case retC <- *od:
+		case <-ctx.Done():
+		}
+	}
+
+	if err := stream.Keep(ctx, ex.dialUserData(ctx, market, margin, handler, errHandler), errC, func() { close(retC) }); err != nil {
+		return nil, nil, err
+	}
+	return retC, errC, nil
+}
+
+// subscribe private balance updates, only changed balances are sent
+func (ex *Client) SubBalances(ctx context.Context, market fintypes.Market, margin fintypes.Margin) (<-chan fintypes.Balance, <-chan error, error) {
+	retC := make(chan fintypes.Balance, streamBufferSize)
+	errC := make(chan error, streamBufferSize)
+	errHandler := func(err error) { stream.SendErr(errC, err) }
+	handler := func(msg []byte) {
+		balances, err := ex.parseWsBalances(market, margin, msg)
+		if err != nil {
+			errHandler(err)
+			return
+		}
+		for _, b := range balances {
+			select {
+			case retC <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	if err := stream.Keep(ctx, ex.dialUserData(ctx, market, margin, handler, errHandler), errC, func() { close(retC) }); err != nil {
+		return nil, nil, err
+	}
+	return retC, errC, nil
+}
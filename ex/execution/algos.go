@@ -0,0 +1,83 @@
+package execution
+
+import (
+	"github.com/foxtrader/gofin/ex"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"time"
+)
+
+// scheduled algo of slices at offsets, amounts are weights of every slice
+func newScheduled(e ex.Ex, parent fintypes.TradeRequest, offsets []time.Duration, weights []gdecimal.Decimal) (*Algo, error) {
+	a, err := newAlgo(e, parent)
+	if err != nil {
+		return nil, err
+	}
+	total := gdecimal.Zero
+	for _, w := range weights {
+		total = total.Add(w)
+	}
+	if !total.IsPositive() {
+		return nil, errors.Errorf("sum of slice weights %s should be positive", total.String())
+	}
+	cum := gdecimal.Zero
+	for i, w := range weights {
+		cum = cum.Add(w)
+		target := parent.Amount.Mul(cum).Div(total)
+		if i == len(weights)-1 {
+			target = parent.Amount // no rounding error in the last slice
+		}
+		a.offsets = append(a.offsets, offsets[i])
+		a.targets = append(a.targets, target)
+	}
+	return a, nil
+}
+
+// TWAP，时间加权
+// parent amount is split equally into slices placed evenly in duration, the first slice is placed at start.
+func NewTWAP(e ex.Ex, parent fintypes.TradeRequest, duration time.Duration, slices int) (*Algo, error) {
+	if slices <= 0 || duration < 0 {
+		return nil, errors.Errorf("invalid TWAP duration %s or slices %d", duration, slices)
+	}
+	var offsets []time.Duration
+	var weights []gdecimal.Decimal
+	for i := 0; i < slices; i++ {
+		offsets = append(offsets, duration*time.Duration(i)/time.Duration(slices))
+		weights = append(weights, gdecimal.One)
+	}
+	return newScheduled(e, parent, offsets, weights)
+}
+
+// VWAP，成交量加权
+// bars of profile, like yesterday's klines of the pair, are mapped to equal slices of duration in time order,
+// parent amount is split by volumes of bars.
+func NewVWAP(e ex.Ex, parent fintypes.TradeRequest, duration time.Duration, profile *fintypes.Kline) (*Algo, error) {
+	if profile == nil || profile.Len() == 0 || duration < 0 {
+		return nil, errors.Errorf("invalid VWAP duration %s or empty volume profile", duration)
+	}
+	profile.Sort()
+	volumes := profile.Volumes()
+	var offsets []time.Duration
+	for i := range volumes {
+		offsets = append(offsets, duration*time.Duration(i)/time.Duration(len(volumes)))
+	}
+	return newScheduled(e, parent, offsets, volumes)
+}
+
+// Iceberg，冰山单
+// only visible amount of the parent limit order rests in the order book, the next child is placed when it's filled.
+func NewIceberg(e ex.Ex, parent fintypes.TradeRequest, visible gdecimal.Decimal) (*Algo, error) {
+	if parent.Type != fintypes.OrderTypeLimit {
+		return nil, errors.Errorf("iceberg requires limit order, but %s", parent.Type)
+	}
+	if !visible.IsPositive() {
+		return nil, errors.Errorf("visible amount %s should be positive", visible.String())
+	}
+	a, err := newAlgo(e, parent)
+	if err != nil {
+		return nil, err
+	}
+	a.visible = visible
+	return a, nil
+}
@@ -0,0 +1,362 @@
+package execution
+
+/**
+执行算法，TWAP / VWAP / Iceberg
+
+a parent order (fintypes.TradeRequest of market or limit type) is sliced into child orders placed by ex.TradeIdempotent.
+
+scheduled algos (TWAP and VWAP) have slices of target amount at offsets from start, at every poll the amount due but
+not filled yet is placed as one child order; unfilled limit child is canceled when the next slice is due, and its
+remaining amount is placed again with the new slice.
+iceberg keeps one limit child of the visible amount resting in the order book, the next one is placed when it's filled.
+
+amount of child orders is rounded down by PairInfo.NormalizeOrder, amount below UnitMin or MinNotional waits for the next
+slice, the algo is done when nothing can be placed after the last slice, so dust may be left unfilled.
+fills are tracked by GetOrder, paused time doesn't count in schedule, open child is canceled when paused or canceled.
+*/
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/ex"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"sync"
+	"time"
+)
+
+type (
+	Status string
+
+	Algo struct {
+		PollInterval time.Duration // interval of placing and querying child orders, DefaultPollInterval if zero
+		TradeRetries int           // retries of ex.TradeIdempotent when placing result is unknown
+
+		e       ex.Ex
+		clock   gtime.Clock
+		parent  fintypes.TradeRequest
+		offsets []time.Duration    // scheduled algos only, offset of every slice from start
+		targets []gdecimal.Decimal // scheduled algos only, cumulative amount to fill by every slice
+		visible gdecimal.Decimal   // iceberg only
+		info    fintypes.PairInfo
+
+		mu          sync.Mutex
+		status      Status
+		err         error
+		start       time.Time
+		pausedAt    time.Time
+		pausedTotal time.Duration
+		dealAmount  gdecimal.Decimal
+		dealQuote   gdecimal.Decimal
+		orders      []fintypes.Order // finished child orders
+		open        *fintypes.Order  // unfinished child order
+		openSlices  int              // slices due when open child was placed
+		canceling   bool             // open child is being canceled
+	}
+)
+
+const (
+	StatusPending  Status = "pending"
+	StatusRunning  Status = "running"
+	StatusPaused   Status = "paused"
+	StatusCanceled Status = "canceled"
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+)
+
+var DefaultPollInterval = time.Second
+
+func newAlgo(e ex.Ex, parent fintypes.TradeRequest) (*Algo, error) {
+	if e == nil {
+		return nil, errors.Errorf("nil exchange")
+	}
+	if err := parent.Verify(); err != nil {
+		return nil, err
+	}
+	if parent.Type != fintypes.OrderTypeMarket && parent.Type != fintypes.OrderTypeLimit {
+		return nil, errors.Errorf("OrderType(%s) of parent order is not supported, market or limit expected", parent.Type)
+	}
+	parent.ClientId = ""
+	clock := e.Property().Clock
+	if clock == nil {
+		clock = gtime.GetSysClock()
+	}
+	return &Algo{e: e, clock: clock, parent: parent, status: StatusPending, TradeRetries: 2}, nil
+}
+
+func (a *Algo) Status() Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.status
+}
+
+// error of failed algo
+func (a *Algo) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// filled amount and average price of all child orders
+func (a *Algo) Deal() (amount, avgPrice gdecimal.Decimal) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.dealAmount.IsPositive() {
+		avgPrice = a.dealQuote.Div(a.dealAmount)
+	}
+	return a.dealAmount, avgPrice
+}
+
+// child orders, the unfinished one is the last
+func (a *Algo) Orders() []fintypes.Order {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r := append([]fintypes.Order{}, a.orders...)
+	if a.open != nil {
+		r = append(r, *a.open)
+	}
+	return r
+}
+
+// stops placing child orders and cancels the open one, paused time doesn't count in schedule
+func (a *Algo) Pause() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status != StatusRunning {
+		return errors.Errorf("can't pause %s algo", a.status)
+	}
+	a.status = StatusPaused
+	a.pausedAt = a.clock.Now()
+	return nil
+}
+
+func (a *Algo) Resume() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status != StatusPaused {
+		return errors.Errorf("can't resume %s algo", a.status)
+	}
+	a.status = StatusRunning
+	a.pausedTotal += a.clock.Now().Sub(a.pausedAt)
+	return nil
+}
+
+// cancels the open child order and stops, Run returns after the open child is finished
+func (a *Algo) Cancel() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status == StatusPending || a.status == StatusRunning || a.status == StatusPaused {
+		a.status = StatusCanceled
+	}
+}
+
+// Run executes the algo until done, canceled or failed, it blocks, so call Pause, Resume and Cancel in other goroutines.
+// errors of placing child orders fail the algo, errors of querying them are retried at next poll.
+// if ctx is done, open child is canceled and ctx.Err() is returned.
+func (a *Algo) Run(ctx context.Context) error {
+	a.mu.Lock()
+	if a.status != StatusPending {
+		a.mu.Unlock()
+		return errors.Errorf("algo is %s, it can be run only once", a.status)
+	}
+	a.status = StatusRunning
+	a.start = a.clock.Now()
+	a.mu.Unlock()
+
+	if err := a.init(ctx); err != nil {
+		return a.fail(err)
+	}
+	interval := a.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done, err := a.step(ctx)
+		if err != nil {
+			return a.fail(err)
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			a.Cancel()
+			a.cancelOpen(context.Background())
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Algo) init(ctx context.Context) error {
+	mi, err := a.e.GetMarketInfoContext(ctx, true)
+	if err != nil {
+		return err
+	}
+	pm := a.parent.Pair.SetM(a.parent.Market)
+	info, ok := mi.Infos[pm]
+	if !ok {
+		return errors.Wrapf(fintypes.ErrInvalidPair, "PairM(%s) not found in market info", pm.String())
+	}
+	a.info = info
+	if a.visible.IsPositive() {
+		if _, _, _, err := info.NormalizeOrder(a.parent.Type, a.visible, a.parent.Price, gdecimal.Zero); err != nil {
+			return errors.Wrapf(err, "invalid visible amount")
+		}
+	}
+	return nil
+}
+
+func (a *Algo) fail(err error) error {
+	a.cancelOpen(context.Background())
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status = StatusFailed
+	a.err = err
+	return err
+}
+
+// count of slices due, it is always 1 for iceberg
+func (a *Algo) due() int {
+	if len(a.offsets) == 0 {
+		return 1
+	}
+	a.mu.Lock()
+	elapsed := a.clock.Now().Sub(a.start) - a.pausedTotal
+	a.mu.Unlock()
+	n := 0
+	for n < len(a.offsets) && a.offsets[n] <= elapsed {
+		n++
+	}
+	return n
+}
+
+// cumulative amount to fill by due slices
+func (a *Algo) target(due int) gdecimal.Decimal {
+	if len(a.targets) == 0 {
+		return a.parent.Amount
+	}
+	if due == 0 {
+		return gdecimal.Zero
+	}
+	return a.targets[due-1]
+}
+
+// updates open child by exchange, it is moved to finished orders if it ends
+func (a *Algo) refresh(ctx context.Context) {
+	a.mu.Lock()
+	open := a.open
+	a.mu.Unlock()
+	if open == nil {
+		return
+	}
+	od, err := a.e.GetOrderContext(ctx, open.Id)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !od.Status.End() {
+		a.open = od
+		return
+	}
+	price := od.AvgPrice
+	if !price.IsPositive() {
+		price = od.Price
+	}
+	a.dealAmount = a.dealAmount.Add(od.DealAmount)
+	a.dealQuote = a.dealQuote.Add(od.DealAmount.Mul(price))
+	a.orders = append(a.orders, *od)
+	a.open = nil
+	a.canceling = false
+}
+
+func (a *Algo) cancelOpen(ctx context.Context) {
+	a.mu.Lock()
+	open := a.open
+	canceling := a.canceling
+	a.canceling = open != nil
+	a.mu.Unlock()
+	if open == nil || canceling {
+		return
+	}
+	// error is ignored, the order may be finished already, its status is refreshed at next poll
+	_ = a.e.CancelOrderContext(ctx, open.Id)
+}
+
+// places or cancels child orders, returns true if the algo is finished
+func (a *Algo) step(ctx context.Context) (bool, error) {
+	a.refresh(ctx)
+	a.mu.Lock()
+	status, open, openSlices := a.status, a.open, a.openSlices
+	remaining := a.parent.Amount.Sub(a.dealAmount)
+	deal := a.dealAmount
+	a.mu.Unlock()
+
+	switch status {
+	case StatusCanceled:
+		a.cancelOpen(ctx)
+		return open == nil, nil
+	case StatusPaused:
+		a.cancelOpen(ctx)
+		return false, nil
+	}
+
+	due := a.due()
+	if open != nil {
+		if len(a.offsets) > 0 && due > openSlices {
+			a.cancelOpen(ctx) // replaced with new slice after it's canceled
+		}
+		return false, nil
+	}
+
+	want := a.target(due).Sub(deal)
+	if a.visible.IsPositive() {
+		want = gdecimal.Min(want, a.visible)
+	}
+	if want.IsPositive() {
+		if amount, _, _, err := a.info.NormalizeOrder(a.parent.Type, want, a.parent.Price, gdecimal.Zero); err == nil {
+			return false, a.place(ctx, amount, due)
+		}
+	}
+
+	// nothing can be placed after the last slice
+	if due >= len(a.offsets) {
+		if _, _, _, err := a.info.NormalizeOrder(a.parent.Type, remaining, a.parent.Price, gdecimal.Zero); err != nil {
+			a.mu.Lock()
+			a.status = StatusDone
+			a.mu.Unlock()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (a *Algo) place(ctx context.Context, amount gdecimal.Decimal, due int) error {
+	req := a.parent
+	req.Amount = amount
+	id, err := ex.TradeIdempotent(ctx, a.e, req, a.TradeRetries)
+	if err != nil {
+		return errors.Wrapf(err, "place child order of %s", amount.String())
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.open = &fintypes.Order{
+		Id:          *id,
+		Time:        a.clock.Now(),
+		Market:      req.Market,
+		Margin:      req.Margin,
+		Pair:        req.Pair,
+		Side:        req.Side,
+		Type:        req.Type,
+		TimeInForce: req.TimeInForce,
+		Status:      fintypes.OrderStatusNew,
+		Price:       req.Price,
+		Amount:      amount,
+	}
+	a.openSlices = due
+	return nil
+}
@@ -0,0 +1,174 @@
+package execution
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/ex"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPair = fintypes.BTC.Against(fintypes.USDT)
+
+// market orders are filled at 100 immediately, limit orders are filled at the first query if fillLimit
+type testEx struct {
+	ex.Ex
+	mu        sync.Mutex
+	fillLimit bool
+	orders    []fintypes.Order
+}
+
+func (e *testEx) Property() *fintypes.ExProperty {
+	return &fintypes.ExProperty{Name: fintypes.Binance}
+}
+
+func (e *testEx) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	info := fintypes.PairInfo{Enabled: true, UnitStep: gdecimal.NewFromFloat64(0.01), UnitMin: gdecimal.NewFromFloat64(0.01)}
+	return &fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{testPair.SetM(fintypes.MarketSpot): info}}, nil
+}
+
+func (e *testEx) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	od := fintypes.Order{Id: fintypes.NewOrderId(req.Market, req.Margin, req.Pair, strconv.Itoa(len(e.orders))), Type: req.Type, Status: fintypes.OrderStatusNew, Price: req.Price, Amount: req.Amount}
+	if req.Type == fintypes.OrderTypeMarket {
+		od.Status, od.DealAmount, od.AvgPrice = fintypes.OrderStatusFilled, req.Amount, gdecimal.NewFromInt(100)
+	}
+	e.orders = append(e.orders, od)
+	return &od.Id, nil
+}
+
+func (e *testEx) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i, _ := strconv.Atoi(id.StrId())
+	if e.fillLimit && e.orders[i].Status == fintypes.OrderStatusNew {
+		e.orders[i].Status, e.orders[i].DealAmount = fintypes.OrderStatusFilled, e.orders[i].Amount
+	}
+	od := e.orders[i]
+	return &od, nil
+}
+
+func (e *testEx) CancelOrderContext(ctx context.Context, id fintypes.OrderId) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i, _ := strconv.Atoi(id.StrId())
+	if e.orders[i].Status.End() {
+		return fintypes.ErrOrderNotFound
+	}
+	e.orders[i].Status = fintypes.OrderStatusCanceled
+	return nil
+}
+
+func (e *testEx) snapshot() []fintypes.Order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]fintypes.Order{}, e.orders...)
+}
+
+func testParent(orderType fintypes.OrderType) fintypes.TradeRequest {
+	r := fintypes.TradeRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: testPair, Side: fintypes.OrderSideBuyLong, Type: orderType, Amount: gdecimal.One}
+	if orderType == fintypes.OrderTypeLimit {
+		r.Price = gdecimal.NewFromInt(90)
+	}
+	return r
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for start := time.Now(); !cond(); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			gtest.PrintlnExit(t, "timeout waiting for %s", what)
+		}
+	}
+}
+
+func TestTWAP(t *testing.T) {
+	e := &testEx{}
+	a, err := NewTWAP(e, testParent(fintypes.OrderTypeMarket), 200*time.Millisecond, 4)
+	gtest.Assert(t, err)
+	a.PollInterval = time.Millisecond
+	gtest.Assert(t, a.Run(context.Background()))
+
+	orders := e.snapshot()
+	if a.Status() != StatusDone || len(orders) != 4 {
+		t.Errorf("4 child orders expected, but %d, status %s", len(orders), a.Status())
+	}
+	for _, od := range orders {
+		if !od.Amount.Equal(gdecimal.NewFromFloat64(0.25)) {
+			t.Errorf("child amount should be 0.25, but %s", od.Amount)
+		}
+	}
+	amount, price := a.Deal()
+	if !amount.Equal(gdecimal.One) || !price.Equal(gdecimal.NewFromInt(100)) {
+		t.Errorf("1 should be filled at 100, but %s at %s", amount, price)
+	}
+}
+
+func TestVWAP(t *testing.T) {
+	profile := &fintypes.Kline{}
+	for i, v := range []int64{1, 3} {
+		profile.Items = append(profile.Items, fintypes.Bar{T: time.Unix(int64(i)*60, 0), V: gdecimal.NewFromInt(v)})
+	}
+	e := &testEx{}
+	a, err := NewVWAP(e, testParent(fintypes.OrderTypeMarket), 100*time.Millisecond, profile)
+	gtest.Assert(t, err)
+	a.PollInterval = time.Millisecond
+	gtest.Assert(t, a.Run(context.Background()))
+
+	orders := e.snapshot()
+	if len(orders) != 2 || !orders[0].Amount.Equal(gdecimal.NewFromFloat64(0.25)) || !orders[1].Amount.Equal(gdecimal.NewFromFloat64(0.75)) {
+		t.Errorf("0.25 + 0.75 expected, but %v", orders)
+	}
+}
+
+func TestIceberg(t *testing.T) {
+	e := &testEx{fillLimit: true}
+	a, err := NewIceberg(e, testParent(fintypes.OrderTypeLimit), gdecimal.NewFromFloat64(0.3))
+	gtest.Assert(t, err)
+	a.PollInterval = time.Millisecond
+	gtest.Assert(t, a.Run(context.Background()))
+
+	orders := e.snapshot()
+	if len(orders) != 4 || !orders[3].Amount.Equal(gdecimal.NewFromFloat64(0.1)) {
+		t.Errorf("0.3 * 3 + 0.1 expected, but %v", orders)
+	}
+	if amount, price := a.Deal(); !amount.Equal(gdecimal.One) || !price.Equal(gdecimal.NewFromInt(90)) {
+		t.Errorf("1 should be filled at 90, but %s at %s", amount, price)
+	}
+}
+
+func TestAlgo_PauseResumeCancel(t *testing.T) {
+	e := &testEx{}
+	a, err := NewTWAP(e, testParent(fintypes.OrderTypeLimit), time.Hour, 2)
+	gtest.Assert(t, err)
+	a.PollInterval = time.Millisecond
+	errs := make(chan error)
+	go func() { errs <- a.Run(context.Background()) }()
+
+	waitFor(t, "the first child", func() bool { return len(e.snapshot()) == 1 })
+	gtest.Assert(t, a.Pause())
+	waitFor(t, "canceling the first child", func() bool { return len(a.Orders()) == 1 && a.Orders()[0].Status == fintypes.OrderStatusCanceled })
+	gtest.Assert(t, a.Resume())
+	waitFor(t, "the child placed again", func() bool { return len(e.snapshot()) == 2 })
+	if od := e.snapshot()[1]; !od.Amount.Equal(gdecimal.NewFromFloat64(0.5)) {
+		t.Errorf("0.5 should be placed again, but %s", od.Amount)
+	}
+
+	a.Cancel()
+	gtest.Assert(t, <-errs)
+	if a.Status() != StatusCanceled {
+		t.Errorf("algo should be canceled, but %s", a.Status())
+	}
+	for _, od := range e.snapshot() {
+		if od.Status != fintypes.OrderStatusCanceled {
+			t.Errorf("child %s should be canceled, but %s", od.Id, od.Status)
+		}
+	}
+	if err := a.Resume(); err == nil {
+		t.Errorf("canceled algo should not be resumed")
+	}
+}
@@ -9,71 +9,164 @@ type (
 )
 
 const (
-	apiUrlBase                   hbApiUrl = "base"
-	apiUrlMarketInfo             hbApiUrl = "market-info"
-	apiUrlAccountIds             hbApiUrl = "accounts-info"
-	apiUrlAccountBalance         hbApiUrl = "account-balance"
-	apiUrlDepth                  hbApiUrl = "depth"
-	apiUrlTicks                  hbApiUrl = "ticks"
-	apiUrlKline                  hbApiUrl = "kline"
-	apiUrlFills                  hbApiUrl = "fills"
-	apiUrlMMarginBorrowable      hbApiUrl = "margin-borrowable"
-	apiUrlMCrossMarginBorrowable hbApiUrl = "cross-margin-borrowable"
-	apiUrlMMarginBorrow          hbApiUrl = "mmargin-borrow"
-	apiUrlMCrossMarginBorrow     hbApiUrl = "mcross-margin-borrow"
-	apiUrlMMarginRepay           hbApiUrl = "mmargin-repay"
-	apiUrlMCrossMarginRepay      hbApiUrl = "mcross-margin-repay"
-	apiUrlTransferIn             hbApiUrl = "transfer-in"
-	apiUrlTransferOut            hbApiUrl = "transfer-out"
-	apiUrlTrade                  hbApiUrl = "trade"
-	apiUrlOrders                 hbApiUrl = "orders"
-	apiUrlOpenOrders             hbApiUrl = "open-orders"
-	apiUrlOrder                  hbApiUrl = "order"
-	apiUrlCancelOrder            hbApiUrl = "cancel-order"
+	apiUrlMarketInfo       hbApiUrl = "market-info"
+	apiUrlAccountIds       hbApiUrl = "accounts-info"
+	apiUrlAccountBalance   hbApiUrl = "account-balance"
+	apiUrlCrossAccount     hbApiUrl = "cross-account-balance"
+	apiUrlDepth            hbApiUrl = "depth"
+	apiUrlTicks            hbApiUrl = "ticks"
+	apiUrlTick             hbApiUrl = "tick"
+	apiUrlKline            hbApiUrl = "kline"
+	apiUrlFills            hbApiUrl = "fills"
+	apiUrlBorrowable       hbApiUrl = "borrowable"
+	apiUrlBorrow           hbApiUrl = "borrow"
+	apiUrlRepay            hbApiUrl = "repay"
+	apiUrlTransferIn       hbApiUrl = "transfer-in"
+	apiUrlTransferOut      hbApiUrl = "transfer-out"
+	apiUrlCrossTransferIn  hbApiUrl = "cross-transfer-in"
+	apiUrlCrossTransferOut hbApiUrl = "cross-transfer-out"
+	apiUrlTransferContract hbApiUrl = "transfer-contract"
+	apiUrlTrade            hbApiUrl = "trade"
+	apiUrlCrossTrade       hbApiUrl = "cross-trade"
+	apiUrlOrders           hbApiUrl = "orders"
+	apiUrlCrossOrders      hbApiUrl = "cross-orders"
+	apiUrlOpenOrders       hbApiUrl = "open-orders"
+	apiUrlCrossOpenOrders  hbApiUrl = "cross-open-orders"
+	apiUrlOrder            hbApiUrl = "order"
+	apiUrlCrossOrder       hbApiUrl = "cross-order"
+	apiUrlCancelOrder      hbApiUrl = "cancel-order"
+	apiUrlClientOrder      hbApiUrl = "client-order"
+	apiUrlClientCancel     hbApiUrl = "client-cancel"
+	apiUrlCrossCancelOrder hbApiUrl = "cross-cancel-order"
+	apiUrlBatchTrade       hbApiUrl = "batch-trade"
+	apiUrlCrossBatchTrade  hbApiUrl = "cross-batch-trade"
+	apiUrlBatchCancel      hbApiUrl = "batch-cancel"
+	apiUrlCancelAll        hbApiUrl = "cancel-all"
+	apiUrlCrossCancelAll   hbApiUrl = "cross-cancel-all"
+	apiUrlDepositAddress   hbApiUrl = "deposit-address"
+	apiUrlWithdraw         hbApiUrl = "withdraw"
+	apiUrlWalletHistory    hbApiUrl = "wallet-history"
 )
 
+// spot and margin share the same host, perp is USDT margined swap (linear swap)
+var apiHosts = map[fintypes.Market]string{
+	fintypes.MarketSpot: "https://api.huobi.pro",
+	fintypes.MarketPerp: "https://api.hbdm.com",
+}
+
+// the prefix 'cross' means cross margin api, otherwise it is isolated margin api in perp market
 var apiPathMap = map[fintypes.Market]map[hbApiUrl]string{
 	fintypes.MarketSpot: {
-		apiUrlBase:                   "https://api.huobi.pro",
-		apiUrlMarketInfo:             "/v1/common/symbols",
-		apiUrlAccountIds:             "/v1/account/accounts",
-		apiUrlAccountBalance:         "/v1/account/accounts/%s/balance",
-		apiUrlDepth:                  "/market/depth?symbol=%s&type=step0&depth=%d",
-		apiUrlKline:                  "/market/history/kline?period=%s&size=%d&symbol=%s",
-		apiUrlFills:                  "/market/history/trade?symbol=%s&size=%d",
-		apiUrlMMarginBorrowable:      "/v1/margin/loan-info",
-		apiUrlMCrossMarginBorrowable: "/v1/cross-margin/loan-info",
-		apiUrlMMarginBorrow:          "/v1/margin/orders",
-		apiUrlMCrossMarginBorrow:     "/v1/cross-margin/orders",
-		apiUrlMMarginRepay:           "/v1/margin/orders/%s/repay",
-		apiUrlMCrossMarginRepay:      "/v1/cross-margin/orders/%s/repay",
-		apiUrlTransferIn:             "/v1/dw/transfer-in/margin",
-		apiUrlTransferOut:            "/v1/dw/transfer-out/margin",
-		apiUrlTrade:                  "/v1/order/orders/place",
-		apiUrlOrders:                 "/v1/order/orders",
-		apiUrlCancelOrder:            "/v1/order/orders/%s/submitcancel",
-	},
-	fintypes.MarketFuture: {
-		apiUrlBase:           "https://api.hbdm.com",
-		apiUrlAccountBalance: "/v1/contract_account_info",
-		apiUrlDepth:          "/market/depth?symbol=%s&type=step0",
-		apiUrlKline:          "/market/history/kline?period=%s&size=%d&symbol=%s&from=%d&to=%d",
-		apiUrlFills:          "/market/history/trade?symbol=%s&size=%d",
-		apiUrlTransferIn:     "/v1/futures/transfer",
-		apiUrlOrders:         "/api/v1/contract_order_info",
-		apiUrlOpenOrders:     "/api/v1/contract_openorders",
-		apiUrlCancelOrder:    "/api/v1/contract_cancel",
-		apiUrlOrder:          "/api/v1/contract_order_detail",
+		apiUrlMarketInfo:       "/v1/common/symbols",
+		apiUrlAccountIds:       "/v1/account/accounts",
+		apiUrlAccountBalance:   "/v1/account/accounts/%s/balance",
+		apiUrlDepth:            "/market/depth",
+		apiUrlTicks:            "/market/tickers",
+		apiUrlTick:             "/market/detail/merged",
+		apiUrlKline:            "/market/history/kline",
+		apiUrlFills:            "/market/history/trade",
+		apiUrlBorrowable:       "/v1/cross-margin/loan-info",
+		apiUrlBorrow:           "/v1/cross-margin/orders",
+		apiUrlRepay:            "/v2/account/repayment",
+		apiUrlTransferIn:       "/v1/dw/transfer-in/margin",
+		apiUrlTransferOut:      "/v1/dw/transfer-out/margin",
+		apiUrlCrossTransferIn:  "/v1/cross-margin/transfer-in",
+		apiUrlCrossTransferOut: "/v1/cross-margin/transfer-out",
+		apiUrlTransferContract: "/v2/account/transfer",
+		apiUrlTrade:            "/v1/order/orders/place",
+		apiUrlOrders:           "/v1/order/orders",
+		apiUrlOpenOrders:       "/v1/order/openOrders",
+		apiUrlOrder:            "/v1/order/orders/%s",
+		apiUrlCancelOrder:      "/v1/order/orders/%s/submitcancel",
+		apiUrlClientOrder:      "/v1/order/orders/getClientOrder",
+		apiUrlClientCancel:     "/v1/order/orders/submitCancelClientOrder",
+		apiUrlBatchTrade:       "/v1/order/batch-orders",
+		apiUrlBatchCancel:      "/v1/order/orders/batchcancel",
+		apiUrlDepositAddress:   "/v2/account/deposit/address",
+		apiUrlWithdraw:         "/v1/dw/withdraw/api/create",
+		apiUrlWalletHistory:    "/v1/query/deposit-withdraw",
 	},
 	fintypes.MarketPerp: {
-		apiUrlBase:           "https://swap-vip.btcquant.pro",
-		apiUrlAccountBalance: "swap-api/v1/swap_account_info",
-		apiUrlDepth:          "/swap-ex/market/depth?contract_code=%s&type=step0",
-		apiUrlKline:          "/swap-ex/market/history/kline?period=%s&size=%d&contract_code=%s&from=%d&to=%d",
-		apiUrlFills:          "/swap-ex/market/history/trade?contract_code=%s&size=%d",
-		apiUrlOrders:         "/swap-api/v1/swap_order_info",
-		apiUrlOpenOrders:     "/swap-api/v1/swap_openorders",
-		apiUrlOrder:          "/swap-api/v1/swap_order_detail",
-		apiUrlCancelOrder:    "/swap-api/v1/swap_cancel",
+		apiUrlMarketInfo:       "/linear-swap-api/v1/swap_contract_info",
+		apiUrlAccountBalance:   "/linear-swap-api/v1/swap_account_info",
+		apiUrlCrossAccount:     "/linear-swap-api/v1/swap_cross_account_info",
+		apiUrlDepth:            "/linear-swap-ex/market/depth",
+		apiUrlTicks:            "/linear-swap-ex/market/detail/batch_merged",
+		apiUrlKline:            "/linear-swap-ex/market/history/kline",
+		apiUrlFills:            "/linear-swap-ex/market/history/trade",
+		apiUrlTrade:            "/linear-swap-api/v1/swap_order",
+		apiUrlCrossTrade:       "/linear-swap-api/v1/swap_cross_order",
+		apiUrlOrders:           "/linear-swap-api/v1/swap_hisorders",
+		apiUrlCrossOrders:      "/linear-swap-api/v1/swap_cross_hisorders",
+		apiUrlOpenOrders:       "/linear-swap-api/v1/swap_openorders",
+		apiUrlCrossOpenOrders:  "/linear-swap-api/v1/swap_cross_openorders",
+		apiUrlOrder:            "/linear-swap-api/v1/swap_order_info",
+		apiUrlCrossOrder:       "/linear-swap-api/v1/swap_cross_order_info",
+		apiUrlCancelOrder:      "/linear-swap-api/v1/swap_cancel",
+		apiUrlCrossCancelOrder: "/linear-swap-api/v1/swap_cross_cancel",
+		apiUrlBatchTrade:       "/linear-swap-api/v1/swap_batchorder",
+		apiUrlCrossBatchTrade:  "/linear-swap-api/v1/swap_cross_batchorder",
+		apiUrlCancelAll:        "/linear-swap-api/v1/swap_cancelall",
+		apiUrlCrossCancelAll:   "/linear-swap-api/v1/swap_cross_cancelall",
 	},
 }
+
+// max orders in one batch request
+const (
+	spotBatchTradeSize  = 10
+	spotBatchCancelSize = 50
+	perpBatchSize       = 10
+	walletHistorySize   = 500 // max records of deposit & withdrawal history in one request
+)
+
+// huobi account types
+const (
+	accTypeSpot        = "spot"
+	accTypeMargin      = "margin"       // isolated margin, one account per symbol
+	accTypeSuperMargin = "super-margin" // cross margin
+)
+
+// order source of spot/margin orders
+var orderSources = map[fintypes.Margin]string{
+	fintypes.MarginNo:       "spot-api",
+	fintypes.MarginIsolated: "margin-api",
+	fintypes.MarginCross:    "super-margin-api",
+}
+
+// status of perp orders
+var contractOrderStatus = map[int]fintypes.OrderStatus{
+	1:  fintypes.OrderStatusNew, // ready to submit
+	2:  fintypes.OrderStatusNew, // ready to submit
+	3:  fintypes.OrderStatusNew,
+	4:  fintypes.OrderStatusPartiallyFilled,
+	5:  fintypes.OrderStatusPartiallyCanceled,
+	6:  fintypes.OrderStatusFilled,
+	7:  fintypes.OrderStatusCanceled,
+	11: fintypes.OrderStatusCanceling,
+}
+
+// status of deposits, 'safe' means the deposit is credited and can be withdrawn
+var depositStatus = map[string]fintypes.WalletStatus{
+	"unknown":    fintypes.WalletStatusPending,
+	"confirming": fintypes.WalletStatusPending,
+	"confirmed":  fintypes.WalletStatusSuccess,
+	"safe":       fintypes.WalletStatusSuccess,
+	"orphan":     fintypes.WalletStatusFailed,
+}
+
+// status of withdrawals
+var withdrawStatus = map[string]fintypes.WalletStatus{
+	"verifying":       fintypes.WalletStatusPending,
+	"submitted":       fintypes.WalletStatusPending,
+	"reexamine":       fintypes.WalletStatusPending,
+	"pass":            fintypes.WalletStatusPending,
+	"pre-transfer":    fintypes.WalletStatusPending,
+	"wallet-transfer": fintypes.WalletStatusPending,
+	"confirmed":       fintypes.WalletStatusSuccess,
+	"canceled":        fintypes.WalletStatusCanceled,
+	"failed":          fintypes.WalletStatusFailed,
+	"reject":          fintypes.WalletStatusFailed,
+	"wallet-reject":   fintypes.WalletStatusFailed,
+	"confirm-error":   fintypes.WalletStatusFailed,
+	"repealed":        fintypes.WalletStatusFailed,
+}
@@ -0,0 +1,60 @@
+package huobi
+
+import (
+	"github.com/foxtrader/gofin/fintypes"
+	"strings"
+)
+
+// spot v1 err-code, docs: https://huobiapi.github.io/docs/spot/v1/cn/#5ea2e0cde2-3
+var spotErrorKinds = map[string]error{
+	"api-signature-not-valid":                   fintypes.ErrInvalidApiKey,
+	"api-signature-check-failed":                fintypes.ErrInvalidApiKey,
+	"invalid-access-key":                        fintypes.ErrInvalidApiKey,
+	"login-required":                            fintypes.ErrInvalidApiKey,
+	"too-many-requests":                         fintypes.ErrRateLimited,
+	"api-request-limit":                         fintypes.ErrRateLimited,
+	"account-frozen-balance-insufficient-error": fintypes.ErrInsufficientBalance,
+	"account-balance-insufficient-error":        fintypes.ErrInsufficientBalance,
+	"insufficient-balance":                      fintypes.ErrInsufficientBalance,
+	"order-accountbalance-error":                fintypes.ErrInsufficientBalance,
+	"order-value-min-error":                     fintypes.ErrMinNotional,
+	"order-limitorder-amount-min-error":         fintypes.ErrInvalidOrder,
+	"order-marketorder-amount-min-error":        fintypes.ErrInvalidOrder,
+	"order-orderprice-precision-error":          fintypes.ErrInvalidOrder,
+	"order-orderamount-precision-error":         fintypes.ErrInvalidOrder,
+	"base-record-invalid":                       fintypes.ErrOrderNotFound,
+	"order-orderstate-error":                    fintypes.ErrOrderRejected,
+	"base-symbol-error":                         fintypes.ErrInvalidPair,
+}
+
+// spot v2 code
+var spotV2ErrorKinds = map[int]error{
+	1002: fintypes.ErrInvalidApiKey, // unauthorized
+	1003: fintypes.ErrInvalidApiKey, // invalid signature
+	4000: fintypes.ErrRateLimited,   // too many requests
+}
+
+// contract err_code, docs: https://huobiapi.github.io/docs/usdt_swap/v1/cn/#api-4
+var contractErrorKinds = map[int]error{
+	1003: fintypes.ErrInvalidApiKey,       // verification failed
+	1004: fintypes.ErrInvalidApiKey,       // access key incorrect
+	1032: fintypes.ErrRateLimited,         // access frequency exceeded
+	1047: fintypes.ErrInsufficientBalance, // insufficient margin available
+	1048: fintypes.ErrInsufficientBalance, // insufficient close amount available
+	1061: fintypes.ErrOrderNotFound,       // order doesn't exist
+	1071: fintypes.ErrOrderNotFound,       // order has been canceled or finished
+	1014: fintypes.ErrInvalidPair,         // contract doesn't exist
+	1038: fintypes.ErrInvalidOrder,        // price precision exceeded
+	1050: fintypes.ErrDuplicateOrder,      // customer's order number is repeated
+}
+
+func spotErrorKind(code string) error {
+	if kind, ok := spotErrorKinds[code]; ok {
+		return kind
+	}
+	// other precision and range errors of order params, like order-limitorder-price-max-error
+	if strings.HasPrefix(code, "order-") && (strings.Contains(code, "-min-") || strings.Contains(code, "-max-") || strings.Contains(code, "precision")) {
+		return fintypes.ErrInvalidOrder
+	}
+	return nil
+}
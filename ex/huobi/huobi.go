@@ -1,42 +1,127 @@
 package huobi
 
+/**
+docs:
+spot & margin: https://huobiapi.github.io/docs/spot/v1/cn/
+USDT margined swap: https://huobiapi.github.io/docs/usdt_swap/v1/cn/
+
+NOTE:
+perp market is USDT margined swap (linear swap), pair BTC/USDT in perp market means contract BTC-USDT.
+perp order amount is unit amount like spot, it will be converted to contract volume by contract size, which is UnitStep in PairInfo.
+perp orders are placed with offset 'both', so one-way position mode (单向持仓) is required.
+isolated margin account is per symbol in huobi, it is identified by pair string as CustomSubAccName in Account and as Name in SubAcc.
+loan api of isolated margin is per symbol too, so GetBorrowable/Borrow/Repay support cross margin only.
+amount of spot market buy order is quote amount in huobi, it is converted from unit amount by best ask price.
+*/
+
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"github.com/foxtrader/gofin/ex/ratelimit"
 	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
 	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
 	"github.com/shawnwyckoff/gopkg/net/ghttp"
 	"github.com/shawnwyckoff/gopkg/sys/gtime"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/shawnwyckoff/gopkg/apputil/gparam"
-	"github.com/shawnwyckoff/gopkg/container/gdecimal"
-	"github.com/shawnwyckoff/gopkg/net/ghttputils"
 )
 
-/**
-docs: https://huobiapi.github.io/docs/spot/v1/cn/
-*/
-
-type Client struct {
-	config     *fintypes.ExProperty
-	httpClient *http.Client
-	accessKey  string
-	secretKey  string
-	proxy      string
-}
+type (
+	account struct {
+		id     string
+		margin fintypes.Margin
+		pair   fintypes.Pair // isolated margin only
+	}
+
+	spotOrder struct {
+		Id           number `json:"id"`
+		ClientId     string `json:"client-order-id"`
+		Symbol       string `json:"symbol"`
+		AccountId    number `json:"account-id"`
+		Amount       number `json:"amount"`
+		Price        number `json:"price"`
+		CreatedAt    int64  `json:"created-at"`
+		Type         string `json:"type"`
+		FieldAmount  number `json:"field-amount"`       // order detail & history
+		FieldCash    number `json:"field-cash-amount"`  // order detail & history
+		FieldFees    number `json:"field-fees"`         // order detail & history
+		FilledAmount number `json:"filled-amount"`      // open orders
+		FilledCash   number `json:"filled-cash-amount"` // open orders
+		FilledFees   number `json:"filled-fees"`        // open orders
+		State        string `json:"state"`
+		StopPrice    number `json:"stop-price"`
+	}
+
+	contractOrder struct {
+		ContractCode   string `json:"contract_code"`
+		Volume         number `json:"volume"`
+		Price          number `json:"price"`
+		OrderPriceType string `json:"order_price_type"`
+		Direction      string `json:"direction"`
+		OrderIdStr     string `json:"order_id_str"`
+		ClientOrderId  number `json:"client_order_id"`
+		CreatedAt      int64  `json:"created_at"`  // order detail & open orders
+		CreateDate     int64  `json:"create_date"` // history orders
+		TradeVolume    number `json:"trade_volume"`
+		TradeAvgPrice  number `json:"trade_avg_price"`
+		Fee            number `json:"fee"`
+		Status         int    `json:"status"`
+	}
+
+	// response data of perp cancel and cancel-all api, successes are order ids joined by ','
+	perpCancelResult struct {
+		Errors []struct {
+			OrderId string `json:"order_id"`
+			ErrCode int    `json:"err_code"`
+			ErrMsg  string `json:"err_msg"`
+		} `json:"errors"`
+		Successes string `json:"successes"`
+	}
+
+	// deposit or withdrawal record
+	walletRecord struct {
+		Id         number `json:"id"`
+		Type       string `json:"type"`
+		Currency   string `json:"currency"`
+		Chain      string `json:"chain"`
+		TxHash     string `json:"tx-hash"`
+		Amount     number `json:"amount"`
+		Fee        number `json:"fee"`
+		Address    string `json:"address"`
+		AddressTag string `json:"address-tag"`
+		ClientId   string `json:"client-order-id"` // withdrawal only
+		State      string `json:"state"`
+		CreatedAt  int64  `json:"created-at"`
+	}
+
+	Client struct {
+		property         fintypes.ExProperty
+		httpClient       *http.Client
+		hosts            map[fintypes.Market]string
+		accessKey        string
+		secretKey        string
+		mu               sync.Mutex
+		accounts         []account
+		marketInfoCache  fintypes.MarketInfo
+		marketInfoUpdate time.Time
+	}
+)
 
+// email is required in living trading, but not required in kline spider
 func New(apiKey, secretKey, proxy string, c gtime.Clock, email string) (*Client, error) {
 	hb := &Client{}
-	hb.config = &fintypes.ExProperty{
+	hb.property = fintypes.ExProperty{
 		Name:                   fintypes.Huobi,
 		Email:                  email,
 		MaxDepth:               150,
+		MaxFills:               2000,
 		PairDelimiter:          "",
 		PairDelimiterLeftTail:  nil,
 		PairDelimiterRightHead: nil,
@@ -53,1071 +138,2079 @@ func New(apiKey, secretKey, proxy string, c gtime.Clock, email string) (*Client,
 			fintypes.OrderStatusPartiallyFilled: "partial-filled", fintypes.OrderStatusFilled: "filled",
 			fintypes.OrderStatusPartiallyCanceled: "partial-canceled", fintypes.OrderStatusCanceled: "canceled", fintypes.OrderStatusCanceling: "canceling",
 		},
+		OrderTypes: map[fintypes.OrderType]string{
+			fintypes.OrderTypeLimit: "limit", fintypes.OrderTypeMarket: "market", fintypes.OrderTypeStopLimit: "stop-limit",
+		},
 		RateLimits: map[fintypes.ExApi]time.Duration{
 			fintypes.ExApiGetKline: time.Second / 10,
 			fintypes.ExApiGetFill:  time.Second / 10,
 		},
 		MarketEnabled: map[fintypes.Market]bool{
-			fintypes.MarketSpot:   true,
-			fintypes.MarketFuture: true,
-			fintypes.MarketPerp:   true,
+			fintypes.MarketSpot: true,
+			fintypes.MarketPerp: true,
 		},
-		Clock:          c,
 		IsBackTestEx:   false,
 		TradeBeginTime: time.Date(2017, 10, 1, 20, 20, 20, 20, gtime.TimeZoneAsiaShanghai),
 	}
+	if c == nil {
+		hb.property.Clock = gtime.GetSysClock()
+	} else {
+		hb.property.Clock = c
+	}
 
-	hb.httpClient = http.DefaultClient
-	if err := ghttp.SetProxy(hb.httpClient, proxy); err != nil {
-		return nil, err
+	hb.httpClient = &http.Client{Timeout: time.Minute}
+	if proxy != "" {
+		if err := ghttp.SetProxy(hb.httpClient, proxy); err != nil {
+			return nil, err
+		}
+	}
+	noWait := func() bool { return hb.property.RateLimitNoWait }
+	hb.httpClient = ratelimit.Wrap(hb.httpClient, ratelimit.New(rateLimitRules(apiKey, hb.property.RateLimits)...), requestCost, noWait)
+	hb.hosts = map[fintypes.Market]string{}
+	for market, host := range apiHosts {
+		hb.hosts[market] = host
 	}
 	hb.accessKey = apiKey
 	hb.secretKey = secretKey
-	hb.proxy = proxy
+	hb.marketInfoUpdate = gtime.ZeroTime
 	return hb, nil
 }
 
 // exchange custom settings
-func (hb *Client) Config() *fintypes.ExProperty {
-	return hb.config
+func (hb *Client) Property() *fintypes.ExProperty {
+	return &hb.property
 }
 
-// get all supported pairs, min trade amount
-func (hb *Client) GetMarketInfo() (*fintypes.MarketInfo, error) {
-	res := &fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+// redirect rest api of market to baseUrl, like a local simulator or testnet
+func (hb *Client) SetBaseUrl(market fintypes.Market, baseUrl string) error {
+	if _, ok := apiHosts[market]; !ok {
+		return gerror.Errorf("huobi doesn't support Market(%s)", market)
+	}
+	hb.hosts[market] = strings.TrimSuffix(baseUrl, "/")
+	return nil
+}
 
-	for market, enabled := range hb.config.MarketEnabled {
-		if !enabled {
-			continue
-		}
-		baseUrl, ok := apiPathMap[market][apiUrlBase]
-		if !ok {
-			return nil, gerror.New("apiUrlBase for %s not found", market)
-		}
-		subUrl, ok := apiPathMap[market][apiUrlMarketInfo]
-		if !ok {
-			return nil, gerror.New("apiUrlMarketInfo for %s not found", market)
-		}
-		reqUrl := baseUrl + subUrl
-		respMap, err := ghttp.GetMap(reqUrl, hb.proxy, time.Minute)
-		if err != nil {
-			return nil, err
-		}
-		if respMap["status"].(string) != "ok" {
-			return nil, errors.New(respMap["err-code"].(string))
-		}
+// send requests by rt instead of proxy, like a recording or replaying transport in tests, rate limit is kept
+func (hb *Client) SetTransport(rt http.RoundTripper) {
+	ratelimit.SetBase(hb.httpClient, rt)
+}
 
-		data, ok := respMap["data"].([]interface{})
-		if !ok {
-			return nil, errors.New("nil data in map")
-		}
-		for _, v := range data {
-			vMap := v.(map[string]interface{})
-			unitSym := vMap["base-currency"].(string)
-			quoteSym := vMap["quote-currency"].(string)
-			state := vMap["state"].(string)
-			amountPrecision := (vMap["amount-precision"]).(int)
-			pricePrecision := (vMap["price-precision"]).(int)
-			pairInfo := fintypes.PairInfo{}
-			pairInfo.Enabled = "online" == state
-			pairInfo.UnitPrecision = amountPrecision
-			pairInfo.QuotePrecision = pricePrecision
-			pairInfo.UnitMin = gdecimal.NewFromFloat64((vMap["min-order-amt"]).(float64))
-			pairInfo.UnitStep = pairInfo.UnitMin
-			res.Infos[fintypes.NewPair(unitSym, quoteSym).SetM(market)] = pairInfo
-		}
+func contractCode(p fintypes.Pair) string {
+	return strings.ToUpper(p.Unit() + "-" + p.Quote())
+}
+
+func parseContractCode(s string) (fintypes.Pair, error) {
+	ss := strings.Split(s, "-")
+	if len(ss) != 2 {
+		return fintypes.PairErr, gerror.Errorf("invalid contract code %s", s)
 	}
+	p := fintypes.NewPair(ss[0], ss[1])
+	if p == fintypes.PairErr {
+		return fintypes.PairErr, gerror.Errorf("invalid contract code %s", s)
+	}
+	return p, nil
+}
 
-	return res, nil
+// 0.0001 for precision 4
+func precisionStep(precision int) gdecimal.Decimal {
+	if precision <= 0 {
+		return gdecimal.One
+	}
+	r, _ := gdecimal.NewFromString("0." + strings.Repeat("0", precision-1) + "1")
+	return r
 }
 
-func parseMarketMargin(s string) (fintypes.Market, fintypes.Margin, bool) {
-	switch s {
-	case "spot":
-		return fintypes.MarketSpot, fintypes.MarginNo, true
-	case "margin":
-		return fintypes.MarketSpot, fintypes.MarginIsolated, true
-	case "super-margin":
-		return fintypes.MarketSpot, fintypes.MarginCross, true
-	default:
-		return fintypes.MarketError, fintypes.MarginError, false
+// client order id of huobi perp order is positive int64
+func perpClientId(clientId string) (int64, error) {
+	n, err := strconv.ParseInt(clientId, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, gerror.Errorf("client order id(%s) of huobi perp order should be positive integer", clientId)
+	}
+	return n, nil
+}
+
+// truncate decimal string to precision without rounding
+func truncString(d gdecimal.Decimal, precision int) string {
+	s := d.String()
+	idx := strings.Index(s, ".")
+	if idx < 0 {
+		return s
 	}
+	if precision <= 0 {
+		return s[:idx]
+	}
+	if len(s)-idx-1 > precision {
+		return s[:idx+1+precision]
+	}
+	return s
 }
 
-type accId struct {
-	market fintypes.Market
-	margin fintypes.Margin
-	id     string
+// get all supported pairs, min trade amount
+func (hb *Client) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	return hb.GetMarketInfoContext(context.Background(), ignorePairsNotFound)
 }
 
-func (hb *Client) getAccountIds() ([]accId, error) {
-	var ids []accId
-	for market, enabled := range hb.config.MarketEnabled {
-		if !enabled {
-			continue
+func (hb *Client) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	mi := fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+
+	// process spot market info
+	var symbols []struct {
+		BaseCurrency             string `json:"base-currency"`
+		QuoteCurrency            string `json:"quote-currency"`
+		PricePrecision           int    `json:"price-precision"`
+		AmountPrecision          int    `json:"amount-precision"`
+		State                    string `json:"state"`
+		MinOrderAmt              number `json:"min-order-amt"`
+		MinOrderValue            number `json:"min-order-value"`
+		LeverageRatio            number `json:"leverage-ratio"`
+		SuperMarginLeverageRatio number `json:"super-margin-leverage-ratio"`
+	}
+	if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlMarketInfo], nil, nil, false, &symbols); err != nil {
+		return nil, err
+	}
+	for _, v := range symbols {
+		p := fintypes.NewPair(v.BaseCurrency, v.QuoteCurrency)
+		if p == fintypes.PairErr {
+			if ignorePairsNotFound {
+				continue
+			}
+			return nil, gerror.Errorf("invalid huobi symbol %s%s", v.BaseCurrency, v.QuoteCurrency)
 		}
-		baseUrl, ok := apiPathMap[market][apiUrlBase]
-		if !ok {
-			return nil, gerror.New("apiUrlBase for %s not found", market)
+		minAmount, err := v.MinOrderAmt.Decimal()
+		if err != nil {
+			return nil, err
 		}
-		subUrl, ok := apiPathMap[market][apiUrlMarketInfo]
-		if !ok {
-			return nil, gerror.New("apiUrlMarketInfo for %s not found", market)
+		minValue, err := v.MinOrderValue.Decimal()
+		if err != nil {
+			return nil, err
+		}
+		isolatedLeverage, err := v.LeverageRatio.Decimal()
+		if err != nil {
+			return nil, err
 		}
-		params := &url.Values{}
-		hb.signForm(params, "GET", baseUrl, subUrl)
-		reqUrl := baseUrl + subUrl + "?" + params.Encode()
-		respMap, err := ghttp.GetMap(reqUrl, hb.proxy, time.Minute)
+		crossLeverage, err := v.SuperMarginLeverageRatio.Decimal()
 		if err != nil {
 			return nil, err
 		}
-		if respMap["status"].(string) != "ok" {
-			return nil, errors.New(respMap["err-code"].(string))
+
+		spotInfo := fintypes.PairInfo{}
+		spotInfo.MakerFee = gdecimal.NewFromFloat64(0.002) // FIXME 目前暂时统一填写0.002，以后可能更改
+		spotInfo.TakerFee = gdecimal.NewFromFloat64(0.002) // FIXME 目前暂时统一填写0.002，以后可能更改
+		spotInfo.Enabled = v.State == "online"
+		spotInfo.UnitPrecision = v.AmountPrecision
+		spotInfo.QuotePrecision = v.PricePrecision
+		spotInfo.UnitMin = minAmount
+		spotInfo.UnitStep = precisionStep(v.AmountPrecision)
+		spotInfo.QuoteStep = precisionStep(v.PricePrecision)
+		spotInfo.MinNotional = minValue
+		spotInfo.MarginIsolatedEnabled = isolatedLeverage.IsPositive()
+		spotInfo.MarginCrossEnabled = crossLeverage.IsPositive()
+		if spotInfo.MarginIsolatedEnabled || spotInfo.MarginCrossEnabled {
+			spotInfo.MinLeverage = 1
+			spotInfo.MaxLeverage = int(gdecimal.Max(isolatedLeverage, crossLeverage).Float64())
 		}
+		mi.Infos[p.SetM(fintypes.MarketSpot)] = spotInfo
+	}
 
-		data := respMap["data"].([]interface{})
-		for _, v := range data {
-			vMap := v.(map[string]interface{})
-			market, margin, ok := parseMarketMargin(vMap["type"].(string))
-			if !ok {
-				// 未识别的type，越过
-				continue
+	// process perp market info
+	if hb.property.MarketEnabled[fintypes.MarketPerp] {
+		var contracts []struct {
+			ContractCode   string `json:"contract_code"`
+			ContractSize   number `json:"contract_size"`
+			PriceTick      number `json:"price_tick"`
+			ContractStatus int    `json:"contract_status"`
+		}
+		if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketPerp, apiPathMap[fintypes.MarketPerp][apiUrlMarketInfo], nil, nil, false, &contracts); err != nil {
+			return nil, err
+		}
+		for _, v := range contracts {
+			p, err := parseContractCode(v.ContractCode)
+			if err != nil {
+				if ignorePairsNotFound {
+					continue
+				}
+				return nil, err
 			}
-			item := accId{
-				market: market,
-				margin: margin,
-				id:     vMap["id"].(string),
+			size, err := v.ContractSize.Decimal()
+			if err != nil {
+				return nil, err
 			}
-			ids = append(ids, item)
+			tick, err := v.PriceTick.Decimal()
+			if err != nil {
+				return nil, err
+			}
+
+			perpInfo := fintypes.PairInfo{}
+			perpInfo.MakerFee = gdecimal.NewFromFloat64(0.0002) // FIXME 目前暂时统一填写0.0002，以后可能更改
+			perpInfo.TakerFee = gdecimal.NewFromFloat64(0.0004) // FIXME 目前暂时统一填写0.0004，以后可能更改
+			perpInfo.Enabled = v.ContractStatus == 1
+			perpInfo.UnitMin = size // min volume is 1 contract
+			perpInfo.UnitStep = size
+			perpInfo.QuoteStep = tick
+			perpInfo.MarginIsolatedEnabled = true
+			perpInfo.MarginCrossEnabled = true
+			mi.Infos[p.SetM(fintypes.MarketPerp)] = perpInfo
 		}
 	}
 
-	return ids, nil
+	// cache it
+	hb.mu.Lock()
+	hb.marketInfoCache = mi
+	hb.marketInfoUpdate = hb.property.Clock.Now()
+	hb.mu.Unlock()
+
+	return &mi, nil
 }
 
-func getAccId(ids []accId, market fintypes.Market, margin fintypes.Margin) (string, bool) {
-	for _, v := range ids {
-		if v.market == market && v.margin == margin {
-			return v.id, true
+// get pair info from cache, market info will be updated if necessary
+func (hb *Client) getPairInfo(ctx context.Context, pm fintypes.PairM) (fintypes.PairInfo, error) {
+	hb.mu.Lock()
+	expired := hb.marketInfoCache.Infos == nil || hb.property.Clock.Now().Sub(hb.marketInfoUpdate) > gtime.Day
+	hb.mu.Unlock()
+	if expired {
+		if _, err := hb.GetMarketInfoContext(ctx, true); err != nil {
+			return fintypes.PairInfo{}, err
 		}
 	}
-	return "", false
+
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	info, ok := hb.marketInfoCache.Infos[pm]
+	if !ok {
+		return fintypes.PairInfo{}, gerror.Errorf("market info of %s not found", pm.String())
+	}
+	return info, nil
 }
 
-// get account info includes all currency balances
-func (hb *Client) GetAccount() (*fintypes.Account, error) {
-	ids, err := hb.getAccountIds()
+// contract size of perp pair
+func (hb *Client) contractSize(ctx context.Context, target fintypes.Pair) (gdecimal.Decimal, error) {
+	info, err := hb.getPairInfo(ctx, target.SetM(fintypes.MarketPerp))
 	if err != nil {
-		return nil, err
+		return gdecimal.Zero, err
 	}
+	if !info.UnitStep.IsPositive() {
+		return gdecimal.Zero, gerror.Errorf("invalid contract size of %s", target.String())
+	}
+	return info.UnitStep, nil
+}
 
-	res := fintypes.NewEmptyAccount()
+// get spot/margin accounts, cached accounts are returned if refresh is false
+func (hb *Client) getAccounts(ctx context.Context, refresh bool) ([]account, error) {
+	hb.mu.Lock()
+	if hb.accounts != nil && !refresh {
+		defer hb.mu.Unlock()
+		return hb.accounts, nil
+	}
+	hb.mu.Unlock()
 
-	accId, ok := getAccId(ids, fintypes.MarketSpot, fintypes.MarginNo)
-	if !ok {
-		return nil, gerror.New("MarketSpot MarginNo account id not found")
+	var data []struct {
+		Id      number `json:"id"`
+		Type    string `json:"type"`
+		Subtype string `json:"subtype"`
 	}
-	baseUrl, ok := apiPathMap[fintypes.MarketSpot][apiUrlBase]
-	if !ok {
-		return nil, gerror.New("apiUrlBase not found")
+	if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlAccountIds], nil, nil, true, &data); err != nil {
+		return nil, err
 	}
-	subUrl, ok := apiPathMap[fintypes.MarketSpot][apiUrlAccountBalance]
-	if !ok {
-		return nil, gerror.New("apiUrlAccountIds not found")
+
+	var r []account
+	for _, v := range data {
+		switch v.Type {
+		case accTypeSpot:
+			r = append(r, account{id: v.Id.String(), margin: fintypes.MarginNo})
+		case accTypeMargin:
+			p, err := fintypes.ParsePairCustom(v.Subtype, &hb.property)
+			if err != nil {
+				continue // 未识别的交易对，越过
+			}
+			r = append(r, account{id: v.Id.String(), margin: fintypes.MarginIsolated, pair: p})
+		case accTypeSuperMargin:
+			r = append(r, account{id: v.Id.String(), margin: fintypes.MarginCross})
+		default:
+			// 未识别的type，越过
+		}
 	}
-	params := &url.Values{}
-	params.Set("accountId-id", accId)
-	hb.signForm(params, "GET", baseUrl, subUrl)
-	reqUrl := baseUrl + subUrl + "?" + params.Encode()
-	respMap, err := ghttp.GetMap(reqUrl, hb.proxy, time.Minute)
+
+	hb.mu.Lock()
+	hb.accounts = r
+	hb.mu.Unlock()
+	return r, nil
+}
+
+// target is required by isolated margin only
+func (hb *Client) getAccountId(ctx context.Context, margin fintypes.Margin, target fintypes.Pair) (string, error) {
+	for i := 0; i < 2; i++ {
+		// new isolated margin account may be opened after last query, so refresh it once
+		accs, err := hb.getAccounts(ctx, i > 0)
+		if err != nil {
+			return "", err
+		}
+		for _, v := range accs {
+			if v.margin == margin && (margin != fintypes.MarginIsolated || v.pair == target) {
+				return v.id, nil
+			}
+		}
+	}
+	return "", gerror.Errorf("huobi account of Margin(%s) %s not found", margin, target.String())
+}
+
+// get account info includes all currency balances
+func (hb *Client) GetAccount() (*fintypes.Account, error) {
+	return hb.GetAccountContext(context.Background())
+}
+
+func (hb *Client) GetAccountContext(ctx context.Context) (*fintypes.Account, error) {
+	accs, err := hb.getAccounts(ctx, true)
 	if err != nil {
 		return nil, err
 	}
-	if respMap["status"].(string) != "ok" {
-		return nil, gerror.Errorf("result status %s != ok", respMap["err-code"].(string))
-	}
-	dataMap := respMap["data"].(map[string]interface{})
-	if dataMap["state"].(string) != "working" {
-		return nil, gerror.Errorf("data state %s != working", dataMap["state"].(string))
-	}
 
-	list := dataMap["list"].([]interface{})
+	r := fintypes.NewEmptyAccount()
 
-	for _, v := range list {
-		vMap := v.(map[string]interface{})
-		currencyStr := vMap["currency"].(string)
-		typeStr := vMap["type"].(string)
-		balance, err := gdecimal.NewFromString(vMap["balance"].(string))
-		if err != nil {
+	// spot & margin accounts
+	for _, acc := range accs {
+		var data struct {
+			List []struct {
+				Currency string `json:"currency"`
+				Type     string `json:"type"`
+				Balance  number `json:"balance"`
+			} `json:"list"`
+		}
+		path := fmt.Sprintf(apiPathMap[fintypes.MarketSpot][apiUrlAccountBalance], acc.id)
+		if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, path, nil, nil, true, &data); err != nil {
 			return nil, err
 		}
 
-		assetProperty := fintypes.AssetProperty{}
-		assetProperty.Market = fintypes.MarketSpot
-		assetProperty.Margin = fintypes.MarginNo
-		assetProperty.Asset = strings.ToUpper(currencyStr)
-		switch typeStr {
-		case "trade":
-			res.SetFreeAmount(assetProperty, balance)
-		case "frozen":
-			res.SetLockedAmount(assetProperty, balance)
-		default:
-			return nil, gerror.Errorf("invalid typeStr %s", typeStr)
+		var assets []string
+		balances := map[string]*fintypes.Balance{}
+		for _, v := range data.List {
+			amount, err := v.Balance.Decimal()
+			if err != nil {
+				return nil, err
+			}
+			asset := strings.ToUpper(v.Currency)
+			b, ok := balances[asset]
+			if !ok {
+				b = &fintypes.Balance{}
+				b.Market = fintypes.MarketSpot
+				b.Margin = acc.margin
+				if acc.margin == fintypes.MarginIsolated {
+					b.CustomSubAccName = acc.pair.String()
+				}
+				b.Asset = asset
+				b.Free, b.Locked, b.Borrowed, b.Interest = gdecimal.Zero, gdecimal.Zero, gdecimal.Zero, gdecimal.Zero
+				balances[asset] = b
+				assets = append(assets, asset)
+			}
+			switch v.Type {
+			case "trade":
+				b.Free = b.Free.Add(amount)
+			case "frozen":
+				b.Locked = b.Locked.Add(amount)
+			case "loan": // negative in huobi
+				b.Borrowed = b.Borrowed.Add(amount.Abs())
+			case "interest": // negative in huobi
+				b.Interest = b.Interest.Add(amount.Abs())
+			}
 		}
+		for _, asset := range assets {
+			if balances[asset].IsZero() {
+				continue
+			}
+			r.Balances = append(r.Balances, *balances[asset])
+		}
+	}
+
+	if !hb.property.MarketEnabled[fintypes.MarketPerp] {
+		return r, nil
 	}
 
-	/*
-		baseUrl := apiPathMap[fintypes.MarketFuture][apiUrlAccountBalance]
-		var resp []struct {
-			Symbol            string  `json:"symbol"`
-			MarginBalance     float64 `json:"margin_balance"`
-			MarginPosition    float64 `json:"margin_position"`
-			ProfitUnreal      float64 `json:"profit_unreal"`
-			WithdrawAvailable float64 `json:"withdraw_available"`
+	// perp accounts
+	type contractAccount struct {
+		ContractCode      string `json:"contract_code"`
+		MarginAsset       string `json:"margin_asset"`
+		MarginAvailable   number `json:"margin_available"`
+		WithdrawAvailable number `json:"withdraw_available"`
+		MarginPosition    number `json:"margin_position"`
+		MarginFrozen      number `json:"margin_frozen"`
+	}
+	for _, margin := range []fintypes.Margin{fintypes.MarginIsolated, fintypes.MarginCross} {
+		path := apiPathMap[fintypes.MarketPerp][apiUrlAccountBalance]
+		if margin == fintypes.MarginCross {
+			path = apiPathMap[fintypes.MarketPerp][apiUrlCrossAccount]
 		}
-		params := &url.Values{}
-		if err := hb.doRequest(baseUrl, params, &resp); err != nil {
+		var data []contractAccount
+		if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, map[string]interface{}{}, true, &data); err != nil {
 			return nil, err
 		}
-		for _, v := range resp {
-			fintypes.AssetAmount{
-				MaxWithdrawAmount:     gdecimal.NewFromFloat64(v.WithdrawAvailable),
-				PositionInitialMargin: gdecimal.NewFromFloat64(v.MarginPosition),
-				UnrealizedProfit:      gdecimal.NewFromFloat64(v.ProfitUnreal),
-				WalletBalance:         gdecimal.NewFromFloat64(v.MarginBalance),
+		for _, v := range data {
+			b := fintypes.Balance{}
+			b.Market = fintypes.MarketPerp
+			b.Margin = margin
+			b.Asset = strings.ToUpper(v.MarginAsset)
+			if margin == fintypes.MarginIsolated {
+				p, err := parseContractCode(v.ContractCode)
+				if err != nil {
+					return nil, err
+				}
+				b.CustomSubAccName = p.String()
+				b.Free, err = v.MarginAvailable.Decimal()
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				b.Free, err = v.WithdrawAvailable.Decimal()
+				if err != nil {
+					return nil, err
+				}
+			}
+			position, err := v.MarginPosition.Decimal()
+			if err != nil {
+				return nil, err
+			}
+			frozen, err := v.MarginFrozen.Decimal()
+			if err != nil {
+				return nil, err
 			}
+			b.Locked = position.Add(frozen)
+			b.Borrowed = gdecimal.Zero
+			b.Interest = gdecimal.Zero
+			if b.IsZero() {
+				continue
+			}
+			r.Balances = append(r.Balances, b)
 		}
-	*/
+	}
 
-	return res, nil
+	return r, nil
 }
 
-// get open order books
-func (hb *Client) GetDepth(market fintypes.Market, pair fintypes.Pair, limit int) (*fintypes.Depth, error) {
-	var depth *fintypes.Depth
-	hb.setBaseUrl(market)
-	if fintypes.MarketSpot == market || MarketMargin == market {
-		n := 5
-		if limit <= 5 {
-
-			n = 5
-		} else if limit <= 10 {
-			n = 10
-		} else if limit <= 20 {
-			n = 20
-		} else {
-			n = 150
+func parseOrderBookList(src [][]number, amountMul gdecimal.Decimal) (fintypes.OrderBookList, error) {
+	var r fintypes.OrderBookList
+	for _, v := range src {
+		if len(v) < 2 {
+			return nil, gerror.Errorf("invalid order book %v", v)
 		}
-		url := hb.baseUrl + ApiPathMap[market][ApiUrlDepth]
-		urlStr := fmt.Sprintf(url, pair.Format("", false), n)
-		respmap, err := ghttputils.HttpGet(hb.httpClient, urlStr)
+		price, err := v[0].Decimal()
 		if err != nil {
 			return nil, err
 		}
-		if "ok" != respmap["status"].(string) {
-			return nil, errors.New(respmap["err-msg"].(string))
+		amount, err := v[1].Decimal()
+		if err != nil {
+			return nil, err
 		}
+		r = append(r, fintypes.OrderBook{Price: price, Amount: amount.Mul(amountMul)})
+	}
+	return r, nil
+}
+
+// get open order books
+func (hb *Client) GetDepth(market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	return hb.GetDepthContext(context.Background(), market, target)
+}
 
-		tick, _ := respmap["tick"].(map[string]interface{})
+func (hb *Client) GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
 
-		depth = hb.parseDepthData(tick, limit)
-		mills := utils.ToInt64(tick["ts"].(float64))
-		depth.Time = time.Unix(int64(mills/1000), int64(mills%1000)*int64(time.Millisecond))
-	} else if fintypes.MarketFuture == market || fintypes.MarketPerp == market {
-		urlStr := hb.baseUrl + fmt.Sprintf(ApiPathMap[market][ApiUrlDepth], strings.ToUpper(string(pair)))
-		ret, err := ghttputils.HttpGet(hb.httpClient, urlStr)
+	params := url.Values{}
+	params.Set("type", "step0")
+	amountMul := gdecimal.One
+	if market == fintypes.MarketSpot {
+		params.Set("symbol", target.CustomFormat(hb.Property()))
+	} else if market == fintypes.MarketPerp {
+		params.Set("contract_code", contractCode(target))
+		size, err := hb.contractSize(ctx, target)
 		if err != nil {
 			return nil, err
 		}
-
-		s := ret["status"].(string)
-		if s == "error" {
-			return nil, errors.New(ret["err_msg"].(string))
-		}
-
-		tick, _ := ret["tick"].(map[string]interface{})
-		depth = hb.parseDepthData(tick, limit)
-		mills := utils.ToInt64(tick["ts"].(float64))
-		depth.Time = time.Unix(int64(mills/1000), int64(mills%1000)*int64(time.Millisecond))
+		amountMul = size // depth amount of contract is volume
 	} else {
+		return nil, gerror.Errorf("huobi doesn't support Market(%s)", market)
 	}
 
-	return depth, nil
-}
-
-// get all ticks
-func (hb *Client) GetTicks() (map[fintypes.PairM]fintypes.Tick, error) {
-	hb.setBaseUrl(market)
-	url := hb.baseUrl + "/market/tickers"
-	respmap, err := ghttputils.HttpGet(hb.httpClient, url)
+	resp, err := hb.request(ctx, http.MethodGet, market, apiPathMap[market][apiUrlDepth], params, nil, false)
 	if err != nil {
 		return nil, err
 	}
-	if respmap["status"].(string) == "error" {
-		return nil, errors.New(respmap["err-msg"].(string))
+	var tick struct {
+		Bids [][]number `json:"bids"`
+		Asks [][]number `json:"asks"`
+		Ts   int64      `json:"ts"`
 	}
-	data, ok := respmap["data"].([]interface{})
-	if !ok {
-		return nil, errors.New("response format error")
+	if err := json.Unmarshal(resp.Tick, &tick); err != nil {
+		return nil, err
 	}
 
-	ticks := make(map[PairExt]*fintypes.Tick)
-	for _, v := range data {
-		_sym := v.(map[string]interface{})
-		_symbol := _sym["symbol"].(string)
-		_close := _sym["close"].(float64)
-		_low := _sym["low"].(float64)
-		_high := _sym["high"].(float64)
-		_vol := _sym["vol"].(float64)
-
-		tick := new(fintypes.Tick)
-		tick.Time = time.Now()
-		tick.Last = gdecimal.NewFromFloat64(_close)
-		tick.Buy = gdecimal.Zero
-		tick.Sell = gdecimal.Zero
-		tick.High = gdecimal.NewFromFloat64(_high)
-		tick.Low = gdecimal.NewFromFloat64(_low)
-		tick.Volume = gdecimal.NewFromFloat64(_vol)
-		tpair, _ := fintypes.ParsePairCustom(_symbol, hb.config)
-		pairExt := tpair.SetM(market)
-		ticks[pairExt] = tick
+	res := fintypes.Depth{}
+	res.Time = gtime.EpochMillisToTime(tick.Ts)
+	res.Buys, err = parseOrderBookList(tick.Bids, amountMul)
+	if err != nil {
+		return nil, err
 	}
-	for k, v := range ticks {
-		pairTicks.Ticks[k] = *v
+	res.Sells, err = parseOrderBookList(tick.Asks, amountMul)
+	if err != nil {
+		return nil, err
 	}
+	return &res, nil
+}
 
-	return pairTicks, nil
+// get all ticks
+func (hb *Client) GetTicks(ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	return hb.GetTicksContext(context.Background(), ignorePairsNotFound)
 }
 
-// get candle bars
-func (hb *Client) GetKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes2.Kline, error) {
-	var klines *fintypes2.Kline
-	hb.setBaseUrl(market)
-	if fintypes.MarketSpot == market || MarketMargin == market {
-		url := hb.baseUrl + ApiPathMap[market][ApiUrlKline]
-		symbol := target.Format("", false)
-		periodSec := period.ToSeconds()
-		times := int64(time.Since(*since).Seconds()) / periodSec
-		if times > 2000 {
-			times = 2000
-		}
-		periodSecStr := period.String()
-		respmap, err := ghttputils.HttpGet(hb.httpClient, fmt.Sprintf(url, periodSecStr, times, symbol))
-		if err != nil {
-			return nil, err
-		}
-		data, ok := respmap["data"].([]interface{})
-		if !ok {
-			return nil, errors.New("response format error")
-		}
-
-		klines = &fintypes2.Kline{}
-		klines.Pair = PairExt(string(target))
-		klines.Period = period
-		for _, e := range data {
-			item := e.(map[string]interface{})
-			klines.Items = append(klines.Items, fintypes2.Bar{
-				O: gdecimal.NewFromFloat64(item["open"].(float64)),
-				C: gdecimal.NewFromFloat64(item["close"].(float64)),
-				H: gdecimal.NewFromFloat64(item["high"].(float64)),
-				L: gdecimal.NewFromFloat64(item["low"].(float64)),
-				V: gdecimal.NewFromFloat64(item["vol"].(float64)),
-				T: time.Unix(int64(item["id"].(float64)), 0),
-			})
-		}
-	} else if fintypes.MarketFuture == market || fintypes.MarketPerp == market {
-		url := hb.baseUrl + ApiPathMap[market][ApiUrlKline]
-		symbol := target.Format("", true)
-		periodSec := period.ToSeconds()
-		times := int64(time.Since(*since).Seconds()) / periodSec
-		periodSecStr := period.String()
-
-		respmap, err := ghttputils.HttpGet(hb.httpClient, fmt.Sprintf(url, periodSecStr,
-			times, symbol, since.Unix(), time.Now().Unix())) // 默认到现在为止
+func (hb *Client) GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	res := make(map[fintypes.PairM]fintypes.Tick)
+	now := hb.property.Clock.Now()
+
+	var ticks []struct {
+		Symbol string `json:"symbol"`
+		High   number `json:"high"`
+		Low    number `json:"low"`
+		Close  number `json:"close"`
+		Amount number `json:"amount"`
+		Bid    number `json:"bid"`
+		Ask    number `json:"ask"`
+	}
+	if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlTicks], nil, nil, false, &ticks); err != nil {
+		return nil, err
+	}
+	for _, v := range ticks {
+		pair, err := fintypes.ParsePairCustom(v.Symbol, hb.Property())
 		if err != nil {
-			return nil, err
+			if ignorePairsNotFound {
+				continue
+			} else {
+				return nil, err
+			}
 		}
-
-		data, ok := respmap["data"].([]interface{})
-		if !ok {
-			return nil, errors.New("response format error")
-		}
-
-		klines = new(fintypes2.Kline)
-		klines.Pair = PairExt(string(target))
-		klines.Period = period
-		for _, e := range data {
-			item := e.(map[string]interface{})
-			klines.Items = append(klines.Items, fintypes2.Bar{
-				O: gdecimal.NewFromFloat64(item["open"].(float64)),
-				C: gdecimal.NewFromFloat64(item["close"].(float64)),
-				H: gdecimal.NewFromFloat64(item["high"].(float64)),
-				L: gdecimal.NewFromFloat64(item["low"].(float64)),
-				V: gdecimal.NewFromFloat64(item["vol"].(float64)),
-				T: time.Unix(int64(item["id"].(float64)), 0),
-			})
+		item := fintypes.Tick{Time: now}
+		for _, kv := range []struct {
+			dst *gdecimal.Decimal
+			src number
+		}{{&item.Last, v.Close}, {&item.Buy, v.Bid}, {&item.Sell, v.Ask}, {&item.High, v.High}, {&item.Low, v.Low}, {&item.Volume, v.Amount}} {
+			if *kv.dst, err = kv.src.Decimal(); err != nil {
+				return nil, err
+			}
 		}
-	} else {
+		res[pair.SetM(fintypes.MarketSpot)] = item
 	}
 
-	return klines, nil
-}
+	if !hb.property.MarketEnabled[fintypes.MarketPerp] {
+		return res, nil
+	}
 
-// get exchange filled trades history
-func (hb *Client) GetFills(market fintypes.Market, target fintypes.Pair, fromId *int64, limit int) ([]fintypes.Fill, error) {
-	var fills []fintypes.Fill
-	hb.setBaseUrl(market)
-	url := hb.baseUrl + ApiPathMap[market][ApiUrlFills]
-	urlStr := fmt.Sprintf(url, target.Format("", false), limit)
-	respmap, err := ghttputils.HttpGet(hb.httpClient, urlStr)
+	resp, err := hb.request(ctx, http.MethodGet, fintypes.MarketPerp, apiPathMap[fintypes.MarketPerp][apiUrlTicks], nil, nil, false)
 	if err != nil {
 		return nil, err
 	}
-	if respmap["status"].(string) == "error" {
-		return nil, errors.New(respmap["err-msg"].(string))
+	var ticksPerp []struct {
+		ContractCode string   `json:"contract_code"`
+		High         number   `json:"high"`
+		Low          number   `json:"low"`
+		Close        number   `json:"close"`
+		Amount       number   `json:"amount"`
+		Bid          []number `json:"bid"`
+		Ask          []number `json:"ask"`
 	}
-	tickmap, ok := respmap["data"].([]interface{})
-	if !ok {
-		return nil, errors.New("tick assert error")
+	if err := json.Unmarshal(resp.Ticks, &ticksPerp); err != nil {
+		return nil, err
 	}
-
-	for _, e := range tickmap {
-		tickInter := e.(map[string]interface{})
-		itemInters := tickInter["data"].([]interface{})
-		for _, z := range itemInters {
-			item := z.(map[string]interface{})
-			fills = append(fills, fintypes.Fill{
-				Id:      int64(tickInter["id"].(float64)),
-				Time:    time.Unix(int64(item["ts"].(float64)/1000), 0),
-				Price:   gdecimal.NewFromFloat64(item["price"].(float64)),
-				UnitQty: gdecimal.NewFromFloat64(item["amount"].(float64)),
-				Side:    SideType(item["direction"].(string)),
-			})
+	for _, v := range ticksPerp {
+		pair, err := parseContractCode(v.ContractCode)
+		if err != nil {
+			if ignorePairsNotFound {
+				continue
+			} else {
+				return nil, err
+			}
 		}
+		bid, ask := number(""), number("")
+		if len(v.Bid) > 0 {
+			bid = v.Bid[0]
+		}
+		if len(v.Ask) > 0 {
+			ask = v.Ask[0]
+		}
+		item := fintypes.Tick{Time: now}
+		for _, kv := range []struct {
+			dst *gdecimal.Decimal
+			src number
+		}{{&item.Last, v.Close}, {&item.Buy, bid}, {&item.Sell, ask}, {&item.High, v.High}, {&item.Low, v.Low}, {&item.Volume, v.Amount}} {
+			if *kv.dst, err = kv.src.Decimal(); err != nil {
+				return nil, err
+			}
+		}
+		res[pair.SetM(fintypes.MarketPerp)] = item
 	}
 
-	return fills, nil
+	return res, nil
 }
 
-// margin account borrowable
-func (hb *Client) GetBorrowable(target fintypes.Pair, tp UnitQuoteType, margin MarginType) (gdecimal.Decimal, error) {
-	var path string
-	params := &url.Values{}
-	if MMargin == margin {
-		path = "/v1/margin/loan-info"
-		params.Set("symbols", target.Format("", false))
-	} else {
-		path = "/v1/cross-margin/loan-info"
+// get candle bars
+// huobi spot kline api doesn't support begin time, so latest 2000 bars are downloaded and filtered by since
+func (hb *Client) GetKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return hb.GetKlineContext(context.Background(), market, target, period, since)
+}
+
+func (hb *Client) GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
 	}
-	hb.setBaseUrl(MarketMargin)
-	hb.buildPostForm("GET", path, params)
-	urlStr := hb.baseUrl + path + "?" + params.Encode()
-	respmap, err := ghttputils.HttpGet(hb.httpClient, urlStr)
+	hbPeriod, err := period.CustomFormat(hb.Property())
 	if err != nil {
-		return gdecimal.Zero, err
-	}
-	if respmap["status"].(string) == "error" {
-		return gdecimal.Zero, errors.New(respmap["err-msg"].(string))
+		return nil, err
 	}
 
-	tickmap, ok := respmap["data"].([]interface{})
-	if !ok {
-		return gdecimal.Zero, errors.New("tick assert error")
-	}
-	asset := strings.ToLower(target.Dist(tp))
-	var loanableAmt gdecimal.Decimal
-	if MMargin == margin {
-		for _, e := range tickmap {
-			tickInter := e.(map[string]interface{})
-			items := tickInter["currencies"].([]interface{})
-			for _, v := range items {
-				item := v.(map[string]interface{})
-				log.Print(item["currency"].(string))
-				if asset == item["currency"].(string) {
-					loanableAmt, _ = gdecimal.NewFromString(item["loanable-amt"].(string))
-					break
-				}
-			}
+	params := url.Values{}
+	params.Set("period", hbPeriod)
+	if market == fintypes.MarketSpot {
+		params.Set("symbol", target.CustomFormat(hb.Property()))
+		params.Set("size", "2000")
+	} else if market == fintypes.MarketPerp {
+		params.Set("contract_code", contractCode(target))
+		if since != nil {
+			to := gtime.MinTime(since.Add(period.ToDuration()*1999), hb.property.Clock.Now())
+			params.Set("from", fmt.Sprintf("%d", since.Unix()))
+			params.Set("to", fmt.Sprintf("%d", to.Unix()))
+		} else {
+			params.Set("size", "2000")
 		}
 	} else {
-		for _, e := range tickmap {
-			item := e.(map[string]interface{})
-			if asset == item["currency"].(string) {
-				loanableAmt, _ = gdecimal.NewFromString(item["loanable-amt"].(string))
-				break
-			}
-		}
+		return nil, gerror.Errorf("huobi doesn't support Market(%s)", market)
 	}
 
-	return loanableAmt, nil
-}
-
-// margin account borrow
-func (hb *Client) Borrow(target fintypes.Pair, tp UnitQuoteType, margin MarginType, amount gdecimal.Decimal) error {
-	var path string
-	params := &url.Values{}
-	hb.setBaseUrl(MarketMargin)
-	err0 := hb.setNewHuobiId(MarketMargin)
-	if nil != err0 {
-		return err0
+	var data []struct {
+		Id     int64  `json:"id"`
+		Open   number `json:"open"`
+		Close  number `json:"close"`
+		Low    number `json:"low"`
+		High   number `json:"high"`
+		Amount number `json:"amount"` // volume in unit asset
 	}
-	params.Set("accountId-id", hb.accountId)
-	if MMargin == margin {
-		path = "/v1/margin/orders"
-	} else {
-		path = "/v1/cross-margin/orders"
-	}
-	hb.buildPostForm("POST", path, params)
-	urlStr := hb.baseUrl + path + "?" + params.Encode()
-	asset := strings.ToLower(target.Dist(tp))
-	postData := make(map[string]string)
-	postData["symbol"] = target.Format("", false)
-	postData["currency"] = asset
-	postData["amount"] = amount.String()
-	respData, err := ghttputils.HttpPostForm4(hb.httpClient, urlStr, postData, nil)
-	if err != nil {
-		return err
+	if err := hb.requestData(ctx, http.MethodGet, market, apiPathMap[market][apiUrlKline], params, nil, false, &data); err != nil {
+		return nil, err
 	}
 
-	var respmap map[string]interface{}
-	json.Unmarshal(respData, &respmap)
-	status := respmap["status"].(string)
-	if status == "error" {
-		return errors.New(respmap["err-msg"].(string))
+	r := new(fintypes.Kline)
+	r.Pair = target.SetI(period).SetM(market).SetP(fintypes.Huobi)
+	for _, v := range data {
+		item := fintypes.Bar{}
+		item.T = time.Unix(v.Id, 0).UTC()
+		if since != nil && item.T.Before(*since) {
+			continue
+		}
+		for _, kv := range []struct {
+			dst *gdecimal.Decimal
+			src number
+		}{{&item.O, v.Open}, {&item.H, v.High}, {&item.L, v.Low}, {&item.C, v.Close}, {&item.V, v.Amount}} {
+			if *kv.dst, err = kv.src.Decimal(); err != nil {
+				return nil, err
+			}
+		}
+		r.Items = append(r.Items, item)
 	}
 
-	return nil
+	r.Sort()
+	return r, nil
+}
+
+// get exchange filled trades history
+// huobi returns only the latest fills (limit <= 2000), fills older than fromId are dropped if fromId is not nil
+func (hb *Client) GetFills(market fintypes.Market, target fintypes.Pair, fromId *int64, limit int) ([]fintypes.Fill, error) {
+	return hb.GetFillsContext(context.Background(), market, target, fromId, limit)
 }
 
-// margin account repay
-func (hb *Client) Repay(orderId string, margin MarginType, amount gdecimal.Decimal) error {
-	var path string
-	gparams := &url.Values{}
-	hb.setBaseUrl(MarketMargin)
-	err0 := hb.setNewHuobiId(MarketMargin)
-	if nil != err0 {
-		return err0
+func (hb *Client) GetFillsContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, fromId *int64, limit int) ([]fintypes.Fill, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
 	}
-	gparams.Set("accountId-id", hb.accountId)
-	if MMargin == margin {
-		path = fmt.Sprintf("/v1/margin/orders/%s/repay", orderId)
+	params := url.Values{}
+	params.Set("size", strconv.Itoa(limit))
+	if market == fintypes.MarketSpot {
+		params.Set("symbol", target.CustomFormat(hb.Property()))
+	} else if market == fintypes.MarketPerp {
+		params.Set("contract_code", contractCode(target))
 	} else {
-		path = fmt.Sprintf("/v1/cross-margin/orders/%s/repay", orderId)
-	}
-	hb.buildPostForm("POST", path, gparams)
-	urlStr := hb.baseUrl + path + "?" + gparams.Encode()
-	postData := make(map[string]string)
-	postData["order-id"] = orderId
-	postData["amount"] = amount.String()
-	respData, err := ghttputils.HttpPostForm4(hb.httpClient, urlStr, postData, nil)
-	if err != nil {
-		return err
+		return nil, gerror.Errorf("huobi doesn't support Market(%s)", market)
 	}
 
-	var respmap map[string]interface{}
-	json.Unmarshal([]byte(respData), &respmap)
-	status := respmap["status"].(string)
-	if status == "error" {
-		return errors.New(respmap["err-msg"].(string))
+	var data []struct {
+		Data []struct {
+			Id        int64  `json:"id"`
+			TradeId   int64  `json:"trade-id"` // spot only, id of spot fill is not sequential
+			Ts        int64  `json:"ts"`
+			Price     number `json:"price"`
+			Amount    number `json:"amount"`   // contracts of perp
+			Quantity  number `json:"quantity"` // perp only, in unit asset
+			Direction string `json:"direction"`
+		} `json:"data"`
 	}
-
-	return nil
-}
-
-// transfer between spot and margin account
-func (hb *Client) Transfer(market fintypes.Market, target fintypes.Pair, tp UnitQuoteType, amount gdecimal.Decimal) error {
-	if market != fintypes.MarketSpot && market != MarketMargin {
-		return errors.New("Access denied")
-	}
-
-	gparams := &url.Values{}
-	hb.setBaseUrl(market)
-	err0 := hb.setNewHuobiId(market)
-	if nil != err0 {
-		return err0
-	}
-	gparams.Set("accountId-id", hb.accountId)
-	path := ApiPathMap[market][ApiUrlTransfer]
-	hb.buildPostForm("POST", path, gparams)
-	urlStr := hb.baseUrl + path + "?" + gparams.Encode()
-	asset := strings.ToLower(target.Dist(tp))
-	postData := make(map[string]string)
-	postData["symbol"] = target.Format("", false)
-	postData["currency"] = asset
-	postData["amount"] = amount.String()
-	respData, err := ghttputils.HttpPostForm4(hb.httpClient, urlStr, postData, nil)
-	if err != nil {
-		return err
+	if err := hb.requestData(ctx, http.MethodGet, market, apiPathMap[market][apiUrlFills], params, nil, false, &data); err != nil {
+		return nil, err
 	}
 
-	var respmap map[string]interface{}
-	json.Unmarshal(respData, &respmap)
-	if nil == respmap["data"] {
-		return errors.New(respmap["err-msg"].(string))
-	}
-	transferId := int(respmap["data"].(float64))
-	if transferId <= 0 {
-		return errors.New(respmap["err-msg"].(string))
+	var fills []fintypes.Fill
+	for _, v := range data {
+		for _, item := range v.Data {
+			fill := fintypes.Fill{Id: item.Id, Time: time.Unix(0, item.Ts*int64(time.Millisecond)).UTC(), Side: item.Direction}
+			if item.TradeId > 0 {
+				fill.Id = item.TradeId
+			}
+			if fromId != nil && fill.Id < *fromId {
+				continue
+			}
+			var err error
+			if fill.Price, err = item.Price.Decimal(); err != nil {
+				return nil, err
+			}
+			qty := item.Amount
+			if market == fintypes.MarketPerp {
+				qty = item.Quantity
+			}
+			if fill.UnitQty, err = qty.Decimal(); err != nil {
+				return nil, err
+			}
+			fills = append(fills, fill)
+		}
 	}
+	return fills, nil
+}
 
-	return nil
+func (hb *Client) GetBorrowable(margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	return hb.GetBorrowableContext(context.Background(), margin, asset)
 }
 
-// limit-buy, limit-sell, market-buy, market-sell
-// when market-buy/market-sell, price will be ignored
-// amount: always unit amount, not quote amount, whether trade type is buy or sell.
-func (hb *Client) Trade(market fintypes.Market, target fintypes.Pair, side TradeTypeSide, amount, price gdecimal.Decimal) (*fintypes.OrderId, error) {
-	if market != fintypes.MarketSpot && market != MarketMargin {
-		return nil, errors.New("Access denied")
+func (hb *Client) GetBorrowableContext(ctx context.Context, margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	if margin != fintypes.MarginCross {
+		return gdecimal.N0, gerror.Errorf("unsupported margin(%s)", margin)
 	}
-	strId, err := hb.placeOrder(market, target, amount.String(), price.String(), side.CustomFormat(hb.config))
-	if err != nil {
-		return nil, err
+	var data []struct {
+		Currency    string `json:"currency"`
+		LoanableAmt number `json:"loanable-amt"`
 	}
-	res := fintypes.NewOrderId(market, target, strId)
-	return &res, nil
+	if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlBorrowable], nil, nil, true, &data); err != nil {
+		return gdecimal.Zero, err
+	}
+	for _, v := range data {
+		if strings.EqualFold(v.Currency, asset) {
+			return v.LoanableAmt.Decimal()
+		}
+	}
+	return gdecimal.Zero, gerror.Errorf("asset %s is not borrowable", asset)
 }
 
-// get all my history orders' info
-func (hb *Client) GetAllOrders(market fintypes.Market, target fintypes.Pair) ([]fintypes.Order, error) {
-	var orders []fintypes.Order
-	var err error
-	if fintypes.MarketSpot == market || MarketMargin == market {
-		orders, err = hb.getProOrders(market, QueryOrdersParams{
-			fintypes.Pair: target,
-			States:        "partial-canceled,filled",
-			Size:          100,
-			Direct:        "next",
-		})
-	} else if fintypes.MarketFuture == market || fintypes.MarketPerp == market {
-		orders, err = hb.getContractOrders(market, QueryOrdersParams{
-			Types:  "all",
-			Symbol: strings.ToUpper(string(target)),
-		})
-	} else {
+func (hb *Client) Borrow(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return hb.BorrowContext(context.Background(), margin, asset, amount)
+}
+
+func (hb *Client) BorrowContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	if margin != fintypes.MarginCross {
+		return gerror.Errorf("unsupported margin(%s)", margin)
+	}
+	body := map[string]interface{}{
+		"currency": strings.ToLower(asset),
+		"amount":   amount.String(),
 	}
+	return hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlBorrow], nil, body, true, nil)
+}
 
-	return orders, err
+func (hb *Client) Repay(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return hb.RepayContext(context.Background(), margin, asset, amount)
 }
 
-// get all my unfinished orders' info
-func (hb *Client) GetOpenOrders(market fintypes.Market, target fintypes.Pair) ([]fintypes.Order, error) {
-	var orders []fintypes.Order
-	var err error
-	if fintypes.MarketSpot == market || MarketMargin == market {
-		orders, err = hb.getProOrders(market, QueryOrdersParams{
-			fintypes.Pair: target,
-			Size:          100,
-			States:        "pre-submitted,submitted,partial-filled",
-		})
-	} else if fintypes.MarketFuture == market || fintypes.MarketPerp == market {
-		orders, err = hb.getContractOrders(market, QueryOrdersParams{
-			Types:  "unfinished",
-			Symbol: strings.ToUpper(string(target)),
-		})
-	} else {
+func (hb *Client) RepayContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	if margin != fintypes.MarginCross {
+		return gerror.Errorf("unsupported margin(%s)", margin)
+	}
+	accId, err := hb.getAccountId(ctx, fintypes.MarginCross, fintypes.PairErr)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"accountId": accId,
+		"currency":  strings.ToLower(asset),
+		"amount":    amount.String(),
 	}
+	return hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlRepay], nil, body, true, nil)
+}
 
-	return orders, err
+// transfer between spot and margin/perp account
+// for isolated margin, the name of SubAcc is the pair like 'BTC/USDT'
+func (hb *Client) Transfer(asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	return hb.TransferContext(context.Background(), asset, amount, from, to)
 }
 
-// get order info by id
-func (hb *Client) GetOrder(market fintypes.Market, pair fintypes.Pair, orderId fintypes.OrderId) (*fintypes.Order, error) {
-	var order *fintypes.Order
-	var err error
-	hb.setBaseUrl(market)
-	if fintypes.MarketSpot == market || MarketMargin == market {
-		path := "/v1/order/orders/" + string(orderId)
-		params := url.Values{}
-		hb.buildPostForm("GET", path, &params)
-		respmap, err := ghttputils.HttpGet(hb.httpClient, hb.baseUrl+path+"?"+params.Encode())
+func (hb *Client) TransferContext(ctx context.Context, asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	saFrom, err := from.Parse()
+	if err != nil {
+		return err
+	}
+	saTo, err := to.Parse()
+	if err != nil {
+		return err
+	}
+	isSpot := func(sa fintypes.SubAccParsed) bool {
+		return sa.Market == fintypes.MarketSpot && sa.Margin == fintypes.MarginNo
+	}
+	pairOf := func(sa fintypes.SubAccParsed) (fintypes.Pair, error) {
+		p, err := fintypes.ParsePair(sa.Name)
 		if err != nil {
-			return nil, err
+			return fintypes.PairErr, gerror.Errorf("pair required as SubAcc name of isolated margin, but got '%s'", sa.Name)
 		}
+		return p, nil
+	}
+	currency := strings.ToLower(asset)
 
-		if respmap["status"].(string) != "ok" {
-			return nil, errors.New(respmap["err-code"].(string))
-		}
-
-		datamap := respmap["data"].(map[string]interface{})
-		order = hb.parseOrder(market, datamap)
-	} else if fintypes.MarketFuture == market || fintypes.MarketPerp == market {
-		var path string
-		params := &url.Values{}
-		postData := make(map[string]string)
-		if fintypes.MarketFuture == market {
-			path = ApiPathMap[fintypes.MarketFuture][ApiUrlOrder]
-			postData["order_id"] = string(orderId)
-			postData["symbol"] = string(pair)
-		} else if fintypes.MarketPerp == market {
-			path = ApiPathMap[fintypes.MarketPerp][ApiUrlOrder]
-			postData["order_id"] = string(orderId)
-			postData["contract_code"] = string(pair)
-			postData["order_type"] = "1"
-		}
-		hb.buildPostForm("POST", path, params)
-		urlStr := hb.baseUrl + path + "?" + params.Encode()
-		respData, err := ghttputils.HttpPostForm4(hb.httpClient, urlStr, postData, nil)
-		if err != nil {
-			return nil, err
+	// spot <-> margin
+	if (isSpot(saFrom) && saTo.Market == fintypes.MarketSpot) || (isSpot(saTo) && saFrom.Market == fintypes.MarketSpot) {
+		in := isSpot(saFrom)
+		other := saTo
+		if !in {
+			other = saFrom
 		}
+		body := map[string]interface{}{"currency": currency, "amount": amount.String()}
+		path := ""
+		if other.Margin == fintypes.MarginCross {
+			path = apiPathMap[fintypes.MarketSpot][apiUrlCrossTransferIn]
+			if !in {
+				path = apiPathMap[fintypes.MarketSpot][apiUrlCrossTransferOut]
+			}
+		} else if other.Margin == fintypes.MarginIsolated {
+			p, err := pairOf(other)
+			if err != nil {
+				return err
+			}
+			body["symbol"] = p.CustomFormat(hb.Property())
+			path = apiPathMap[fintypes.MarketSpot][apiUrlTransferIn]
+			if !in {
+				path = apiPathMap[fintypes.MarketSpot][apiUrlTransferOut]
+			}
+		} else {
+			return gerror.Errorf("unsupported transfer %s -> %s", from, to)
+		}
+		return hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, path, nil, body, true, nil)
+	}
 
-		var respmap map[string]interface{}
-		json.Unmarshal([]byte(respData), &respmap)
-		if respmap["status"].(string) != "ok" {
-			return nil, errors.New(respmap["err-code"].(string))
+	// spot <-> perp
+	if (isSpot(saFrom) && saTo.Market == fintypes.MarketPerp) || (isSpot(saTo) && saFrom.Market == fintypes.MarketPerp) {
+		perp := saTo
+		fromType, toType := "spot", "linear-swap"
+		if !isSpot(saFrom) {
+			perp = saFrom
+			fromType, toType = toType, fromType
 		}
-		datamap := respmap["data"].(map[string]interface{})
-		order = hb.parseContractOneOrder(market, datamap)
-	} else {
+		marginAccount := strings.ToUpper(asset)
+		if perp.Margin == fintypes.MarginIsolated {
+			p, err := pairOf(perp)
+			if err != nil {
+				return err
+			}
+			marginAccount = contractCode(p)
+		}
+		body := map[string]interface{}{
+			"from":           fromType,
+			"to":             toType,
+			"currency":       currency,
+			"amount":         amount.String(),
+			"margin-account": marginAccount,
+		}
+		return hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlTransferContract], nil, body, true, nil)
 	}
 
-	return order, err
+	return gerror.Errorf("unsupported transfer %s -> %s", from, to)
 }
 
-// cancel unfinished order by id
-func (hb *Client) CancelOrder(market fintypes.Market, pair fintypes.Pair, orderId fintypes.OrderId) error {
-	hb.setBaseUrl(market)
-	if fintypes.MarketSpot == market || MarketMargin == market {
-		path := fmt.Sprintf("/v1/order/orders/%s/submitcancel", string(orderId))
+// limit-buy, limit-sell, market-buy, market-sell, stop-limit-buy, stop-limit-sell
+// when market-buy/market-sell, price will be ignored
+// amount: always unit amount, not quote amount, whether trade type is buy or sell.
+func (hb *Client) Trade(market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return hb.TradeContext(context.Background(), market, margin, leverage, target, side, orderType, amount, price, stopPrice)
+}
+
+func (hb *Client) TradeContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return hb.TradeExContext(ctx, fintypes.TradeRequest{Market: market, Margin: margin, Leverage: leverage, Pair: target, Side: side, Type: orderType, Amount: amount, Price: price, StopPrice: stopPrice})
+}
+
+func (hb *Client) TradeEx(req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	return hb.TradeExContext(context.Background(), req)
+}
+
+// only GTC limit, market and stop-limit orders are supported
+// client order id of perp order must be integer, like ids generated by fintypes.NewClientId
+func (hb *Client) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	amount, price, stopPrice, err := hb.normalizeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Market == fintypes.MarketSpot {
+		return hb.tradeSpot(ctx, req.Margin, req.Pair, req.Side, req.Type, amount, price, stopPrice, req.ClientId)
+	}
+	if req.Market == fintypes.MarketPerp {
+		return hb.tradePerp(ctx, req.Margin, req.Leverage, req.Pair, req.Side, req.Type, amount, price, req.ClientId)
+	}
+	return nil, gerror.Errorf("huobi doesn't support Market(%s)", req.Market)
+}
+
+// verify request, round amount and prices by steps, check min amount and min order value before sending
+func (hb *Client) normalizeRequest(ctx context.Context, req fintypes.TradeRequest) (amount, price, stopPrice gdecimal.Decimal, err error) {
+	if err := req.Pair.Verify(); err != nil {
+		return amount, price, stopPrice, err
+	}
+	if err := req.Side.Verify(); err != nil {
+		return amount, price, stopPrice, err
+	}
+	if req.TimeInForce != fintypes.TimeInForceError && req.TimeInForce != fintypes.TimeInForceGTC {
+		return amount, price, stopPrice, gerror.Errorf("huobi doesn't support TimeInForce(%s)", req.TimeInForce)
+	}
+	if req.ReduceOnly {
+		return amount, price, stopPrice, gerror.Errorf("huobi doesn't support reduce only order")
+	}
+	if req.ClientId != "" {
+		if err := fintypes.VerifyClientId(req.ClientId); err != nil {
+			return amount, price, stopPrice, err
+		}
+	}
+
+	info, err := hb.getPairInfo(ctx, req.Pair.SetM(req.Market))
+	if err != nil {
+		return amount, price, stopPrice, err
+	}
+	return info.NormalizeOrder(req.Type, req.Amount, req.Price, req.StopPrice)
+}
+
+func (hb *Client) tradeSpot(ctx context.Context, margin fintypes.Margin, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal, clientId string) (*fintypes.OrderId, error) {
+	body, err := hb.spotOrderBody(ctx, margin, target, side, orderType, amount, price, stopPrice, clientId)
+	if err != nil {
+		return nil, err
+	}
+	var strId number
+	if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlTrade], nil, body, true, &strId); err != nil {
+		return nil, err
+	}
+	res := fintypes.NewOrderId(fintypes.MarketSpot, margin, target, strId.String())
+	return &res, nil
+}
+
+// request body of spot order, it is the same in single and batch placing
+func (hb *Client) spotOrderBody(ctx context.Context, margin fintypes.Margin, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal, clientId string) (map[string]interface{}, error) {
+	source, ok := orderSources[margin]
+	if !ok {
+		return nil, gerror.Errorf("unsupported Margin(%s)", margin)
+	}
+	if !orderType.IsLimit() && !orderType.IsMarket() && !orderType.IsStopLimit() {
+		return nil, gerror.Errorf("unsupported OrderType(%s)", orderType)
+	}
+	accId, err := hb.getAccountId(ctx, margin, target)
+	if err != nil {
+		return nil, err
+	}
+	symbol := target.CustomFormat(hb.Property())
+
+	body := map[string]interface{}{
+		"account-id": accId,
+		"symbol":     symbol,
+		"type":       side.CustomFormat(hb.Property()) + "-" + orderType.CustomFormat(hb.Property()),
+		"amount":     amount.String(),
+		"source":     source,
+	}
+	if clientId != "" {
+		body["client-order-id"] = clientId
+	}
+	if orderType.IsLimit() || orderType.IsStopLimit() {
+		body["price"] = price.String()
+	}
+	if orderType.IsStopLimit() {
+		body["stop-price"] = stopPrice.String()
+		if side.IsBuy() {
+			body["operator"] = "gte"
+		} else {
+			body["operator"] = "lte"
+		}
+	}
+
+	// amount of market buy order is quote amount in huobi
+	if orderType.IsMarket() && side.IsBuy() {
 		params := url.Values{}
-		hb.buildPostForm("POST", path, &params)
-		resp, err := ghttputils.HttpPostForm3(hb.httpClient, hb.baseUrl+path+"?"+params.Encode(), toJson(params),
-			map[string]string{"Content-Type": "application/json", "Accept-Language": "zh-cn"})
+		params.Set("symbol", symbol)
+		resp, err := hb.request(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlTick], params, nil, false)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		var tick struct {
+			Ask []number `json:"ask"`
+		}
+		if err := json.Unmarshal(resp.Tick, &tick); err != nil {
+			return nil, err
+		}
+		if len(tick.Ask) == 0 {
+			return nil, gerror.Errorf("no ask price of %s", target.String())
 		}
+		ask, err := tick.Ask[0].Decimal()
+		if err != nil {
+			return nil, err
+		}
+		info, err := hb.getPairInfo(ctx, target.SetM(fintypes.MarketSpot))
+		if err != nil {
+			return nil, err
+		}
+		body["amount"] = truncString(amount.Mul(ask), info.QuotePrecision)
+	}
+	return body, nil
+}
 
-		var respmap map[string]interface{}
-		err = json.Unmarshal(resp, &respmap)
+func (hb *Client) tradePerp(ctx context.Context, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price gdecimal.Decimal, clientId string) (*fintypes.OrderId, error) {
+	path, err := perpPath(margin, apiUrlTrade, apiUrlCrossTrade)
+	if err != nil {
+		return nil, err
+	}
+	body, err := hb.perpOrderBody(ctx, leverage, target, side, orderType, amount, price, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		OrderIdStr string `json:"order_id_str"`
+	}
+	if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, body, true, &data); err != nil {
+		return nil, err
+	}
+	res := fintypes.NewOrderId(fintypes.MarketPerp, margin, target, data.OrderIdStr)
+	return &res, nil
+}
+
+// path of isolated or cross margin perp api
+func perpPath(margin fintypes.Margin, isolated, cross hbApiUrl) (string, error) {
+	if margin == fintypes.MarginIsolated {
+		return apiPathMap[fintypes.MarketPerp][isolated], nil
+	} else if margin == fintypes.MarginCross {
+		return apiPathMap[fintypes.MarketPerp][cross], nil
+	}
+	return "", gerror.Errorf("Margin(%s) not supported in perp market", margin)
+}
+
+// request body of perp order, it is the same in single and batch placing
+func (hb *Client) perpOrderBody(ctx context.Context, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price gdecimal.Decimal, clientId string) (map[string]interface{}, error) {
+	priceType := ""
+	if orderType.IsLimit() {
+		priceType = "limit"
+	} else if orderType.IsMarket() {
+		priceType = "opponent" // BBO price, it works like market order
+	} else {
+		return nil, gerror.Errorf("unsupported OrderType(%s) in perp market", orderType)
+	}
+	if leverage <= 0 {
+		return nil, gerror.Errorf("invalid leverage %d", leverage)
+	}
+
+	// convert unit amount to contract volume
+	size, err := hb.contractSize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	volume := truncString(amount.Div(size), 0)
+	if volume == "0" || strings.HasPrefix(volume, "-") {
+		return nil, gerror.Errorf("amount %s is less than contract size %s", amount.String(), size.String())
+	}
+
+	body := map[string]interface{}{
+		"contract_code":    contractCode(target),
+		"volume":           json.Number(volume),
+		"direction":        side.CustomFormat(hb.Property()),
+		"offset":           "both",
+		"lever_rate":       leverage,
+		"order_price_type": priceType,
+	}
+	if orderType.IsLimit() {
+		body["price"] = json.Number(price.String())
+	}
+	if clientId != "" {
+		intId, err := perpClientId(clientId)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		body["client_order_id"] = intId
+	}
+	return body, nil
+}
 
-		if respmap["status"].(string) != "ok" {
-			return errors.New(string(resp))
-		}
-	} else if fintypes.MarketFuture == market || fintypes.MarketPerp == market {
-		var path string
-		params := &url.Values{}
-		postData := make(map[string]string)
-		if fintypes.MarketFuture == market {
-			path = ApiPathMap[fintypes.MarketFuture][ApiUrlCancelOrder]
-			postData["order_id"] = string(orderId)
-			postData["symbol"] = string(pair)
-		} else if fintypes.MarketPerp == market {
-			path = ApiPathMap[fintypes.MarketPerp][ApiUrlCancelOrder]
-			postData["order_id"] = string(orderId)
-			postData["contract_code"] = string(pair)
-		} else {
+func (hb *Client) spotOrderToApiOrder(margin fintypes.Margin, src spotOrder) (*fintypes.Order, error) {
+	p, err := fintypes.ParsePairCustom(src.Symbol, hb.Property())
+	if err != nil {
+		return nil, err
+	}
+
+	res := fintypes.Order{}
+	res.Pair = p
+	res.Market = fintypes.MarketSpot
+	res.Margin = margin
+	res.Time = gtime.EpochMillisToTime(src.CreatedAt)
+	res.Id = fintypes.NewOrderId(fintypes.MarketSpot, margin, p, src.Id.String())
+	res.ClientId = src.ClientId
+
+	ss := strings.SplitN(src.Type, "-", 2)
+	if len(ss) != 2 {
+		return nil, gerror.Errorf("unsupported order type(%s)", src.Type)
+	}
+	switch ss[0] {
+	case "buy":
+		res.Side = fintypes.OrderSideBuyLong
+	case "sell":
+		res.Side = fintypes.OrderSideSellShort
+	default:
+		return nil, gerror.Errorf("unsupported OrderSide(%s)", ss[0])
+	}
+	switch ss[1] {
+	case "limit":
+		res.Type = fintypes.OrderTypeLimit
+	case "market":
+		res.Type = fintypes.OrderTypeMarket
+	case "stop-limit":
+		res.Type = fintypes.OrderTypeStopLimit
+	default:
+		return nil, gerror.Errorf("unsupported OrderType(%s)", ss[1])
+	}
+
+	switch src.State {
+	case "created", "pre-submitted", "submitted": // 'created' means stop-limit order not triggered
+		res.Status = fintypes.OrderStatusNew
+	case "partial-filled":
+		res.Status = fintypes.OrderStatusPartiallyFilled
+	case "filled":
+		res.Status = fintypes.OrderStatusFilled
+	case "partial-canceled":
+		res.Status = fintypes.OrderStatusPartiallyCanceled
+	case "canceling":
+		res.Status = fintypes.OrderStatusCanceling
+	case "canceled":
+		res.Status = fintypes.OrderStatusCanceled
+	default:
+		return nil, gerror.Errorf("unsupported order status(%s)", src.State)
+	}
+
+	dealAmount, dealCash, fee := src.FieldAmount, src.FieldCash, src.FieldFees
+	if dealAmount == "" {
+		dealAmount, dealCash, fee = src.FilledAmount, src.FilledCash, src.FilledFees
+	}
+	for _, kv := range []struct {
+		dst *gdecimal.Decimal
+		src number
+	}{{&res.Price, src.Price}, {&res.Amount, src.Amount}, {&res.StopPrice, src.StopPrice}, {&res.DealAmount, dealAmount}, {&res.Fee, fee}} {
+		if *kv.dst, err = kv.src.Decimal(); err != nil {
+			return nil, err
 		}
-		hb.buildPostForm("POST", path, params)
-		urlStr := hb.baseUrl + path + "?" + params.Encode()
-		respData, err := ghttputils.HttpPostForm4(hb.httpClient, urlStr, postData, nil)
+	}
+	cash, err := dealCash.Decimal()
+	if err != nil {
+		return nil, err
+	}
+	res.AvgPrice = gdecimal.Zero
+	if res.DealAmount.IsPositive() {
+		res.AvgPrice = cash.Div(res.DealAmount)
+	}
+	// amount of market buy order is quote amount, unit amount is unknown until it is finished
+	if res.Type.IsMarket() && res.Side.IsBuy() {
+		res.Amount = res.DealAmount
+	}
+	return &res, nil
+}
+
+func (hb *Client) contractOrderToApiOrder(ctx context.Context, margin fintypes.Margin, src contractOrder) (*fintypes.Order, error) {
+	p, err := parseContractCode(src.ContractCode)
+	if err != nil {
+		return nil, err
+	}
+	size, err := hb.contractSize(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	res := fintypes.Order{}
+	res.Pair = p
+	res.Market = fintypes.MarketPerp
+	res.Margin = margin
+	res.Time = gtime.EpochMillisToTime(src.CreatedAt)
+	if src.CreatedAt == 0 {
+		res.Time = gtime.EpochMillisToTime(src.CreateDate)
+	}
+	res.Id = fintypes.NewOrderId(fintypes.MarketPerp, margin, p, src.OrderIdStr)
+	res.ClientId = src.ClientOrderId.String()
+
+	switch src.Direction {
+	case "buy":
+		res.Side = fintypes.OrderSideBuyLong
+	case "sell":
+		res.Side = fintypes.OrderSideSellShort
+	default:
+		return nil, gerror.Errorf("unsupported OrderSide(%s)", src.Direction)
+	}
+	switch src.OrderPriceType {
+	case "limit":
+		res.Type = fintypes.OrderTypeLimit
+	case "opponent", "optimal_5", "optimal_10", "optimal_20", "lightning":
+		res.Type = fintypes.OrderTypeMarket
+	default:
+		return nil, gerror.Errorf("unsupported OrderType(%s)", src.OrderPriceType)
+	}
+	status, ok := contractOrderStatus[src.Status]
+	if !ok {
+		return nil, gerror.Errorf("unsupported order status(%d)", src.Status)
+	}
+	res.Status = status
+
+	volume, err := src.Volume.Decimal()
+	if err != nil {
+		return nil, err
+	}
+	dealVolume, err := src.TradeVolume.Decimal()
+	if err != nil {
+		return nil, err
+	}
+	fee, err := src.Fee.Decimal()
+	if err != nil {
+		return nil, err
+	}
+	res.Amount = volume.Mul(size)
+	res.DealAmount = dealVolume.Mul(size)
+	res.Fee = fee.Abs() // negative in huobi
+	res.StopPrice = gdecimal.Zero
+	if res.Price, err = src.Price.Decimal(); err != nil {
+		return nil, err
+	}
+	if res.AvgPrice, err = src.TradeAvgPrice.Decimal(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// get all my history orders' info
+func (hb *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	return hb.GetAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (hb *Client) GetAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+
+	var r []fintypes.Order
+
+	if market == fintypes.MarketSpot {
+		accId, err := hb.getAccountId(ctx, margin, target)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		var respmap map[string]interface{}
-		json.Unmarshal([]byte(respData), &respmap)
-		if respmap["status"].(string) != "ok" {
-			return errors.New(respmap["err-code"].(string))
+		params := url.Values{}
+		params.Set("symbol", target.CustomFormat(hb.Property()))
+		params.Set("states", "created,submitted,partial-filled,filled,partial-canceled,canceled")
+		var data []spotOrder
+		if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlOrders], params, nil, true, &data); err != nil {
+			return nil, err
+		}
+		for _, o := range data {
+			if o.AccountId.String() != accId {
+				continue // 其他账户的订单
+			}
+			item, err := hb.spotOrderToApiOrder(margin, o)
+			if err != nil {
+				return nil, err
+			}
+			r = append(r, *item)
 		}
-		if nil == respmap["data"] {
-			return errors.New(respmap["err-msg"].(string))
+	} else if market == fintypes.MarketPerp {
+		path := apiPathMap[fintypes.MarketPerp][apiUrlOrders]
+		if margin == fintypes.MarginCross {
+			path = apiPathMap[fintypes.MarketPerp][apiUrlCrossOrders]
+		}
+		body := map[string]interface{}{
+			"contract_code": contractCode(target),
+			"trade_type":    0,   // all
+			"type":          1,   // all orders
+			"status":        "0", // all status
+			"create_date":   90,  // days
+			"page_size":     50,
+		}
+		var data struct {
+			Orders []contractOrder `json:"orders"`
+		}
+		if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, body, true, &data); err != nil {
+			return nil, err
+		}
+		for _, o := range data.Orders {
+			item, err := hb.contractOrderToApiOrder(ctx, margin, o)
+			if err != nil {
+				return nil, err
+			}
+			r = append(r, *item)
 		}
 	} else {
+		return nil, gerror.Errorf("unsupported Market(%s)", market)
 	}
 
-	return nil
+	return r, nil
 }
 
-func (hb *Client) parseDepthData(tick map[string]interface{}, size int) *fintypes.Depth {
-	bids, _ := tick["bids"].([]interface{})
-	asks, _ := tick["asks"].([]interface{})
+// get all my unfinished orders' info
+// NOTE: perp orders are queried only if target is not nil, because huobi requires contract code
+func (hb *Client) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	return hb.GetOpenOrdersContext(context.Background(), market, margin, target)
+}
 
-	depth := new(fintypes.Depth)
-	n := 0
-	for _, r := range asks {
-		var dr fintypes.OrderBook
-		rr := r.([]interface{})
-		dr.Price = gdecimal.NewFromFloat64(rr[0].(float64))
-		dr.Amount = gdecimal.NewFromFloat64(rr[1].(float64))
-		depth.Sells = append(depth.Sells, dr)
-		n++
-		if n == size {
-			break
+func (hb *Client) GetOpenOrdersContext(ctx context.Context, market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	if target != nil {
+		if err := target.Verify(); err != nil {
+			return nil, err
 		}
 	}
 
-	n = 0
-	for _, r := range bids {
-		var dr fintypes.OrderBook
-		rr := r.([]interface{})
-		dr.Price = gdecimal.NewFromFloat64(rr[0].(float64))
-		dr.Amount = gdecimal.NewFromFloat64(rr[1].(float64))
-		depth.Buys = append(depth.Buys, dr)
-		n++
-		if n == size {
-			break
+	var r []fintypes.Order
+
+	// 现货以及现货杠杆
+	if market == nil || *market == fintypes.MarketSpot {
+		accs, err := hb.getAccounts(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, acc := range accs {
+			if margin != nil && *margin != acc.margin {
+				continue
+			}
+			if acc.margin == fintypes.MarginIsolated && target != nil && acc.pair != *target {
+				continue
+			}
+			params := url.Values{}
+			params.Set("account-id", acc.id)
+			if target != nil {
+				params.Set("symbol", target.CustomFormat(hb.Property()))
+			}
+			var data []spotOrder
+			if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlOpenOrders], params, nil, true, &data); err != nil {
+				return nil, err
+			}
+			for _, o := range data {
+				item, err := hb.spotOrderToApiOrder(acc.margin, o)
+				if err != nil {
+					return nil, err
+				}
+				r = append(r, *item)
+			}
 		}
 	}
 
-	return depth
+	// 永续合约
+	if market == nil || *market == fintypes.MarketPerp {
+		if target == nil {
+			if market != nil {
+				return nil, gerror.Errorf("pair required to get open orders of perp market")
+			}
+			return r, nil
+		}
+		for _, m := range []fintypes.Margin{fintypes.MarginIsolated, fintypes.MarginCross} {
+			if margin != nil && *margin != m {
+				continue
+			}
+			path := apiPathMap[fintypes.MarketPerp][apiUrlOpenOrders]
+			if m == fintypes.MarginCross {
+				path = apiPathMap[fintypes.MarketPerp][apiUrlCrossOpenOrders]
+			}
+			body := map[string]interface{}{
+				"contract_code": contractCode(*target),
+				"page_size":     50,
+			}
+			var data struct {
+				Orders []contractOrder `json:"orders"`
+			}
+			if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, body, true, &data); err != nil {
+				return nil, err
+			}
+			for _, o := range data.Orders {
+				item, err := hb.contractOrderToApiOrder(ctx, m, o)
+				if err != nil {
+					return nil, err
+				}
+				r = append(r, *item)
+			}
+		}
+	}
+
+	return r, nil
 }
 
-func (hb *Client) signForm(postForm *url.Values, reqMethod, baseUrl, path string) error {
-	postForm.Set("AccessKeyId", hb.accessKey)
-	postForm.Set("SignatureMethod", "HmacSHA256")
-	postForm.Set("SignatureVersion", "2")
-	postForm.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05"))
-	domain := strings.Replace(baseUrl, "https://", "", len(baseUrl))
-	payload := fmt.Sprintf("%s\n%s\n%s\n%s", reqMethod, domain, path, postForm.Encode())
-	sign, _ := gparam.GetParamHmacSHA256Base64Sign(hb.secretKey, payload)
-	postForm.Set("Signature", sign)
-	return nil
+// get order info by id
+func (hb *Client) GetOrder(id fintypes.OrderId) (*fintypes.Order, error) {
+	return hb.GetOrderContext(context.Background(), id)
 }
 
-func (hb *Client) placeOrder(market fintypes.Market, pair fintypes.Pair, amount, price, orderType string) (string, error) {
-	path := ApiPathMap[market][ApiUrlTrade]
-	gparams := &url.Values{}
-	hb.setBaseUrl(market)
-	err0 := hb.setNewHuobiId(market)
-	if nil != err0 {
-		return "", err0
-	}
-	gparams.Set("accountId-id", hb.accountId)
-	hb.buildPostForm("POST", path, gparams)
-	urlStr := hb.baseUrl + path + "?" + gparams.Encode()
-	postData := make(map[string]string)
-	postData["account-id"] = hb.accountId
-	postData["amount"] = amount
-	postData["symbol"] = pair.Format("", false)
-	postData["type"] = orderType
-
-	if fintypes.MarketSpot == market {
-		postData["source"] = "spot-api"
-	} else if MarketMargin == market {
-		postData["source"] = "margin-api"
-	} else {
+func (hb *Client) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	if err := id.Verify(); err != nil {
+		return nil, err
 	}
-
-	switch orderType {
-	case string(TradeTypeSideLimitBuy), string(TradeTypeSideLimitSell):
-		postData["price"] = price
+	market := id.Market()
+	margin := id.Margin()
+	if margin == fintypes.MarginError {
+		return nil, gerror.Errorf("OrderId(%s) in GetOrder required margin member", id.String())
 	}
 
-	resp, err := ghttputils.HttpPostForm4(hb.httpClient, urlStr, postData, nil)
-	if err != nil {
-		return "", err
+	if market == fintypes.MarketSpot {
+		var data spotOrder
+		path := fmt.Sprintf(apiPathMap[fintypes.MarketSpot][apiUrlOrder], id.StrId())
+		if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, path, nil, nil, true, &data); err != nil {
+			return nil, err
+		}
+		return hb.spotOrderToApiOrder(margin, data)
 	}
 
-	respmap := make(map[string]interface{})
-	err = json.Unmarshal(resp, &respmap)
-	if err != nil {
-		return "", err
+	if market == fintypes.MarketPerp {
+		body := map[string]interface{}{
+			"order_id":      id.StrId(),
+			"contract_code": contractCode(id.Pair()),
+		}
+		od, err := hb.getPerpOrder(ctx, margin, body)
+		if err != nil {
+			return nil, err
+		}
+		if od == nil {
+			return nil, gerror.Errorf("OrderId(%s) not found", id.String())
+		}
+		return od, nil
 	}
 
-	if respmap["status"].(string) != "ok" {
-		return "", errors.New(respmap["err-code"].(string))
+	return nil, gerror.Errorf("unsupported Market(%s)", market)
+}
+
+// get perp order by order_id or client_order_id in body, nil is returned if not found
+func (hb *Client) getPerpOrder(ctx context.Context, margin fintypes.Margin, body map[string]interface{}) (*fintypes.Order, error) {
+	path := apiPathMap[fintypes.MarketPerp][apiUrlOrder]
+	if margin == fintypes.MarginCross {
+		path = apiPathMap[fintypes.MarketPerp][apiUrlCrossOrder]
+	}
+	var data []contractOrder
+	if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, body, true, &data); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
 	}
+	return hb.contractOrderToApiOrder(ctx, margin, data[0])
+}
 
-	return respmap["data"].(string), nil
+// get order by client order id
+// NOTE: huobi keeps client order id of finished spot orders for 2 hours and perp orders for 8 hours only
+func (hb *Client) GetOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	return hb.GetOrderByClientIdContext(context.Background(), market, margin, target, clientId)
 }
 
-func (hb *Client) getProOrders(market fintypes.Market, queryparams QueryOrdersParams) ([]fintypes.Order, error) {
-	params := url.Values{}
-	path := ApiPathMap[market][ApiUrlOrders]
-	var orders []fintypes.Order
-	hb.setBaseUrl(market)
-	params.Set("symbol", queryparams.Pair.Format("", false))
-	params.Set("states", queryparams.States)
+func (hb *Client) GetOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if err := fintypes.VerifyClientId(clientId); err != nil {
+		return nil, err
+	}
 
-	if queryparams.Direct != "" {
-		params.Set("direct", queryparams.Direct)
+	if market == fintypes.MarketSpot {
+		params := url.Values{}
+		params.Set("clientOrderId", clientId)
+		var data spotOrder
+		if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlClientOrder], params, nil, true, &data); err != nil {
+			return nil, err
+		}
+		return hb.spotOrderToApiOrder(margin, data)
 	}
 
-	if queryparams.Size > 0 {
-		params.Set("size", fmt.Sprint(queryparams.Size))
+	if market == fintypes.MarketPerp {
+		intId, err := perpClientId(clientId)
+		if err != nil {
+			return nil, err
+		}
+		body := map[string]interface{}{
+			"client_order_id": intId,
+			"contract_code":   contractCode(target),
+		}
+		od, err := hb.getPerpOrder(ctx, margin, body)
+		if err != nil {
+			return nil, err
+		}
+		if od == nil {
+			return nil, errors.Wrapf(fintypes.ErrOrderNotFound, "client order id(%s)", clientId)
+		}
+		return od, nil
 	}
 
-	hb.buildPostForm("GET", path, &params)
-	respmap, err := ghttputils.HttpGet(hb.httpClient, fmt.Sprintf("%s%s?%s", hb.baseUrl, path, params.Encode()))
-	if err != nil {
-		return nil, err
+	return nil, gerror.Errorf("unsupported Market(%s)", market)
+}
+
+// cancel unfinished order by id
+func (hb *Client) CancelOrder(id fintypes.OrderId) error {
+	return hb.CancelOrderContext(context.Background(), id)
+}
+
+func (hb *Client) CancelOrderContext(ctx context.Context, id fintypes.OrderId) error {
+	if err := id.Verify(); err != nil {
+		return err
+	}
+	market := id.Market()
+	margin := id.Margin()
+	if margin == fintypes.MarginError {
+		return gerror.Errorf("OrderId(%s) in CancelOrder required margin member", id.String())
 	}
 
-	if respmap["status"].(string) != "ok" {
-		return nil, errors.New(respmap["err-code"].(string))
+	if market == fintypes.MarketSpot {
+		path := fmt.Sprintf(apiPathMap[fintypes.MarketSpot][apiUrlCancelOrder], id.StrId())
+		return hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, path, nil, map[string]interface{}{}, true, nil)
 	}
 
-	datamap := respmap["data"].([]interface{})
-	for _, v := range datamap {
-		ordmap := v.(map[string]interface{})
-		ord := hb.parseOrder(market, ordmap)
-		orders = append(orders, *ord)
+	if market == fintypes.MarketPerp {
+		return hb.cancelPerpOrder(ctx, margin, map[string]interface{}{
+			"order_id":      id.StrId(),
+			"contract_code": contractCode(id.Pair()),
+		})
 	}
 
-	return orders, nil
+	return gerror.Errorf("unsupported Market/Margin(%s,%s)", market, margin)
 }
 
-func (hb *Client) getContractOrders(market fintypes.Market, queryparams QueryOrdersParams) ([]fintypes.Order, error) {
-	var orders []fintypes.Order
-	var path string
-	params := &url.Values{}
-	hb.setBaseUrl(market)
-	if "all" == queryparams.Types {
-		path = ApiPathMap[market][ApiUrlOrders]
-	} else if "unfinished" == queryparams.Types {
-		path = ApiPathMap[market][ApiUrlOpenOrders]
-	} else {
+// cancel perp order by order_id or client_order_id in body
+func (hb *Client) cancelPerpOrder(ctx context.Context, margin fintypes.Margin, body map[string]interface{}) error {
+	path := apiPathMap[fintypes.MarketPerp][apiUrlCancelOrder]
+	if margin == fintypes.MarginCross {
+		path = apiPathMap[fintypes.MarketPerp][apiUrlCrossCancelOrder]
 	}
-	hb.buildPostForm("POST", path, params)
-	urlStr := hb.baseUrl + path + "?" + params.Encode()
-	postData := make(map[string]string)
-	if fintypes.MarketFuture == market {
-		postData["symbol"] = queryparams.Symbol // 例如：BTC
-	} else if fintypes.MarketPerp == market {
-		postData["contract_code"] = queryparams.Symbol // 例如：BTC-USD
-	} else {
+	var data perpCancelResult
+	if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, body, true, &data); err != nil {
+		return err
 	}
-	respData, err := ghttputils.HttpPostForm4(hb.httpClient, urlStr, postData, nil)
-	if err != nil {
-		return nil, err
+	if len(data.Errors) > 0 {
+		e := data.Errors[0]
+		return fintypes.NewExError(fintypes.Huobi, strconv.Itoa(e.ErrCode), e.ErrMsg, contractErrorKinds[e.ErrCode])
 	}
-	respmap := make(map[string]interface{})
-	json.Unmarshal(respData, &respmap)
-	status := respmap["status"].(string)
-	if status == "error" {
-		return nil, errors.New(respmap["err-msg"].(string))
+	return nil
+}
+
+// cancel unfinished order by client order id
+func (hb *Client) CancelOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	return hb.CancelOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (hb *Client) CancelOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	if err := target.Verify(); err != nil {
+		return err
+	}
+	if err := fintypes.VerifyClientId(clientId); err != nil {
+		return err
 	}
 
-	var data []interface{}
-	if "all" == queryparams.Types {
-		data = respmap["data"].([]interface{})
-	} else if "unfinished" == queryparams.Types {
-		response := respmap["data"].(map[string]interface{})
-		data = response["orders"].([]interface{})
-	} else {
+	if market == fintypes.MarketSpot {
+		body := map[string]interface{}{"client-order-id": clientId}
+		return hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlClientCancel], nil, body, true, nil)
 	}
 
-	for _, v := range data {
-		ordmap := v.(map[string]interface{})
-		ord := hb.parseContractOrder(market, ordmap)
-		orders = append(orders, ord)
+	if market == fintypes.MarketPerp {
+		intId, err := perpClientId(clientId)
+		if err != nil {
+			return err
+		}
+		return hb.cancelPerpOrder(ctx, margin, map[string]interface{}{
+			"client_order_id": intId,
+			"contract_code":   contractCode(target),
+		})
 	}
 
-	return orders, nil
+	return gerror.Errorf("unsupported Market/Margin(%s,%s)", market, margin)
+}
+
+// place orders by batch api, 10 orders per request, spot and margin orders can be mixed, perp orders are grouped by margin
+func (hb *Client) TradeBatch(reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	return hb.TradeBatchContext(context.Background(), reqs)
 }
 
-func (hb *Client) doRequest(path string, params *url.Values, data interface{}) error {
-	type BaseResponse struct {
-		Status  string          `json:"status"`
-		Ch      string          `json:"ch"`
-		Ts      int64           `json:"ts"`
-		ErrCode int             `json:"err_code"`
-		ErrMsg  string          `json:"err_msg"`
-		Data    json.RawMessage `json:"data"`
+func (hb *Client) TradeBatchContext(ctx context.Context, reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(reqs))
+
+	// build request bodies, invalid orders are not sent
+	bodies := make([]map[string]interface{}, len(reqs))
+	var spotIdx []int
+	perpIdx := map[fintypes.Margin][]int{}
+	for i, req := range reqs {
+		amount, price, stopPrice, err := hb.normalizeRequest(ctx, req)
+		if err == nil {
+			if req.Market == fintypes.MarketSpot {
+				bodies[i], err = hb.spotOrderBody(ctx, req.Margin, req.Pair, req.Side, req.Type, amount, price, stopPrice, req.ClientId)
+			} else if req.Market == fintypes.MarketPerp {
+				if _, err = perpPath(req.Margin, apiUrlBatchTrade, apiUrlCrossBatchTrade); err == nil {
+					bodies[i], err = hb.perpOrderBody(ctx, req.Leverage, req.Pair, req.Side, req.Type, amount, price, req.ClientId)
+				}
+			} else {
+				err = gerror.Errorf("huobi doesn't support Market(%s)", req.Market)
+			}
+		}
+		if err != nil {
+			res[i].Err = err
+			continue
+		}
+		if req.Market == fintypes.MarketSpot {
+			spotIdx = append(spotIdx, i)
+		} else {
+			perpIdx[req.Margin] = append(perpIdx[req.Margin], i)
+		}
 	}
 
-	hb.buildPostForm("POST", path, params)
-	jsonD, _ := utils.ValuesToJson(*params)
-	log.Println(string(jsonD))
-	var ret BaseResponse
+	for start := 0; start < len(spotIdx); start += spotBatchTradeSize {
+		end := start + spotBatchTradeSize
+		if end > len(spotIdx) {
+			end = len(spotIdx)
+		}
+		hb.tradeSpotBatch(ctx, reqs, bodies, spotIdx[start:end], res)
+	}
+	for margin, idx := range perpIdx {
+		for start := 0; start < len(idx); start += perpBatchSize {
+			end := start + perpBatchSize
+			if end > len(idx) {
+				end = len(idx)
+			}
+			hb.tradePerpBatch(ctx, margin, reqs, bodies, idx[start:end], res)
+		}
+	}
+	return res, nil
+}
 
-	resp, err := ghttputils.HttpPostForm3(hb.httpClient,
-		hb.baseUrl+path+"?"+params.Encode(), string(jsonD),
-		map[string]string{"Content-Type": "application/json", "Accept-Language": "zh-cn"})
+// results of spot batch api are in the same order as request
+func (hb *Client) tradeSpotBatch(ctx context.Context, reqs []fintypes.TradeRequest, bodies []map[string]interface{}, idx []int, res []fintypes.OrderResult) {
+	var list []map[string]interface{}
+	for _, i := range idx {
+		list = append(list, bodies[i])
+	}
+	var data []struct {
+		OrderId number `json:"order-id"`
+		ErrCode string `json:"err-code"`
+		ErrMsg  string `json:"err-msg"`
+	}
+	err := hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlBatchTrade], nil, list, true, &data)
+	for j, i := range idx {
+		switch {
+		case err != nil:
+			res[i].Err = err
+		case j >= len(data):
+			res[i].Err = gerror.Errorf("order %d not found in batch response", j)
+		case data[j].ErrCode != "":
+			res[i].Err = fintypes.NewExError(fintypes.Huobi, data[j].ErrCode, data[j].ErrMsg, spotErrorKind(data[j].ErrCode))
+		default:
+			res[i].Id = fintypes.NewOrderId(fintypes.MarketSpot, reqs[i].Margin, reqs[i].Pair, data[j].OrderId.String())
+		}
+	}
+}
 
+// results of perp batch api are identified by index, which starts from 1
+func (hb *Client) tradePerpBatch(ctx context.Context, margin fintypes.Margin, reqs []fintypes.TradeRequest, bodies []map[string]interface{}, idx []int, res []fintypes.OrderResult) {
+	path, err := perpPath(margin, apiUrlBatchTrade, apiUrlCrossBatchTrade)
 	if err != nil {
-		return err
+		for _, i := range idx {
+			res[i].Err = err
+		}
+		return
+	}
+	var list []map[string]interface{}
+	for _, i := range idx {
+		list = append(list, bodies[i])
+	}
+	var data struct {
+		Errors []struct {
+			Index   int    `json:"index"`
+			ErrCode int    `json:"err_code"`
+			ErrMsg  string `json:"err_msg"`
+		} `json:"errors"`
+		Success []struct {
+			Index      int    `json:"index"`
+			OrderIdStr string `json:"order_id_str"`
+		} `json:"success"`
+	}
+	if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, map[string]interface{}{"orders_data": list}, true, &data); err != nil {
+		for _, i := range idx {
+			res[i].Err = err
+		}
+		return
+	}
+	for _, i := range idx {
+		res[i].Err = gerror.Errorf("order not found in batch response")
+	}
+	for _, v := range data.Errors {
+		if v.Index >= 1 && v.Index <= len(idx) {
+			res[idx[v.Index-1]].Err = fintypes.NewExError(fintypes.Huobi, strconv.Itoa(v.ErrCode), v.ErrMsg, contractErrorKinds[v.ErrCode])
+		}
+	}
+	for _, v := range data.Success {
+		if v.Index >= 1 && v.Index <= len(idx) {
+			i := idx[v.Index-1]
+			res[i].Err = nil
+			res[i].Id = fintypes.NewOrderId(fintypes.MarketPerp, margin, reqs[i].Pair, v.OrderIdStr)
+		}
 	}
+}
 
-	log.Println(string(resp))
-	err = json.Unmarshal(resp, &ret)
-	if err != nil {
-		return err
+// cancel orders by batch api, 50 spot orders or 10 perp orders of the same contract per request
+func (hb *Client) CancelOrders(ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	return hb.CancelOrdersContext(context.Background(), ids)
+}
+
+func (hb *Client) CancelOrdersContext(ctx context.Context, ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(ids))
+
+	// group perp orders by margin and contract
+	type perpGroup struct {
+		margin fintypes.Margin
+		pair   fintypes.Pair
+	}
+	var spotIdx []int
+	var perpKeys []perpGroup
+	perpIdx := map[perpGroup][]int{}
+	for i, id := range ids {
+		res[i].Id = id
+		if err := id.Verify(); err != nil {
+			res[i].Err = err
+			continue
+		}
+		if id.Margin() == fintypes.MarginError {
+			res[i].Err = gerror.Errorf("OrderId(%s) in CancelOrders required margin member", id.String())
+			continue
+		}
+		if id.Market() == fintypes.MarketSpot {
+			spotIdx = append(spotIdx, i)
+		} else if id.Market() == fintypes.MarketPerp {
+			key := perpGroup{margin: id.Margin(), pair: id.Pair()}
+			if _, ok := perpIdx[key]; !ok {
+				perpKeys = append(perpKeys, key)
+			}
+			perpIdx[key] = append(perpIdx[key], i)
+		} else {
+			res[i].Err = gerror.Errorf("unsupported Market(%s)", id.Market())
+		}
 	}
 
-	if ret.Status != "ok" {
-		return errors.New(fmt.Sprintf("%d:[%s]", ret.ErrCode, ret.ErrMsg))
+	for start := 0; start < len(spotIdx); start += spotBatchCancelSize {
+		end := start + spotBatchCancelSize
+		if end > len(spotIdx) {
+			end = len(spotIdx)
+		}
+		hb.cancelSpotBatch(ctx, ids, spotIdx[start:end], res)
+	}
+	for _, key := range perpKeys {
+		idx := perpIdx[key]
+		for start := 0; start < len(idx); start += perpBatchSize {
+			end := start + perpBatchSize
+			if end > len(idx) {
+				end = len(idx)
+			}
+			var strIds []string
+			for _, i := range idx[start:end] {
+				strIds = append(strIds, ids[i].StrId())
+			}
+			path, err := perpPath(key.margin, apiUrlCancelOrder, apiUrlCrossCancelOrder)
+			if err == nil {
+				var data perpCancelResult
+				body := map[string]interface{}{
+					"order_id":      strings.Join(strIds, ","),
+					"contract_code": contractCode(key.pair),
+				}
+				if err = hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, body, true, &data); err == nil {
+					errs := data.errs()
+					for _, i := range idx[start:end] {
+						res[i].Err = errs[ids[i].StrId()]
+					}
+					continue
+				}
+			}
+			for _, i := range idx[start:end] {
+				res[i].Err = err
+			}
+		}
 	}
+	return res, nil
+}
+
+func (hb *Client) cancelSpotBatch(ctx context.Context, ids []fintypes.OrderId, idx []int, res []fintypes.OrderResult) {
+	var strIds []string
+	for _, i := range idx {
+		strIds = append(strIds, ids[i].StrId())
+	}
+	var data struct {
+		Failed []struct {
+			OrderId string `json:"order-id"`
+			ErrCode string `json:"err-code"`
+			ErrMsg  string `json:"err-msg"`
+		} `json:"failed"`
+	}
+	err := hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlBatchCancel], nil, map[string]interface{}{"order-ids": strIds}, true, &data)
+	errs := map[string]error{}
+	for _, v := range data.Failed {
+		errs[v.OrderId] = fintypes.NewExError(fintypes.Huobi, v.ErrCode, v.ErrMsg, spotErrorKind(v.ErrCode))
+	}
+	for _, i := range idx {
+		if err != nil {
+			res[i].Err = err
+		} else {
+			res[i].Err = errs[ids[i].StrId()]
+		}
+	}
+}
 
-	return json.Unmarshal(ret.Data, data)
+// errors of failed orders by order id
+func (r perpCancelResult) errs() map[string]error {
+	errs := map[string]error{}
+	for _, e := range r.Errors {
+		errs[e.OrderId] = fintypes.NewExError(fintypes.Huobi, strconv.Itoa(e.ErrCode), e.ErrMsg, contractErrorKinds[e.ErrCode])
+	}
+	return errs
 }
 
-func (hb *Client) parseOrder(market fintypes.Market, ordmap map[string]interface{}) *fintypes.Order {
-	pair, _ := fintypes.ParsePairCustom(ordmap["symbol"].(string), hb.config)
-	ord := fintypes.Order{
-		Id:         fintypes.NewOrderId(market, pair, ordmap["id"].(string)),
-		Time:       time.Unix(ToInt64(ordmap["created-at"].(float64)/1000), 0),
-		Pair:       pair,
-		TypeSide:   CustomFormatTradeTypeSide(ordmap["type"].(string), hb.config),
-		Price:      gdecimal.NewFromFloat64(ordmap["price"].(float64)),
-		Amount:     gdecimal.NewFromFloat64(ordmap["amount"].(float64)),
-		Status:     CustomFormatTradeStatus(ordmap["state"].(string), hb.config),
-		AvgPrice:   gdecimal.Zero,
-		DealAmount: gdecimal.NewFromFloat64(ordmap["field-amount"].(float64)),
-		Fee:        gdecimal.NewFromFloat64(ordmap["field-fees"].(float64)),
+// spot orders are canceled by open orders and batch cancel api, because cancel-all api of spot returns counts only
+func (hb *Client) CancelAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	return hb.CancelAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (hb *Client) CancelAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+
+	if market == fintypes.MarketSpot {
+		ods, err := hb.GetOpenOrdersContext(ctx, &market, &margin, &target)
+		if err != nil {
+			return nil, err
+		}
+		var ids []fintypes.OrderId
+		for _, od := range ods {
+			ids = append(ids, od.Id)
+		}
+		return hb.CancelOrdersContext(ctx, ids)
 	}
 
-	dealAmount := ord.DealAmount.Float64()
-	if dealAmount > 0.0 {
-		ord.AvgPrice = gdecimal.NewFromFloat64(ToFloat64(ordmap["field-cash-amount"]) / dealAmount)
+	if market == fintypes.MarketPerp {
+		path, err := perpPath(margin, apiUrlCancelAll, apiUrlCrossCancelAll)
+		if err != nil {
+			return nil, err
+		}
+		var data perpCancelResult
+		if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketPerp, path, nil, map[string]interface{}{"contract_code": contractCode(target)}, true, &data); err != nil {
+			return nil, err
+		}
+		var res []fintypes.OrderResult
+		for _, strId := range strings.Split(data.Successes, ",") {
+			if strId != "" {
+				res = append(res, fintypes.OrderResult{Id: fintypes.NewOrderId(market, margin, target, strId)})
+			}
+		}
+		for strId, err := range data.errs() {
+			res = append(res, fintypes.OrderResult{Id: fintypes.NewOrderId(market, margin, target, strId), Err: err})
+		}
+		return res, nil
 	}
 
-	return &ord
+	return nil, gerror.Errorf("unsupported Market(%s)", market)
+}
+
+// position management is not supported yet
+func (hb *Client) GetPositions(market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return hb.GetPositionsContext(context.Background(), market, target)
+}
+
+func (hb *Client) GetPositionsContext(ctx context.Context, market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (hb *Client) SetLeverage(market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return hb.SetLeverageContext(context.Background(), market, target, leverage)
+}
+
+func (hb *Client) SetLeverageContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (hb *Client) SetMarginType(market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return hb.SetMarginTypeContext(context.Background(), market, target, margin)
+}
+
+func (hb *Client) SetMarginTypeContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (hb *Client) ClosePosition(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return hb.ClosePositionContext(context.Background(), market, margin, target)
+}
+
+func (hb *Client) ClosePositionContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+// contract market data is not supported yet
+func (hb *Client) GetFundingRates(market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return hb.GetFundingRatesContext(context.Background(), market, target, since)
+}
+
+func (hb *Client) GetFundingRatesContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (hb *Client) GetMarkPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return hb.GetMarkPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (hb *Client) GetMarkPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (hb *Client) GetIndexPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return hb.GetIndexPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (hb *Client) GetIndexPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (hb *Client) GetOpenInterests(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return hb.GetOpenInterestsContext(context.Background(), market, target, period, since)
 }
 
-func (hb *Client) parseContractOrder(market fintypes.Market, ordmap map[string]interface{}) fintypes.Order {
-	pair := fintypes.NewPair(ordmap["contract_code"].(string), "USD")
-	ord := fintypes.Order{
-		Id:         fintypes.NewOrderId(market, pair, ordmap["order_id_str"].(string)),
-		Time:       time.Unix(ToInt64(ordmap["created_at"].(float64)/1000), 0),
-		Pair:       pair,
-		TypeSide:   CustomFormatTradeTypeSide(ordmap["direction"].(string)+"-"+ordmap["order_price_type"].(string), hb.config),
-		Price:      gdecimal.NewFromFloat64(ordmap["price"].(float64)),
-		Amount:     gdecimal.NewFromFloat64(ordmap["volume"].(float64)),
-		Status:     TradeStatusMap[int(ordmap["status"].(float64))],
-		AvgPrice:   gdecimal.NewFromFloat64(ordmap["trade_avg_price"].(float64)),
-		DealAmount: gdecimal.Zero,
-		Fee:        gdecimal.NewFromFloat64(ordmap["fee"].(float64)),
+func (hb *Client) GetOpenInterestsContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+// network is chain name of huobi like "trc20usdt", empty network means the default chain of asset
+func (hb *Client) GetDepositAddress(asset, network string) (*fintypes.DepositAddress, error) {
+	return hb.GetDepositAddressContext(context.Background(), asset, network)
+}
+
+func (hb *Client) GetDepositAddressContext(ctx context.Context, asset, network string) (*fintypes.DepositAddress, error) {
+	if asset == "" {
+		return nil, gerror.Errorf("empty deposit asset")
+	}
+	var data []struct {
+		Currency   string `json:"currency"`
+		Address    string `json:"address"`
+		AddressTag string `json:"addressTag"`
+		Chain      string `json:"chain"`
+	}
+	params := url.Values{}
+	params.Set("currency", strings.ToLower(asset))
+	if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlDepositAddress], params, nil, true, &data); err != nil {
+		return nil, err
+	}
+	// chain of default network is the same as currency, like 'usdt' of USDT on Omni
+	if network == "" && len(data) > 0 {
+		network = data[0].Chain
+		for _, v := range data {
+			if strings.EqualFold(v.Chain, v.Currency) {
+				network = v.Chain
+			}
+		}
+	}
+	for _, v := range data {
+		if strings.EqualFold(v.Chain, network) {
+			return &fintypes.DepositAddress{
+				Asset:   strings.ToUpper(v.Currency),
+				Network: v.Chain,
+				Address: v.Address,
+				Tag:     v.AddressTag,
+			}, nil
+		}
 	}
+	return nil, gerror.Errorf("huobi deposit address of %s on network(%s) not found", asset, network)
+}
 
-	return ord
+// withdrawal address must be in address book of huobi account
+func (hb *Client) Withdraw(req fintypes.WithdrawRequest) (string, error) {
+	return hb.WithdrawContext(context.Background(), req)
 }
 
-func (hb *Client) parseContractOneOrder(market fintypes.Market, ordmap map[string]interface{}) *fintypes.Order {
-	pair := fintypes.NewPair(ordmap["contract_code"].(string), "USD")
-	item := ordmap["trades"].([]interface{})
-	trade := item[0].(map[string]interface{})
-	ord := fintypes.Order{
-		Id:         fintypes.NewOrderId(market, pair, trade["id"].(string)),
-		Time:       time.Unix(ToInt64(trade["created_at"].(float64)/1000), 0),
-		Pair:       pair,
-		TypeSide:   CustomFormatTradeTypeSide(ordmap["direction"].(string)+"-"+ordmap["order_price_type"].(string), hb.config),
-		Price:      gdecimal.NewFromFloat64(ordmap["price"].(float64)),
-		Amount:     gdecimal.NewFromFloat64(ordmap["volume"].(float64)),
-		Status:     TradeStatusMap[6],
-		AvgPrice:   gdecimal.Zero,
-		DealAmount: gdecimal.Zero,
-		Fee:        gdecimal.Zero,
+func (hb *Client) WithdrawContext(ctx context.Context, req fintypes.WithdrawRequest) (string, error) {
+	if err := req.Verify(); err != nil {
+		return "", err
+	}
+	body := map[string]interface{}{
+		"address":  req.Address,
+		"amount":   req.Amount.String(),
+		"currency": strings.ToLower(req.Asset),
 	}
+	if req.Network != "" {
+		body["chain"] = strings.ToLower(req.Network)
+	}
+	if req.Tag != "" {
+		body["addr-tag"] = req.Tag
+	}
+	if req.ClientId != "" {
+		body["client-order-id"] = req.ClientId
+	}
+	var id number
+	if err := hb.requestData(ctx, http.MethodPost, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlWithdraw], nil, body, true, &id); err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
 
-	return &ord
+// get latest 500 deposits at most
+func (hb *Client) GetDeposits(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return hb.GetDepositsContext(context.Background(), asset, since)
 }
 
-func adaptPerpSymbol(pair fintypes.Pair) string {
-	pairStr := pair.Unit() + "-" + pair.Quote()
+func (hb *Client) GetDepositsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return hb.getWalletHistory(ctx, "deposit", asset, since)
+}
 
-	return pairStr
+// get latest 500 withdrawals at most
+func (hb *Client) GetWithdrawals(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return hb.GetWithdrawalsContext(context.Background(), asset, since)
 }
 
-func toJson(params url.Values) string {
-	parammap := make(map[string]string)
-	for k, v := range params {
-		parammap[k] = v[0]
+func (hb *Client) GetWithdrawalsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return hb.getWalletHistory(ctx, "withdraw", asset, since)
+}
+
+// recordType is 'deposit' or 'withdraw', huobi queries history by record id, so since time is filtered here
+func (hb *Client) getWalletHistory(ctx context.Context, recordType, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	statusMap := depositStatus
+	if recordType == "withdraw" {
+		statusMap = withdrawStatus
+	}
+	params := url.Values{}
+	params.Set("type", recordType)
+	params.Set("size", strconv.Itoa(walletHistorySize))
+	if asset != "" {
+		params.Set("currency", strings.ToLower(asset))
+	}
+	var data []walletRecord
+	if err := hb.requestData(ctx, http.MethodGet, fintypes.MarketSpot, apiPathMap[fintypes.MarketSpot][apiUrlWalletHistory], params, nil, true, &data); err != nil {
+		return nil, err
+	}
+
+	var r []fintypes.WalletRecord
+	for _, v := range data {
+		item := fintypes.WalletRecord{
+			Id:       v.Id.String(),
+			ClientId: v.ClientId,
+			Time:     gtime.EpochMillisToTime(v.CreatedAt),
+			Asset:    strings.ToUpper(v.Currency),
+			Network:  v.Chain,
+			Address:  v.Address,
+			Tag:      v.AddressTag,
+			TxId:     v.TxHash,
+		}
+		if since != nil && item.Time.Before(*since) {
+			continue
+		}
+		status, ok := statusMap[v.State]
+		if !ok {
+			return nil, gerror.Errorf("unknown huobi %s state %s", recordType, v.State)
+		}
+		item.Status = status
+		var err error
+		if item.Amount, err = v.Amount.Decimal(); err != nil {
+			return nil, err
+		}
+		if item.Fee, err = v.Fee.Decimal(); err != nil {
+			return nil, err
+		}
+		r = append(r, item)
 	}
-	jsonData, _ := json.Marshal(parammap)
-	return string(jsonData)
+	return r, nil
 }
@@ -1,135 +1,290 @@
 package huobi
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
-
-	"github.com/shawnwyckoff/gopkg/apputil/glogs"
-	"github.com/shawnwyckoff/gopkg/container/gdecimal"
-	"github.com/stretchr/testify/assert"
 )
 
-var (
-	apikey    = "a72631b3-6d1e289e-a64b10cd-bg2hyw2dfg"
-	secretkey = "8df51ad1-e477e214-cad7fb3d-1fc64"
-)
+var testResponses = map[string]string{
+	"/v1/common/symbols":                        `{"status":"ok","data":[{"base-currency":"btc","quote-currency":"usdt","price-precision":2,"amount-precision":6,"state":"online","min-order-amt":0.0001,"leverage-ratio":5,"super-margin-leverage-ratio":3}]}`,
+	"/v1/account/accounts":                      `{"status":"ok","data":[{"id":100009,"type":"spot","subtype":"","state":"working"},{"id":100010,"type":"margin","subtype":"btcusdt","state":"working"}]}`,
+	"/market/depth":                             `{"status":"ok","ts":1577836800000,"tick":{"bids":[[7000.1,1.5],[7000,2]],"asks":[[7000.2,0.5]],"ts":1577836800000}}`,
+	"/market/tickers":                           `{"status":"ok","data":[{"symbol":"btcusdt","open":6900,"high":7100,"low":6800,"close":7000.1,"amount":120.5,"bid":7000.1,"ask":7000.2}]}`,
+	"/market/detail/merged":                     `{"status":"ok","tick":{"close":7000.1,"bid":[7000.1,1.5],"ask":[7000.2,0.5]}}`,
+	"/v1/order/orders/place":                    `{"status":"ok","data":"59378"}`,
+	"/v1/order/orders/getClientOrder":           `{"status":"ok","data":{"id":59378,"client-order-id":"a0001","symbol":"btcusdt","account-id":100009,"amount":"0.5","price":"7100","created-at":1577836800000,"type":"sell-limit","field-amount":"0.2","field-cash-amount":"1420","field-fees":"0","state":"partial-filled"}}`,
+	"/v1/order/batch-orders":                    `{"status":"ok","data":[{"order-id":61713,"client-order-id":"c1"},{"client-order-id":"c2","err-code":"account-balance-insufficient-error","err-msg":"insufficient balance"}]}`,
+	"/v2/account/deposit/address":               `{"code":200,"data":[{"currency":"usdt","address":"0xabc","addressTag":"","chain":"usdterc20"},{"currency":"usdt","address":"1Abc","addressTag":"","chain":"usdt"}]}`,
+	"/v1/dw/withdraw/api/create":                `{"status":"ok","data":700}`,
+	"/v1/query/deposit-withdraw":                `{"status":"ok","data":[{"id":1171,"type":"withdraw","currency":"usdt","chain":"usdterc20","tx-hash":"0xdef","amount":100,"address":"0x123","address-tag":"","fee":1,"state":"confirmed","created-at":1577836800000},{"id":1172,"type":"withdraw","currency":"usdt","chain":"usdterc20","tx-hash":"","amount":"50","address":"0x123","address-tag":"","fee":"1","state":"reexamine","created-at":1577923200000}]}`,
+	"/linear-swap-api/v1/swap_cross_order_info": `{"status":"ok","data":[]}`,
+	"/linear-swap-api/v1/swap_cross_cancel":     `{"status":"ok","data":{"errors":[{"order_id":"2","err_code":1071,"err_msg":"Repeated withdraw."}],"successes":"1"}}`,
+	"/market/history/trade":                     `{"status":"ok","data":[{"id":31459,"ts":1577836800500,"data":[{"id":1001,"trade-id":101,"ts":1577836800500,"amount":0.1,"price":7000.1,"direction":"buy"},{"id":1000,"trade-id":100,"ts":1577836800400,"amount":"0.2","price":"7000","direction":"sell"}]}]}`,
+	"/linear-swap-ex/market/depth":              `{"status":"ok","tick":{"bids":[[7000.1,15]],"asks":[[7000.2,5]],"ts":1577836800000}}`,
+	"/linear-swap-api/v1/swap_contract_info":    `{"status":"ok","data":[{"contract_code":"BTC-USDT","contract_size":0.001,"price_tick":0.1,"contract_status":1}]}`,
+}
 
-var hbpro, _ = New(apikey, secretkey, "")
+// returns a client pointed at a local server, bodies of POST requests are sent to posted
+func newTestClient(t *testing.T, posted chan<- map[string]interface{}) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && posted != nil {
+			b, _ := ioutil.ReadAll(r.Body)
+			body := map[string]interface{}{}
+			_ = json.Unmarshal(b, &body)
+			posted <- body
+		}
+		resp, ok := testResponses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(srv.Close)
 
-func init() {
-	glogs.Log.SetLevel(glogs.DEBUG)
+	hb, err := New("access", "secret", "", nil, "")
+	gtest.Assert(t, err)
+	hb.hosts[fintypes.MarketSpot] = srv.URL
+	hb.hosts[fintypes.MarketPerp] = srv.URL
+	return hb
 }
 
-func TestHuobiPro_Config(t *testing.T) {
-	info := hbpro.Config()
-	t.Log(info)
+func TestSign(t *testing.T) {
+	params := url.Values{}
+	params.Set("AccessKeyId", "e2xxxxxx-99xxxxxx-84xxxxxx-7xxxx")
+	params.Set("SignatureMethod", "HmacSHA256")
+	params.Set("SignatureVersion", "2")
+	params.Set("Timestamp", "2017-05-11T15:19:30")
+	s1 := sign("b0xxxxxx-c6xxxxxx-94xxxxxx-dxxxx", http.MethodGet, "api.huobi.pro", "/v1/order/orders", params)
+	s2 := sign("b0xxxxxx-c6xxxxxx-94xxxxxx-dxxxx", http.MethodGet, "api.huobi.pro", "/v1/order/orders", params)
+	if s1 == "" || s1 != s2 {
+		gtest.PrintlnExit(t, "signature should be stable, but got %s and %s", s1, s2)
+	}
+	if s3 := sign("another", http.MethodGet, "api.huobi.pro", "/v1/order/orders", params); s3 == s1 {
+		gtest.PrintlnExit(t, "signature should depend on secret key")
+	}
 }
 
-func TestHuobiPro_GetAccountInfo(t *testing.T) {
-	// info, err := hbpro.GetAccountInfo(MarketTypeMargin)
-	info, err := hbpro.GetAccountInfo(fintypes.MarketSpot)
-	assert.Nil(t, err)
-	t.Log(info)
-}
+func TestClient_GetDepth(t *testing.T) {
+	hb := newTestClient(t, nil)
+	target := fintypes.BTC.Against(fintypes.USDT)
 
-func TestHuobiPro_GetMarketInfo(t *testing.T) {
-	info, err := hbpro.GetMarketInfo(fintypes.MarketSpot)
-	assert.Nil(t, err)
-	t.Log(info)
-}
+	dp, err := hb.GetDepth(fintypes.MarketSpot, target)
+	gtest.Assert(t, err)
+	if len(dp.Buys) != 2 || len(dp.Sells) != 1 || dp.Buys[0].Price.String() != "7000.1" || dp.Buys[0].Amount.String() != "1.5" {
+		gtest.PrintlnExit(t, "spot depth error %v", dp)
+	}
 
-func TestHuobiPro_GetAccount(t *testing.T) {
-	info, err := hbpro.GetAccount(fintypes.MarketSpot)
-	assert.Nil(t, err)
-	t.Log(info)
+	// perp depth amount is contract volume, it should be converted to unit amount
+	dp, err = hb.GetDepth(fintypes.MarketPerp, target)
+	gtest.Assert(t, err)
+	if len(dp.Buys) != 1 || dp.Buys[0].Amount.String() != "0.015" || dp.Sells[0].Amount.String() != "0.005" {
+		gtest.PrintlnExit(t, "perp depth error %v", dp)
+	}
 }
 
-func TestHuobiPro_GetDepth(t *testing.T) {
-	info, err := hbpro.GetDepth(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt"), 5) // 测试 现货/杠杆 交易
-	// info, err := hbpro.GetDepth(MarketTypeFuture, NewPair("BTC-USD", "USD"), 5) // 测试 交割/永续 交易
-	assert.Nil(t, err)
-	t.Log(info)
-}
+func TestClient_GetDepthContext(t *testing.T) {
+	hb := newTestClient(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-func TestHuobiPro_GetTicks(t *testing.T) {
-	info, err := hbpro.GetTicks(fintypes.MarketSpot)
-	assert.Nil(t, err)
-	t.Log(info)
+	_, err := hb.GetDepthContext(ctx, fintypes.MarketSpot, fintypes.BTC.Against(fintypes.USDT))
+	if !errors.Is(err, context.Canceled) {
+		gtest.PrintlnExit(t, "canceled context should stop request, but got %v", err)
+	}
 }
 
-func TestHuobiPro_GetKline(t *testing.T) {
-	st, _ := time.ParseDuration("-1500s")
-	tm := time.Now().Add(st)
-	period, _ := fintypes.ParsePeriod("5min")
-	info, err := hbpro.GetKline(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt"), period, &tm) // 测试 现货/杠杆 交易
-	// info, err := hbpro.GetKline(MarketTypeFuture, NewPair("BTC-USD", "USD"), period, &tm) // 测试 交割/永续 交易
-	assert.Nil(t, err)
-	t.Log(info)
-}
+func TestClient_GetTicks(t *testing.T) {
+	hb := newTestClient(t, nil)
+	hb.property.MarketEnabled[fintypes.MarketPerp] = false
 
-func TestHuobiPro_GetFills(t *testing.T) {
-	var fromId int64
-	info, err := hbpro.GetFills(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt"), &fromId, 3) // 测试 现货/杠杆 交易
-	// info, err := hbpro.GetFills(MarketTypeFuture, NewPair("BTC-USD", "USD"), &fromId, 3) // 测试 交割/永续 交易
-	assert.Nil(t, err)
-	t.Log(info)
+	ticks, err := hb.GetTicks(false)
+	gtest.Assert(t, err)
+	tk, ok := ticks[fintypes.BTC.Against(fintypes.USDT).SetM(fintypes.MarketSpot)]
+	if !ok || tk.Last.String() != "7000.1" || tk.Sell.String() != "7000.2" || tk.Volume.String() != "120.5" {
+		gtest.PrintlnExit(t, "ticks error %v", ticks)
+	}
 }
 
-func TestHuobiPro_GetBorrowable(t *testing.T) {
-	info, err := hbpro.GetBorrowable(fintypes.NewPair("btc", "usdt"), Unit, MMargin)
-	assert.Nil(t, err)
-	t.Log(info)
-}
+func TestClient_GetFills(t *testing.T) {
+	hb := newTestClient(t, nil)
 
-func TestHuobiPro_Borrow(t *testing.T) {
-	err := hbpro.Borrow(fintypes.NewPair("btc", "usdt"), Quote, MMargin, gdecimal.NewFromFloat64(0.5))
-	assert.Nil(t, err)
-	t.Log(err)
+	fills, err := hb.GetFills(fintypes.MarketSpot, fintypes.BTC.Against(fintypes.USDT), nil, 2)
+	gtest.Assert(t, err)
+	if len(fills) != 2 || fills[0].Id != 101 || fills[0].Side != "buy" || fills[1].UnitQty.String() != "0.2" || fills[1].Time.UnixNano() != 1577836800400*int64(time.Millisecond) {
+		gtest.PrintlnExit(t, "fills error %v", fills)
+	}
+	fromId := int64(101)
+	fills, err = hb.GetFills(fintypes.MarketSpot, fintypes.BTC.Against(fintypes.USDT), &fromId, 2)
+	gtest.Assert(t, err)
+	if len(fills) != 1 || fills[0].Id != 101 {
+		gtest.PrintlnExit(t, "fills from id 101 error %v", fills)
+	}
 }
 
-func TestHuobiPro_Repay(t *testing.T) {
-	err := hbpro.Repay("1116237737", MMargin, gdecimal.NewFromFloat64(0.5)) // order ID不存在
-	assert.Nil(t, err)
-	t.Log(err)
-}
+func TestClient_Trade(t *testing.T) {
+	posted := make(chan map[string]interface{}, 1)
+	hb := newTestClient(t, posted)
+	target := fintypes.BTC.Against(fintypes.USDT)
+
+	id, err := hb.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, target, fintypes.OrderSideSellShort, fintypes.OrderTypeLimit, gdecimal.NewFromFloat64(0.5), gdecimal.NewFromInt(7100), gdecimal.Zero)
+	gtest.Assert(t, err)
+	if id.StrId() != "59378" || id.Market() != fintypes.MarketSpot {
+		gtest.PrintlnExit(t, "order id error %s", id.String())
+	}
+	body := <-posted
+	if body["account-id"] != "100009" || body["symbol"] != "btcusdt" || body["type"] != "sell-limit" || body["amount"] != "0.5" || body["price"] != "7100" {
+		gtest.PrintlnExit(t, "order body error %v", body)
+	}
 
-func TestHuobiPro_Transfer(t *testing.T) {
-	err := hbpro.Transfer(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt"), Quote, gdecimal.NewFromFloat64(0.5))
-	assert.Nil(t, err)
-	t.Log(err)
+	// market buy amount is converted to quote amount by best ask
+	_, err = hb.Trade(fintypes.MarketSpot, fintypes.MarginIsolated, 1, target, fintypes.OrderSideBuyLong, fintypes.OrderTypeMarket, gdecimal.NewFromFloat64(0.5), gdecimal.Zero, gdecimal.Zero)
+	gtest.Assert(t, err)
+	body = <-posted
+	if body["account-id"] != "100010" || body["type"] != "buy-market" || body["amount"] != "3500.1" || body["source"] != "margin-api" {
+		gtest.PrintlnExit(t, "market order body error %v", body)
+	}
 }
 
-func TestHuobiPro_Trade(t *testing.T) {
-	info, err := hbpro.Trade(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt"), TradeTypeSideLimitBuy,
-		gdecimal.NewFromFloat64(0.01), gdecimal.NewFromFloat64(8855.5))
-	assert.Nil(t, err)
-	t.Log(info)
+func TestClient_ClientOrder(t *testing.T) {
+	posted := make(chan map[string]interface{}, 1)
+	hb := newTestClient(t, posted)
+	target := fintypes.BTC.Against(fintypes.USDT)
+
+	req := fintypes.TradeRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: target, Side: fintypes.OrderSideSellShort,
+		Type: fintypes.OrderTypeLimit, Amount: gdecimal.NewFromFloat64(0.5), Price: gdecimal.NewFromInt(7100), ClientId: "a0001"}
+	_, err := hb.TradeEx(req)
+	gtest.Assert(t, err)
+	if body := <-posted; body["client-order-id"] != "a0001" {
+		gtest.PrintlnExit(t, "client order id not sent %v", body)
+	}
+
+	od, err := hb.GetOrderByClientId(fintypes.MarketSpot, fintypes.MarginNo, target, "a0001")
+	gtest.Assert(t, err)
+	if od.ClientId != "a0001" || od.Id.StrId() != "59378" || od.Status != fintypes.OrderStatusPartiallyFilled {
+		gtest.PrintlnExit(t, "order by client id error %v", od)
+	}
+
+	// perp client order id must be integer
+	if _, err := hb.GetOrderByClientId(fintypes.MarketPerp, fintypes.MarginCross, target, "a0001"); err == nil {
+		gtest.PrintlnExit(t, "non-integer client id of perp order should be rejected")
+	}
+	_, err = hb.GetOrderByClientId(fintypes.MarketPerp, fintypes.MarginCross, target, "10001")
+	<-posted
+	if !errors.Is(err, fintypes.ErrOrderNotFound) {
+		gtest.PrintlnExit(t, "expect ErrOrderNotFound, but got %v", err)
+	}
 }
 
-func TestHuobiPro_GetAllOrders(t *testing.T) {
-	info, err := hbpro.GetAllOrders(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt")) // 测试 现货/杠杆 交易
-	// info, err := hbpro.GetAllOrders(MarketTypeFuture, NewPair("BTC-USD", "USD")) // 测试 交割 交易
-	// info, err := hbpro.GetAllOrders(MarketTypePerp, NewPair("BTC-USD", "USD"))   // 测试 永续 交易
-	assert.Nil(t, err)
-	t.Log(info)
+func TestClient_Batch(t *testing.T) {
+	posted := make(chan map[string]interface{}, 1)
+	hb := newTestClient(t, posted)
+	target := fintypes.BTC.Against(fintypes.USDT)
+
+	// too small order is not sent, others are sent in one request
+	req := fintypes.TradeRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: target, Side: fintypes.OrderSideBuyLong, Type: fintypes.OrderTypeLimit, Amount: gdecimal.NewFromFloat64(0.5), Price: gdecimal.NewFromInt(7000)}
+	small := req
+	small.Amount = gdecimal.NewFromFloat64(0.00001)
+	res, err := hb.TradeBatch([]fintypes.TradeRequest{req, req, small})
+	gtest.Assert(t, err)
+	<-posted
+	if len(res) != 3 || res[0].Err != nil || res[0].Id.StrId() != "61713" {
+		gtest.PrintlnExit(t, "first order of batch error %v", res)
+	}
+	if !errors.Is(res[1].Err, fintypes.ErrInsufficientBalance) || res[2].Err == nil {
+		gtest.PrintlnExit(t, "failed orders of batch error %v", res)
+	}
+
+	ids := []fintypes.OrderId{
+		fintypes.NewOrderId(fintypes.MarketPerp, fintypes.MarginCross, target, "1"),
+		fintypes.NewOrderId(fintypes.MarketPerp, fintypes.MarginCross, target, "2"),
+	}
+	res, err = hb.CancelOrders(ids)
+	gtest.Assert(t, err)
+	body := <-posted
+	if body["order_id"] != "1,2" || body["contract_code"] != "BTC-USDT" {
+		gtest.PrintlnExit(t, "batch cancel body error %v", body)
+	}
+	if res[0].Err != nil || res[0].Id != ids[0] || !errors.Is(res[1].Err, fintypes.ErrOrderNotFound) {
+		gtest.PrintlnExit(t, "batch cancel result error %v", res)
+	}
 }
 
-func TestHuobiPro_GetOpenOrders(t *testing.T) {
-	info, err := hbpro.GetOpenOrders(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt")) // 测试 现货/杠杆 交易
-	// info, err := hbpro.GetOpenOrders(MarketSpot, NewPair("BTC-USD", "USD")) // 测试 交割 交易
-	// info, err := hbpro.GetOpenOrders(MarketSpot, NewPair("BTC-USD", "USD")) // 测试 永续 交易
-	assert.Nil(t, err)
-	t.Log(info)
+func TestResponse_err(t *testing.T) {
+	for _, v := range []struct {
+		body string
+		kind error
+	}{
+		{`{"status":"error","err-code":"account-frozen-balance-insufficient-error","err-msg":"trade account balance is not enough"}`, fintypes.ErrInsufficientBalance},
+		{`{"status":"error","err-code":"order-limitorder-price-max-error","err-msg":"limit order price error"}`, fintypes.ErrInvalidOrder},
+		{`{"status":"error","err_code":1032,"err_msg":"The number of access exceeded the limit"}`, fintypes.ErrRateLimited},
+		{`{"code":1003,"message":"invalid signature"}`, fintypes.ErrInvalidApiKey},
+	} {
+		r := &response{}
+		gtest.Assert(t, json.Unmarshal([]byte(v.body), r))
+		err := r.err()
+		var exErr *fintypes.ExError
+		if !errors.Is(err, v.kind) || !errors.As(err, &exErr) || exErr.Platform != fintypes.Huobi {
+			gtest.PrintlnExit(t, "error kind of %s should be %v, but got %v", v.body, v.kind, err)
+		}
+	}
 }
 
-func TestHuobiPro_GetOrder(t *testing.T) {
-	ord, err := hbpro.GetOrder(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt"), "1116237737") // order ID不存在
-	assert.Nil(t, err)
-	t.Log(ord)
+func TestRequestCost(t *testing.T) {
+	for path, rule := range map[string]string{
+		"/linear-swap-api/v1/swap_cross_order":      rulePerpTrade,
+		"/linear-swap-api/v1/swap_cancelall":        rulePerpTrade,
+		"/linear-swap-api/v1/swap_order_info":       rulePerpQuery,
+		"/linear-swap-api/v1/swap_cross_openorders": rulePerpQuery,
+		"/linear-swap-api/v1/swap_hisorders":        rulePerpQuery,
+	} {
+		req := httptest.NewRequest(http.MethodPost, path+"?Signature=x", nil)
+		if cost := requestCost(req); cost[rule] != 1 || len(cost) != 1 {
+			t.Errorf("cost of %s should be charged to %s, but %v", path, rule, cost)
+		}
+	}
 }
 
-func TestHuobiPro_CancelOrder(t *testing.T) {
-	err := hbpro.CancelOrder(fintypes.MarketSpot, fintypes.NewPair("btc", "usdt"), "1116237737") // order ID不存在
-	assert.Nil(t, err)
+func TestClient_Wallet(t *testing.T) {
+	posted := make(chan map[string]interface{}, 1)
+	hb := newTestClient(t, posted)
+
+	addr, err := hb.GetDepositAddress("USDT", "")
+	gtest.Assert(t, err)
+	if addr.Network != "usdt" || addr.Address != "1Abc" {
+		gtest.PrintlnExit(t, "default deposit address %+v", addr)
+	}
+	addr, err = hb.GetDepositAddress("USDT", "usdterc20")
+	gtest.Assert(t, err)
+	if addr.Address != "0xabc" {
+		gtest.PrintlnExit(t, "erc20 deposit address %+v", addr)
+	}
+	if _, err = hb.GetDepositAddress("USDT", "trc20usdt"); err == nil {
+		t.Error("deposit address of unknown network should fail")
+		return
+	}
+
+	id, err := hb.Withdraw(fintypes.WithdrawRequest{Asset: "USDT", Network: "usdterc20", Address: "0x123", Amount: gdecimal.NewFromInt(100)})
+	gtest.Assert(t, err)
+	body := <-posted
+	if id != "700" || body["currency"] != "usdt" || body["chain"] != "usdterc20" || body["amount"] != "100" {
+		gtest.PrintlnExit(t, "withdraw id %s, body %v", id, body)
+	}
+
+	since := time.Unix(1577880000, 0)
+	records, err := hb.GetWithdrawals("USDT", &since)
+	gtest.Assert(t, err)
+	if len(records) != 1 || records[0].Id != "1172" || records[0].Status != fintypes.WalletStatusPending || records[0].Amount.String() != "50" || records[0].Fee.String() != "1" {
+		gtest.PrintlnExit(t, "withdrawals %v", records)
+	}
 }
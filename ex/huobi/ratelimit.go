@@ -0,0 +1,74 @@
+package huobi
+
+import (
+	"github.com/foxtrader/gofin/ex/ratelimit"
+	"github.com/foxtrader/gofin/fintypes"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/**
+火币限频
+1. 现货: 私有接口每个UID 100次/10秒, 公共接口每个IP 100次/10秒
+2. U本位合约: 私有接口交易类和查询类每个UID各 72次/3秒, 公共接口每个IP 120次/3秒
+超限返回429
+*/
+
+const (
+	ruleSpotPublic  = "spotPublic"
+	ruleSpotPrivate = "spotPrivate"
+	rulePerpPublic  = "perpPublic"
+	rulePerpTrade   = "perpTrade"
+	rulePerpQuery   = "perpQuery"
+)
+
+func rateLimitRules(accessKey string, rateLimits map[fintypes.ExApi]time.Duration) []ratelimit.Rule {
+	rules := []ratelimit.Rule{
+		{Name: ruleSpotPublic, Key: "huobi/spot/public", Limit: 100, Interval: time.Second * 10},
+		{Name: ruleSpotPrivate, Key: "huobi/spot/private/" + accessKey, Limit: 100, Interval: time.Second * 10},
+		{Name: rulePerpPublic, Key: "huobi/perp/public", Limit: 120, Interval: time.Second * 3},
+		{Name: rulePerpTrade, Key: "huobi/perp/trade/" + accessKey, Limit: 72, Interval: time.Second * 3},
+		{Name: rulePerpQuery, Key: "huobi/perp/query/" + accessKey, Limit: 72, Interval: time.Second * 3},
+	}
+	return append(rules, ratelimit.RulesOf(rateLimits, "huobi")...)
+}
+
+// trade endpoints of perp, other signed endpoints like swap_order_info and swap_openorders are query ones
+var perpTradePaths = map[string]bool{
+	"swap_order":            true,
+	"swap_batchorder":       true,
+	"swap_cancel":           true,
+	"swap_cancelall":        true,
+	"swap_cross_order":      true,
+	"swap_cross_batchorder": true,
+	"swap_cross_cancel":     true,
+	"swap_cross_cancelall":  true,
+}
+
+func requestCost(req *http.Request) ratelimit.Cost {
+	path := req.URL.Path
+	signed := req.URL.Query().Get("Signature") != ""
+	cost := ratelimit.Cost{}
+
+	if strings.HasPrefix(path, "/linear-swap") {
+		if !signed {
+			cost[rulePerpPublic] = 1
+		} else if perpTradePaths[path[strings.LastIndex(path, "/")+1:]] {
+			cost[rulePerpTrade] = 1
+		} else {
+			cost[rulePerpQuery] = 1
+		}
+	} else if signed {
+		cost[ruleSpotPrivate] = 1
+	} else {
+		cost[ruleSpotPublic] = 1
+	}
+
+	if strings.HasSuffix(path, "/history/kline") {
+		cost[string(fintypes.ExApiGetKline)] = 1
+	} else if strings.HasSuffix(path, "/history/trade") {
+		cost[string(fintypes.ExApiGetFill)] = 1
+	}
+	return cost
+}
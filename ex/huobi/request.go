@@ -0,0 +1,178 @@
+package huobi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// common response of spot api(v1 & v2) and contract api
+	response struct {
+		Status string `json:"status"`
+
+		// spot v1 error
+		ErrCode string `json:"err-code"`
+		ErrMsg  string `json:"err-msg"`
+
+		// contract error
+		ContractErrCode int    `json:"err_code"`
+		ContractErrMsg  string `json:"err_msg"`
+
+		// spot v2
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+
+		Data  json.RawMessage `json:"data"`
+		Tick  json.RawMessage `json:"tick"`
+		Ticks json.RawMessage `json:"ticks"`
+		Ts    int64           `json:"ts"`
+	}
+
+	// huobi returns numbers sometimes with quotes and sometimes without, this type accepts both
+	number string
+)
+
+func (n *number) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" {
+		s = ""
+	}
+	*n = number(s)
+	return nil
+}
+
+func (n number) Decimal() (gdecimal.Decimal, error) {
+	if n == "" {
+		return gdecimal.Zero, nil
+	}
+	return gdecimal.NewFromString(string(n))
+}
+
+func (n number) String() string {
+	return string(n)
+}
+
+func (r *response) err() error {
+	if r.Status == "error" {
+		if r.ErrCode != "" {
+			return fintypes.NewExError(fintypes.Huobi, r.ErrCode, r.ErrMsg, spotErrorKind(r.ErrCode))
+		}
+		code := strconv.Itoa(r.ContractErrCode)
+		return fintypes.NewExError(fintypes.Huobi, code, r.ContractErrMsg, contractErrorKinds[r.ContractErrCode])
+	}
+	// v2 api returns code 200 if success
+	if r.Status == "" && r.Code != 0 && r.Code != 200 {
+		return fintypes.NewExError(fintypes.Huobi, strconv.Itoa(r.Code), r.Message, spotV2ErrorKinds[r.Code])
+	}
+	return nil
+}
+
+// signature version 2, docs: https://huobiapi.github.io/docs/spot/v1/cn/#c64cd15fdc
+func sign(secretKey, method, host, path string, params url.Values) string {
+	payload := fmt.Sprintf("%s\n%s\n%s\n%s", method, host, path, params.Encode())
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// market selects the host, params are sent in url query, body is sent in json if not nil
+func (hb *Client) request(ctx context.Context, method string, market fintypes.Market, path string, params url.Values, body interface{}, signed bool) (*response, error) {
+	host, ok := hb.hosts[market]
+	if !ok {
+		return nil, gerror.Errorf("huobi host of Market(%s) not found", market)
+	}
+	if params == nil {
+		params = url.Values{}
+	}
+	if signed {
+		if hb.accessKey == "" || hb.secretKey == "" {
+			return nil, gerror.Errorf("huobi api key required for %s", path)
+		}
+		u, err := url.Parse(host)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("AccessKeyId", hb.accessKey)
+		params.Set("SignatureMethod", "HmacSHA256")
+		params.Set("SignatureVersion", "2")
+		params.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05"))
+		params.Set("Signature", sign(hb.secretKey, method, u.Host, path, params))
+	}
+
+	reqUrl := host + path
+	if len(params) > 0 {
+		reqUrl += "?" + params.Encode()
+	}
+	var reqBody []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := hb.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fintypes.NewExError(fintypes.Huobi, strconv.Itoa(resp.StatusCode), string(b), fintypes.ErrRateLimited)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fintypes.NewExError(fintypes.Huobi, strconv.Itoa(resp.StatusCode), string(b), fintypes.ErrUnknownResult)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, gerror.Errorf("huobi http status %d: %s", resp.StatusCode, string(b))
+	}
+
+	r := &response{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// request and unmarshal 'data' of response into out
+func (hb *Client) requestData(ctx context.Context, method string, market fintypes.Market, path string, params url.Values, body interface{}, signed bool, out interface{}) error {
+	r, err := hb.request(ctx, method, market, path, params, body, signed)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if len(r.Data) == 0 {
+		return gerror.Errorf("huobi empty data of %s", path)
+	}
+	return json.Unmarshal(r.Data, out)
+}
@@ -0,0 +1,66 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+var (
+	// wait before querying an order whose placing result is unknown, exchanges may not find a just-placed order immediately
+	TradeRetryInterval = time.Second
+)
+
+// whether the request may or may not be processed by exchange, like timeout, broken connection and gateway errors
+func isUnknownResult(err error) bool {
+	var exErr *fintypes.ExError
+	if errors.As(err, &exErr) {
+		return errors.Is(err, fintypes.ErrUnknownResult)
+	}
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// TradeIdempotent places order by TradeExContext with client order id, random one is generated if req.ClientId is empty.
+// if the result is unknown, order is queried by client id before resending, so resubmission never creates duplicate orders,
+// order which already exists with the same client id is returned as success.
+// retries is max count of extra queries and resending, definite errors like insufficient balance are returned without retry.
+func TradeIdempotent(ctx context.Context, e Ex, req fintypes.TradeRequest, retries int) (*fintypes.OrderId, error) {
+	if req.ClientId == "" {
+		req.ClientId = fintypes.NewClientId()
+	}
+	if err := req.Verify(); err != nil {
+		return nil, err
+	}
+
+	id, err := e.TradeExContext(ctx, req)
+	for i := 0; err != nil; i++ {
+		if !errors.Is(err, fintypes.ErrDuplicateOrder) && !isUnknownResult(err) {
+			return nil, err
+		}
+		if i >= retries {
+			return nil, errors.Wrapf(err, "result of client order(%s) is unknown", req.ClientId)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(err, "result of client order(%s) is unknown", req.ClientId)
+		case <-time.After(TradeRetryInterval):
+		}
+
+		od, qerr := e.GetOrderByClientIdContext(ctx, req.Market, req.Margin, req.Pair, req.ClientId)
+		if qerr == nil {
+			return &od.Id, nil
+		}
+		if errors.Is(qerr, fintypes.ErrOrderNotFound) {
+			id, err = e.TradeExContext(ctx, req) // not placed, safe to resend
+		} else {
+			err = qerr // still unknown if query failed by network error
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,82 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"net"
+	"testing"
+)
+
+// accepts all orders, but reports timeout error for the first `timeouts` sends
+type testFlakyEx struct {
+	Ex
+	timeouts int
+	sends    int
+	orders   map[string]fintypes.Order
+}
+
+func (e *testFlakyEx) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	e.sends++
+	if _, ok := e.orders[req.ClientId]; ok {
+		return nil, fintypes.NewExError(fintypes.Binance, "-2010", "Duplicate order sent.", fintypes.ErrDuplicateOrder)
+	}
+	od := fintypes.Order{Id: fintypes.NewOrderId(req.Market, req.Margin, req.Pair, req.ClientId), ClientId: req.ClientId}
+	e.orders[req.ClientId] = od
+	if e.timeouts > 0 {
+		e.timeouts--
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: context.DeadlineExceeded}
+	}
+	return &od.Id, nil
+}
+
+func (e *testFlakyEx) GetOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	od, ok := e.orders[clientId]
+	if !ok {
+		return nil, fintypes.ErrOrderNotFound
+	}
+	return &od, nil
+}
+
+func TestTradeIdempotent(t *testing.T) {
+	TradeRetryInterval = 0
+	req := fintypes.TradeRequest{
+		Market: fintypes.MarketSpot,
+		Margin: fintypes.MarginNo,
+		Pair:   fintypes.BTC.Against(fintypes.USDT),
+		Side:   fintypes.OrderSideBuyLong,
+		Type:   fintypes.OrderTypeMarket,
+		Amount: gdecimal.One,
+	}
+
+	// placed but timeout, it should be found by client id without resending
+	e := &testFlakyEx{timeouts: 1, orders: map[string]fintypes.Order{}}
+	id, err := TradeIdempotent(context.Background(), e, req, 3)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if e.sends != 1 || len(e.orders) != 1 || id.StrId() != e.orders[id.StrId()].ClientId {
+		t.Errorf("order should be sent once, but sent %d times, %d orders created", e.sends, len(e.orders))
+		return
+	}
+
+	// retry with the same client id returns the existing order
+	req.ClientId = id.StrId()
+	id2, err := TradeIdempotent(context.Background(), e, req, 3)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if *id2 != *id || len(e.orders) != 1 {
+		t.Errorf("duplicate order created, %s != %s", id2.String(), id.String())
+		return
+	}
+
+	// retries exhausted
+	e = &testFlakyEx{timeouts: 1, orders: map[string]fintypes.Order{}}
+	req.ClientId = ""
+	if _, err := TradeIdempotent(context.Background(), e, req, 0); err == nil {
+		t.Errorf("unknown result should be reported when retries exhausted")
+	}
+}
@@ -0,0 +1,108 @@
+package kraken
+
+import (
+	"github.com/foxtrader/gofin/fintypes"
+)
+
+type (
+	krApiUrl string
+)
+
+const (
+	apiUrlMarketInfo     krApiUrl = "market-info"
+	apiUrlAccount        krApiUrl = "account"
+	apiUrlDepth          krApiUrl = "depth"
+	apiUrlTicks          krApiUrl = "ticks"
+	apiUrlKline          krApiUrl = "kline"
+	apiUrlTransfer       krApiUrl = "transfer"
+	apiUrlTrade          krApiUrl = "trade"
+	apiUrlClosedOrders   krApiUrl = "closed-orders"
+	apiUrlOpenOrders     krApiUrl = "open-orders"
+	apiUrlOrder          krApiUrl = "order"
+	apiUrlCancelOrder    krApiUrl = "cancel-order"
+	apiUrlWithdrawToSpot krApiUrl = "withdraw-to-spot"
+)
+
+// spot & margin share the same host, perp is kraken futures
+var apiHosts = map[fintypes.Market]string{
+	fintypes.MarketSpot: "https://api.kraken.com",
+	fintypes.MarketPerp: "https://futures.kraken.com",
+}
+
+var apiPathMap = map[fintypes.Market]map[krApiUrl]string{
+	fintypes.MarketSpot: {
+		apiUrlMarketInfo:   "/0/public/AssetPairs",
+		apiUrlAccount:      "/0/private/BalanceEx",
+		apiUrlDepth:        "/0/public/Depth",
+		apiUrlTicks:        "/0/public/Ticker",
+		apiUrlKline:        "/0/public/OHLC",
+		apiUrlTransfer:     "/0/private/WalletTransfer",
+		apiUrlTrade:        "/0/private/AddOrder",
+		apiUrlClosedOrders: "/0/private/ClosedOrders",
+		apiUrlOpenOrders:   "/0/private/OpenOrders",
+		apiUrlOrder:        "/0/private/QueryOrders",
+		apiUrlCancelOrder:  "/0/private/CancelOrder",
+	},
+	fintypes.MarketPerp: {
+		apiUrlMarketInfo:     "/derivatives/api/v3/instruments",
+		apiUrlAccount:        "/derivatives/api/v3/accounts",
+		apiUrlDepth:          "/derivatives/api/v3/orderbook",
+		apiUrlTicks:          "/derivatives/api/v3/tickers",
+		apiUrlKline:          "/api/charts/v1/trade/%s/%s",
+		apiUrlTrade:          "/derivatives/api/v3/sendorder",
+		apiUrlOpenOrders:     "/derivatives/api/v3/openorders",
+		apiUrlOrder:          "/derivatives/api/v3/orders/status",
+		apiUrlCancelOrder:    "/derivatives/api/v3/cancelorder",
+		apiUrlWithdrawToSpot: "/derivatives/api/v3/withdrawal",
+	},
+}
+
+const (
+	// only linear multi-collateral perpetuals are supported, like PF_XBTUSD
+	perpSymbolPrefix = "PF_"
+
+	// the only margin account of kraken futures
+	perpFlexAccount = "flex"
+
+	// kraken wallet names used in transfer
+	walletSpot    = "Spot Wallet"
+	walletFutures = "Futures Wallet"
+)
+
+// resolutions of kraken futures charts api, spot kline periods are in ExProperty
+var perpPeriods = map[fintypes.Period]string{
+	fintypes.Period1Min:   "1m",
+	fintypes.Period5Min:   "5m",
+	fintypes.Period15Min:  "15m",
+	fintypes.Period30Min:  "30m",
+	fintypes.Period1Hour:  "1h",
+	fintypes.Period4Hour:  "4h",
+	fintypes.Period12Hour: "12h",
+	fintypes.Period1Day:   "1d",
+	fintypes.Period1Week:  "1w",
+}
+
+// order types of spot & margin
+var spotOrderTypes = map[string]fintypes.OrderType{
+	"limit":           fintypes.OrderTypeLimit,
+	"market":          fintypes.OrderTypeMarket,
+	"stop-loss-limit": fintypes.OrderTypeStopLimit,
+}
+
+// order types of perp, 'stp' is stop-limit order
+var perpOrderTypes = map[string]fintypes.OrderType{
+	"lmt":  fintypes.OrderTypeLimit,
+	"mkt":  fintypes.OrderTypeMarket,
+	"stp":  fintypes.OrderTypeStopLimit,
+	"stop": fintypes.OrderTypeStopLimit,
+}
+
+// status of perp orders in orders/status api
+var perpOrderStatus = map[string]fintypes.OrderStatus{
+	"ENTERED_BOOK":               fintypes.OrderStatusNew,
+	"TRIGGER_PLACED":             fintypes.OrderStatusNew,
+	"FULLY_EXECUTED":             fintypes.OrderStatusFilled,
+	"CANCELLED":                  fintypes.OrderStatusCanceled,
+	"REJECTED":                   fintypes.OrderStatusRejected,
+	"TRIGGER_ACTIVATION_FAILURE": fintypes.OrderStatusRejected,
+}
@@ -0,0 +1,64 @@
+package kraken
+
+import (
+	"github.com/foxtrader/gofin/fintypes"
+	"strings"
+)
+
+// spot errors are like "EOrder:Insufficient funds", docs: https://docs.kraken.com/rest/#section/General-Usage/Error-Messages
+var spotErrorKinds = map[string]error{
+	"EAPI:Rate limit exceeded":        fintypes.ErrRateLimited,
+	"EOrder:Rate limit exceeded":      fintypes.ErrRateLimited,
+	"EGeneral:Too many requests":      fintypes.ErrRateLimited,
+	"EAPI:Invalid key":                fintypes.ErrInvalidApiKey,
+	"EAPI:Invalid signature":          fintypes.ErrInvalidApiKey,
+	"EAPI:Invalid nonce":              fintypes.ErrInvalidTimestamp,
+	"EGeneral:Permission denied":      fintypes.ErrInvalidApiKey,
+	"EOrder:Insufficient funds":       fintypes.ErrInsufficientBalance,
+	"EOrder:Insufficient margin":      fintypes.ErrInsufficientBalance,
+	"EOrder:Unknown order":            fintypes.ErrOrderNotFound,
+	"EOrder:Order minimum not met":    fintypes.ErrInvalidOrder,
+	"EOrder:Cost minimum not met":     fintypes.ErrMinNotional,
+	"EOrder:Invalid price":            fintypes.ErrInvalidOrder,
+	"EQuery:Unknown asset pair":       fintypes.ErrInvalidPair,
+	"EOrder:Orders limit exceeded":    fintypes.ErrOrderRejected,
+	"EOrder:Positions limit exceeded": fintypes.ErrOrderRejected,
+}
+
+// futures errors and send/cancel status, docs: https://docs.futures.kraken.com/#http-api-http-api-introduction-errors
+var perpErrorKinds = map[string]error{
+	"apiLimitExceeded":           fintypes.ErrRateLimited,
+	"authenticationError":        fintypes.ErrInvalidApiKey,
+	"nonceBelowThreshold":        fintypes.ErrInvalidTimestamp,
+	"nonceDuplicate":             fintypes.ErrInvalidTimestamp,
+	"insufficientAvailableFunds": fintypes.ErrInsufficientBalance,
+	"notFound":                   fintypes.ErrOrderNotFound,
+	"orderForEditNotFound":       fintypes.ErrOrderNotFound,
+	"invalidSize":                fintypes.ErrInvalidOrder,
+	"invalidPrice":               fintypes.ErrInvalidOrder,
+	"tooManySmallOrders":         fintypes.ErrMinNotional,
+	"marketSuspended":            fintypes.ErrOrderRejected,
+	"wouldCauseLiquidation":      fintypes.ErrInsufficientBalance,
+}
+
+func spotError(errs []string) error {
+	msg := strings.Join(errs, ", ")
+	var kind error
+	for _, v := range errs {
+		// some errors have extra info, like "EGeneral:Invalid arguments:volume minimum not met"
+		for k, e := range spotErrorKinds {
+			if strings.HasPrefix(v, k) {
+				kind = e
+				break
+			}
+		}
+		if kind != nil {
+			break
+		}
+	}
+	return fintypes.NewExError(fintypes.Kraken, errs[0], msg, kind)
+}
+
+func perpError(code string) error {
+	return fintypes.NewExError(fintypes.Kraken, code, code, perpErrorKinds[code])
+}
@@ -0,0 +1,1554 @@
+package kraken
+
+/**
+docs:
+spot & margin: https://docs.kraken.com/rest/
+futures: https://docs.futures.kraken.com/
+
+NOTE:
+kraken uses XBT instead of BTC, and legacy assets have X/Z prefix like XXBT/ZUSD, so pair BTC/USD is XBTUSD in request and XXBTZUSD in response.
+asset symbols are mapped by AssetSymbols in ExProperty, pair symbols in response are mapped by market info.
+margin trading of kraken shares the spot wallet, positions are opened by leverage of order, so margin is cross only and there is no borrow/repay api.
+perp market is kraken futures linear multi-collateral perpetual, pair BTC/USD in perp market means PF_XBTUSD, perp amount is unit amount.
+perp margin is cross only (flex account), leverage of perp order is ignored, it should be set in kraken futures preferences.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/foxtrader/gofin/ex/ratelimit"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/net/ghttp"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	spotOrder struct {
+		Status string  `json:"status"`
+		OpenTm float64 `json:"opentm"`
+		Descr  struct {
+			Pair      string `json:"pair"`
+			Type      string `json:"type"`
+			OrderType string `json:"ordertype"`
+			Price     number `json:"price"`  // trigger price of stop-loss-limit order
+			Price2    number `json:"price2"` // limit price of stop-loss-limit order
+			Leverage  string `json:"leverage"`
+		} `json:"descr"`
+		Vol     number `json:"vol"`
+		VolExec number `json:"vol_exec"`
+		Fee     number `json:"fee"`
+		Price   number `json:"price"` // average price
+		ClOrdId string `json:"cl_ord_id"`
+	}
+
+	perpOpenOrder struct {
+		OrderId      string `json:"order_id"`
+		CliOrdId     string `json:"cliOrdId"`
+		Symbol       string `json:"symbol"`
+		Side         string `json:"side"`
+		OrderType    string `json:"orderType"`
+		LimitPrice   number `json:"limitPrice"`
+		StopPrice    number `json:"stopPrice"`
+		UnfilledSize number `json:"unfilledSize"`
+		FilledSize   number `json:"filledSize"`
+		ReceivedTime string `json:"receivedTime"`
+	}
+
+	perpOrderStatusItem struct {
+		Order struct {
+			Type         string `json:"type"` // ORDER or TRIGGER_ORDER
+			OrderId      string `json:"orderId"`
+			CliOrdId     string `json:"cliOrdId"`
+			Symbol       string `json:"symbol"`
+			Side         string `json:"side"`
+			Quantity     number `json:"quantity"`
+			Filled       number `json:"filled"`
+			LimitPrice   number `json:"limitPrice"`
+			TriggerPrice number `json:"triggerPrice"`
+			Timestamp    string `json:"timestamp"`
+		} `json:"order"`
+		Status string `json:"status"`
+	}
+
+	Client struct {
+		property         fintypes.ExProperty
+		httpClient       *http.Client
+		hosts            map[fintypes.Market]string
+		apiKey           string
+		secretKey        string
+		mu               sync.Mutex
+		symbols          map[string]fintypes.Pair // kraken spot pair symbols (like XXBTZUSD and XBTUSD) -> pair
+		marketInfoCache  fintypes.MarketInfo
+		marketInfoUpdate time.Time
+		nonceMu          sync.Mutex
+		nonce            int64
+	}
+)
+
+// email is required in living trading, but not required in kline spider
+// secretKey is base64 encoded private key of kraken
+func New(apiKey, secretKey, proxy string, c gtime.Clock, email string) (*Client, error) {
+	kr := &Client{}
+	kr.property = fintypes.ExProperty{
+		Name:                   fintypes.Kraken,
+		Email:                  email,
+		MaxDepth:               500,
+		MaxFills:               1000,
+		PairDelimiter:          "",
+		PairDelimiterLeftTail:  nil,
+		PairDelimiterRightHead: nil,
+		PairNormalOrder:        true,
+		PairUpperCase:          true,
+		PairsSeparator:         ",",
+		AssetSymbols:           map[string]string{"BTC": "XBT", "DOGE": "XDG"},
+		Periods: map[fintypes.Period]string{fintypes.Period1Min: "1", fintypes.Period5Min: "5", fintypes.Period15Min: "15",
+			fintypes.Period30Min: "30", fintypes.Period1Hour: "60", fintypes.Period4Hour: "240",
+			fintypes.Period1Day: "1440", fintypes.Period1Week: "10080",
+		},
+		OrderStatus: map[fintypes.OrderStatus]string{
+			fintypes.OrderStatusNew: "open", fintypes.OrderStatusFilled: "closed",
+			fintypes.OrderStatusCanceled: "canceled", fintypes.OrderStatusExpired: "expired",
+		},
+		OrderTypes: map[fintypes.OrderType]string{
+			fintypes.OrderTypeLimit: "limit", fintypes.OrderTypeMarket: "market", fintypes.OrderTypeStopLimit: "stop-loss-limit",
+		},
+		RateLimits: map[fintypes.ExApi]time.Duration{
+			fintypes.ExApiGetKline: time.Second,
+			fintypes.ExApiGetFill:  time.Second,
+		},
+		MarketEnabled: map[fintypes.Market]bool{
+			fintypes.MarketSpot: true,
+			fintypes.MarketPerp: true,
+		},
+		IsBackTestEx:   false,
+		TradeBeginTime: time.Date(2013, 9, 10, 0, 0, 0, 0, time.UTC),
+	}
+	if c == nil {
+		kr.property.Clock = gtime.GetSysClock()
+	} else {
+		kr.property.Clock = c
+	}
+
+	kr.httpClient = &http.Client{Timeout: time.Minute}
+	if proxy != "" {
+		if err := ghttp.SetProxy(kr.httpClient, proxy); err != nil {
+			return nil, err
+		}
+	}
+	noWait := func() bool { return kr.property.RateLimitNoWait }
+	kr.httpClient = ratelimit.Wrap(kr.httpClient, ratelimit.New(rateLimitRules(apiKey, kr.property.RateLimits)...), requestCost, noWait)
+	kr.hosts = map[fintypes.Market]string{}
+	for market, host := range apiHosts {
+		kr.hosts[market] = host
+	}
+	kr.apiKey = apiKey
+	kr.secretKey = secretKey
+	kr.symbols = map[string]fintypes.Pair{}
+	kr.marketInfoUpdate = gtime.ZeroTime
+	return kr, nil
+}
+
+// exchange custom settings
+func (kr *Client) Property() *fintypes.ExProperty {
+	return &kr.property
+}
+
+// redirect rest api of market to baseUrl, like a local simulator or testnet
+func (kr *Client) SetBaseUrl(market fintypes.Market, baseUrl string) error {
+	if _, ok := apiHosts[market]; !ok {
+		return gerror.Errorf("kraken doesn't support Market(%s)", market)
+	}
+	kr.hosts[market] = strings.TrimSuffix(baseUrl, "/")
+	return nil
+}
+
+// send requests by rt instead of proxy, like a recording or replaying transport in tests, rate limit is kept
+func (kr *Client) SetTransport(rt http.RoundTripper) {
+	ratelimit.SetBase(kr.httpClient, rt)
+}
+
+// legacy assets of kraken have X/Z prefix, like XXBT and ZUSD
+func (kr *Client) stdAsset(s string) string {
+	s = strings.ToUpper(s)
+	if len(s) == 4 && (s[0] == 'X' || s[0] == 'Z') {
+		s = s[1:]
+	}
+	return kr.property.StdAsset(s)
+}
+
+func (kr *Client) perpSymbol(p fintypes.Pair) string {
+	return perpSymbolPrefix + p.CustomFormat(kr.Property())
+}
+
+func (kr *Client) parsePerpSymbol(s string) (fintypes.Pair, error) {
+	if !strings.HasPrefix(strings.ToUpper(s), perpSymbolPrefix) {
+		return fintypes.PairErr, gerror.Errorf("unsupported kraken futures symbol %s", s)
+	}
+	return fintypes.ParsePairCustom(s[len(perpSymbolPrefix):], kr.Property())
+}
+
+// spot pair symbol in response, like XXBTZUSD or XBTUSD
+func (kr *Client) parseSpotSymbol(ctx context.Context, s string) (fintypes.Pair, error) {
+	if err := kr.loadMarketInfo(ctx); err != nil {
+		return fintypes.PairErr, err
+	}
+	kr.mu.Lock()
+	p, ok := kr.symbols[strings.ToUpper(s)]
+	kr.mu.Unlock()
+	if ok {
+		return p, nil
+	}
+	return fintypes.ParsePairCustom(s, kr.Property())
+}
+
+// 0.0001 for precision 4
+func precisionStep(precision int) gdecimal.Decimal {
+	if precision <= 0 {
+		return gdecimal.One
+	}
+	r, _ := gdecimal.NewFromString("0." + strings.Repeat("0", precision-1) + "1")
+	return r
+}
+
+// fee tiers are [[volume, percent fee]...], fee of lowest tier is used
+func tierFee(tiers [][]number) (gdecimal.Decimal, error) {
+	if len(tiers) == 0 || len(tiers[0]) < 2 {
+		return gdecimal.Zero, nil
+	}
+	fee, err := tiers[0][1].Decimal()
+	if err != nil {
+		return gdecimal.Zero, err
+	}
+	return fee.DivInt(100), nil
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return gtime.ZeroTime
+	}
+	return t
+}
+
+func floatSecondsToTime(sec float64) time.Time {
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+// get all supported pairs, min trade amount
+func (kr *Client) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	return kr.GetMarketInfoContext(context.Background(), ignorePairsNotFound)
+}
+
+func (kr *Client) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	mi := fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+	symbols := map[string]fintypes.Pair{}
+
+	// process spot market info
+	var pairs map[string]struct {
+		Altname      string     `json:"altname"`
+		Wsname       string     `json:"wsname"`
+		PairDecimals int        `json:"pair_decimals"`
+		LotDecimals  int        `json:"lot_decimals"`
+		OrderMin     number     `json:"ordermin"`
+		LeverageBuy  []int      `json:"leverage_buy"`
+		Fees         [][]number `json:"fees"`
+		FeesMaker    [][]number `json:"fees_maker"`
+		Status       string     `json:"status"`
+	}
+	if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlMarketInfo], nil, false, &pairs); err != nil {
+		return nil, err
+	}
+	for key, v := range pairs {
+		ss := strings.Split(v.Wsname, "/")
+		p := fintypes.PairErr
+		if len(ss) == 2 {
+			p = fintypes.NewPair(kr.stdAsset(ss[0]), kr.stdAsset(ss[1]))
+		}
+		if p == fintypes.PairErr {
+			if ignorePairsNotFound {
+				continue
+			}
+			return nil, gerror.Errorf("invalid kraken pair %s", key)
+		}
+		symbols[strings.ToUpper(key)] = p
+		symbols[strings.ToUpper(v.Altname)] = p
+
+		minAmount, err := v.OrderMin.Decimal()
+		if err != nil {
+			return nil, err
+		}
+		takerFee, err := tierFee(v.Fees)
+		if err != nil {
+			return nil, err
+		}
+		makerFee := takerFee
+		if len(v.FeesMaker) > 0 {
+			if makerFee, err = tierFee(v.FeesMaker); err != nil {
+				return nil, err
+			}
+		}
+
+		spotInfo := fintypes.PairInfo{}
+		spotInfo.Enabled = v.Status == "" || v.Status == "online"
+		spotInfo.UnitPrecision = v.LotDecimals
+		spotInfo.QuotePrecision = v.PairDecimals
+		spotInfo.UnitMin = minAmount
+		spotInfo.UnitStep = precisionStep(v.LotDecimals)
+		spotInfo.QuoteStep = precisionStep(v.PairDecimals)
+		spotInfo.MakerFee = makerFee
+		spotInfo.TakerFee = takerFee
+		spotInfo.MarginCrossEnabled = len(v.LeverageBuy) > 0
+		if spotInfo.MarginCrossEnabled {
+			spotInfo.MinLeverage = 1
+			for _, l := range v.LeverageBuy {
+				if l > spotInfo.MaxLeverage {
+					spotInfo.MaxLeverage = l
+				}
+			}
+		}
+		mi.Infos[p.SetM(fintypes.MarketSpot)] = spotInfo
+	}
+
+	// process perp market info
+	if kr.property.MarketEnabled[fintypes.MarketPerp] {
+		var data struct {
+			Instruments []struct {
+				Symbol                 string `json:"symbol"`
+				TickSize               number `json:"tickSize"`
+				ContractValuePrecision int    `json:"contractValuePrecision"`
+				Tradeable              bool   `json:"tradeable"`
+				MarginLevels           []struct {
+					InitialMargin     number `json:"initialMargin"`
+					MaintenanceMargin number `json:"maintenanceMargin"`
+				} `json:"marginLevels"`
+			} `json:"instruments"`
+		}
+		if err := kr.requestPerp(ctx, http.MethodGet, apiPathMap[fintypes.MarketPerp][apiUrlMarketInfo], nil, false, &data); err != nil {
+			return nil, err
+		}
+		for _, v := range data.Instruments {
+			if !strings.HasPrefix(strings.ToUpper(v.Symbol), perpSymbolPrefix) {
+				continue // 只支持线性永续合约
+			}
+			p, err := kr.parsePerpSymbol(v.Symbol)
+			if err != nil {
+				if ignorePairsNotFound {
+					continue
+				}
+				return nil, err
+			}
+			tick, err := v.TickSize.Decimal()
+			if err != nil {
+				return nil, err
+			}
+
+			perpInfo := fintypes.PairInfo{}
+			perpInfo.Enabled = v.Tradeable
+			perpInfo.UnitPrecision = v.ContractValuePrecision
+			perpInfo.UnitMin = precisionStep(v.ContractValuePrecision)
+			perpInfo.UnitStep = precisionStep(v.ContractValuePrecision)
+			perpInfo.QuoteStep = tick
+			perpInfo.MakerFee = gdecimal.NewFromFloat64(0.0002) // FIXME 目前暂时统一填写0.0002，以后可能更改
+			perpInfo.TakerFee = gdecimal.NewFromFloat64(0.0005) // FIXME 目前暂时统一填写0.0005，以后可能更改
+			perpInfo.MarginCrossEnabled = true
+			if len(v.MarginLevels) > 0 {
+				if perpInfo.RequiredMarginPercent, err = v.MarginLevels[0].InitialMargin.Decimal(); err != nil {
+					return nil, err
+				}
+				if perpInfo.MaintMarginPercent, err = v.MarginLevels[0].MaintenanceMargin.Decimal(); err != nil {
+					return nil, err
+				}
+				if perpInfo.RequiredMarginPercent.IsPositive() {
+					perpInfo.MinLeverage = 1
+					perpInfo.MaxLeverage = int(1 / perpInfo.RequiredMarginPercent.Float64())
+				}
+			}
+			mi.Infos[p.SetM(fintypes.MarketPerp)] = perpInfo
+		}
+	}
+
+	// cache it
+	kr.mu.Lock()
+	kr.symbols = symbols
+	kr.marketInfoCache = mi
+	kr.marketInfoUpdate = kr.property.Clock.Now()
+	kr.mu.Unlock()
+
+	return &mi, nil
+}
+
+// market info will be updated if necessary
+func (kr *Client) loadMarketInfo(ctx context.Context) error {
+	kr.mu.Lock()
+	expired := kr.marketInfoCache.Infos == nil || kr.property.Clock.Now().Sub(kr.marketInfoUpdate) > gtime.Day
+	kr.mu.Unlock()
+	if expired {
+		if _, err := kr.GetMarketInfoContext(ctx, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get account info includes all currency balances
+func (kr *Client) GetAccount() (*fintypes.Account, error) {
+	return kr.GetAccountContext(context.Background())
+}
+
+func (kr *Client) GetAccountContext(ctx context.Context) (*fintypes.Account, error) {
+	r := fintypes.NewEmptyAccount()
+
+	// spot wallet, which is shared by margin trading
+	var balances map[string]struct {
+		Balance   number `json:"balance"`
+		HoldTrade number `json:"hold_trade"`
+	}
+	if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlAccount], nil, true, &balances); err != nil {
+		return nil, err
+	}
+	for asset, v := range balances {
+		if strings.Contains(asset, ".") {
+			continue // staking/earn assets like XBT.F
+		}
+		total, err := v.Balance.Decimal()
+		if err != nil {
+			return nil, err
+		}
+		hold, err := v.HoldTrade.Decimal()
+		if err != nil {
+			return nil, err
+		}
+		b := fintypes.Balance{}
+		b.Market = fintypes.MarketSpot
+		b.Margin = fintypes.MarginNo
+		b.Asset = kr.stdAsset(asset)
+		b.Free = total.Sub(hold)
+		b.Locked = hold
+		b.Borrowed = gdecimal.Zero
+		b.Interest = gdecimal.Zero
+		if b.IsZero() {
+			continue
+		}
+		r.Balances = append(r.Balances, b)
+	}
+
+	if !kr.property.MarketEnabled[fintypes.MarketPerp] {
+		return r, nil
+	}
+
+	// futures flex account
+	var data struct {
+		Accounts map[string]struct {
+			Currencies map[string]struct {
+				Quantity  number `json:"quantity"`
+				Available number `json:"available"`
+			} `json:"currencies"`
+		} `json:"accounts"`
+	}
+	if err := kr.requestPerp(ctx, http.MethodGet, apiPathMap[fintypes.MarketPerp][apiUrlAccount], nil, true, &data); err != nil {
+		return nil, err
+	}
+	for asset, v := range data.Accounts[perpFlexAccount].Currencies {
+		quantity, err := v.Quantity.Decimal()
+		if err != nil {
+			return nil, err
+		}
+		available, err := v.Available.Decimal()
+		if err != nil {
+			return nil, err
+		}
+		b := fintypes.Balance{}
+		b.Market = fintypes.MarketPerp
+		b.Margin = fintypes.MarginCross
+		b.Asset = kr.stdAsset(asset)
+		b.Free = available
+		b.Locked = gdecimal.Max(quantity.Sub(available), gdecimal.Zero)
+		b.Borrowed = gdecimal.Zero
+		b.Interest = gdecimal.Zero
+		if b.IsZero() {
+			continue
+		}
+		r.Balances = append(r.Balances, b)
+	}
+
+	return r, nil
+}
+
+func parseOrderBookList(src [][]number) (fintypes.OrderBookList, error) {
+	var r fintypes.OrderBookList
+	for _, v := range src {
+		if len(v) < 2 {
+			return nil, gerror.Errorf("invalid order book %v", v)
+		}
+		price, err := v[0].Decimal()
+		if err != nil {
+			return nil, err
+		}
+		amount, err := v[1].Decimal()
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, fintypes.OrderBook{Price: price, Amount: amount})
+	}
+	return r, nil
+}
+
+// get open order books
+func (kr *Client) GetDepth(market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	return kr.GetDepthContext(context.Background(), market, target)
+}
+
+func (kr *Client) GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+
+	var book struct {
+		Bids [][]number `json:"bids"`
+		Asks [][]number `json:"asks"`
+	}
+	res := fintypes.Depth{}
+
+	if market == fintypes.MarketSpot {
+		params := url.Values{}
+		params.Set("pair", target.CustomFormat(kr.Property()))
+		params.Set("count", fmt.Sprintf("%d", kr.property.MaxDepth))
+		var data map[string]json.RawMessage // key is kraken pair name like XXBTZUSD
+		if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlDepth], params, false, &data); err != nil {
+			return nil, err
+		}
+		for _, v := range data {
+			if err := json.Unmarshal(v, &book); err != nil {
+				return nil, err
+			}
+			break
+		}
+		res.Time = kr.property.Clock.Now()
+	} else if market == fintypes.MarketPerp {
+		params := url.Values{}
+		params.Set("symbol", kr.perpSymbol(target))
+		var data struct {
+			OrderBook  json.RawMessage `json:"orderBook"`
+			ServerTime string          `json:"serverTime"`
+		}
+		if err := kr.requestPerp(ctx, http.MethodGet, apiPathMap[fintypes.MarketPerp][apiUrlDepth], params, false, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data.OrderBook, &book); err != nil {
+			return nil, err
+		}
+		res.Time = parseTime(data.ServerTime)
+	} else {
+		return nil, gerror.Errorf("kraken doesn't support Market(%s)", market)
+	}
+
+	var err error
+	res.Buys, err = parseOrderBookList(book.Bids)
+	if err != nil {
+		return nil, err
+	}
+	res.Sells, err = parseOrderBookList(book.Asks)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// get all ticks
+func (kr *Client) GetTicks(ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	return kr.GetTicksContext(context.Background(), ignorePairsNotFound)
+}
+
+func (kr *Client) GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	res := make(map[fintypes.PairM]fintypes.Tick)
+	now := kr.property.Clock.Now()
+
+	// a: ask, b: bid, c: last trade, v: volume, h: high, l: low, the second of v/h/l is last 24 hours
+	var ticks map[string]struct {
+		A []number `json:"a"`
+		B []number `json:"b"`
+		C []number `json:"c"`
+		V []number `json:"v"`
+		H []number `json:"h"`
+		L []number `json:"l"`
+	}
+	if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlTicks], nil, false, &ticks); err != nil {
+		return nil, err
+	}
+	at := func(ns []number, idx int) number {
+		if idx < len(ns) {
+			return ns[idx]
+		}
+		return ""
+	}
+	for key, v := range ticks {
+		pair, err := kr.parseSpotSymbol(ctx, key)
+		if err != nil {
+			if ignorePairsNotFound {
+				continue
+			} else {
+				return nil, err
+			}
+		}
+		item := fintypes.Tick{Time: now}
+		for _, kv := range []struct {
+			dst *gdecimal.Decimal
+			src number
+		}{{&item.Last, at(v.C, 0)}, {&item.Buy, at(v.B, 0)}, {&item.Sell, at(v.A, 0)}, {&item.High, at(v.H, 1)}, {&item.Low, at(v.L, 1)}, {&item.Volume, at(v.V, 1)}} {
+			if *kv.dst, err = kv.src.Decimal(); err != nil {
+				return nil, err
+			}
+		}
+		res[pair.SetM(fintypes.MarketSpot)] = item
+	}
+
+	if !kr.property.MarketEnabled[fintypes.MarketPerp] {
+		return res, nil
+	}
+
+	var data struct {
+		Tickers []struct {
+			Symbol  string `json:"symbol"`
+			Last    number `json:"last"`
+			Bid     number `json:"bid"`
+			Ask     number `json:"ask"`
+			High24h number `json:"high24h"`
+			Low24h  number `json:"low24h"`
+			Vol24h  number `json:"vol24h"`
+		} `json:"tickers"`
+	}
+	if err := kr.requestPerp(ctx, http.MethodGet, apiPathMap[fintypes.MarketPerp][apiUrlTicks], nil, false, &data); err != nil {
+		return nil, err
+	}
+	for _, v := range data.Tickers {
+		if !strings.HasPrefix(strings.ToUpper(v.Symbol), perpSymbolPrefix) {
+			continue // 只支持线性永续合约
+		}
+		pair, err := kr.parsePerpSymbol(v.Symbol)
+		if err != nil {
+			if ignorePairsNotFound {
+				continue
+			} else {
+				return nil, err
+			}
+		}
+		item := fintypes.Tick{Time: now}
+		for _, kv := range []struct {
+			dst *gdecimal.Decimal
+			src number
+		}{{&item.Last, v.Last}, {&item.Buy, v.Bid}, {&item.Sell, v.Ask}, {&item.High, v.High24h}, {&item.Low, v.Low24h}, {&item.Volume, v.Vol24h}} {
+			if *kv.dst, err = kv.src.Decimal(); err != nil {
+				return nil, err
+			}
+		}
+		res[pair.SetM(fintypes.MarketPerp)] = item
+	}
+
+	return res, nil
+}
+
+// get candle bars
+// kraken spot returns at most 720 bars
+func (kr *Client) GetKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return kr.GetKlineContext(context.Background(), market, target, period, since)
+}
+
+func (kr *Client) GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+
+	r := new(fintypes.Kline)
+	r.Pair = target.SetI(period).SetM(market).SetP(fintypes.Kraken)
+
+	if market == fintypes.MarketSpot {
+		interval, err := period.CustomFormat(kr.Property())
+		if err != nil {
+			return nil, err
+		}
+		params := url.Values{}
+		params.Set("pair", target.CustomFormat(kr.Property()))
+		params.Set("interval", interval)
+		if since != nil {
+			params.Set("since", fmt.Sprintf("%d", since.Unix()-1))
+		}
+		var data map[string]json.RawMessage // key is kraken pair name like XXBTZUSD, and 'last'
+		if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlKline], params, false, &data); err != nil {
+			return nil, err
+		}
+		for key, raw := range data {
+			if key == "last" {
+				continue
+			}
+			// [time, open, high, low, close, vwap, volume, count]
+			var bars [][]number
+			if err := json.Unmarshal(raw, &bars); err != nil {
+				return nil, err
+			}
+			for _, v := range bars {
+				if len(v) < 7 {
+					return nil, gerror.Errorf("invalid kraken bar %v", v)
+				}
+				sec, err := v[0].Decimal()
+				if err != nil {
+					return nil, err
+				}
+				item := fintypes.Bar{}
+				item.T = time.Unix(int64(sec.Float64()), 0).UTC()
+				for i, dst := range []*gdecimal.Decimal{&item.O, &item.H, &item.L, &item.C} {
+					if *dst, err = v[i+1].Decimal(); err != nil {
+						return nil, err
+					}
+				}
+				if item.V, err = v[6].Decimal(); err != nil {
+					return nil, err
+				}
+				r.Items = append(r.Items, item)
+			}
+		}
+	} else if market == fintypes.MarketPerp {
+		resolution, ok := perpPeriods[period]
+		if !ok {
+			return nil, gerror.Errorf("kraken futures doesn't support Period(%s)", period)
+		}
+		params := url.Values{}
+		if since != nil {
+			params.Set("from", fmt.Sprintf("%d", since.Unix()))
+		}
+		var data struct {
+			Candles []struct {
+				Time   int64  `json:"time"` // milliseconds
+				Open   number `json:"open"`
+				High   number `json:"high"`
+				Low    number `json:"low"`
+				Close  number `json:"close"`
+				Volume number `json:"volume"`
+			} `json:"candles"`
+		}
+		path := fmt.Sprintf(apiPathMap[fintypes.MarketPerp][apiUrlKline], kr.perpSymbol(target), resolution)
+		if err := kr.requestPerp(ctx, http.MethodGet, path, params, false, &data); err != nil {
+			return nil, err
+		}
+		var err error
+		for _, v := range data.Candles {
+			item := fintypes.Bar{}
+			item.T = gtime.EpochMillisToTime(v.Time)
+			for _, kv := range []struct {
+				dst *gdecimal.Decimal
+				src number
+			}{{&item.O, v.Open}, {&item.H, v.High}, {&item.L, v.Low}, {&item.C, v.Close}, {&item.V, v.Volume}} {
+				if *kv.dst, err = kv.src.Decimal(); err != nil {
+					return nil, err
+				}
+			}
+			r.Items = append(r.Items, item)
+		}
+	} else {
+		return nil, gerror.Errorf("kraken doesn't support Market(%s)", market)
+	}
+
+	if since != nil {
+		r = r.SliceAfterEqual(*since)
+	}
+	r.Sort()
+	return r, nil
+}
+
+// kraken margin has no borrow api, loans are opened by leverage of orders
+func (kr *Client) GetBorrowable(margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	return kr.GetBorrowableContext(context.Background(), margin, asset)
+}
+
+func (kr *Client) GetBorrowableContext(ctx context.Context, margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	return gdecimal.Zero, fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) Borrow(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return kr.BorrowContext(context.Background(), margin, asset, amount)
+}
+
+func (kr *Client) BorrowContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) Repay(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return kr.RepayContext(context.Background(), margin, asset, amount)
+}
+
+func (kr *Client) RepayContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+// transfer between spot wallet and futures wallet
+func (kr *Client) Transfer(asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	return kr.TransferContext(context.Background(), asset, amount, from, to)
+}
+
+func (kr *Client) TransferContext(ctx context.Context, asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	saFrom, err := from.Parse()
+	if err != nil {
+		return err
+	}
+	saTo, err := to.Parse()
+	if err != nil {
+		return err
+	}
+	customAsset := kr.property.CustomAsset(strings.ToUpper(asset))
+
+	if saFrom.Market == fintypes.MarketSpot && saTo.Market == fintypes.MarketPerp {
+		params := url.Values{}
+		params.Set("asset", customAsset)
+		params.Set("from", walletSpot)
+		params.Set("to", walletFutures)
+		params.Set("amount", amount.String())
+		return kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlTransfer], params, true, nil)
+	}
+	if saFrom.Market == fintypes.MarketPerp && saTo.Market == fintypes.MarketSpot {
+		params := url.Values{}
+		params.Set("currency", strings.ToLower(customAsset))
+		params.Set("amount", amount.String())
+		return kr.requestPerp(ctx, http.MethodPost, apiPathMap[fintypes.MarketPerp][apiUrlWithdrawToSpot], params, true, nil)
+	}
+	return gerror.Errorf("unsupported transfer %s -> %s", from, to)
+}
+
+// limit-buy, limit-sell, market-buy, market-sell, stop-limit-buy, stop-limit-sell
+// when market-buy/market-sell, price will be ignored
+// amount: always unit amount, not quote amount, whether trade type is buy or sell.
+func (kr *Client) Trade(market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return kr.TradeContext(context.Background(), market, margin, leverage, target, side, orderType, amount, price, stopPrice)
+}
+
+func (kr *Client) TradeContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return kr.TradeExContext(ctx, fintypes.TradeRequest{Market: market, Margin: margin, Leverage: leverage, Pair: target, Side: side, Type: orderType, Amount: amount, Price: price, StopPrice: stopPrice})
+}
+
+func (kr *Client) TradeEx(req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	return kr.TradeExContext(context.Background(), req)
+}
+
+// only GTC limit, market and stop-limit orders are supported
+// client order id of spot order is 18 chars at most, like ids generated by fintypes.NewClientId
+func (kr *Client) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	market, margin, leverage, target, side, orderType := req.Market, req.Margin, req.Leverage, req.Pair, req.Side, req.Type
+	amount, price, stopPrice := req.Amount, req.Price, req.StopPrice
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if err := side.Verify(); err != nil {
+		return nil, err
+	}
+	if !orderType.IsLimit() && !orderType.IsMarket() && !orderType.IsStopLimit() {
+		return nil, gerror.Errorf("unsupported OrderType(%s)", orderType)
+	}
+	if req.TimeInForce != fintypes.TimeInForceError && req.TimeInForce != fintypes.TimeInForceGTC {
+		return nil, gerror.Errorf("kraken doesn't support TimeInForce(%s)", req.TimeInForce)
+	}
+	if req.ReduceOnly {
+		return nil, gerror.Errorf("kraken doesn't support reduce only order")
+	}
+	if req.ClientId != "" {
+		if err := fintypes.VerifyClientId(req.ClientId); err != nil {
+			return nil, err
+		}
+	}
+
+	if market == fintypes.MarketSpot {
+		params := url.Values{}
+		params.Set("pair", target.CustomFormat(kr.Property()))
+		params.Set("type", side.CustomFormat(kr.Property()))
+		params.Set("ordertype", orderType.CustomFormat(kr.Property()))
+		params.Set("volume", amount.String())
+		if orderType.IsLimit() {
+			params.Set("price", price.String())
+		} else if orderType.IsStopLimit() {
+			params.Set("price", stopPrice.String())
+			params.Set("price2", price.String())
+		}
+		if req.ClientId != "" {
+			params.Set("cl_ord_id", req.ClientId)
+		}
+		switch margin {
+		case fintypes.MarginNo:
+		case fintypes.MarginCross:
+			if leverage < 2 {
+				return nil, gerror.Errorf("leverage of kraken margin order should be greater than 1, but got %d", leverage)
+			}
+			params.Set("leverage", fmt.Sprintf("%d", leverage))
+		default:
+			return nil, gerror.Errorf("unsupported Margin(%s)", margin)
+		}
+
+		var data struct {
+			Txid []string `json:"txid"`
+		}
+		if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlTrade], params, true, &data); err != nil {
+			return nil, err
+		}
+		if len(data.Txid) == 0 {
+			return nil, gerror.Errorf("kraken empty txid of new order")
+		}
+		res := fintypes.NewOrderId(fintypes.MarketSpot, margin, target, data.Txid[0])
+		return &res, nil
+	}
+
+	if market == fintypes.MarketPerp {
+		if margin != fintypes.MarginCross {
+			return nil, gerror.Errorf("Margin(%s) not supported in perp market", margin)
+		}
+		params := url.Values{}
+		params.Set("symbol", kr.perpSymbol(target))
+		params.Set("side", side.CustomFormat(kr.Property()))
+		params.Set("size", amount.String())
+		if orderType.IsLimit() {
+			params.Set("orderType", "lmt")
+			params.Set("limitPrice", price.String())
+		} else if orderType.IsMarket() {
+			params.Set("orderType", "mkt")
+		} else {
+			params.Set("orderType", "stp")
+			params.Set("limitPrice", price.String())
+			params.Set("stopPrice", stopPrice.String())
+		}
+		if req.ClientId != "" {
+			params.Set("cliOrdId", req.ClientId)
+		}
+
+		var data struct {
+			SendStatus struct {
+				OrderId string `json:"order_id"`
+				Status  string `json:"status"`
+			} `json:"sendStatus"`
+		}
+		if err := kr.requestPerp(ctx, http.MethodPost, apiPathMap[fintypes.MarketPerp][apiUrlTrade], params, true, &data); err != nil {
+			return nil, err
+		}
+		if data.SendStatus.Status != "placed" {
+			return nil, perpError(data.SendStatus.Status)
+		}
+		res := fintypes.NewOrderId(fintypes.MarketPerp, margin, target, data.SendStatus.OrderId)
+		return &res, nil
+	}
+
+	return nil, gerror.Errorf("kraken doesn't support Market(%s)", market)
+}
+
+func (kr *Client) spotOrderToApiOrder(ctx context.Context, txid string, src spotOrder) (*fintypes.Order, error) {
+	p, err := kr.parseSpotSymbol(ctx, src.Descr.Pair)
+	if err != nil {
+		return nil, err
+	}
+	margin := fintypes.MarginNo
+	if src.Descr.Leverage != "" && src.Descr.Leverage != "none" {
+		margin = fintypes.MarginCross
+	}
+
+	res := fintypes.Order{}
+	res.Pair = p
+	res.Market = fintypes.MarketSpot
+	res.Margin = margin
+	res.Time = floatSecondsToTime(src.OpenTm)
+	res.Id = fintypes.NewOrderId(fintypes.MarketSpot, margin, p, txid)
+	res.ClientId = src.ClOrdId
+
+	switch src.Descr.Type {
+	case "buy":
+		res.Side = fintypes.OrderSideBuyLong
+	case "sell":
+		res.Side = fintypes.OrderSideSellShort
+	default:
+		return nil, gerror.Errorf("unsupported OrderSide(%s)", src.Descr.Type)
+	}
+	orderType, ok := spotOrderTypes[src.Descr.OrderType]
+	if !ok {
+		return nil, gerror.Errorf("unsupported OrderType(%s)", src.Descr.OrderType)
+	}
+	res.Type = orderType
+
+	for _, kv := range []struct {
+		dst *gdecimal.Decimal
+		src number
+	}{{&res.Amount, src.Vol}, {&res.DealAmount, src.VolExec}, {&res.AvgPrice, src.Price}, {&res.Fee, src.Fee}} {
+		if *kv.dst, err = kv.src.Decimal(); err != nil {
+			return nil, err
+		}
+	}
+	res.StopPrice = gdecimal.Zero
+	if res.Type.IsStopLimit() {
+		if res.StopPrice, err = src.Descr.Price.Decimal(); err != nil {
+			return nil, err
+		}
+		if res.Price, err = src.Descr.Price2.Decimal(); err != nil {
+			return nil, err
+		}
+	} else if res.Price, err = src.Descr.Price.Decimal(); err != nil {
+		return nil, err
+	}
+
+	partial := res.DealAmount.IsPositive()
+	switch src.Status {
+	case "pending", "open":
+		res.Status = fintypes.OrderStatusNew
+		if partial {
+			res.Status = fintypes.OrderStatusPartiallyFilled
+		}
+	case "closed":
+		res.Status = fintypes.OrderStatusFilled
+	case "canceled":
+		res.Status = fintypes.OrderStatusCanceled
+		if partial {
+			res.Status = fintypes.OrderStatusPartiallyCanceled
+		}
+	case "expired":
+		res.Status = fintypes.OrderStatusExpired
+	default:
+		return nil, gerror.Errorf("unsupported order status(%s)", src.Status)
+	}
+	return &res, nil
+}
+
+func (kr *Client) perpOpenOrderToApiOrder(src perpOpenOrder) (*fintypes.Order, error) {
+	p, err := kr.parsePerpSymbol(src.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	res := fintypes.Order{}
+	res.Pair = p
+	res.Market = fintypes.MarketPerp
+	res.Margin = fintypes.MarginCross
+	res.Time = parseTime(src.ReceivedTime)
+	res.Id = fintypes.NewOrderId(fintypes.MarketPerp, fintypes.MarginCross, p, src.OrderId)
+	res.ClientId = src.CliOrdId
+
+	switch src.Side {
+	case "buy":
+		res.Side = fintypes.OrderSideBuyLong
+	case "sell":
+		res.Side = fintypes.OrderSideSellShort
+	default:
+		return nil, gerror.Errorf("unsupported OrderSide(%s)", src.Side)
+	}
+	orderType, ok := perpOrderTypes[src.OrderType]
+	if !ok {
+		return nil, gerror.Errorf("unsupported OrderType(%s)", src.OrderType)
+	}
+	res.Type = orderType
+
+	unfilled, err := src.UnfilledSize.Decimal()
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range []struct {
+		dst *gdecimal.Decimal
+		src number
+	}{{&res.Price, src.LimitPrice}, {&res.StopPrice, src.StopPrice}, {&res.DealAmount, src.FilledSize}} {
+		if *kv.dst, err = kv.src.Decimal(); err != nil {
+			return nil, err
+		}
+	}
+	res.Amount = unfilled.Add(res.DealAmount)
+	res.AvgPrice = gdecimal.Zero // not provided by kraken futures
+	res.Fee = gdecimal.Zero      // not provided by kraken futures
+	res.Status = fintypes.OrderStatusNew
+	if res.DealAmount.IsPositive() {
+		res.Status = fintypes.OrderStatusPartiallyFilled
+	}
+	return &res, nil
+}
+
+func (kr *Client) perpOrderStatusToApiOrder(src perpOrderStatusItem) (*fintypes.Order, error) {
+	p, err := kr.parsePerpSymbol(src.Order.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	res := fintypes.Order{}
+	res.Pair = p
+	res.Market = fintypes.MarketPerp
+	res.Margin = fintypes.MarginCross
+	res.Time = parseTime(src.Order.Timestamp)
+	res.Id = fintypes.NewOrderId(fintypes.MarketPerp, fintypes.MarginCross, p, src.Order.OrderId)
+	res.ClientId = src.Order.CliOrdId
+
+	switch src.Order.Side {
+	case "buy":
+		res.Side = fintypes.OrderSideBuyLong
+	case "sell":
+		res.Side = fintypes.OrderSideSellShort
+	default:
+		return nil, gerror.Errorf("unsupported OrderSide(%s)", src.Order.Side)
+	}
+	for _, kv := range []struct {
+		dst *gdecimal.Decimal
+		src number
+	}{{&res.Price, src.Order.LimitPrice}, {&res.StopPrice, src.Order.TriggerPrice}, {&res.Amount, src.Order.Quantity}, {&res.DealAmount, src.Order.Filled}} {
+		if *kv.dst, err = kv.src.Decimal(); err != nil {
+			return nil, err
+		}
+	}
+	// order type is not provided by status api, it is inferred by trigger price and limit price
+	if src.Order.Type == "TRIGGER_ORDER" {
+		res.Type = fintypes.OrderTypeStopLimit
+	} else if res.Price.IsPositive() {
+		res.Type = fintypes.OrderTypeLimit
+	} else {
+		res.Type = fintypes.OrderTypeMarket
+	}
+	res.AvgPrice = gdecimal.Zero // not provided by kraken futures
+	res.Fee = gdecimal.Zero      // not provided by kraken futures
+
+	status, ok := perpOrderStatus[src.Status]
+	if !ok {
+		return nil, gerror.Errorf("unsupported order status(%s)", src.Status)
+	}
+	partial := res.DealAmount.IsPositive()
+	if status == fintypes.OrderStatusNew && partial {
+		status = fintypes.OrderStatusPartiallyFilled
+	} else if status == fintypes.OrderStatusCanceled && partial {
+		status = fintypes.OrderStatusPartiallyCanceled
+	}
+	res.Status = status
+	return &res, nil
+}
+
+// get all my history orders' info
+// NOTE: kraken futures has no order history api, so only open orders are returned in perp market
+func (kr *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	return kr.GetAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (kr *Client) GetAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+
+	if market == fintypes.MarketPerp {
+		return kr.GetOpenOrdersContext(ctx, &market, &margin, &target)
+	}
+	if market != fintypes.MarketSpot {
+		return nil, gerror.Errorf("unsupported Market(%s)", market)
+	}
+
+	r, err := kr.GetOpenOrdersContext(ctx, &market, &margin, &target)
+	if err != nil {
+		return nil, err
+	}
+	for offset := 0; ; {
+		params := url.Values{}
+		params.Set("ofs", fmt.Sprintf("%d", offset))
+		var data struct {
+			Closed map[string]spotOrder `json:"closed"`
+			Count  int                  `json:"count"`
+		}
+		if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlClosedOrders], params, true, &data); err != nil {
+			return nil, err
+		}
+		for txid, o := range data.Closed {
+			item, err := kr.spotOrderToApiOrder(ctx, txid, o)
+			if err != nil {
+				return nil, err
+			}
+			if item.Pair != target || item.Margin != margin {
+				continue
+			}
+			r = append(r, *item)
+		}
+		offset += len(data.Closed)
+		if len(data.Closed) == 0 || offset >= data.Count {
+			break
+		}
+	}
+
+	sort.Slice(r, func(i, j int) bool { return r[i].Time.Before(r[j].Time) })
+	return r, nil
+}
+
+// get all my unfinished orders' info
+func (kr *Client) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	return kr.GetOpenOrdersContext(context.Background(), market, margin, target)
+}
+
+func (kr *Client) GetOpenOrdersContext(ctx context.Context, market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	if target != nil {
+		if err := target.Verify(); err != nil {
+			return nil, err
+		}
+	}
+
+	var r []fintypes.Order
+
+	// 现货以及现货杠杆
+	if market == nil || *market == fintypes.MarketSpot {
+		var data struct {
+			Open map[string]spotOrder `json:"open"`
+		}
+		if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlOpenOrders], nil, true, &data); err != nil {
+			return nil, err
+		}
+		for txid, o := range data.Open {
+			item, err := kr.spotOrderToApiOrder(ctx, txid, o)
+			if err != nil {
+				return nil, err
+			}
+			if (margin != nil && item.Margin != *margin) || (target != nil && item.Pair != *target) {
+				continue
+			}
+			r = append(r, *item)
+		}
+	}
+
+	// 永续合约，只有全仓
+	if (market == nil || *market == fintypes.MarketPerp) && (margin == nil || *margin == fintypes.MarginCross) {
+		var data struct {
+			OpenOrders []perpOpenOrder `json:"openOrders"`
+		}
+		if err := kr.requestPerp(ctx, http.MethodGet, apiPathMap[fintypes.MarketPerp][apiUrlOpenOrders], nil, true, &data); err != nil {
+			return nil, err
+		}
+		for _, o := range data.OpenOrders {
+			if !strings.HasPrefix(strings.ToUpper(o.Symbol), perpSymbolPrefix) {
+				continue // 只支持线性永续合约
+			}
+			item, err := kr.perpOpenOrderToApiOrder(o)
+			if err != nil {
+				return nil, err
+			}
+			if target != nil && item.Pair != *target {
+				continue
+			}
+			r = append(r, *item)
+		}
+	}
+
+	return r, nil
+}
+
+// get order info by id
+func (kr *Client) GetOrder(id fintypes.OrderId) (*fintypes.Order, error) {
+	return kr.GetOrderContext(context.Background(), id)
+}
+
+func (kr *Client) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	if err := id.Verify(); err != nil {
+		return nil, err
+	}
+
+	if id.Market() == fintypes.MarketSpot {
+		params := url.Values{}
+		params.Set("txid", id.StrId())
+		var data map[string]spotOrder
+		if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlOrder], params, true, &data); err != nil {
+			return nil, err
+		}
+		o, ok := data[id.StrId()]
+		if !ok {
+			return nil, gerror.Errorf("OrderId(%s) not found", id.String())
+		}
+		return kr.spotOrderToApiOrder(ctx, id.StrId(), o)
+	}
+
+	if id.Market() == fintypes.MarketPerp {
+		params := url.Values{}
+		params.Set("orderIds", id.StrId())
+		var data struct {
+			Orders []perpOrderStatusItem `json:"orders"`
+		}
+		if err := kr.requestPerp(ctx, http.MethodPost, apiPathMap[fintypes.MarketPerp][apiUrlOrder], params, true, &data); err != nil {
+			return nil, err
+		}
+		if len(data.Orders) == 0 {
+			return nil, gerror.Errorf("OrderId(%s) not found", id.String())
+		}
+		return kr.perpOrderStatusToApiOrder(data.Orders[0])
+	}
+
+	return nil, gerror.Errorf("unsupported Market(%s)", id.Market())
+}
+
+// cancel unfinished order by id
+func (kr *Client) CancelOrder(id fintypes.OrderId) error {
+	return kr.CancelOrderContext(context.Background(), id)
+}
+
+func (kr *Client) CancelOrderContext(ctx context.Context, id fintypes.OrderId) error {
+	if err := id.Verify(); err != nil {
+		return err
+	}
+
+	if id.Market() == fintypes.MarketSpot {
+		params := url.Values{}
+		params.Set("txid", id.StrId())
+		return kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlCancelOrder], params, true, nil)
+	}
+
+	if id.Market() == fintypes.MarketPerp {
+		params := url.Values{}
+		params.Set("order_id", id.StrId())
+		return kr.cancelPerpOrder(ctx, params)
+	}
+
+	return gerror.Errorf("unsupported Market(%s)", id.Market())
+}
+
+// cancel perp order by order_id or cliOrdId in params
+func (kr *Client) cancelPerpOrder(ctx context.Context, params url.Values) error {
+	var data struct {
+		CancelStatus struct {
+			Status string `json:"status"`
+		} `json:"cancelStatus"`
+	}
+	if err := kr.requestPerp(ctx, http.MethodPost, apiPathMap[fintypes.MarketPerp][apiUrlCancelOrder], params, true, &data); err != nil {
+		return err
+	}
+	if data.CancelStatus.Status != "cancelled" {
+		return perpError(data.CancelStatus.Status)
+	}
+	return nil
+}
+
+// get order info by client order id
+func (kr *Client) GetOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	return kr.GetOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (kr *Client) GetOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if err := fintypes.VerifyClientId(clientId); err != nil {
+		return nil, err
+	}
+
+	// kraken spot has no query api by client order id, so open orders and closed orders are filtered by cl_ord_id
+	if market == fintypes.MarketSpot {
+		params := url.Values{}
+		params.Set("cl_ord_id", clientId)
+		var open struct {
+			Open map[string]spotOrder `json:"open"`
+		}
+		if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlOpenOrders], params, true, &open); err != nil {
+			return nil, err
+		}
+		for txid, o := range open.Open {
+			return kr.spotOrderToApiOrder(ctx, txid, o)
+		}
+		var closed struct {
+			Closed map[string]spotOrder `json:"closed"`
+		}
+		if err := kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlClosedOrders], params, true, &closed); err != nil {
+			return nil, err
+		}
+		for txid, o := range closed.Closed {
+			return kr.spotOrderToApiOrder(ctx, txid, o)
+		}
+		return nil, errors.Wrapf(fintypes.ErrOrderNotFound, "client order id(%s)", clientId)
+	}
+
+	if market == fintypes.MarketPerp {
+		params := url.Values{}
+		params.Set("cliOrdIds", clientId)
+		var data struct {
+			Orders []perpOrderStatusItem `json:"orders"`
+		}
+		if err := kr.requestPerp(ctx, http.MethodPost, apiPathMap[fintypes.MarketPerp][apiUrlOrder], params, true, &data); err != nil {
+			return nil, err
+		}
+		if len(data.Orders) == 0 {
+			return nil, errors.Wrapf(fintypes.ErrOrderNotFound, "client order id(%s)", clientId)
+		}
+		return kr.perpOrderStatusToApiOrder(data.Orders[0])
+	}
+
+	return nil, gerror.Errorf("unsupported Market(%s)", market)
+}
+
+// cancel unfinished order by client order id
+func (kr *Client) CancelOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	return kr.CancelOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (kr *Client) CancelOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	if err := target.Verify(); err != nil {
+		return err
+	}
+	if err := fintypes.VerifyClientId(clientId); err != nil {
+		return err
+	}
+
+	if market == fintypes.MarketSpot {
+		params := url.Values{}
+		params.Set("cl_ord_id", clientId)
+		return kr.requestSpot(ctx, apiPathMap[fintypes.MarketSpot][apiUrlCancelOrder], params, true, nil)
+	}
+
+	if market == fintypes.MarketPerp {
+		params := url.Values{}
+		params.Set("cliOrdId", clientId)
+		return kr.cancelPerpOrder(ctx, params)
+	}
+
+	return gerror.Errorf("unsupported Market(%s)", market)
+}
+
+// kraken batch apis have restrictions like same pair and no margin, so orders are placed one by one
+func (kr *Client) TradeBatch(reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	return kr.TradeBatchContext(context.Background(), reqs)
+}
+
+func (kr *Client) TradeBatchContext(ctx context.Context, reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(reqs))
+	for i, req := range reqs {
+		id, err := kr.TradeExContext(ctx, req)
+		if err != nil {
+			res[i].Err = err
+			continue
+		}
+		res[i].Id = *id
+	}
+	return res, nil
+}
+
+// orders are canceled one by one
+func (kr *Client) CancelOrders(ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	return kr.CancelOrdersContext(context.Background(), ids)
+}
+
+func (kr *Client) CancelOrdersContext(ctx context.Context, ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(ids))
+	for i, id := range ids {
+		res[i] = fintypes.OrderResult{Id: id, Err: kr.CancelOrderContext(ctx, id)}
+	}
+	return res, nil
+}
+
+// spot CancelAll of kraken cancels orders of all pairs, so open orders of the pair are canceled one by one
+func (kr *Client) CancelAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	return kr.CancelAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (kr *Client) CancelAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	ods, err := kr.GetOpenOrdersContext(ctx, &market, &margin, &target)
+	if err != nil {
+		return nil, err
+	}
+	var ids []fintypes.OrderId
+	for _, od := range ods {
+		ids = append(ids, od.Id)
+	}
+	return kr.CancelOrdersContext(ctx, ids)
+}
+
+// position management is not supported yet
+func (kr *Client) GetPositions(market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return kr.GetPositionsContext(context.Background(), market, target)
+}
+
+func (kr *Client) GetPositionsContext(ctx context.Context, market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) SetLeverage(market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return kr.SetLeverageContext(context.Background(), market, target, leverage)
+}
+
+func (kr *Client) SetLeverageContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) SetMarginType(market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return kr.SetMarginTypeContext(context.Background(), market, target, margin)
+}
+
+func (kr *Client) SetMarginTypeContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) ClosePosition(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return kr.ClosePositionContext(context.Background(), market, margin, target)
+}
+
+func (kr *Client) ClosePositionContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+// contract market data is not supported yet
+func (kr *Client) GetFundingRates(market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return kr.GetFundingRatesContext(context.Background(), market, target, since)
+}
+
+func (kr *Client) GetFundingRatesContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) GetMarkPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return kr.GetMarkPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (kr *Client) GetMarkPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) GetIndexPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return kr.GetIndexPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (kr *Client) GetIndexPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) GetOpenInterests(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return kr.GetOpenInterestsContext(context.Background(), market, target, period, since)
+}
+
+func (kr *Client) GetOpenInterestsContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+// wallet api is not supported yet
+func (kr *Client) GetDepositAddress(asset, network string) (*fintypes.DepositAddress, error) {
+	return kr.GetDepositAddressContext(context.Background(), asset, network)
+}
+
+func (kr *Client) GetDepositAddressContext(ctx context.Context, asset, network string) (*fintypes.DepositAddress, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) Withdraw(req fintypes.WithdrawRequest) (string, error) {
+	return kr.WithdrawContext(context.Background(), req)
+}
+
+func (kr *Client) WithdrawContext(ctx context.Context, req fintypes.WithdrawRequest) (string, error) {
+	return "", fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) GetDeposits(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return kr.GetDepositsContext(context.Background(), asset, since)
+}
+
+func (kr *Client) GetDepositsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (kr *Client) GetWithdrawals(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return kr.GetWithdrawalsContext(context.Background(), asset, since)
+}
+
+func (kr *Client) GetWithdrawalsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
@@ -0,0 +1,147 @@
+package kraken
+
+import (
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// recorded responses of kraken api
+var testResponses = map[string]string{
+	"/0/public/AssetPairs":            `{"error":[],"result":{"XXBTZUSD":{"altname":"XBTUSD","wsname":"XBT/USD","base":"XXBT","quote":"ZUSD","pair_decimals":1,"lot_decimals":8,"ordermin":"0.0001","leverage_buy":[2,3,4,5],"leverage_sell":[2,3,4,5],"fees":[[0,0.26],[50000,0.24]],"fees_maker":[[0,0.16],[50000,0.14]],"status":"online"},"XDGUSD":{"altname":"XDGUSD","wsname":"XDG/USD","base":"XXDG","quote":"ZUSD","pair_decimals":7,"lot_decimals":8,"ordermin":"50","leverage_buy":[],"leverage_sell":[],"fees":[[0,0.26]],"fees_maker":[[0,0.16]],"status":"online"}}}`,
+	"/0/public/Ticker":                `{"error":[],"result":{"XXBTZUSD":{"a":["30300.10000","1","1.000"],"b":["30300.00000","1","1.000"],"c":["30303.20000","0.00067643"],"v":["4083.67001100","4412.73601799"],"p":["30706.77771","30689.13205"],"t":[34619,38907],"l":["29868.30000","29868.30000"],"h":["31631.00000","31631.00000"],"o":"30502.80000"}}}`,
+	"/0/private/AddOrder":             `{"error":[],"result":{"descr":{"order":"buy 1.25000000 XBTUSD @ limit 27500.0"},"txid":["OU22CG-KLAF2-FWUDD7"]}}`,
+	"/0/private/OpenOrders":           `{"error":[],"result":{"open":{}}}`,
+	"/0/private/ClosedOrders":         `{"error":[],"result":{"closed":{"OU22CG-KLAF2-FWUDD7":{"status":"closed","opentm":1688666559.8974,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"27500.0","price2":"0","leverage":"none"},"vol":"1.25000000","vol_exec":"1.25000000","fee":"0.00000","price":"27500.0","cl_ord_id":"100000000000000001"}},"count":1}}`,
+	"/derivatives/api/v3/instruments": `{"result":"success","instruments":[{"symbol":"PF_XBTUSD","type":"flexible_futures","tickSize":0.5,"contractValuePrecision":4,"tradeable":true,"marginLevels":[{"contracts":0,"initialMargin":0.02,"maintenanceMargin":0.01}]},{"symbol":"PI_XBTUSD","type":"futures_inverse","tickSize":0.5,"tradeable":true}]}`,
+	"/derivatives/api/v3/orderbook":   `{"result":"success","orderBook":{"bids":[[30290.5,1.2],[30290,0.5]],"asks":[[30300,0.8]]},"serverTime":"2022-06-01T00:00:00.000Z"}`,
+}
+
+func newTestClient(t *testing.T, posted chan<- url.Values) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && posted != nil {
+			_ = r.ParseForm()
+			posted <- r.PostForm
+		}
+		resp, ok := testResponses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(srv.Close)
+
+	kr, err := New("key", "kQH5HW/8p1uGOVjbgWA7FunAmGO8lsSUXNsu3eow76sz84Q18fWxnyRzBHCd3pd5nE9qa99HAZtuZuj6F1huXg==", "", nil, "")
+	gtest.Assert(t, err)
+	kr.hosts[fintypes.MarketSpot] = srv.URL
+	kr.hosts[fintypes.MarketPerp] = srv.URL
+	return kr
+}
+
+// example of kraken docs
+func TestSignSpot(t *testing.T) {
+	postData := "nonce=1616492376594&ordertype=limit&pair=XBTUSD&price=37500&type=buy&volume=1.25"
+	s, err := signSpot("kQH5HW/8p1uGOVjbgWA7FunAmGO8lsSUXNsu3eow76sz84Q18fWxnyRzBHCd3pd5nE9qa99HAZtuZuj6F1huXg==", "/0/private/AddOrder", "1616492376594", postData)
+	gtest.Assert(t, err)
+	if s != "4/dpxb3iT4tp/ZCVEwSnEsLxx0bqyhLpdfOpc6fn7OR8+UClSV5n9E6aSS8MPtnRfp32bAb0nmbRn6H8ndwLUQ==" {
+		gtest.PrintlnExit(t, "signature error %s", s)
+	}
+}
+
+func TestClient_GetMarketInfo(t *testing.T) {
+	kr := newTestClient(t, nil)
+
+	mi, err := kr.GetMarketInfo(false)
+	gtest.Assert(t, err)
+	btcusd := fintypes.BTC.Against(fintypes.USD)
+	spot, ok := mi.Infos[btcusd.SetM(fintypes.MarketSpot)]
+	if !ok || spot.MaxLeverage != 5 || !spot.MarginCrossEnabled || spot.TakerFee.String() != "0.0026" || spot.QuoteStep.String() != "0.1" {
+		gtest.PrintlnExit(t, "spot market info error %v", mi.Infos)
+	}
+	if _, ok := mi.Infos[fintypes.NewPair("DOGE", "USD").SetM(fintypes.MarketSpot)]; !ok {
+		gtest.PrintlnExit(t, "XDG should be mapped to DOGE, %v", mi.Infos)
+	}
+	perp, ok := mi.Infos[btcusd.SetM(fintypes.MarketPerp)]
+	if !ok || perp.MaxLeverage != 50 || perp.UnitStep.String() != "0.0001" {
+		gtest.PrintlnExit(t, "perp market info error %v", mi.Infos)
+	}
+	if len(mi.Infos) != 3 {
+		gtest.PrintlnExit(t, "inverse futures should be ignored, %v", mi.Infos)
+	}
+}
+
+func TestClient_GetTicks(t *testing.T) {
+	kr := newTestClient(t, nil)
+	kr.property.MarketEnabled[fintypes.MarketPerp] = false
+
+	ticks, err := kr.GetTicks(false)
+	gtest.Assert(t, err)
+	tk, ok := ticks[fintypes.BTC.Against(fintypes.USD).SetM(fintypes.MarketSpot)]
+	if !ok || tk.Last.String() != "30303.2" || tk.Buy.String() != "30300" || tk.Volume.String() != "4412.73601799" {
+		gtest.PrintlnExit(t, "ticks error %v", ticks)
+	}
+}
+
+func TestClient_GetDepth(t *testing.T) {
+	kr := newTestClient(t, nil)
+
+	dp, err := kr.GetDepth(fintypes.MarketPerp, fintypes.BTC.Against(fintypes.USD))
+	gtest.Assert(t, err)
+	if len(dp.Buys) != 2 || len(dp.Sells) != 1 || dp.Sells[0].Price.String() != "30300" || dp.Time.IsZero() {
+		gtest.PrintlnExit(t, "perp depth error %v", dp)
+	}
+}
+
+func TestClient_Trade(t *testing.T) {
+	posted := make(chan url.Values, 1)
+	kr := newTestClient(t, posted)
+
+	id, err := kr.Trade(fintypes.MarketSpot, fintypes.MarginCross, 2, fintypes.BTC.Against(fintypes.USD), fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit, gdecimal.NewFromFloat64(1.25), gdecimal.NewFromInt(27500), gdecimal.Zero)
+	gtest.Assert(t, err)
+	if id.StrId() != "OU22CG-KLAF2-FWUDD7" || id.Margin() != fintypes.MarginCross {
+		gtest.PrintlnExit(t, "order id error %s", id.String())
+	}
+	form := <-posted
+	if form.Get("pair") != "XBTUSD" || form.Get("type") != "buy" || form.Get("ordertype") != "limit" || form.Get("volume") != "1.25" || form.Get("leverage") != "2" || form.Get("nonce") == "" {
+		gtest.PrintlnExit(t, "order form error %v", form)
+	}
+
+	req := fintypes.TradeRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: fintypes.BTC.Against(fintypes.USD), Side: fintypes.OrderSideBuyLong,
+		Type: fintypes.OrderTypeMarket, Amount: gdecimal.NewFromFloat64(1.25), ClientId: "100000000000000001"}
+	_, err = kr.TradeEx(req)
+	gtest.Assert(t, err)
+	if form = <-posted; form.Get("cl_ord_id") != "100000000000000001" {
+		gtest.PrintlnExit(t, "client order id not sent %v", form)
+	}
+}
+
+func TestClient_GetOrderByClientId(t *testing.T) {
+	kr := newTestClient(t, nil)
+
+	od, err := kr.GetOrderByClientId(fintypes.MarketSpot, fintypes.MarginNo, fintypes.BTC.Against(fintypes.USD), "100000000000000001")
+	gtest.Assert(t, err)
+	if od.ClientId != "100000000000000001" || od.Id.StrId() != "OU22CG-KLAF2-FWUDD7" || od.Status != fintypes.OrderStatusFilled {
+		gtest.PrintlnExit(t, "order by client id error %v", od)
+	}
+}
+
+func TestSpotError(t *testing.T) {
+	err := spotError([]string{"EGeneral:Invalid arguments:volume minimum not met"})
+	if errors.Is(err, fintypes.ErrInsufficientBalance) {
+		gtest.PrintlnExit(t, "unknown error should not have kind, %v", err)
+	}
+	err = spotError([]string{"EOrder:Insufficient funds"})
+	var exErr *fintypes.ExError
+	if !errors.Is(err, fintypes.ErrInsufficientBalance) || !errors.As(err, &exErr) || exErr.Code != "EOrder:Insufficient funds" {
+		gtest.PrintlnExit(t, "spot error kind error %v", err)
+	}
+	if !errors.Is(perpError("apiLimitExceeded"), fintypes.ErrRateLimited) {
+		gtest.PrintlnExit(t, "perp error kind error")
+	}
+}
@@ -0,0 +1,89 @@
+package kraken
+
+import (
+	"github.com/foxtrader/gofin/ex/ratelimit"
+	"github.com/foxtrader/gofin/fintypes"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/**
+kraken限频
+1. 现货公共接口每个IP约1次/秒
+2. 现货私有接口是衰减计数器, 上限15, 每秒衰减0.33, 这里近似为 15/45秒; 下单和撤单单独计数
+3. 合约私有接口每10秒500点, 不同接口消耗不同
+*/
+
+const (
+	ruleSpotPublic  = "spotPublic"
+	ruleSpotPrivate = "spotPrivate"
+	ruleSpotOrders  = "spotOrders"
+	rulePerpPublic  = "perpPublic"
+	rulePerpPrivate = "perpPrivate"
+)
+
+// costs of spot private counter and futures private budget, default cost is 1
+var (
+	spotPrivateCosts = map[string]int{
+		"/0/private/ClosedOrders":  2,
+		"/0/private/QueryTrades":   2,
+		"/0/private/TradesHistory": 2,
+		"/0/private/Ledgers":       2,
+	}
+
+	perpPrivateCosts = map[string]int{
+		"/derivatives/api/v3/sendorder":       10,
+		"/derivatives/api/v3/editorder":       10,
+		"/derivatives/api/v3/cancelorder":     10,
+		"/derivatives/api/v3/batchorder":      9,
+		"/derivatives/api/v3/cancelallorders": 25,
+		"/derivatives/api/v3/accounts":        2,
+		"/derivatives/api/v3/openpositions":   2,
+		"/derivatives/api/v3/openorders":      2,
+		"/derivatives/api/v3/fills":           2,
+	}
+)
+
+func rateLimitRules(apiKey string, rateLimits map[fintypes.ExApi]time.Duration) []ratelimit.Rule {
+	rules := []ratelimit.Rule{
+		{Name: ruleSpotPublic, Key: "kraken/spot/public", Limit: 1, Interval: time.Second},
+		{Name: ruleSpotPrivate, Key: "kraken/spot/private/" + apiKey, Limit: 15, Interval: time.Second * 45},
+		{Name: ruleSpotOrders, Key: "kraken/spot/orders/" + apiKey, Limit: 60, Interval: time.Minute},
+		{Name: rulePerpPublic, Key: "kraken/perp/public", Limit: 20, Interval: time.Second},
+		{Name: rulePerpPrivate, Key: "kraken/perp/private/" + apiKey, Limit: 500, Interval: time.Second * 10},
+	}
+	return append(rules, ratelimit.RulesOf(rateLimits, "kraken")...)
+}
+
+func costOf(costs map[string]int, path string) int {
+	if c, ok := costs[path]; ok {
+		return c
+	}
+	return 1
+}
+
+func requestCost(req *http.Request) ratelimit.Cost {
+	path := req.URL.Path
+	cost := ratelimit.Cost{}
+
+	switch {
+	case path == apiPathMap[fintypes.MarketSpot][apiUrlTrade] || path == apiPathMap[fintypes.MarketSpot][apiUrlCancelOrder]:
+		cost[ruleSpotOrders] = 1
+	case strings.HasPrefix(path, "/0/private/"):
+		cost[ruleSpotPrivate] = costOf(spotPrivateCosts, path)
+	case strings.HasPrefix(path, "/0/public/"):
+		cost[ruleSpotPublic] = 1
+	case req.Header.Get("APIKey") != "":
+		cost[rulePerpPrivate] = costOf(perpPrivateCosts, path)
+	default:
+		cost[rulePerpPublic] = 1
+	}
+
+	if path == apiPathMap[fintypes.MarketSpot][apiUrlKline] || strings.HasPrefix(path, "/api/charts/") {
+		cost[string(fintypes.ExApiGetKline)] = 1
+	} else if strings.HasSuffix(path, "/Trades") || strings.HasSuffix(path, "/history") {
+		cost[string(fintypes.ExApiGetFill)] = 1
+	}
+	return cost
+}
@@ -0,0 +1,230 @@
+package kraken
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// response of spot api
+	spotResponse struct {
+		Error  []string        `json:"error"`
+		Result json.RawMessage `json:"result"`
+	}
+
+	// common fields of futures api response, other fields are in the same level
+	perpResponse struct {
+		Result string `json:"result"`
+		Error  string `json:"error"`
+	}
+
+	// kraken returns numbers sometimes with quotes and sometimes without, this type accepts both
+	number string
+)
+
+func (n *number) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" {
+		s = ""
+	}
+	*n = number(s)
+	return nil
+}
+
+func (n number) Decimal() (gdecimal.Decimal, error) {
+	if n == "" {
+		return gdecimal.Zero, nil
+	}
+	return gdecimal.NewFromString(string(n))
+}
+
+func (n number) String() string {
+	return string(n)
+}
+
+// docs: https://docs.kraken.com/rest/#section/Authentication/Headers-and-Signature
+func signSpot(secret, path, nonce, postData string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", gerror.Errorf("invalid kraken secret key: %s", err.Error())
+	}
+	sha := sha256.Sum256([]byte(nonce + postData))
+	mac := hmac.New(sha512.New, key)
+	mac.Write(append([]byte(path), sha[:]...))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// docs: https://docs.futures.kraken.com/#http-api-http-api-introduction-authentication
+// path is endpoint path without '/derivatives' prefix
+func signPerp(secret, path, nonce, postData string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", gerror.Errorf("invalid kraken secret key: %s", err.Error())
+	}
+	sha := sha256.Sum256([]byte(postData + nonce + path))
+	mac := hmac.New(sha512.New, key)
+	mac.Write(sha[:])
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// nonce must be increasing for the same api key
+func (kr *Client) nextNonce() string {
+	kr.nonceMu.Lock()
+	defer kr.nonceMu.Unlock()
+	n := time.Now().UnixNano() / 1000
+	if n <= kr.nonce {
+		n = kr.nonce + 1
+	}
+	kr.nonce = n
+	return fmt.Sprintf("%d", n)
+}
+
+func (kr *Client) do(req *http.Request) ([]byte, error) {
+	resp, err := kr.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fintypes.NewExError(fintypes.Kraken, strconv.Itoa(resp.StatusCode), string(b), fintypes.ErrRateLimited)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fintypes.NewExError(fintypes.Kraken, strconv.Itoa(resp.StatusCode), string(b), fintypes.ErrUnknownResult)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, gerror.Errorf("kraken http status %d: %s", resp.StatusCode, string(b))
+	}
+	return b, nil
+}
+
+// public api uses GET with query params, private api uses POST with form body
+func (kr *Client) requestSpot(ctx context.Context, path string, params url.Values, signed bool, out interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	host := kr.hosts[fintypes.MarketSpot]
+
+	var req *http.Request
+	var err error
+	if signed {
+		if kr.apiKey == "" || kr.secretKey == "" {
+			return gerror.Errorf("kraken api key required for %s", path)
+		}
+		nonce := kr.nextNonce()
+		params.Set("nonce", nonce)
+		postData := params.Encode()
+		signature, err := signSpot(kr.secretKey, path, nonce, postData)
+		if err != nil {
+			return err
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, host+path, strings.NewReader(postData))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("API-Key", kr.apiKey)
+		req.Header.Set("API-Sign", signature)
+	} else {
+		reqUrl := host + path
+		if len(params) > 0 {
+			reqUrl += "?" + params.Encode()
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := kr.do(req)
+	if err != nil {
+		return err
+	}
+	r := spotResponse{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	if len(r.Error) > 0 {
+		return spotError(r.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	if len(r.Result) == 0 {
+		return gerror.Errorf("kraken empty result of %s", path)
+	}
+	return json.Unmarshal(r.Result, out)
+}
+
+// whole response body is unmarshalled into out, because futures api has no common data field
+func (kr *Client) requestPerp(ctx context.Context, method, path string, params url.Values, signed bool, out interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	host := kr.hosts[fintypes.MarketPerp]
+	postData := params.Encode()
+
+	reqUrl := host + path
+	var body *strings.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(postData)
+	} else {
+		if postData != "" {
+			reqUrl += "?" + postData
+		}
+		body = strings.NewReader("")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, body)
+	if err != nil {
+		return err
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if signed {
+		if kr.apiKey == "" || kr.secretKey == "" {
+			return gerror.Errorf("kraken api key required for %s", path)
+		}
+		nonce := kr.nextNonce()
+		signature, err := signPerp(kr.secretKey, strings.TrimPrefix(path, "/derivatives"), nonce, postData)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("APIKey", kr.apiKey)
+		req.Header.Set("Nonce", nonce)
+		req.Header.Set("Authent", signature)
+	}
+
+	b, err := kr.do(req)
+	if err != nil {
+		return err
+	}
+	r := perpResponse{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	if r.Result == "error" {
+		return perpError(r.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
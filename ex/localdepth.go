@@ -0,0 +1,83 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/ex/stream"
+	"github.com/foxtrader/gofin/fintypes"
+	"time"
+)
+
+var (
+	// min interval between two snapshot requests of the same local depth
+	DepthResyncInterval = time.Second
+)
+
+// SubLocalDepth maintains local order books from snapshot and diff stream until ctx is done.
+// diff events received before snapshot are buffered, broken sequence or reconnection triggers resync automatically,
+// errors and resyncs are reported by error channel, it is closed after ctx is done.
+func SubLocalDepth(ctx context.Context, s DepthDiffStreamer, market fintypes.Market, target fintypes.Pair) (*fintypes.LocalDepth, <-chan error, error) {
+	diffC, diffErrC, err := s.SubDepthDiff(ctx, market, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ld := fintypes.NewLocalDepth()
+	errC := make(chan error, 1024)
+	go func() {
+		defer close(errC)
+
+		var buffered []fintypes.DepthDiff
+		var lastSnapshot time.Time
+		resync := func() {
+			if time.Since(lastSnapshot) < DepthResyncInterval {
+				return
+			}
+			lastSnapshot = time.Now()
+			snapshot, lastUpdateId, err := s.GetDepthSnapshot(ctx, market, target)
+			if err != nil {
+				stream.SendErr(errC, err)
+				return
+			}
+			ld.Reset(*snapshot, lastUpdateId)
+			for _, v := range buffered {
+				if err := ld.Apply(v); err != nil {
+					stream.SendErr(errC, err)
+					break
+				}
+			}
+			buffered = nil
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				ld.Invalidate()
+				return
+			case err, ok := <-diffErrC:
+				if !ok {
+					diffErrC = nil
+					continue
+				}
+				stream.SendErr(errC, err)
+				if err == stream.ErrDisconnected { // events may be lost during reconnection
+					ld.Invalidate()
+				}
+			case diff, ok := <-diffC:
+				if !ok {
+					ld.Invalidate()
+					return
+				}
+				if ld.Synced() {
+					err := ld.Apply(diff)
+					if err == nil {
+						continue
+					}
+					stream.SendErr(errC, err)
+				}
+				buffered = append(buffered, diff)
+				resync()
+			}
+		}
+	}()
+	return ld, errC, nil
+}
@@ -0,0 +1,61 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"testing"
+	"time"
+)
+
+type testDiffStreamer struct {
+	snapshotIds []int64 // last update ids of snapshots in order
+	diffC       chan fintypes.DepthDiff
+}
+
+func (s *testDiffStreamer) GetDepthSnapshot(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, int64, error) {
+	id := s.snapshotIds[0]
+	s.snapshotIds = s.snapshotIds[1:]
+	dp := &fintypes.Depth{}
+	dp.Buys = fintypes.OrderBookList{{Price: gdecimal.NewFromInt(10), Amount: gdecimal.One}}
+	dp.Sells = fintypes.OrderBookList{{Price: gdecimal.NewFromInt(11), Amount: gdecimal.One}}
+	return dp, id, nil
+}
+
+func (s *testDiffStreamer) SubDepthDiff(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.DepthDiff, <-chan error, error) {
+	return s.diffC, make(chan error), nil
+}
+
+func TestSubLocalDepth(t *testing.T) {
+	DepthResyncInterval = 0
+	s := &testDiffStreamer{snapshotIds: []int64{100, 119}, diffC: make(chan fintypes.DepthDiff)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ld, errC, err := SubLocalDepth(ctx, s, fintypes.MarketSpot, fintypes.BTC.Against(fintypes.USDT))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	s.diffC <- fintypes.DepthDiff{FirstUpdateId: 95, LastUpdateId: 105}
+	s.diffC <- fintypes.DepthDiff{FirstUpdateId: 106, LastUpdateId: 108, DepthRawData: fintypes.DepthRawData{Buys: fintypes.OrderBookList{{Price: gdecimal.NewFromInt(10), Amount: gdecimal.Zero}}}}
+	// gap, resync from second snapshot
+	s.diffC <- fintypes.DepthDiff{FirstUpdateId: 120, LastUpdateId: 125}
+	if err := <-errC; err != fintypes.ErrDepthGap {
+		t.Errorf("expect ErrDepthGap, but got %v", err)
+		return
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for ld.LastUpdateId() != 125 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	dp, err := ld.Depth(0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if ld.LastUpdateId() != 125 || len(dp.Buys) != 1 || len(dp.Sells) != 1 {
+		t.Errorf("local depth error, last update id %d, depth %s", ld.LastUpdateId(), dp.String())
+	}
+}
@@ -0,0 +1,400 @@
+package oms
+
+/**
+订单管理，Order Management System
+
+OMS tracks every order submitted through it, or restored after restart, and keeps the latest state of them.
+order updates from polling (Poll / Run), streaming (Stream) or caller (Update) are checked by OrderStatus.CanTransitTo
+and DealAmount never decreasing, invalid updates are rejected with ErrInvalidTransition.
+canceled order with positive DealAmount is normalized to OrderStatusPartiallyCanceled, so it's the same in all exchanges.
+
+after restart, Restore saved orders then Reconcile them with exchange: open orders in exchange are tracked,
+and orders finished while OMS was down are updated by GetAllOrders.
+*/
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/ex"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	EventType string
+
+	// order and the strategy it belongs to
+	Tracked struct {
+		fintypes.Order
+		Tag string // caller defined, like strategy name
+	}
+
+	Event struct {
+		Type       EventType
+		Order      Tracked              // latest state
+		Prev       fintypes.OrderStatus // status before this update
+		FillAmount gdecimal.Decimal     // EventFill only, unit amount filled by this update
+		FillPrice  gdecimal.Decimal     // EventFill only, average price of this fill
+	}
+
+	OMS struct {
+		PollInterval time.Duration // interval of Run, DefaultPollInterval if zero
+		TradeRetries int           // retries of ex.TradeIdempotent when placing result is unknown
+
+		e        ex.Ex
+		mu       sync.Mutex
+		orders   map[fintypes.OrderId]*Tracked
+		handlers []func(Event)
+	}
+)
+
+const (
+	EventNew    EventType = "new"    // order submitted or found by reconciliation
+	EventFill   EventType = "fill"   // DealAmount increased
+	EventStatus EventType = "status" // status changed, fill event is emitted first if both happen
+)
+
+var (
+	ErrInvalidTransition = errors.Errorf("invalid order transition")
+
+	DefaultPollInterval = time.Second
+)
+
+func New(e ex.Ex) *OMS {
+	return &OMS{e: e, orders: map[fintypes.OrderId]*Tracked{}, TradeRetries: 2}
+}
+
+// handler is called synchronously in the goroutine applying updates, it should not block
+func (o *OMS) OnEvent(handler func(Event)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.handlers = append(o.handlers, handler)
+}
+
+func (o *OMS) emit(events []Event) {
+	o.mu.Lock()
+	handlers := append([]func(Event){}, o.handlers...)
+	o.mu.Unlock()
+	for _, ev := range events {
+		for _, h := range handlers {
+			h(ev)
+		}
+	}
+}
+
+func (o *OMS) now() time.Time {
+	if c := o.e.Property().Clock; c != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// places order by ex.TradeIdempotent and tracks it
+func (o *OMS) Submit(ctx context.Context, req fintypes.TradeRequest, tag string) (*fintypes.OrderId, error) {
+	if req.ClientId == "" {
+		req.ClientId = fintypes.NewClientId()
+	}
+	id, err := ex.TradeIdempotent(ctx, o.e, req, o.TradeRetries)
+	if err != nil {
+		return nil, err
+	}
+	tr := Tracked{Tag: tag, Order: fintypes.Order{
+		Id:          *id,
+		ClientId:    req.ClientId,
+		Time:        o.now(),
+		Market:      req.Market,
+		Margin:      req.Margin,
+		Leverage:    req.Leverage,
+		Pair:        req.Pair,
+		Side:        req.Side,
+		Type:        req.Type,
+		TimeInForce: req.TimeInForce,
+		Status:      fintypes.OrderStatusNew,
+		StopPrice:   req.StopPrice,
+		Price:       req.Price,
+		Amount:      req.Amount,
+	}}
+	o.mu.Lock()
+	if cur, ok := o.orders[*id]; ok {
+		// tracked by stream update already, EventNew was emitted then, only caller's info is added
+		cur.Tag = tag
+		if cur.ClientId == "" {
+			cur.ClientId = req.ClientId
+		}
+		o.mu.Unlock()
+		return id, nil
+	}
+	o.orders[*id] = &tr
+	o.mu.Unlock()
+	o.emit([]Event{{Type: EventNew, Order: tr, Prev: fintypes.OrderStatusError}})
+	return id, nil
+}
+
+// requests canceling of tracked order, status becomes OrderStatusCanceling until exchange confirms it
+func (o *OMS) Cancel(ctx context.Context, id fintypes.OrderId) error {
+	o.mu.Lock()
+	tr, ok := o.orders[id]
+	var od fintypes.Order
+	if ok {
+		od = tr.Order
+	}
+	o.mu.Unlock()
+	if !ok {
+		return errors.Wrapf(fintypes.ErrOrderNotFound, "order %s not tracked", id)
+	}
+	if od.Status.End() {
+		return nil
+	}
+	if err := o.e.CancelOrderContext(ctx, id); err != nil {
+		return err
+	}
+	od.Status = fintypes.OrderStatusCanceling
+	if err := o.Update(od); err != nil && !errors.Is(err, ErrInvalidTransition) {
+		return err
+	}
+	return nil
+}
+
+// latest state of tracked order
+func (o *OMS) Get(id fintypes.OrderId) (Tracked, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	tr, ok := o.orders[id]
+	if !ok {
+		return Tracked{}, false
+	}
+	return *tr, true
+}
+
+// all tracked orders sorted by time, they can be saved and restored after restart
+func (o *OMS) Orders() []Tracked {
+	return o.filter(func(tr *Tracked) bool { return true })
+}
+
+// unfinished orders of tag
+func (o *OMS) OpenOrders(tag string) []Tracked {
+	return o.filter(func(tr *Tracked) bool { return tr.Tag == tag && !tr.Status.End() })
+}
+
+func (o *OMS) filter(fn func(tr *Tracked) bool) []Tracked {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var r []Tracked
+	for _, tr := range o.orders {
+		if fn(tr) {
+			r = append(r, *tr)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Time.Equal(r[j].Time) {
+			return r[i].Id < r[j].Id
+		}
+		return r[i].Time.Before(r[j].Time)
+	})
+	return r
+}
+
+// tracks orders saved before restart, call Reconcile then to get their latest state
+func (o *OMS) Restore(orders []Tracked) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, tr := range orders {
+		cpy := tr
+		o.orders[tr.Id] = &cpy
+	}
+}
+
+// canceled order with positive DealAmount is partially canceled
+func normalize(od fintypes.Order) fintypes.Order {
+	if od.Status == fintypes.OrderStatusCanceled && od.DealAmount.IsPositive() {
+		od.Status = fintypes.OrderStatusPartiallyCanceled
+	}
+	return od
+}
+
+// applies order update from exchange, untracked order is tracked with empty tag.
+// error wraps ErrInvalidTransition if status can't change or DealAmount decreases, like stale update of polling.
+func (o *OMS) Update(od fintypes.Order) error {
+	events, err := o.update(od, true)
+	o.emit(events)
+	return err
+}
+
+func (o *OMS) update(od fintypes.Order, trackNew bool) ([]Event, error) {
+	od = normalize(od)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	tr, ok := o.orders[od.Id]
+	if !ok {
+		if !trackNew {
+			return nil, nil
+		}
+		tr = &Tracked{Order: od}
+		o.orders[od.Id] = tr
+		return []Event{{Type: EventNew, Order: *tr, Prev: fintypes.OrderStatusError}}, nil
+	}
+
+	prev := tr.Order
+	if prev.Status == fintypes.OrderStatusCanceling && od.Status == fintypes.OrderStatusNew {
+		od.Status = prev.Status // exchange hasn't processed canceling yet
+	}
+	if !prev.Status.CanTransitTo(od.Status) {
+		return nil, errors.Wrapf(ErrInvalidTransition, "order %s from %s to %s", od.Id, prev.Status, od.Status)
+	}
+	if od.DealAmount.LessThan(prev.DealAmount) {
+		return nil, errors.Wrapf(ErrInvalidTransition, "deal amount of order %s from %s to %s", od.Id, prev.DealAmount.String(), od.DealAmount.String())
+	}
+	if od.Time.IsZero() {
+		od.Time = prev.Time
+	}
+	if od.ClientId == "" {
+		od.ClientId = prev.ClientId
+	}
+	tr.Order = od
+
+	var events []Event
+	if fill := od.DealAmount.Sub(prev.DealAmount); fill.IsPositive() {
+		events = append(events, Event{Type: EventFill, Order: *tr, Prev: prev.Status, FillAmount: fill, FillPrice: fillPrice(prev, od, fill)})
+	}
+	if od.Status != prev.Status {
+		events = append(events, Event{Type: EventStatus, Order: *tr, Prev: prev.Status})
+	}
+	return events, nil
+}
+
+// average price of new fill by change of AvgPrice, limit price is used if exchange doesn't provide AvgPrice
+func fillPrice(prev, cur fintypes.Order, fill gdecimal.Decimal) gdecimal.Decimal {
+	if !cur.AvgPrice.IsPositive() {
+		return cur.Price
+	}
+	quote := cur.AvgPrice.Mul(cur.DealAmount).Sub(prev.AvgPrice.Mul(prev.DealAmount))
+	if !quote.IsPositive() {
+		return cur.AvgPrice
+	}
+	return quote.Div(fill)
+}
+
+// queries every unfinished order by GetOrder and applies the results
+func (o *OMS) Poll(ctx context.Context) error {
+	var errs []error
+	for _, tr := range o.filter(func(tr *Tracked) bool { return !tr.Status.End() }) {
+		od, err := o.e.GetOrderContext(ctx, tr.Id)
+		if err == nil {
+			err = o.Update(*od)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Wrapf(errs[0], "%d errors in polling, the first one", len(errs))
+	}
+	return nil
+}
+
+// polls unfinished orders every PollInterval until ctx is done, errors of polling are reported by onErr if it's not nil
+func (o *OMS) Run(ctx context.Context, onErr func(error)) error {
+	interval := o.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := o.Poll(ctx); err != nil && onErr != nil {
+			onErr(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// applies order updates of exchange streaming api until ctx is done,
+// updates of orders not submitted by OMS are tracked too, invalid ones and stream errors are reported by onErr if it's not nil
+func (o *OMS) Stream(ctx context.Context, market fintypes.Market, margin fintypes.Margin, onErr func(error)) error {
+	st, ok := ex.AsStreamer(o.e)
+	if !ok {
+		return errors.Errorf("%s doesn't support streaming api", o.e.Property().Name)
+	}
+	orders, errs, err := st.SubOrders(ctx, market, margin)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case od, ok := <-orders:
+			if !ok {
+				return ctx.Err()
+			}
+			if err := o.Update(od); err != nil && onErr != nil {
+				onErr(err)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if onErr != nil {
+				onErr(err)
+			}
+		}
+	}
+}
+
+// Reconcile makes tracked orders of market and margin consistent with exchange, like after restart:
+// open orders in exchange are tracked if they are not, then unfinished tracked orders not open in exchange
+// are updated by GetAllOrders of their pairs, or GetOrder if not found in history.
+// stale updates are ignored, error is returned only if exchange can't be queried.
+func (o *OMS) Reconcile(ctx context.Context, market fintypes.Market, margin fintypes.Margin) error {
+	opens, err := o.e.GetOpenOrdersContext(ctx, &market, &margin, nil)
+	if err != nil {
+		return errors.Wrapf(err, "get open orders")
+	}
+	openIds := map[fintypes.OrderId]bool{}
+	var events []Event
+	for _, od := range opens {
+		openIds[od.Id] = true
+		evs, _ := o.update(od, true)
+		events = append(events, evs...)
+	}
+
+	missing := map[fintypes.Pair][]fintypes.OrderId{}
+	for _, tr := range o.filter(func(tr *Tracked) bool {
+		return tr.Market == market && tr.Margin == margin && !tr.Status.End() && !openIds[tr.Id]
+	}) {
+		missing[tr.Pair] = append(missing[tr.Pair], tr.Id)
+	}
+	for pair, ids := range missing {
+		all, err := o.e.GetAllOrdersContext(ctx, market, margin, pair)
+		if err != nil {
+			o.emit(events)
+			return errors.Wrapf(err, "get orders of %s", pair)
+		}
+		found := map[fintypes.OrderId]bool{}
+		for _, od := range all {
+			found[od.Id] = true
+			evs, _ := o.update(od, false)
+			events = append(events, evs...)
+		}
+		for _, id := range ids {
+			if found[id] {
+				continue
+			}
+			od, err := o.e.GetOrderContext(ctx, id)
+			if err != nil {
+				o.emit(events)
+				return errors.Wrapf(err, "get order %s", id)
+			}
+			evs, _ := o.update(*od, false)
+			events = append(events, evs...)
+		}
+	}
+	o.emit(events)
+	return nil
+}
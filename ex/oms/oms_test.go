@@ -0,0 +1,178 @@
+package oms
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/ex"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"strconv"
+	"testing"
+)
+
+var testPair = fintypes.BTC.Against(fintypes.USDT)
+
+// orders are kept as they are, tests change them to simulate fills
+type testEx struct {
+	ex.Ex
+	orders  map[fintypes.OrderId]*fintypes.Order
+	onTrade func(od fintypes.Order) // called before placing result returned, like a stream update racing with it
+}
+
+func newTestEx() *testEx {
+	return &testEx{orders: map[fintypes.OrderId]*fintypes.Order{}}
+}
+
+func (e *testEx) Property() *fintypes.ExProperty {
+	return &fintypes.ExProperty{Name: fintypes.Binance}
+}
+
+func (e *testEx) add(status fintypes.OrderStatus) *fintypes.Order {
+	id := fintypes.NewOrderId(fintypes.MarketSpot, fintypes.MarginNo, testPair, strconv.Itoa(len(e.orders)+1))
+	od := &fintypes.Order{Id: id, Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: testPair, Type: fintypes.OrderTypeLimit, Status: status, Price: gdecimal.NewFromInt(100), Amount: gdecimal.One}
+	e.orders[id] = od
+	return od
+}
+
+func (e *testEx) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	od := e.add(fintypes.OrderStatusNew)
+	od.ClientId = req.ClientId
+	if e.onTrade != nil {
+		cpy := *od
+		cpy.ClientId = ""
+		e.onTrade(cpy)
+	}
+	return &od.Id, nil
+}
+
+func (e *testEx) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	od, ok := e.orders[id]
+	if !ok {
+		return nil, fintypes.ErrOrderNotFound
+	}
+	cpy := *od
+	return &cpy, nil
+}
+
+func (e *testEx) CancelOrderContext(ctx context.Context, id fintypes.OrderId) error {
+	e.orders[id].Status = fintypes.OrderStatusCanceled
+	return nil
+}
+
+func (e *testEx) GetOpenOrdersContext(ctx context.Context, market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	var r []fintypes.Order
+	for _, od := range e.orders {
+		if !od.Status.End() {
+			r = append(r, *od)
+		}
+	}
+	return r, nil
+}
+
+func (e *testEx) GetAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	var r []fintypes.Order
+	for _, od := range e.orders {
+		r = append(r, *od)
+	}
+	return r, nil
+}
+
+func TestOMS(t *testing.T) {
+	e := newTestEx()
+	o := New(e)
+	var events []Event
+	o.OnEvent(func(ev Event) { events = append(events, ev) })
+
+	req := fintypes.TradeRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: testPair, Side: fintypes.OrderSideBuyLong, Type: fintypes.OrderTypeLimit, Amount: gdecimal.One, Price: gdecimal.NewFromInt(101)}
+	id, err := o.Submit(context.Background(), req, "grid")
+	gtest.Assert(t, err)
+	if len(o.OpenOrders("grid")) != 1 || len(events) != 1 || events[0].Type != EventNew {
+		t.Errorf("submitted order should be tracked")
+	}
+
+	// fills
+	od := e.orders[*id]
+	od.Status, od.DealAmount, od.AvgPrice = fintypes.OrderStatusPartiallyFilled, gdecimal.NewFromFloat64(0.4), gdecimal.NewFromInt(100)
+	gtest.Assert(t, o.Poll(context.Background()))
+	od.Status, od.DealAmount, od.AvgPrice = fintypes.OrderStatusFilled, gdecimal.One, gdecimal.NewFromFloat64(100.6)
+	gtest.Assert(t, o.Poll(context.Background()))
+	var fills []Event
+	for _, ev := range events {
+		if ev.Type == EventFill {
+			fills = append(fills, ev)
+		}
+	}
+	if len(fills) != 2 || !fills[0].FillAmount.Equal(gdecimal.NewFromFloat64(0.4)) || !fills[1].FillPrice.Equal(gdecimal.NewFromInt(101)) {
+		t.Errorf("fills of 0.4@100 and 0.6@101 expected, but %v", fills)
+	}
+	if len(o.OpenOrders("grid")) != 0 {
+		t.Errorf("filled order should not be open")
+	}
+
+	// ended order never changes
+	stale := *od
+	stale.Status = fintypes.OrderStatusNew
+	if err := o.Update(stale); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("invalid transition error expected, but %v", err)
+	}
+
+	// partially filled then canceled
+	id, err = o.Submit(context.Background(), req, "grid")
+	gtest.Assert(t, err)
+	e.orders[*id].DealAmount = gdecimal.NewFromFloat64(0.5)
+	gtest.Assert(t, o.Cancel(context.Background(), *id))
+	gtest.Assert(t, o.Poll(context.Background()))
+	if tr, _ := o.Get(*id); tr.Status != fintypes.OrderStatusPartiallyCanceled {
+		t.Errorf("order should be partially canceled, but %s", tr.Status)
+	}
+}
+
+func TestOMS_SubmitAfterStream(t *testing.T) {
+	e := newTestEx()
+	o := New(e)
+	e.onTrade = func(od fintypes.Order) { gtest.Assert(t, o.Update(od)) }
+	news := 0
+	o.OnEvent(func(ev Event) {
+		if ev.Type == EventNew {
+			news++
+		}
+	})
+
+	req := fintypes.TradeRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: testPair, Side: fintypes.OrderSideBuyLong, Type: fintypes.OrderTypeLimit, Amount: gdecimal.One, Price: gdecimal.NewFromInt(101), ClientId: "c1"}
+	id, err := o.Submit(context.Background(), req, "grid")
+	gtest.Assert(t, err)
+	if tr, _ := o.Get(*id); tr.Tag != "grid" || tr.ClientId != "c1" {
+		t.Errorf("tag and client id should be set on order tracked by stream, but %s %s", tr.Tag, tr.ClientId)
+	}
+	if news != 1 {
+		t.Errorf("1 new event expected, but %d", news)
+	}
+}
+
+func TestOMS_Reconcile(t *testing.T) {
+	e := newTestEx()
+	filled := e.add(fintypes.OrderStatusNew)
+	saved := []Tracked{{Order: *filled, Tag: "grid"}}
+	filled.Status, filled.DealAmount = fintypes.OrderStatusFilled, gdecimal.One // filled while OMS was down
+	unknown := e.add(fintypes.OrderStatusNew)                                   // placed by others
+
+	o := New(e)
+	o.Restore(saved)
+	var news, fills int
+	o.OnEvent(func(ev Event) {
+		switch ev.Type {
+		case EventNew:
+			news++
+		case EventFill:
+			fills++
+		}
+	})
+	gtest.Assert(t, o.Reconcile(context.Background(), fintypes.MarketSpot, fintypes.MarginNo))
+	if tr, _ := o.Get(filled.Id); tr.Status != fintypes.OrderStatusFilled || tr.Tag != "grid" {
+		t.Errorf("restored order should be filled, but %s", tr.Status)
+	}
+	if _, ok := o.Get(unknown.Id); !ok || news != 1 || fills != 1 {
+		t.Errorf("open order in exchange should be tracked, %d new events and %d fill events", news, fills)
+	}
+}
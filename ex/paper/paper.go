@@ -0,0 +1,824 @@
+package paper
+
+/**
+PaperEx，模拟盘交易所
+
+Orders are matched against Depth and Tick of a feed (a living exchange or recorded data),
+but never be sent to the real exchange, balances are kept in a virtual fintypes.Account.
+
+NOTE:
+matched orders won't consume the order books of feed, every order only remembers the depth consumed by itself,
+so different paper orders may fill against the same depth.
+only spot market (with or without margin) is supported for now.
+fee is charged in the asset received, like binance does.
+*/
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// market data source of paper exchange, every ex.Ex satisfies it
+	Feed interface {
+		Property() *fintypes.ExProperty
+		GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error)
+		GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error)
+		GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error)
+		GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error)
+	}
+
+	order struct {
+		fintypes.Order
+		locked    gdecimal.Decimal            // remaining locked amount of the paying asset
+		dealQuote gdecimal.Decimal            // filled amount in quote, used to calculate AvgPrice
+		triggered bool                        // whether stop price of stop-limit order is reached
+		consumed  map[string]gdecimal.Decimal // depth amount consumed by this order, key is price string
+	}
+
+	Client struct {
+		feed              Feed
+		property          fintypes.ExProperty
+		mu                sync.Mutex
+		account           *fintypes.Account
+		orders            []*order
+		nextId            int64
+		marketInfoCache   *fintypes.MarketInfo
+		interestRateDaily gdecimal.Decimal
+		maxMarginLeverage int
+	}
+)
+
+var (
+	DefaultInterestRateDaily = gdecimal.NewFromFloat64(0.0002)
+	DefaultMaxMarginLeverage = 3
+)
+
+// init: initial virtual account, it will be copied
+func New(feed Feed, init *fintypes.Account, c gtime.Clock) (*Client, error) {
+	if feed == nil {
+		return nil, gerror.Errorf("nil feed")
+	}
+
+	pe := &Client{}
+	pe.feed = feed
+	pe.property = *feed.Property()
+	pe.property.Email = ""
+	if c != nil {
+		pe.property.Clock = c
+	} else if pe.property.Clock == nil {
+		pe.property.Clock = gtime.GetSysClock()
+	}
+	if init != nil {
+		pe.account = init.Clone()
+	} else {
+		pe.account = fintypes.NewEmptyAccount()
+	}
+	pe.nextId = 1
+	pe.interestRateDaily = DefaultInterestRateDaily
+	pe.maxMarginLeverage = DefaultMaxMarginLeverage
+	return pe, nil
+}
+
+func (pe *Client) SetInterestRateDaily(rate gdecimal.Decimal) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	pe.interestRateDaily = rate
+}
+
+func (pe *Client) Property() *fintypes.ExProperty {
+	return &pe.property
+}
+
+func (pe *Client) GetMarketInfo(ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	return pe.GetMarketInfoContext(context.Background(), ignorePairsNotFound)
+}
+
+func (pe *Client) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	mi, err := pe.feed.GetMarketInfoContext(ctx, ignorePairsNotFound)
+	if err != nil {
+		return nil, err
+	}
+	pe.mu.Lock()
+	pe.marketInfoCache = mi
+	pe.mu.Unlock()
+	return mi, nil
+}
+
+func (pe *Client) GetAccount() (*fintypes.Account, error) {
+	return pe.GetAccountContext(context.Background())
+}
+
+func (pe *Client) GetAccountContext(ctx context.Context) (*fintypes.Account, error) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	if err := pe.match(ctx); err != nil {
+		return nil, err
+	}
+	return pe.account.Clone(), nil
+}
+
+func (pe *Client) GetDepth(market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	return pe.GetDepthContext(context.Background(), market, target)
+}
+
+func (pe *Client) GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	return pe.feed.GetDepthContext(ctx, market, target)
+}
+
+func (pe *Client) GetKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return pe.GetKlineContext(context.Background(), market, target, period, since)
+}
+
+func (pe *Client) GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return pe.feed.GetKlineContext(ctx, market, target, period, since)
+}
+
+func (pe *Client) GetTicks(ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	return pe.GetTicksContext(context.Background(), ignorePairsNotFound)
+}
+
+func (pe *Client) GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	return pe.feed.GetTicksContext(ctx, ignorePairsNotFound)
+}
+
+// borrowable = net asset amount * (max leverage - 1) - borrowed
+func (pe *Client) GetBorrowable(margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	return pe.GetBorrowableContext(context.Background(), margin, asset)
+}
+
+func (pe *Client) GetBorrowableContext(ctx context.Context, margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	return pe.borrowable(margin, asset)
+}
+
+func (pe *Client) borrowable(margin fintypes.Margin, asset string) (gdecimal.Decimal, error) {
+	if margin != fintypes.MarginCross && margin != fintypes.MarginIsolated {
+		return gdecimal.Zero, gerror.Errorf("unsupported margin(%s)", margin)
+	}
+	amount := pe.account.GetAmountByProperty(fintypes.NewAP(fintypes.MarketSpot, margin, asset))
+	r := amount.Net().MulInt(pe.maxMarginLeverage - 1).Sub(amount.Borrowed)
+	if !r.IsPositive() {
+		return gdecimal.Zero, nil
+	}
+	return r, nil
+}
+
+func (pe *Client) Borrow(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return pe.BorrowContext(context.Background(), margin, asset, amount)
+}
+
+func (pe *Client) BorrowContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	borrowable, err := pe.borrowable(margin, asset)
+	if err != nil {
+		return err
+	}
+	if amount.GreaterThan(borrowable) {
+		return gerror.Errorf("borrow %s %s exceeds borrowable %s", amount.String(), asset, borrowable.String())
+	}
+	return pe.account.Borrow(pe.property.Clock.Now(), margin, asset, amount)
+}
+
+func (pe *Client) Repay(margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	return pe.RepayContext(context.Background(), margin, asset, amount)
+}
+
+func (pe *Client) RepayContext(ctx context.Context, margin fintypes.Margin, asset string, amount gdecimal.Decimal) error {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	return pe.account.Repay(pe.property.Clock.Now(), pe.interestRateDaily, margin, asset, amount)
+}
+
+func (pe *Client) Transfer(asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	return pe.TransferContext(context.Background(), asset, amount, from, to)
+}
+
+func (pe *Client) TransferContext(ctx context.Context, asset string, amount gdecimal.Decimal, from, to fintypes.SubAcc) error {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	return pe.account.Transfer(asset, amount, from, to)
+}
+
+func (pe *Client) Trade(market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return pe.TradeContext(context.Background(), market, margin, leverage, target, side, orderType, amount, price, stopPrice)
+}
+
+func (pe *Client) TradeContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, amount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	return pe.TradeExContext(ctx, fintypes.TradeRequest{Market: market, Margin: margin, Leverage: leverage, Pair: target, Side: side, Type: orderType, Amount: amount, Price: price, StopPrice: stopPrice})
+}
+
+func (pe *Client) TradeEx(req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	return pe.TradeExContext(context.Background(), req)
+}
+
+// only GTC limit, market and stop-limit orders are supported
+func (pe *Client) TradeExContext(ctx context.Context, req fintypes.TradeRequest) (*fintypes.OrderId, error) {
+	market, margin, leverage, target, side, orderType := req.Market, req.Margin, req.Leverage, req.Pair, req.Side, req.Type
+	amount, price, stopPrice := req.Amount, req.Price, req.StopPrice
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	if market != fintypes.MarketSpot {
+		return nil, gerror.Errorf("paper exchange doesn't support Market(%s)", market)
+	}
+	if err := margin.Verify(); err != nil {
+		return nil, err
+	}
+	if err := side.Verify(); err != nil {
+		return nil, err
+	}
+	if !orderType.IsLimit() && !orderType.IsMarket() && !orderType.IsStopLimit() {
+		return nil, gerror.Errorf("unsupported OrderType(%s)", orderType)
+	}
+	if !amount.IsPositive() {
+		return nil, gerror.Errorf("invalid amount %s", amount.String())
+	}
+	if !orderType.IsMarket() && !price.IsPositive() {
+		return nil, gerror.Errorf("invalid price %s", price.String())
+	}
+	if orderType.IsStopLimit() && !stopPrice.IsPositive() {
+		return nil, gerror.Errorf("invalid stop price %s", stopPrice.String())
+	}
+	if req.TimeInForce != fintypes.TimeInForceError && req.TimeInForce != fintypes.TimeInForceGTC {
+		return nil, gerror.Errorf("paper exchange doesn't support TimeInForce(%s)", req.TimeInForce)
+	}
+	if req.ReduceOnly {
+		return nil, gerror.Errorf("paper exchange doesn't support reduce only order")
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	// client order id is unique in paper exchange, whether the order is finished or not
+	if req.ClientId != "" {
+		if err := fintypes.VerifyClientId(req.ClientId); err != nil {
+			return nil, err
+		}
+		if _, err := pe.findClientOrder(market, margin, target, req.ClientId); err == nil {
+			return nil, errors.Wrapf(fintypes.ErrDuplicateOrder, "client order id(%s)", req.ClientId)
+		}
+	}
+
+	depth, err := pe.feed.GetDepthContext(ctx, market, target)
+	if err != nil {
+		return nil, err
+	}
+	depth.Sort()
+
+	// round amount and prices by PairInfo of feed, market order is checked by best price of depth
+	if pe.marketInfoCache == nil {
+		mi, err := pe.feed.GetMarketInfoContext(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		pe.marketInfoCache = mi
+	}
+	refPrice := price
+	if orderType.IsMarket() {
+		refPrice = gdecimal.Zero
+		if side.IsBuy() && len(depth.Sells) > 0 {
+			refPrice = depth.Sells[0].Price
+		} else if side.IsSell() && len(depth.Buys) > 0 {
+			refPrice = depth.Buys[0].Price
+		}
+	}
+	amount, refPrice, stopPrice, err = pe.marketInfoCache.NormalizeOrder(target.SetM(market), orderType, amount, refPrice, stopPrice)
+	if err != nil {
+		return nil, err
+	}
+	if !orderType.IsMarket() {
+		price = refPrice
+	}
+
+	// calculate the asset and amount to lock
+	payAsset, payAmount := target.Quote(), amount.Mul(price)
+	if side.IsSell() {
+		payAsset, payAmount = target.Unit(), amount
+	} else if orderType.IsMarket() {
+		_, payAmount = walkBook(depth.Sells, amount)
+	}
+	free := pe.account.GetAmountByProperty(fintypes.NewAP(market, margin, payAsset)).Free
+	if free.LessThan(payAmount) {
+		return nil, errors.Wrapf(fintypes.ErrInsufficientBalance, "%s %s required, but %s free", payAmount.String(), payAsset, free.String())
+	}
+	if err := pe.account.Lock(market, margin, payAsset, payAmount); err != nil {
+		return nil, err
+	}
+
+	od := &order{}
+	od.Id = fintypes.NewOrderId(market, margin, target, strconv.FormatInt(pe.nextId, 10))
+	od.ClientId = req.ClientId
+	od.Time = pe.property.Clock.Now()
+	od.Market = market
+	od.Margin = margin
+	od.Leverage = leverage
+	od.Pair = target
+	od.Side = side
+	od.Type = orderType
+	od.Status = fintypes.OrderStatusNew
+	od.StopPrice = stopPrice
+	od.Price = price
+	od.Amount = amount
+	od.locked = payAmount
+	od.consumed = map[string]gdecimal.Decimal{}
+	pe.nextId++
+	pe.orders = append(pe.orders, od)
+
+	last := gdecimal.Zero
+	if orderType.IsStopLimit() {
+		ticks, err := pe.feed.GetTicksContext(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		last = ticks[target.SetM(market)].Last
+	}
+	if err := pe.matchOrder(ctx, od, depth, last, true); err != nil {
+		return nil, err
+	}
+
+	res := od.Id
+	return &res, nil
+}
+
+func (pe *Client) GetAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	return pe.GetAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (pe *Client) GetAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.Order, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	if err := pe.match(ctx); err != nil {
+		return nil, err
+	}
+	var r []fintypes.Order
+	for _, od := range pe.orders {
+		if od.Market == market && od.Margin == margin && od.Pair == target {
+			r = append(r, od.Order)
+		}
+	}
+	return r, nil
+}
+
+func (pe *Client) GetOpenOrders(market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	return pe.GetOpenOrdersContext(context.Background(), market, margin, target)
+}
+
+func (pe *Client) GetOpenOrdersContext(ctx context.Context, market *fintypes.Market, margin *fintypes.Margin, target *fintypes.Pair) ([]fintypes.Order, error) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	if err := pe.match(ctx); err != nil {
+		return nil, err
+	}
+	var r []fintypes.Order
+	for _, od := range pe.orders {
+		if od.Status.End() {
+			continue
+		}
+		if market != nil && od.Market != *market {
+			continue
+		}
+		if margin != nil && od.Margin != *margin {
+			continue
+		}
+		if target != nil && od.Pair != *target {
+			continue
+		}
+		r = append(r, od.Order)
+	}
+	return r, nil
+}
+
+func (pe *Client) GetOrder(id fintypes.OrderId) (*fintypes.Order, error) {
+	return pe.GetOrderContext(context.Background(), id)
+}
+
+func (pe *Client) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	if err := id.Verify(); err != nil {
+		return nil, err
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	if err := pe.match(ctx); err != nil {
+		return nil, err
+	}
+	od, err := pe.findOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	res := od.Order
+	return &res, nil
+}
+
+func (pe *Client) CancelOrder(id fintypes.OrderId) error {
+	return pe.CancelOrderContext(context.Background(), id)
+}
+
+func (pe *Client) CancelOrderContext(ctx context.Context, id fintypes.OrderId) error {
+	if err := id.Verify(); err != nil {
+		return err
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	od, err := pe.findOrder(id)
+	if err != nil {
+		return err
+	}
+	if od.Status.End() {
+		return gerror.Errorf("OrderId(%s) is already %s", id.String(), od.Status)
+	}
+	return pe.finish(od, fintypes.OrderStatusCanceled)
+}
+
+func (pe *Client) GetOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	return pe.GetOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (pe *Client) GetOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*fintypes.Order, error) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	if err := pe.match(ctx); err != nil {
+		return nil, err
+	}
+	od, err := pe.findClientOrder(market, margin, target, clientId)
+	if err != nil {
+		return nil, err
+	}
+	res := od.Order
+	return &res, nil
+}
+
+func (pe *Client) CancelOrderByClientId(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	return pe.CancelOrderByClientIdContext(context.Background(), market, margin, target, clientId)
+}
+
+func (pe *Client) CancelOrderByClientIdContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) error {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	od, err := pe.findClientOrder(market, margin, target, clientId)
+	if err != nil {
+		return err
+	}
+	if od.Status.End() {
+		return gerror.Errorf("client order id(%s) is already %s", clientId, od.Status)
+	}
+	return pe.finish(od, fintypes.OrderStatusCanceled)
+}
+
+// orders are placed one by one
+func (pe *Client) TradeBatch(reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	return pe.TradeBatchContext(context.Background(), reqs)
+}
+
+func (pe *Client) TradeBatchContext(ctx context.Context, reqs []fintypes.TradeRequest) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(reqs))
+	for i, req := range reqs {
+		id, err := pe.TradeExContext(ctx, req)
+		if err != nil {
+			res[i].Err = err
+			continue
+		}
+		res[i].Id = *id
+	}
+	return res, nil
+}
+
+func (pe *Client) CancelOrders(ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	return pe.CancelOrdersContext(context.Background(), ids)
+}
+
+func (pe *Client) CancelOrdersContext(ctx context.Context, ids []fintypes.OrderId) ([]fintypes.OrderResult, error) {
+	res := make([]fintypes.OrderResult, len(ids))
+	for i, id := range ids {
+		res[i] = fintypes.OrderResult{Id: id, Err: pe.CancelOrderContext(ctx, id)}
+	}
+	return res, nil
+}
+
+func (pe *Client) CancelAllOrders(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	return pe.CancelAllOrdersContext(context.Background(), market, margin, target)
+}
+
+func (pe *Client) CancelAllOrdersContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) ([]fintypes.OrderResult, error) {
+	if err := target.Verify(); err != nil {
+		return nil, err
+	}
+	ods, err := pe.GetOpenOrdersContext(ctx, &market, &margin, &target)
+	if err != nil {
+		return nil, err
+	}
+	var ids []fintypes.OrderId
+	for _, od := range ods {
+		ids = append(ids, od.Id)
+	}
+	return pe.CancelOrdersContext(ctx, ids)
+}
+
+func (pe *Client) findClientOrder(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair, clientId string) (*order, error) {
+	for _, od := range pe.orders {
+		if od.ClientId == clientId && od.Market == market && od.Margin == margin && od.Pair == target {
+			return od, nil
+		}
+	}
+	return nil, errors.Wrapf(fintypes.ErrOrderNotFound, "client order id(%s)", clientId)
+}
+
+func (pe *Client) findOrder(id fintypes.OrderId) (*order, error) {
+	for _, od := range pe.orders {
+		if od.Id == id {
+			return od, nil
+		}
+	}
+	return nil, errors.Wrapf(fintypes.ErrOrderNotFound, "order id(%s)", id.String())
+}
+
+// match all unfinished orders with latest depth, caller must hold the lock
+func (pe *Client) match(ctx context.Context) error {
+	depths := map[fintypes.PairM]*fintypes.Depth{}
+	var ticks map[fintypes.PairM]fintypes.Tick
+
+	for _, od := range pe.orders {
+		if od.Status.End() {
+			continue
+		}
+		pm := od.Pair.SetM(od.Market)
+		depth, ok := depths[pm]
+		if !ok {
+			var err error
+			depth, err = pe.feed.GetDepthContext(ctx, od.Market, od.Pair)
+			if err != nil {
+				return err
+			}
+			depth.Sort()
+			depths[pm] = depth
+		}
+		if od.Type.IsStopLimit() && !od.triggered && ticks == nil {
+			var err error
+			ticks, err = pe.feed.GetTicksContext(ctx, true)
+			if err != nil {
+				return err
+			}
+		}
+		if err := pe.matchOrder(ctx, od, depth, ticks[pm].Last, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match one unfinished order against sorted depth
+// taker: whether the order is matched for the first time
+func (pe *Client) matchOrder(ctx context.Context, od *order, depth *fintypes.Depth, last gdecimal.Decimal, taker bool) error {
+	if od.Status.End() {
+		return nil
+	}
+
+	// stop-limit order becomes a new limit order after stop price reached
+	if od.Type.IsStopLimit() && !od.triggered {
+		if !last.IsPositive() {
+			return nil
+		}
+		if (od.Side.IsBuy() && last.GreaterThanOrEqual(od.StopPrice)) || (od.Side.IsSell() && last.LessThanOrEqual(od.StopPrice)) {
+			od.triggered = true
+			taker = true
+		} else {
+			return nil
+		}
+	}
+
+	levels := depth.Buys
+	if od.Side.IsBuy() {
+		levels = depth.Sells
+	}
+	left := od.Amount.Sub(od.DealAmount)
+	for _, lv := range levels {
+		if !left.IsPositive() {
+			break
+		}
+		if !od.Type.IsMarket() {
+			if od.Side.IsBuy() && lv.Price.GreaterThan(od.Price) {
+				break
+			}
+			if od.Side.IsSell() && lv.Price.LessThan(od.Price) {
+				break
+			}
+		}
+		available := lv.Amount.Sub(od.consumed[lv.Price.String()])
+		if !available.IsPositive() {
+			continue
+		}
+		dealUnit := gdecimal.Min(left, available)
+		dealPrice := lv.Price
+		if !taker {
+			dealPrice = od.Price // resting order is filled at its own price
+		}
+		pe.settle(ctx, od, dealUnit, dealPrice, taker)
+		od.consumed[lv.Price.String()] = od.consumed[lv.Price.String()].Add(dealUnit)
+		left = left.Sub(dealUnit)
+	}
+
+	if !left.IsPositive() {
+		return pe.finish(od, fintypes.OrderStatusFilled)
+	}
+	if od.Type.IsMarket() { // market order never rests in order book
+		return pe.finish(od, fintypes.OrderStatusExpired)
+	}
+	if od.DealAmount.IsPositive() {
+		od.Status = fintypes.OrderStatusPartiallyFilled
+	}
+	return nil
+}
+
+// update account and order after a deal
+func (pe *Client) settle(ctx context.Context, od *order, dealUnit, dealPrice gdecimal.Decimal, taker bool) {
+	dealQuote := dealUnit.Mul(dealPrice)
+	feeRate := pe.feeRate(ctx, od.Pair.SetM(od.Market), taker)
+	unitAP := fintypes.NewAP(od.Market, od.Margin, od.Pair.Unit())
+	quoteAP := fintypes.NewAP(od.Market, od.Margin, od.Pair.Quote())
+
+	if od.Side.IsBuy() {
+		fee := dealUnit.Mul(feeRate)
+		pe.account.AddLock(quoteAP, gdecimal.Zero.Sub(dealQuote))
+		pe.account.AddFree(unitAP, dealUnit.Sub(fee))
+		od.locked = od.locked.Sub(dealQuote)
+		od.Fee = od.Fee.Add(fee)
+	} else {
+		fee := dealQuote.Mul(feeRate)
+		pe.account.AddLock(unitAP, gdecimal.Zero.Sub(dealUnit))
+		pe.account.AddFree(quoteAP, dealQuote.Sub(fee))
+		od.locked = od.locked.Sub(dealUnit)
+		od.Fee = od.Fee.Add(fee)
+	}
+	od.DealAmount = od.DealAmount.Add(dealUnit)
+	od.dealQuote = od.dealQuote.Add(dealQuote)
+	od.AvgPrice = od.dealQuote.Div(od.DealAmount)
+}
+
+// set final status and unlock the rest of paying asset
+func (pe *Client) finish(od *order, status fintypes.OrderStatus) error {
+	if od.locked.IsPositive() {
+		payAsset := od.Pair.Quote()
+		if od.Side.IsSell() {
+			payAsset = od.Pair.Unit()
+		}
+		if err := pe.account.Unlock(fintypes.NewAP(od.Market, od.Margin, payAsset), od.locked); err != nil {
+			return err
+		}
+		od.locked = gdecimal.Zero
+	}
+	od.Status = status
+	return nil
+}
+
+func (pe *Client) feeRate(ctx context.Context, pm fintypes.PairM, taker bool) gdecimal.Decimal {
+	if pe.marketInfoCache == nil {
+		mi, err := pe.feed.GetMarketInfoContext(ctx, true)
+		if err != nil {
+			return gdecimal.Zero
+		}
+		pe.marketInfoCache = mi
+	}
+	if taker {
+		fee, _ := pe.marketInfoCache.GetTakerFee(pm)
+		return fee
+	}
+	fee, _ := pe.marketInfoCache.GetMakerFee(pm)
+	return fee
+}
+
+// walk through order books to fill unitAmount, returns filled unit amount and quote amount
+func walkBook(levels fintypes.OrderBookList, unitAmount gdecimal.Decimal) (dealUnit, dealQuote gdecimal.Decimal) {
+	left := unitAmount
+	for _, lv := range levels {
+		if !left.IsPositive() {
+			break
+		}
+		deal := gdecimal.Min(left, lv.Amount)
+		dealUnit = dealUnit.Add(deal)
+		dealQuote = dealQuote.Add(deal.Mul(lv.Price))
+		left = left.Sub(deal)
+	}
+	return dealUnit, dealQuote
+}
+
+// position management is not supported, because only spot market is supported
+func (pe *Client) GetPositions(market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return pe.GetPositionsContext(context.Background(), market, target)
+}
+
+func (pe *Client) GetPositionsContext(ctx context.Context, market fintypes.Market, target *fintypes.Pair) ([]fintypes.Position, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) SetLeverage(market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return pe.SetLeverageContext(context.Background(), market, target, leverage)
+}
+
+func (pe *Client) SetLeverageContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, leverage int) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) SetMarginType(market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return pe.SetMarginTypeContext(context.Background(), market, target, margin)
+}
+
+func (pe *Client) SetMarginTypeContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, margin fintypes.Margin) error {
+	return fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) ClosePosition(market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return pe.ClosePositionContext(context.Background(), market, margin, target)
+}
+
+func (pe *Client) ClosePositionContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, target fintypes.Pair) (*fintypes.OrderId, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+// contract market data is not supported, because only spot market is supported
+func (pe *Client) GetFundingRates(market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return pe.GetFundingRatesContext(context.Background(), market, target, since)
+}
+
+func (pe *Client) GetFundingRatesContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, since *time.Time) (*fintypes.FundingRates, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) GetMarkPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return pe.GetMarkPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (pe *Client) GetMarkPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) GetIndexPriceKline(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return pe.GetIndexPriceKlineContext(context.Background(), market, target, period, since)
+}
+
+func (pe *Client) GetIndexPriceKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) GetOpenInterests(market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return pe.GetOpenInterestsContext(context.Background(), market, target, period, since)
+}
+
+func (pe *Client) GetOpenInterestsContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.OpenInterests, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+// wallet api is not supported, because there is no real wallet
+func (pe *Client) GetDepositAddress(asset, network string) (*fintypes.DepositAddress, error) {
+	return pe.GetDepositAddressContext(context.Background(), asset, network)
+}
+
+func (pe *Client) GetDepositAddressContext(ctx context.Context, asset, network string) (*fintypes.DepositAddress, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) Withdraw(req fintypes.WithdrawRequest) (string, error) {
+	return pe.WithdrawContext(context.Background(), req)
+}
+
+func (pe *Client) WithdrawContext(ctx context.Context, req fintypes.WithdrawRequest) (string, error) {
+	return "", fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) GetDeposits(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return pe.GetDepositsContext(context.Background(), asset, since)
+}
+
+func (pe *Client) GetDepositsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (pe *Client) GetWithdrawals(asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return pe.GetWithdrawalsContext(context.Background(), asset, since)
+}
+
+func (pe *Client) GetWithdrawalsContext(ctx context.Context, asset string, since *time.Time) ([]fintypes.WalletRecord, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
@@ -0,0 +1,169 @@
+package paper
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"testing"
+	"time"
+)
+
+type testFeed struct {
+	depth fintypes.Depth
+	last  gdecimal.Decimal
+}
+
+func (f *testFeed) Property() *fintypes.ExProperty {
+	return &fintypes.ExProperty{Name: fintypes.Binance, Clock: gtime.GetSysClock()}
+}
+
+func (f *testFeed) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	mi := &fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{}}
+	mi.Infos[fintypes.PairM("BTC/USDT.spot")] = fintypes.PairInfo{
+		Enabled:  true,
+		MakerFee: gdecimal.NewFromFloat64(0.001),
+		TakerFee: gdecimal.NewFromFloat64(0.001),
+	}
+	return mi, nil
+}
+
+func (f *testFeed) GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	d := f.depth
+	return &d, nil
+}
+
+func (f *testFeed) GetKlineContext(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error) {
+	return nil, fintypes.ErrFunctionNotSupported
+}
+
+func (f *testFeed) GetTicksContext(ctx context.Context, ignorePairsNotFound bool) (map[fintypes.PairM]fintypes.Tick, error) {
+	return map[fintypes.PairM]fintypes.Tick{fintypes.PairM("BTC/USDT.spot"): {Last: f.last}}, nil
+}
+
+func newTestFeed() *testFeed {
+	f := &testFeed{last: gdecimal.NewFromInt(100)}
+	f.depth.Sells = fintypes.OrderBookList{
+		{Price: gdecimal.NewFromInt(100), Amount: gdecimal.NewFromInt(1)},
+		{Price: gdecimal.NewFromInt(101), Amount: gdecimal.NewFromInt(2)},
+	}
+	f.depth.Buys = fintypes.OrderBookList{
+		{Price: gdecimal.NewFromInt(99), Amount: gdecimal.NewFromInt(1)},
+	}
+	return f
+}
+
+func TestClient_Trade(t *testing.T) {
+	usdt := fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "USDT")
+	btc := fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "BTC")
+	init := fintypes.NewEmptyAccount()
+	init.SetFreeAmount(usdt, gdecimal.NewFromInt(1000))
+
+	feed := newTestFeed()
+	pe, err := New(feed, init, nil)
+	gtest.Assert(t, err)
+
+	// limit buy crosses the best ask, the rest stays in order book
+	id, err := pe.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, fintypes.BTC.Against(fintypes.USDT), fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit, gdecimal.NewFromFloat64(1.5), gdecimal.NewFromFloat64(100.5), gdecimal.Zero)
+	gtest.Assert(t, err)
+	od, err := pe.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusPartiallyFilled || od.DealAmount.String() != "1" {
+		gtest.PrintlnExit(t, "order %s expect partially filled 1 but got %s", od.Status, od.DealAmount.String())
+	}
+	acc, err := pe.GetAccount()
+	gtest.Assert(t, err)
+	if acc.GetAmountByProperty(usdt).Free.String() != "849.25" || acc.GetAmountByProperty(usdt).Locked.String() != "50.75" {
+		gtest.PrintlnExit(t, "USDT balance error %s", acc.String())
+	}
+	if acc.GetAmountByProperty(btc).Free.String() != "0.999" {
+		gtest.PrintlnExit(t, "BTC balance error %s", acc.String())
+	}
+
+	// cancel unlocks the rest
+	gtest.Assert(t, pe.CancelOrder(*id))
+	acc, err = pe.GetAccount()
+	gtest.Assert(t, err)
+	if acc.GetAmountByProperty(usdt).Free.String() != "900" || !acc.GetAmountByProperty(usdt).Locked.IsZero() {
+		gtest.PrintlnExit(t, "USDT balance after cancel error %s", acc.String())
+	}
+
+	// market sell fills against the best bid
+	id, err = pe.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, fintypes.BTC.Against(fintypes.USDT), fintypes.OrderSideSellShort, fintypes.OrderTypeMarket, gdecimal.NewFromFloat64(0.5), gdecimal.Zero, gdecimal.Zero)
+	gtest.Assert(t, err)
+	od, err = pe.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusFilled || od.AvgPrice.String() != "99" {
+		gtest.PrintlnExit(t, "market sell error %s", od.String())
+	}
+	acc, err = pe.GetAccount()
+	gtest.Assert(t, err)
+	if acc.GetAmountByProperty(usdt).Free.String() != "949.4505" {
+		gtest.PrintlnExit(t, "USDT balance after sell error %s", acc.String())
+	}
+
+	// insufficient balance
+	_, err = pe.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, fintypes.BTC.Against(fintypes.USDT), fintypes.OrderSideSellShort, fintypes.OrderTypeLimit, gdecimal.NewFromInt(1), gdecimal.NewFromInt(100), gdecimal.Zero)
+	if !errors.Is(err, fintypes.ErrInsufficientBalance) {
+		gtest.PrintlnExit(t, "insufficient balance expected, but got %v", err)
+	}
+}
+
+func TestClient_StopLimit(t *testing.T) {
+	usdt := fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "USDT")
+	init := fintypes.NewEmptyAccount()
+	init.SetFreeAmount(usdt, gdecimal.NewFromInt(1000))
+
+	feed := newTestFeed()
+	pe, err := New(feed, init, nil)
+	gtest.Assert(t, err)
+
+	id, err := pe.Trade(fintypes.MarketSpot, fintypes.MarginNo, 1, fintypes.BTC.Against(fintypes.USDT), fintypes.OrderSideBuyLong, fintypes.OrderTypeStopLimit, gdecimal.NewFromInt(1), gdecimal.NewFromInt(102), gdecimal.NewFromInt(101))
+	gtest.Assert(t, err)
+	od, err := pe.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusNew {
+		gtest.PrintlnExit(t, "stop-limit order should not be triggered, but %s", od.Status)
+	}
+
+	feed.last = gdecimal.NewFromInt(101)
+	od, err = pe.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusFilled || od.AvgPrice.String() != "100" {
+		gtest.PrintlnExit(t, "stop-limit order should be filled, but %s", od.String())
+	}
+}
+
+func TestClient_ClientOrder(t *testing.T) {
+	usdt := fintypes.NewAP(fintypes.MarketSpot, fintypes.MarginNo, "USDT")
+	init := fintypes.NewEmptyAccount()
+	init.SetFreeAmount(usdt, gdecimal.NewFromInt(1000))
+
+	pe, err := New(newTestFeed(), init, nil)
+	gtest.Assert(t, err)
+
+	pair := fintypes.BTC.Against(fintypes.USDT)
+	req := fintypes.TradeRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Leverage: 1, Pair: pair, Side: fintypes.OrderSideBuyLong, Type: fintypes.OrderTypeLimit, Amount: gdecimal.NewFromInt(1), Price: gdecimal.NewFromInt(90), ClientId: "100000000000000001"}
+	id, err := pe.TradeEx(req)
+	gtest.Assert(t, err)
+	if _, err := pe.TradeEx(req); !errors.Is(err, fintypes.ErrDuplicateOrder) {
+		gtest.PrintlnExit(t, "duplicate client order id expected, but got %v", err)
+	}
+
+	od, err := pe.GetOrderByClientId(fintypes.MarketSpot, fintypes.MarginNo, pair, req.ClientId)
+	gtest.Assert(t, err)
+	if od.Id != *id || od.ClientId != req.ClientId {
+		gtest.PrintlnExit(t, "order by client id error %s", od.String())
+	}
+	gtest.Assert(t, pe.CancelOrderByClientId(fintypes.MarketSpot, fintypes.MarginNo, pair, req.ClientId))
+	od, err = pe.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusCanceled {
+		gtest.PrintlnExit(t, "order should be canceled, but %s", od.Status)
+	}
+	if _, err := pe.GetOrderByClientId(fintypes.MarketSpot, fintypes.MarginNo, pair, "100000000000000002"); !errors.Is(err, fintypes.ErrOrderNotFound) {
+		gtest.PrintlnExit(t, "order not found expected, but got %v", err)
+	}
+}
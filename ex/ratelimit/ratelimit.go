@@ -0,0 +1,186 @@
+package ratelimit
+
+/**
+shared rate limiter of exchange adapters
+
+every Rule is a fixed window budget, like binance 1200 request weight per minute, windows are aligned to interval like exchanges do.
+windows are shared by Rule.Key in the whole process, so clients created with the same api key (or on the same IP)
+consume the same budget in all goroutines.
+a request costs weights of some rules, it waits until all budgets are available, or returns *Error without waiting.
+*/
+
+import (
+	"context"
+	"fmt"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+type (
+	Rule struct {
+		Name     string // name used in Cost
+		Key      string // windows with the same key are shared, like "binance/spot/weight"
+		Limit    int    // max weight in one window
+		Interval time.Duration
+	}
+
+	// weights of one request, rule name -> weight
+	Cost map[string]int
+
+	Limiter struct {
+		windows map[string]*window // rule name -> shared window
+
+		// max waiting duration of Wait, 0 means no limit
+		MaxWait time.Duration
+	}
+
+	window struct {
+		key         string
+		limit       int
+		interval    time.Duration
+		start       time.Time
+		used        int
+		pausedUntil time.Time
+	}
+
+	// Error is returned if budget is not enough and limiter is not allowed to wait
+	Error struct {
+		Key        string
+		RetryAfter time.Duration
+	}
+)
+
+var (
+	mu      sync.Mutex
+	windows = map[string]*window{} // key -> window
+
+	now = time.Now
+)
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rate limit(%s) reached, retry after %s", e.Key, e.RetryAfter.String())
+}
+
+// errors.Is(err, fintypes.ErrRateLimited) is true
+func (e *Error) Unwrap() error {
+	return fintypes.ErrRateLimited
+}
+
+// rules of ExProperty.RateLimits, one call per duration
+func RulesOf(rateLimits map[fintypes.ExApi]time.Duration, keyPrefix string) []Rule {
+	var r []Rule
+	for api, d := range rateLimits {
+		if d <= 0 {
+			continue
+		}
+		r = append(r, Rule{Name: string(api), Key: keyPrefix + "/" + string(api), Limit: 1, Interval: d})
+	}
+	return r
+}
+
+// create limiter, windows of existing keys are reused, and the first rule of a key decides its limit
+func New(rules ...Rule) *Limiter {
+	mu.Lock()
+	defer mu.Unlock()
+
+	l := &Limiter{windows: map[string]*window{}}
+	for _, v := range rules {
+		w, ok := windows[v.Key]
+		if !ok {
+			w = &window{key: v.Key, limit: v.Limit, interval: v.Interval}
+			windows[v.Key] = w
+		}
+		l.windows[v.Name] = w
+	}
+	return l
+}
+
+func (w *window) wait(t time.Time, weight int) time.Duration {
+	if t.Before(w.pausedUntil) {
+		return w.pausedUntil.Sub(t)
+	}
+	start := t.Truncate(w.interval)
+	if !start.Equal(w.start) {
+		w.start = start
+		w.used = 0
+	}
+	if w.used+weight <= w.limit {
+		return 0
+	}
+	return start.Add(w.interval).Sub(t)
+}
+
+// consume budgets if all of them are available, otherwise returns the longest waiting duration and its key
+func (l *Limiter) reserve(cost Cost) (time.Duration, string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	t := now()
+	wait, key := time.Duration(0), ""
+	for name, weight := range cost {
+		w, ok := l.windows[name]
+		if !ok || weight <= 0 {
+			continue
+		}
+		if weight > w.limit {
+			return 0, "", errors.Errorf("weight %d exceeds limit %d of %s", weight, w.limit, w.key)
+		}
+		if d := w.wait(t, weight); d > wait {
+			wait, key = d, w.key
+		}
+	}
+	if wait > 0 {
+		return wait, key, nil
+	}
+	for name, weight := range cost {
+		if w, ok := l.windows[name]; ok && weight > 0 {
+			w.used += weight
+		}
+	}
+	return 0, "", nil
+}
+
+// wait until budgets are available, *Error is returned if waiting duration exceeds MaxWait
+func (l *Limiter) Wait(ctx context.Context, cost Cost) error {
+	for {
+		d, key, err := l.reserve(cost)
+		if err != nil || d == 0 {
+			return err
+		}
+		if l.MaxWait > 0 && d > l.MaxWait {
+			return &Error{Key: key, RetryAfter: d}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// consume budgets without waiting
+func (l *Limiter) Take(cost Cost) error {
+	d, key, err := l.reserve(cost)
+	if err != nil {
+		return err
+	}
+	if d > 0 {
+		return &Error{Key: key, RetryAfter: d}
+	}
+	return nil
+}
+
+// stop all rules of limiter for a while, used when exchange responses 429
+func (l *Limiter) Pause(d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	until := now().Add(d)
+	for _, w := range l.windows {
+		if until.After(w.pausedUntil) {
+			w.pausedUntil = until
+		}
+	}
+}
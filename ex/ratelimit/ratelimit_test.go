@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Take(t *testing.T) {
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return base }
+	defer func() { now = time.Now }()
+
+	rules := []Rule{{Name: "weight", Key: "test/take/weight", Limit: 10, Interval: time.Minute}, {Name: "orders", Key: "test/take/orders", Limit: 2, Interval: time.Second}}
+	l1, l2 := New(rules...), New(rules...)
+
+	if err := l1.Take(Cost{"weight": 5, "orders": 1}); err != nil {
+		t.Error(err)
+		return
+	}
+	// l2 shares windows with l1
+	if err := l2.Take(Cost{"weight": 5, "orders": 1}); err != nil {
+		t.Error(err)
+		return
+	}
+	err := l2.Take(Cost{"weight": 1})
+	var le *Error
+	if !errors.As(err, &le) || le.Key != "test/take/weight" || le.RetryAfter != time.Minute || !errors.Is(err, fintypes.ErrRateLimited) {
+		t.Errorf("expect rate limit error of weight, but got %v", err)
+		return
+	}
+	if err := l1.Take(Cost{"weight": 11}); err == nil || errors.Is(err, fintypes.ErrRateLimited) {
+		t.Errorf("weight larger than limit should never be satisfied, but got %v", err)
+		return
+	}
+
+	// next window
+	base = base.Add(time.Minute)
+	if err := l1.Take(Cost{"weight": 10}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	l1.Pause(time.Hour)
+	if err := l2.Take(Cost{"orders": 1}); err == nil {
+		t.Errorf("paused limiter should return error")
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	l := New(Rule{Name: "weight", Key: "test/wait/weight", Limit: 1, Interval: time.Millisecond * 50})
+	if err := l.Wait(context.Background(), Cost{"weight": 1}); err != nil {
+		t.Error(err)
+		return
+	}
+	begin := time.Now()
+	if err := l.Wait(context.Background(), Cost{"weight": 1}); err != nil {
+		t.Error(err)
+		return
+	}
+	if time.Since(begin) > time.Second {
+		t.Errorf("wait too long")
+		return
+	}
+
+	l.MaxWait = time.Millisecond
+	_ = l.Wait(context.Background(), Cost{"weight": 1})
+	if err := l.Wait(context.Background(), Cost{"weight": 1}); !errors.Is(err, fintypes.ErrRateLimited) {
+		t.Errorf("expect ErrRateLimited, but got %v", err)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	l := New(Rule{Name: "weight", Key: "test/transport/weight", Limit: 100, Interval: time.Minute})
+	c := Wrap(http.DefaultClient, l, func(req *http.Request) Cost { return Cost{"weight": 1} }, func() bool { return true })
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+
+	// paused by 429
+	_, err = c.Get(srv.URL)
+	if !errors.Is(err, fintypes.ErrRateLimited) {
+		t.Errorf("expect ErrRateLimited after 429, but got %v", err)
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Errorf("http.DefaultClient should not be changed")
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type (
+	// weights of http request
+	CostFunc func(req *http.Request) Cost
+
+	// Transport limits every request before sending it, so requests made by exchange sdk are limited too
+	Transport struct {
+		Base    http.RoundTripper
+		Limiter *Limiter
+		Cost    CostFunc
+		NoWait  func() bool // return *Error instead of waiting, optional
+	}
+)
+
+// pause duration if exchange responses 429/418 without Retry-After header
+var DefaultRetryAfter = time.Minute
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cost := t.Cost(req)
+	var err error
+	if t.NoWait != nil && t.NoWait() {
+		err = t.Limiter.Take(cost)
+	} else {
+		err = t.Limiter.Wait(req.Context(), cost)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// 429: too many requests, 418: IP banned by binance
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+		retryAfter := DefaultRetryAfter
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && sec > 0 {
+			retryAfter = time.Duration(sec) * time.Second
+		}
+		t.Limiter.Pause(retryAfter)
+	}
+	return resp, nil
+}
+
+// copy of client whose requests are limited, the original client is not changed because it may be shared, like http.DefaultClient
+func Wrap(c *http.Client, l *Limiter, cost CostFunc, noWait func() bool) *http.Client {
+	cpy := *c
+	cpy.Transport = &Transport{Base: c.Transport, Limiter: l, Cost: cost, NoWait: noWait}
+	return &cpy
+}
+
+// replace underlying transport of client wrapped by Wrap, like a recording or replaying transport in tests,
+// rate limit is kept, other clients get rt directly
+func SetBase(c *http.Client, rt http.RoundTripper) {
+	if t, ok := c.Transport.(*Transport); ok {
+		t.Base = rt
+		return
+	}
+	c.Transport = rt
+}
@@ -0,0 +1,300 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/**
+智能拆单，Smart Order Router
+
+a market order of target amount is split across venues by their depth and taker fees:
+price levels of all venues are ranked by price after taker fee, and the best ones are taken until target amount is reached,
+so every venue gets a prefix of its order book, and the split is optimal if depth doesn't change before dispatching.
+amount of every child order is rounded down by PairInfo.NormalizeOrder, venues whose child order violates
+min amount or min notional are excluded and their amount is split again, so the total may be a little less than target.
+expected result of every child is estimated by Depth.MarketBuyDetectEx/MarketSellDetectEx.
+*/
+
+type (
+	RouteRequest struct {
+		Market   fintypes.Market
+		Margin   fintypes.Margin
+		Pair     fintypes.Pair
+		Side     fintypes.OrderSide
+		Amount   gdecimal.Decimal // target unit amount, whether buy or sell
+		Slippage gdecimal.Decimal // max price deviation from best price of every venue, 0.01 means 1%, zero means no limit
+	}
+
+	// child order of one venue
+	RouteChild struct {
+		Venue    int // index of exchange in exs, venues may share the same platform, like sub accounts
+		Platform fintypes.Platform
+		Amount   gdecimal.Decimal        // unit amount of child order
+		TakerFee gdecimal.Decimal        // taker fee rate of venue
+		Expected fintypes.DepthTolerance // estimated by depth
+		Id       *fintypes.OrderId       // nil if not dispatched or dispatching failed
+		Order    *fintypes.Order         // queried after dispatching
+		Err      error
+	}
+
+	RouteResult struct {
+		Children         []RouteChild
+		ExpectedAmount   gdecimal.Decimal // sum of child amounts
+		ExpectedPrice    gdecimal.Decimal // blended price by depth, taker fee excluded
+		ExpectedNetPrice gdecimal.Decimal // blended price by depth, taker fee included
+		DealAmount       gdecimal.Decimal // sum of filled amounts of children
+		AvgPrice         gdecimal.Decimal // blended fill price of children
+	}
+
+	routeVenue struct {
+		index  int // index in exs
+		e      Ex
+		info   fintypes.PairInfo
+		depth  *fintypes.Depth
+		levels fintypes.OrderBookList // levels within slippage, best first
+	}
+
+	routeLevel struct {
+		venue  int
+		amount gdecimal.Decimal
+		net    gdecimal.Decimal // price after taker fee
+	}
+)
+
+// used by depth detecting when slippage is not limited
+var routeNoSlippageLimit = gdecimal.NewFromInt(1000000)
+
+func (req RouteRequest) verify() error {
+	if req.Side != fintypes.OrderSideBuyLong && req.Side != fintypes.OrderSideSellShort {
+		return errors.Errorf("invalid route side %s", req.Side)
+	}
+	if !req.Amount.IsPositive() {
+		return errors.Errorf("route amount %s should be positive", req.Amount.String())
+	}
+	if req.Slippage.LessThan(gdecimal.Zero) {
+		return errors.Errorf("route slippage %s should not be negative", req.Slippage.String())
+	}
+	return nil
+}
+
+func (req RouteRequest) slippage() gdecimal.Decimal {
+	if req.Slippage.IsPositive() {
+		return req.Slippage
+	}
+	return routeNoSlippageLimit
+}
+
+// price levels of the side to take, within slippage
+func (req RouteRequest) levels(depth *fintypes.Depth) fintypes.OrderBookList {
+	book := depth.Buys
+	if req.Side == fintypes.OrderSideBuyLong {
+		book = depth.Sells
+	}
+	book = fintypes.RemoveInvalidOrders(book)
+	if len(book) == 0 || !req.Slippage.IsPositive() {
+		return book
+	}
+	var r fintypes.OrderBookList
+	best := book[0].Price
+	for _, v := range book {
+		if req.Side == fintypes.OrderSideBuyLong && v.Price.GreaterThan(best.Mul(gdecimal.One.Add(req.Slippage))) {
+			break
+		}
+		if req.Side == fintypes.OrderSideSellShort && v.Price.LessThan(best.Mul(gdecimal.One.Sub(req.Slippage))) {
+			break
+		}
+		r = append(r, v)
+	}
+	return r
+}
+
+// price after taker fee, buyer pays more and seller gets less
+func (req RouteRequest) net(price, fee gdecimal.Decimal) gdecimal.Decimal {
+	if req.Side == fintypes.OrderSideBuyLong {
+		return price.Mul(gdecimal.One.Add(fee))
+	}
+	return price.Mul(gdecimal.One.Sub(fee))
+}
+
+// split amount across venues not excluded, result is keyed by venue index
+func (req RouteRequest) split(venues []routeVenue, excluded map[int]bool) map[int]gdecimal.Decimal {
+	var levels []routeLevel
+	for i, v := range venues {
+		if excluded[i] {
+			continue
+		}
+		for _, ob := range v.levels {
+			levels = append(levels, routeLevel{venue: i, amount: ob.Amount, net: req.net(ob.Price, v.info.TakerFee)})
+		}
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		if req.Side == fintypes.OrderSideBuyLong {
+			return levels[i].net.LessThan(levels[j].net)
+		}
+		return levels[i].net.GreaterThan(levels[j].net)
+	})
+
+	r := map[int]gdecimal.Decimal{}
+	left := req.Amount
+	for _, lv := range levels {
+		if !left.IsPositive() {
+			break
+		}
+		deal := gdecimal.Min(lv.amount, left)
+		r[lv.venue] = r[lv.venue].Add(deal)
+		left = left.Sub(deal)
+	}
+	return r
+}
+
+// precision and lot of unit amount used by MarketBuyDetectEx
+func routeUnitPrecision(info fintypes.PairInfo) (int, gdecimal.Decimal) {
+	if !info.UnitStep.IsPositive() {
+		return 8, gdecimal.NewFromFloat64(0.00000001)
+	}
+	s := info.UnitStep.String()
+	idx := strings.Index(s, ".")
+	if idx < 0 {
+		return 0, info.UnitStep
+	}
+	return len(s) - idx - 1, info.UnitStep
+}
+
+// estimated result of taking amount from venue
+func (req RouteRequest) detect(v routeVenue, amount gdecimal.Decimal) fintypes.DepthTolerance {
+	if req.Side == fintypes.OrderSideSellShort {
+		return v.depth.MarketSellDetectEx(amount, req.slippage())
+	}
+	quote := gdecimal.Zero
+	left := amount
+	for _, ob := range v.levels {
+		deal := gdecimal.Min(ob.Amount, left)
+		quote = quote.Add(deal.Mul(ob.Price))
+		left = left.Sub(deal)
+		if !left.IsPositive() {
+			break
+		}
+	}
+	precision, lot := routeUnitPrecision(v.info)
+	return v.depth.MarketBuyDetectEx(quote, req.slippage(), precision, lot)
+}
+
+// PlanRoute reads depth and market info of every exchange and splits req into child orders without dispatching them.
+// exchanges not listing the pair are ignored.
+func PlanRoute(ctx context.Context, exs []Ex, req RouteRequest) (*RouteResult, error) {
+	if err := req.verify(); err != nil {
+		return nil, err
+	}
+	pm := req.Pair.SetM(req.Market)
+	var venues []routeVenue
+	for index, e := range exs {
+		mi, err := e.GetMarketInfoContext(ctx, true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get market info of %s", e.Property().Name)
+		}
+		info, ok := mi.Infos[pm]
+		if !ok {
+			continue
+		}
+		depth, err := e.GetDepthContext(ctx, req.Market, req.Pair)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get depth of %s", e.Property().Name)
+		}
+		depth.Sort()
+		venues = append(venues, routeVenue{index: index, e: e, info: info, depth: depth, levels: req.levels(depth)})
+	}
+
+	// exclude venues whose child order can't be placed, then split again
+	excluded := map[int]bool{}
+	var amounts map[int]gdecimal.Decimal
+	for {
+		amounts = req.split(venues, excluded)
+		again := false
+		for i, amount := range amounts {
+			norm, _, _, err := venues[i].info.NormalizeOrder(fintypes.OrderTypeMarket, amount, venues[i].levels[0].Price, gdecimal.Zero)
+			if err != nil {
+				excluded[i] = true
+				again = true
+				continue
+			}
+			amounts[i] = norm
+		}
+		if !again {
+			break
+		}
+	}
+
+	r := &RouteResult{}
+	quote, netQuote := gdecimal.Zero, gdecimal.Zero
+	for i, v := range venues {
+		amount, ok := amounts[i]
+		if !ok {
+			continue
+		}
+		child := RouteChild{Venue: v.index, Platform: v.e.Property().Name, Amount: amount, TakerFee: v.info.TakerFee, Expected: req.detect(v, amount)}
+		r.Children = append(r.Children, child)
+		r.ExpectedAmount = r.ExpectedAmount.Add(amount)
+		quote = quote.Add(child.Expected.QuoteDealAmount)
+		netQuote = netQuote.Add(req.net(child.Expected.QuoteDealAmount, v.info.TakerFee))
+	}
+	if len(r.Children) == 0 {
+		return nil, errors.Errorf("no venue can %s %s %s of %s", req.Side, req.Amount.String(), req.Pair, req.Market)
+	}
+	r.ExpectedPrice = quote.Div(r.ExpectedAmount)
+	r.ExpectedNetPrice = netQuote.Div(r.ExpectedAmount)
+	return r, nil
+}
+
+// RouteOrder splits req by PlanRoute and dispatches child market orders concurrently by Ex.TradeContext.
+// filled orders are queried to report the blended fill price, expected price of the child is used if exchange doesn't provide AvgPrice.
+// failure of single child is reported in its Err, error is returned only if planning failed or all children failed.
+func RouteOrder(ctx context.Context, exs []Ex, req RouteRequest) (*RouteResult, error) {
+	r, err := PlanRoute(ctx, exs, req)
+	if err != nil {
+		return nil, err
+	}
+	wg := sync.WaitGroup{}
+	for i := range r.Children {
+		wg.Add(1)
+		go func(child *RouteChild) {
+			defer wg.Done()
+			e := exs[child.Venue]
+			child.Id, child.Err = e.TradeContext(ctx, req.Market, req.Margin, 0, req.Pair, req.Side, fintypes.OrderTypeMarket, child.Amount, gdecimal.Zero, gdecimal.Zero)
+			if child.Err != nil {
+				return
+			}
+			child.Order, child.Err = e.GetOrderContext(ctx, *child.Id)
+		}(&r.Children[i])
+	}
+	wg.Wait()
+
+	quote := gdecimal.Zero
+	var errs []string
+	for _, child := range r.Children {
+		if child.Err != nil {
+			errs = append(errs, child.Platform.String()+": "+child.Err.Error())
+		}
+		if child.Order == nil || !child.Order.DealAmount.IsPositive() {
+			continue
+		}
+		price := child.Order.AvgPrice
+		if !price.IsPositive() && child.Expected.UnitDealAmount.IsPositive() {
+			price = child.Expected.QuoteDealAmount.Div(child.Expected.UnitDealAmount)
+		}
+		r.DealAmount = r.DealAmount.Add(child.Order.DealAmount)
+		quote = quote.Add(child.Order.DealAmount.Mul(price))
+	}
+	if len(errs) == len(r.Children) {
+		return r, errors.Errorf("all child orders failed, %s", strings.Join(errs, "; "))
+	}
+	if r.DealAmount.IsPositive() {
+		r.AvgPrice = quote.Div(r.DealAmount)
+	}
+	return r, nil
+}
@@ -0,0 +1,100 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"strconv"
+	"testing"
+)
+
+// market orders are filled at best ask + 1 immediately
+type testRouteEx struct {
+	Ex
+	property fintypes.ExProperty
+	info     fintypes.PairInfo
+	asks     fintypes.OrderBookList
+	orders   map[fintypes.OrderId]fintypes.Order
+}
+
+func newTestRouteEx(platform fintypes.Platform, fee float64, asks ...int64) *testRouteEx {
+	e := &testRouteEx{property: fintypes.ExProperty{Name: platform}, orders: map[fintypes.OrderId]fintypes.Order{}}
+	e.info = fintypes.PairInfo{Enabled: true, TakerFee: gdecimal.NewFromFloat64(fee)}
+	for i := 0; i+1 < len(asks); i += 2 {
+		e.asks = append(e.asks, fintypes.OrderBook{Price: gdecimal.NewFromInt(asks[i]), Amount: gdecimal.NewFromInt(asks[i+1])})
+	}
+	return e
+}
+
+func (e *testRouteEx) Property() *fintypes.ExProperty {
+	return &e.property
+}
+
+func (e *testRouteEx) GetMarketInfoContext(ctx context.Context, ignorePairsNotFound bool) (*fintypes.MarketInfo, error) {
+	return &fintypes.MarketInfo{Infos: map[fintypes.PairM]fintypes.PairInfo{testPair.SetM(fintypes.MarketSpot): e.info}}, nil
+}
+
+func (e *testRouteEx) GetDepthContext(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, error) {
+	return &fintypes.Depth{DepthRawData: fintypes.DepthRawData{Sells: append(fintypes.OrderBookList{}, e.asks...)}}, nil
+}
+
+func (e *testRouteEx) TradeContext(ctx context.Context, market fintypes.Market, margin fintypes.Margin, leverage int, target fintypes.Pair, side fintypes.OrderSide, orderType fintypes.OrderType, unitAmount, price, stopPrice gdecimal.Decimal) (*fintypes.OrderId, error) {
+	id := fintypes.NewOrderId(market, margin, target, strconv.Itoa(len(e.orders)+1))
+	e.orders[id] = fintypes.Order{Id: id, Status: fintypes.OrderStatusFilled, Amount: unitAmount, DealAmount: unitAmount, AvgPrice: e.asks[0].Price.Add(gdecimal.One)}
+	return &id, nil
+}
+
+func (e *testRouteEx) GetOrderContext(ctx context.Context, id fintypes.OrderId) (*fintypes.Order, error) {
+	od, ok := e.orders[id]
+	if !ok {
+		return nil, fintypes.ErrOrderNotFound
+	}
+	return &od, nil
+}
+
+func TestRouteOrder(t *testing.T) {
+	bn := newTestRouteEx(fintypes.Binance, 0.001, 100, 2, 103, 5)
+	hb := newTestRouteEx(fintypes.Huobi, 0.002, 101, 2, 104, 5)
+	route := RouteRequest{Market: fintypes.MarketSpot, Margin: fintypes.MarginNo, Pair: testPair, Side: fintypes.OrderSideBuyLong, Amount: gdecimal.NewFromInt(4)}
+
+	// 101 of huobi after fee is cheaper than 103 of binance
+	r, err := RouteOrder(context.Background(), []Ex{bn, hb}, route)
+	gtest.Assert(t, err)
+	if len(r.Children) != 2 || !r.Children[0].Amount.Equal(gdecimal.NewFromInt(2)) || !r.Children[1].Amount.Equal(gdecimal.NewFromInt(2)) {
+		t.Errorf("2 + 2 split expected, but %v", r.Children)
+	}
+	if !r.ExpectedPrice.Equal(gdecimal.NewFromFloat64(100.5)) || !r.Children[1].Expected.QuoteDealAmount.Equal(gdecimal.NewFromInt(202)) {
+		t.Errorf("expected price should be 100.5, but %s", r.ExpectedPrice)
+	}
+	if !r.DealAmount.Equal(gdecimal.NewFromInt(4)) || !r.AvgPrice.Equal(gdecimal.NewFromFloat64(101.5)) {
+		t.Errorf("blended fill price should be 101.5, but %s of %s", r.AvgPrice, r.DealAmount)
+	}
+	if len(bn.orders) != 1 || len(hb.orders) != 1 {
+		t.Errorf("one child order should be sent to every venue")
+	}
+
+	// venues of the same platform, like two accounts
+	bn2 := newTestRouteEx(fintypes.Binance, 0.001, 101, 2, 104, 5)
+	bn.orders = map[fintypes.OrderId]fintypes.Order{}
+	r, err = RouteOrder(context.Background(), []Ex{bn, bn2}, route)
+	gtest.Assert(t, err)
+	if len(r.Children) != 2 || r.Children[0].Venue != 0 || r.Children[1].Venue != 1 || len(bn.orders) != 1 || len(bn2.orders) != 1 {
+		t.Errorf("one child order should be sent to every account of the same platform, but %v", r.Children)
+	}
+
+	// huobi is excluded by min amount
+	hb.info.UnitMin = gdecimal.NewFromInt(3)
+	r, err = PlanRoute(context.Background(), []Ex{bn, hb}, route)
+	gtest.Assert(t, err)
+	if len(r.Children) != 1 || r.Children[0].Platform != fintypes.Binance || !r.ExpectedPrice.Equal(gdecimal.NewFromFloat64(101.5)) {
+		t.Errorf("all should be routed to binance, but %v", r.Children)
+	}
+
+	// no liquidity
+	route.Amount = gdecimal.NewFromFloat64(0.5)
+	bn.info.UnitMin = gdecimal.One
+	if _, err := PlanRoute(context.Background(), []Ex{bn, hb}, route); err == nil {
+		t.Errorf("no venue should accept 0.5")
+	}
+}
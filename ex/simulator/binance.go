@@ -0,0 +1,620 @@
+package simulator
+
+import (
+	"fmt"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// binance api dialect, docs: https://binance-docs.github.io/apidocs/spot/en/ and https://binance-docs.github.io/apidocs/futures/en/
+
+var (
+	errBadParam = errors.Errorf("bad parameter")
+
+	binanceStatus = map[fintypes.OrderStatus]string{
+		fintypes.OrderStatusNew:      "NEW",
+		fintypes.OrderStatusFilled:   "FILLED",
+		fintypes.OrderStatusCanceled: "CANCELED",
+		fintypes.OrderStatusExpired:  "EXPIRED",
+	}
+
+	binanceTifs = map[string]fintypes.TimeInForce{
+		"GTC": fintypes.TimeInForceGTC,
+		"IOC": fintypes.TimeInForceIOC,
+		"FOK": fintypes.TimeInForceFOK,
+	}
+
+	wsUpgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+)
+
+func binanceSymbol(p fintypes.Pair) string {
+	return strings.ToUpper(p.Unit() + p.Quote())
+}
+
+func (s *Server) binanceHandler() http.Handler {
+	s.onUpdate = s.pushBinanceUserData
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/ping", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	})
+	mux.HandleFunc("/api/v3/time", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"serverTime": gtime.TimeToEpochMillis(s.clock.Now())})
+	})
+	mux.HandleFunc("/api/v3/exchangeInfo", s.binanceExchangeInfo)
+	mux.HandleFunc("/api/v3/depth", s.binanceDepth)
+	mux.HandleFunc("/api/v3/ticker/price", s.binanceTickerPrice)
+	mux.HandleFunc("/api/v3/account", s.binanceAccount)
+	mux.HandleFunc("/api/v3/order", s.binanceOrder)
+	mux.HandleFunc("/api/v3/openOrders", s.binanceOpenOrders)
+	mux.HandleFunc("/api/v3/allOrders", s.binanceAllOrders)
+	mux.HandleFunc("/api/v3/userDataStream", s.binanceUserDataStream)
+	mux.HandleFunc("/ws/", s.binanceWs)
+
+	// perp orders share handlers with spot, market is told by path
+	mux.HandleFunc("/fapi/v1/exchangeInfo", s.binancePerpExchangeInfo)
+	mux.HandleFunc("/fapi/v1/marginType", s.binancePerpMarginType)
+	mux.HandleFunc("/fapi/v1/leverage", s.binancePerpLeverage)
+	mux.HandleFunc("/fapi/v1/order", s.binanceOrder)
+	mux.HandleFunc("/fapi/v1/openOrders", s.binanceOpenOrders)
+	mux.HandleFunc("/fapi/v1/allOpenOrders", s.binancePerpCancelAll)
+
+	// margin account is not opened
+	mux.HandleFunc("/sapi/v1/margin/account", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": -3003, "msg": "Margin account does not exist."})
+	})
+	mux.HandleFunc("/sapi/v1/margin/openOrders", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []interface{}{})
+	})
+	mux.HandleFunc("/fapi/v1/ticker/price", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []interface{}{})
+	})
+	return mux
+}
+
+// perp api paths start with '/fapi/'
+func binanceMarket(r *http.Request) fintypes.Market {
+	if strings.HasPrefix(r.URL.Path, "/fapi/") {
+		return fintypes.MarketPerp
+	}
+	return fintypes.MarketSpot
+}
+
+// error codes are the same as binance, so they are parsed by adapter as real ones
+func writeBinanceErr(w http.ResponseWriter, err error, cancel bool) {
+	code, msg := -1000, err.Error()
+	switch errors.Cause(err) {
+	case errBadParam:
+		code, msg = -1102, err.Error()
+	case errInvalidPair:
+		code, msg = -1121, "Invalid symbol."
+	case errInvalidOrder:
+		code, msg = -1013, "Filter failure: "+err.Error()
+	case errMinNotional:
+		code, msg = -1013, "Filter failure: NOTIONAL"
+	case errInsufficientBalance:
+		code, msg = -2010, "Account has insufficient balance for requested action."
+	case errDuplicateOrder:
+		code, msg = -2010, "Duplicate order sent."
+	case errPostOnly:
+		code, msg = -2010, "Order would immediately match and take."
+	case errOrderNotFound, errOrderFinished:
+		code, msg = -2013, "Order does not exist."
+		if cancel {
+			code, msg = -2011, "Unknown order sent."
+		}
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": code, "msg": msg})
+}
+
+// symbol parameter is required if required is true, nil is returned if it is optional and empty
+func (s *Server) binancePair(r *http.Request, required bool) (*fintypes.Pair, error) {
+	symbol := r.FormValue("symbol")
+	if symbol == "" {
+		if required {
+			return nil, errors.Wrapf(errBadParam, "Mandatory parameter 'symbol' was not sent, was empty/null, or malformed.")
+		}
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.marketOf(symbol, binanceSymbol)
+	if err != nil {
+		return nil, err
+	}
+	return &m.Pair, nil
+}
+
+func (s *Server) binanceExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	symbols := []interface{}{}
+	for _, m := range s.sortedMarkets() {
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":                 binanceSymbol(m.Pair),
+			"status":                 "TRADING",
+			"baseAsset":              strings.ToUpper(m.Pair.Unit()),
+			"baseAssetPrecision":     m.AmountPrecision,
+			"quoteAsset":             strings.ToUpper(m.Pair.Quote()),
+			"quotePrecision":         m.PricePrecision,
+			"orderTypes":             []string{"LIMIT", "LIMIT_MAKER", "MARKET"},
+			"isSpotTradingAllowed":   true,
+			"isMarginTradingAllowed": false,
+			"filters": []map[string]interface{}{
+				{"filterType": "PRICE_FILTER", "minPrice": precisionStep(m.PricePrecision), "maxPrice": "1000000000", "tickSize": precisionStep(m.PricePrecision)},
+				{"filterType": "LOT_SIZE", "minQty": m.MinAmount.String(), "maxQty": "1000000000", "stepSize": precisionStep(m.AmountPrecision)},
+				{"filterType": "NOTIONAL", "minNotional": m.MinNotional.String()},
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"timezone": "UTC", "serverTime": gtime.TimeToEpochMillis(s.clock.Now()), "symbols": symbols})
+}
+
+func (s *Server) binancePerpExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	symbols := []interface{}{}
+	for _, m := range s.sortedMarkets() {
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":                binanceSymbol(m.Pair),
+			"pair":                  binanceSymbol(m.Pair),
+			"contractType":          "PERPETUAL",
+			"status":                "TRADING",
+			"baseAsset":             strings.ToUpper(m.Pair.Unit()),
+			"quoteAsset":            strings.ToUpper(m.Pair.Quote()),
+			"marginAsset":           strings.ToUpper(m.Pair.Quote()),
+			"pricePrecision":        m.PricePrecision,
+			"quantityPrecision":     m.AmountPrecision,
+			"maintMarginPercent":    "2.5000",
+			"requiredMarginPercent": "5.0000",
+			"filters": []map[string]interface{}{
+				{"filterType": "PRICE_FILTER", "minPrice": precisionStep(m.PricePrecision), "maxPrice": "1000000000", "tickSize": precisionStep(m.PricePrecision)},
+				{"filterType": "LOT_SIZE", "minQty": m.MinAmount.String(), "maxQty": "1000000000", "stepSize": precisionStep(m.AmountPrecision)},
+				{"filterType": "MIN_NOTIONAL", "notional": m.MinNotional.String()},
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"timezone": "UTC", "serverTime": gtime.TimeToEpochMillis(s.clock.Now()), "symbols": symbols})
+}
+
+// margin type is accepted but not emulated
+func (s *Server) binancePerpMarginType(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.binancePair(r, true); err != nil {
+		writeBinanceErr(w, err, false)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": 200, "msg": "success"})
+}
+
+// leverage is accepted but not emulated, perp orders freeze margin without leverage
+func (s *Server) binancePerpLeverage(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.binancePair(r, true)
+	if err != nil {
+		writeBinanceErr(w, err, false)
+		return
+	}
+	leverage, err := strconv.Atoi(r.FormValue("leverage"))
+	if err != nil || leverage <= 0 {
+		writeBinanceErr(w, errors.Wrapf(errBadParam, "Illegal characters found in parameter 'leverage'"), false)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"leverage": leverage, "maxNotionalValue": "1000000000", "symbol": binanceSymbol(*pair)})
+}
+
+func (s *Server) binanceDepth(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.binancePair(r, true)
+	if err != nil {
+		writeBinanceErr(w, err, false)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	books := func(obs []fintypes.OrderBook) [][]string {
+		res := [][]string{}
+		for _, v := range obs {
+			res = append(res, []string{v.Price.String(), v.Amount.String()})
+		}
+		return res
+	}
+	depth := s.markets[*pair].Depth
+	writeJSON(w, http.StatusOK, map[string]interface{}{"lastUpdateId": s.nextId, "bids": books(depth.Buys), "asks": books(depth.Sells)})
+}
+
+func (s *Server) binanceTickerPrice(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.binancePair(r, false)
+	if err != nil {
+		writeBinanceErr(w, err, false)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := []interface{}{}
+	for _, m := range s.sortedMarkets() {
+		if pair != nil && m.Pair != *pair {
+			continue
+		}
+		res = append(res, map[string]interface{}{"symbol": binanceSymbol(m.Pair), "price": m.last.String()})
+	}
+	if pair != nil {
+		writeJSON(w, http.StatusOK, res[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) binanceAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var assets []string
+	for asset := range s.balances {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	balances := []interface{}{}
+	for _, asset := range assets {
+		balances = append(balances, map[string]interface{}{"asset": asset, "free": s.balances[asset].free.String(), "locked": s.balances[asset].locked.String()})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"makerCommission": 0,
+		"takerCommission": 0,
+		"canTrade":        true,
+		"canWithdraw":     true,
+		"canDeposit":      true,
+		"updateTime":      gtime.TimeToEpochMillis(s.clock.Now()),
+		"accountType":     "SPOT",
+		"balances":        balances,
+		"permissions":     []string{"SPOT"},
+	})
+}
+
+// POST: new order, GET: query order, DELETE: cancel order
+func (s *Server) binanceOrder(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.binancePair(r, true)
+	if err != nil {
+		writeBinanceErr(w, err, r.Method == http.MethodDelete)
+		return
+	}
+
+	market := binanceMarket(r)
+	if r.Method == http.MethodPost {
+		o, err := s.binanceParseOrder(r, market, *pair)
+		if err != nil {
+			writeBinanceErr(w, err, false)
+			return
+		}
+		if o, err = s.place(o); err != nil {
+			writeBinanceErr(w, err, false)
+			return
+		}
+		res := binanceOrderJSON(o)
+		if market == fintypes.MarketPerp {
+			writeJSON(w, http.StatusOK, res)
+			return
+		}
+		res["fills"] = []interface{}{}
+		if o.dealAmount.IsPositive() {
+			res["fills"] = []interface{}{map[string]interface{}{
+				"price": o.dealQuote.Div(o.dealAmount).String(), "qty": o.dealAmount.String(), "commission": "0", "commissionAsset": strings.ToUpper(o.pair.Quote()),
+			}}
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	id, _ := strconv.ParseInt(r.FormValue("orderId"), 10, 64)
+	clientId := r.FormValue("origClientOrderId")
+	if id == 0 && clientId == "" {
+		writeBinanceErr(w, errors.Wrapf(errBadParam, "Param 'origClientOrderId' or 'orderId' must be sent, but both were empty/null!"), r.Method == http.MethodDelete)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		o, err := s.get(market, pair, id, clientId)
+		if err != nil {
+			writeBinanceErr(w, err, false)
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrderJSON(o))
+	case http.MethodDelete:
+		o, err := s.cancel(market, pair, id, clientId)
+		if err != nil {
+			writeBinanceErr(w, err, true)
+			return
+		}
+		res := binanceOrderJSON(o)
+		res["origClientOrderId"] = o.clientId
+		writeJSON(w, http.StatusOK, res)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) binanceParseOrder(r *http.Request, market fintypes.Market, pair fintypes.Pair) (order, error) {
+	o := order{market: market, pair: pair, clientId: r.FormValue("newClientOrderId"), tif: fintypes.TimeInForceGTC}
+	switch r.FormValue("side") {
+	case "BUY":
+		o.side = fintypes.OrderSideBuyLong
+	case "SELL":
+		o.side = fintypes.OrderSideSellShort
+	default:
+		return o, errors.Wrapf(errBadParam, "Invalid side.")
+	}
+	switch r.FormValue("type") {
+	case "LIMIT":
+		o.orderType = fintypes.OrderTypeLimit
+		tif, ok := binanceTifs[r.FormValue("timeInForce")]
+		if market == fintypes.MarketPerp && r.FormValue("timeInForce") == "GTX" {
+			tif, ok = fintypes.TimeInForceGTX, true // post only of perp
+		}
+		if !ok {
+			return o, errors.Wrapf(errBadParam, "Invalid timeInForce.")
+		}
+		o.tif = tif
+	case "LIMIT_MAKER":
+		o.orderType = fintypes.OrderTypeLimit
+		o.tif = fintypes.TimeInForceGTX
+	case "MARKET":
+		o.orderType = fintypes.OrderTypeMarket
+	default:
+		return o, errors.Wrapf(errInvalidOrder, "unsupported order type %s", r.FormValue("type"))
+	}
+
+	var err error
+	for _, kv := range []struct {
+		dst *gdecimal.Decimal
+		key string
+	}{{&o.amount, "quantity"}, {&o.quoteAmount, "quoteOrderQty"}, {&o.price, "price"}} {
+		*kv.dst = gdecimal.Zero
+		if v := r.FormValue(kv.key); v != "" {
+			if *kv.dst, err = gdecimal.NewFromString(v); err != nil {
+				return o, errors.Wrapf(errBadParam, "Illegal characters found in parameter '%s'", kv.key)
+			}
+		}
+	}
+	return o, nil
+}
+
+// GET: open orders, DELETE: cancel all open orders of symbol
+func (s *Server) binanceOpenOrders(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.binancePair(r, r.Method == http.MethodDelete)
+	if err != nil {
+		writeBinanceErr(w, err, false)
+		return
+	}
+	market := binanceMarket(r)
+	res := []interface{}{}
+	for _, o := range s.list(market, pair, false) {
+		if r.Method == http.MethodDelete {
+			if o, err = s.cancel(market, pair, o.id, ""); err != nil {
+				continue // finished by others
+			}
+		}
+		res = append(res, binanceOrderJSON(o))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) binanceAllOrders(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.binancePair(r, true)
+	if err != nil {
+		writeBinanceErr(w, err, false)
+		return
+	}
+	res := []interface{}{}
+	for _, o := range s.list(fintypes.MarketSpot, pair, true) {
+		res = append(res, binanceOrderJSON(o))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// DELETE: cancel all perp open orders of symbol, canceled orders are not returned like binance
+func (s *Server) binancePerpCancelAll(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.binancePair(r, true)
+	if err != nil {
+		writeBinanceErr(w, err, true)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	for _, o := range s.list(fintypes.MarketPerp, pair, false) {
+		_, _ = s.cancel(fintypes.MarketPerp, pair, o.id, "") // error means finished by others
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": 200, "msg": "The operation of cancel all open order is done."})
+}
+
+// spot order or perp order like binance
+func binanceOrderJSON(o order) map[string]interface{} {
+	orderType, tif := "LIMIT", o.tif.String()
+	if o.orderType.IsMarket() {
+		orderType, tif = "MARKET", "GTC"
+	} else if o.tif == fintypes.TimeInForceGTX && o.market != fintypes.MarketPerp {
+		orderType, tif = "LIMIT_MAKER", "GTC"
+	}
+	side := "BUY"
+	if o.side.IsSell() {
+		side = "SELL"
+	}
+	clientId := o.clientId
+	if clientId == "" {
+		clientId = fmt.Sprintf("sim%d", o.id)
+	}
+	if o.market == fintypes.MarketPerp {
+		avgPrice := "0"
+		if o.dealAmount.IsPositive() {
+			avgPrice = o.dealQuote.Div(o.dealAmount).String()
+		}
+		return map[string]interface{}{
+			"symbol":        binanceSymbol(o.pair),
+			"orderId":       o.id,
+			"clientOrderId": clientId,
+			"price":         o.price.String(),
+			"avgPrice":      avgPrice,
+			"origQty":       o.amount.String(),
+			"executedQty":   o.dealAmount.String(),
+			"cumQty":        o.dealAmount.String(),
+			"cumQuote":      o.dealQuote.String(),
+			"status":        binanceStatus[o.status],
+			"timeInForce":   strings.ToUpper(tif),
+			"type":          orderType,
+			"origType":      orderType,
+			"side":          side,
+			"positionSide":  "BOTH",
+			"stopPrice":     "0",
+			"reduceOnly":    false,
+			"closePosition": false,
+			"workingType":   "CONTRACT_PRICE",
+			"time":          gtime.TimeToEpochMillis(o.created),
+			"updateTime":    gtime.TimeToEpochMillis(o.updated),
+		}
+	}
+	return map[string]interface{}{
+		"symbol":              binanceSymbol(o.pair),
+		"orderId":             o.id,
+		"orderListId":         -1,
+		"clientOrderId":       clientId,
+		"price":               o.price.String(),
+		"origQty":             o.amount.String(),
+		"executedQty":         o.dealAmount.String(),
+		"cummulativeQuoteQty": o.dealQuote.String(),
+		"status":              binanceStatus[o.status],
+		"timeInForce":         strings.ToUpper(tif),
+		"type":                orderType,
+		"side":                side,
+		"stopPrice":           "0",
+		"icebergQty":          "0",
+		"time":                gtime.TimeToEpochMillis(o.created),
+		"updateTime":          gtime.TimeToEpochMillis(o.updated),
+		"transactTime":        gtime.TimeToEpochMillis(o.updated),
+		"isWorking":           true,
+	}
+}
+
+// POST: new listen key, PUT: keepalive, DELETE: close
+func (s *Server) binanceUserDataStream(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPost:
+		s.streamId++
+		key := fmt.Sprintf("simListenKey%d", s.streamId)
+		s.streams[key] = map[chan []byte]struct{}{}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"listenKey": key})
+	case http.MethodPut:
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	case http.MethodDelete:
+		delete(s.streams, r.FormValue("listenKey"))
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// websocket of user data stream, path is '/ws/{listenKey}'
+func (s *Server) binanceWs(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/ws/")
+	s.mu.Lock()
+	subs, ok := s.streams[key]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": -1125, "msg": "This listenKey does not exist."})
+		return
+	}
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	msgC := make(chan []byte, 100)
+	s.mu.Lock()
+	subs[msgC] = struct{}{}
+	s.mu.Unlock()
+	select {
+	case s.connected <- struct{}{}:
+	default:
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(subs, msgC)
+		s.mu.Unlock()
+	}()
+
+	// reading detects closed connection
+	closeC := make(chan struct{})
+	go func() {
+		defer close(closeC)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-closeC:
+			return
+		case msg := <-msgC:
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// push executionReport and outboundAccountPosition to all user data streams, lock is held by caller
+func (s *Server) pushBinanceUserData(o *order) {
+	if o.market == fintypes.MarketPerp {
+		return // user data stream of perp is not emulated
+	}
+	now := gtime.TimeToEpochMillis(s.clock.Now())
+	execType := "NEW"
+	switch o.status {
+	case fintypes.OrderStatusFilled:
+		execType = "TRADE"
+	case fintypes.OrderStatusCanceled:
+		execType = "CANCELED"
+	case fintypes.OrderStatusExpired:
+		execType = "EXPIRED"
+	}
+	js := binanceOrderJSON(*o)
+	report := map[string]interface{}{
+		"e": "executionReport", "E": now, "s": js["symbol"], "c": js["clientOrderId"], "C": "", "S": js["side"],
+		"o": js["type"], "f": js["timeInForce"], "q": js["origQty"], "p": js["price"], "P": "0", "x": execType,
+		"X": js["status"], "i": o.id, "l": "0", "z": js["executedQty"], "L": "0", "Z": js["cummulativeQuoteQty"],
+		"n": "0", "N": nil, "T": now, "O": js["time"],
+	}
+	if o.status == fintypes.OrderStatusFilled {
+		report["l"], report["L"] = o.dealAmount.String(), o.dealQuote.Div(o.dealAmount).String()
+	}
+	if o.status == fintypes.OrderStatusCanceled {
+		report["C"] = js["clientOrderId"]
+	}
+
+	var balances []interface{}
+	for _, asset := range []string{o.pair.Unit(), o.pair.Quote()} {
+		b := s.balance(asset)
+		balances = append(balances, map[string]interface{}{"a": strings.ToUpper(asset), "f": b.free.String(), "l": b.locked.String()})
+	}
+	position := map[string]interface{}{"e": "outboundAccountPosition", "E": now, "u": now, "B": balances}
+
+	for _, v := range []interface{}{report, position} {
+		msg := mustMarshal(v)
+		for _, subs := range s.streams {
+			for c := range subs {
+				select {
+				case c <- msg:
+				default: // slow reader, message is dropped like a broken connection
+				}
+			}
+		}
+	}
+}
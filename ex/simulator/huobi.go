@@ -0,0 +1,402 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// huobi api dialect, docs: https://huobiapi.github.io/docs/spot/v1/cn/
+
+// id of the only spot account
+const huobiAccountId = 1
+
+var huobiStates = map[fintypes.OrderStatus]string{
+	fintypes.OrderStatusNew:      "submitted",
+	fintypes.OrderStatusFilled:   "filled",
+	fintypes.OrderStatusCanceled: "canceled",
+	fintypes.OrderStatusExpired:  "canceled",
+}
+
+func huobiSymbol(p fintypes.Pair) string {
+	return strings.ToLower(p.Unit() + p.Quote())
+}
+
+func (s *Server) huobiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/common/symbols", s.huobiSymbols)
+	mux.HandleFunc("/v1/account/accounts", func(w http.ResponseWriter, r *http.Request) {
+		writeHuobiData(w, []interface{}{map[string]interface{}{"id": huobiAccountId, "type": "spot", "subtype": "", "state": "working"}})
+	})
+	mux.HandleFunc(fmt.Sprintf("/v1/account/accounts/%d/balance", huobiAccountId), s.huobiBalance)
+	mux.HandleFunc("/market/depth", s.huobiDepth)
+	mux.HandleFunc("/market/tickers", s.huobiTickers)
+	mux.HandleFunc("/market/detail/merged", s.huobiMerged)
+	mux.HandleFunc("/v1/order/openOrders", s.huobiOpenOrders)
+	mux.HandleFunc("/v1/order/orders", s.huobiOpenOrders)
+	mux.HandleFunc("/v1/order/batch-orders", s.huobiBatchOrders)
+	mux.HandleFunc("/v1/order/orders/", s.huobiOrders)
+
+	// perp market is not emulated
+	for _, path := range []string{"/linear-swap-api/v1/swap_contract_info", "/linear-swap-api/v1/swap_account_info", "/linear-swap-api/v1/swap_cross_account_info"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			writeHuobiData(w, []interface{}{})
+		})
+	}
+	mux.HandleFunc("/linear-swap-ex/market/detail/batch_merged", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "ticks": []interface{}{}, "ts": gtime.TimeToEpochMillis(s.clock.Now())})
+	})
+	return mux
+}
+
+func writeHuobiData(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "data": data})
+}
+
+// huobi returns errors with http status 200
+func writeHuobiErr(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "error", "err-code": huobiErrCode(err), "err-msg": err.Error(), "data": nil})
+}
+
+func huobiErrCode(err error) string {
+	switch errors.Cause(err) {
+	case errInvalidPair:
+		return "base-symbol-error"
+	case errMinNotional:
+		return "order-value-min-error"
+	case errInsufficientBalance:
+		return "account-balance-insufficient-error"
+	case errDuplicateOrder:
+		return "invalid-client-order-id"
+	case errOrderNotFound:
+		return "base-record-invalid"
+	case errOrderFinished:
+		return "order-orderstate-error"
+	default:
+		return "invalid-parameter"
+	}
+}
+
+func (s *Server) huobiPair(symbol string) (*fintypes.Pair, error) {
+	if symbol == "" {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.marketOf(symbol, huobiSymbol)
+	if err != nil {
+		return nil, err
+	}
+	return &m.Pair, nil
+}
+
+func (s *Server) huobiSymbols(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := []interface{}{}
+	for _, m := range s.sortedMarkets() {
+		data = append(data, map[string]interface{}{
+			"base-currency":               strings.ToLower(m.Pair.Unit()),
+			"quote-currency":              strings.ToLower(m.Pair.Quote()),
+			"price-precision":             m.PricePrecision,
+			"amount-precision":            m.AmountPrecision,
+			"state":                       "online",
+			"min-order-amt":               m.MinAmount.String(),
+			"min-order-value":             m.MinNotional.String(),
+			"leverage-ratio":              0,
+			"super-margin-leverage-ratio": 0,
+		})
+	}
+	writeHuobiData(w, data)
+}
+
+func (s *Server) huobiBalance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var assets []string
+	for asset := range s.balances {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	list := []interface{}{}
+	for _, asset := range assets {
+		b := s.balances[asset]
+		list = append(list,
+			map[string]interface{}{"currency": strings.ToLower(asset), "type": "trade", "balance": b.free.String()},
+			map[string]interface{}{"currency": strings.ToLower(asset), "type": "frozen", "balance": b.locked.String()})
+	}
+	writeHuobiData(w, map[string]interface{}{"id": huobiAccountId, "type": "spot", "state": "working", "list": list})
+}
+
+func (s *Server) huobiDepth(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.huobiPair(r.FormValue("symbol"))
+	if err == nil && pair == nil {
+		err = errors.Wrapf(errInvalidPair, "empty symbol")
+	}
+	if err != nil {
+		writeHuobiErr(w, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	books := func(obs []fintypes.OrderBook) [][]json.Number {
+		res := [][]json.Number{}
+		for _, v := range obs {
+			res = append(res, []json.Number{json.Number(v.Price.String()), json.Number(v.Amount.String())})
+		}
+		return res
+	}
+	depth := s.markets[*pair].Depth
+	now := gtime.TimeToEpochMillis(s.clock.Now())
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"ts":     now,
+		"tick":   map[string]interface{}{"bids": books(depth.Buys), "asks": books(depth.Sells), "ts": now},
+	})
+}
+
+func (s *Server) huobiTickers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := []interface{}{}
+	for _, m := range s.sortedMarkets() {
+		bid, ask := bestPrices(m.Depth)
+		data = append(data, map[string]interface{}{
+			"symbol": huobiSymbol(m.Pair),
+			"open":   json.Number(m.last.String()),
+			"high":   json.Number(m.last.String()),
+			"low":    json.Number(m.last.String()),
+			"close":  json.Number(m.last.String()),
+			"amount": 0,
+			"bid":    json.Number(bid.String()),
+			"ask":    json.Number(ask.String()),
+		})
+	}
+	writeHuobiData(w, data)
+}
+
+func (s *Server) huobiMerged(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.huobiPair(r.FormValue("symbol"))
+	if err == nil && pair == nil {
+		err = errors.Wrapf(errInvalidPair, "empty symbol")
+	}
+	if err != nil {
+		writeHuobiErr(w, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.markets[*pair]
+	book := func(obs []fintypes.OrderBook) []json.Number {
+		if len(obs) == 0 {
+			return []json.Number{}
+		}
+		return []json.Number{json.Number(obs[0].Price.String()), json.Number(obs[0].Amount.String())}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"ts":     gtime.TimeToEpochMillis(s.clock.Now()),
+		"tick":   map[string]interface{}{"close": json.Number(m.last.String()), "bid": book(m.Depth.Buys), "ask": book(m.Depth.Sells)},
+	})
+}
+
+// open orders, or all orders for '/v1/order/orders'
+func (s *Server) huobiOpenOrders(w http.ResponseWriter, r *http.Request) {
+	pair, err := s.huobiPair(r.FormValue("symbol"))
+	if err != nil {
+		writeHuobiErr(w, err)
+		return
+	}
+	data := []interface{}{}
+	for _, o := range s.list(fintypes.MarketSpot, pair, r.URL.Path == "/v1/order/orders") {
+		data = append(data, huobiOrderJSON(o))
+	}
+	writeHuobiData(w, data)
+}
+
+func (s *Server) huobiBatchOrders(w http.ResponseWriter, r *http.Request) {
+	var bodies []map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&bodies); err != nil {
+		writeHuobiErr(w, errors.Wrapf(err, "invalid body"))
+		return
+	}
+	data := []interface{}{}
+	for _, body := range bodies {
+		o, err := s.huobiParseOrder(body)
+		if err == nil {
+			o, err = s.place(o)
+		}
+		if err != nil {
+			data = append(data, map[string]interface{}{"client-order-id": o.clientId, "err-code": huobiErrCode(err), "err-msg": err.Error()})
+			continue
+		}
+		data = append(data, map[string]interface{}{"order-id": o.id, "client-order-id": o.clientId})
+	}
+	writeHuobiData(w, data)
+}
+
+// routes of '/v1/order/orders/...'
+func (s *Server) huobiOrders(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/order/orders/")
+	body := map[string]interface{}{}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeHuobiErr(w, errors.Wrapf(err, "invalid body"))
+			return
+		}
+	}
+
+	switch {
+	case path == "place" && r.Method == http.MethodPost:
+		o, err := s.huobiParseOrder(body)
+		if err == nil {
+			o, err = s.place(o)
+		}
+		if err != nil {
+			writeHuobiErr(w, err)
+			return
+		}
+		writeHuobiData(w, strconv.FormatInt(o.id, 10))
+	case path == "getClientOrder":
+		o, err := s.get(fintypes.MarketSpot, nil, 0, r.FormValue("clientOrderId"))
+		if err != nil {
+			writeHuobiErr(w, err)
+			return
+		}
+		writeHuobiData(w, huobiOrderJSON(o))
+	case path == "submitCancelClientOrder" && r.Method == http.MethodPost:
+		if _, err := s.cancel(fintypes.MarketSpot, nil, 0, huobiString(body["client-order-id"])); err != nil {
+			writeHuobiErr(w, err)
+			return
+		}
+		writeHuobiData(w, 0)
+	case path == "batchcancel" && r.Method == http.MethodPost:
+		ids, _ := body["order-ids"].([]interface{})
+		success, failed := []string{}, []interface{}{}
+		for _, v := range ids {
+			strId := huobiString(v)
+			id, _ := strconv.ParseInt(strId, 10, 64)
+			if _, err := s.cancel(fintypes.MarketSpot, nil, id, ""); err != nil {
+				failed = append(failed, map[string]interface{}{"order-id": strId, "err-code": huobiErrCode(err), "err-msg": err.Error()})
+				continue
+			}
+			success = append(success, strId)
+		}
+		writeHuobiData(w, map[string]interface{}{"success": success, "failed": failed})
+	case strings.HasSuffix(path, "/submitcancel") && r.Method == http.MethodPost:
+		id, _ := strconv.ParseInt(strings.TrimSuffix(path, "/submitcancel"), 10, 64)
+		o, err := s.cancel(fintypes.MarketSpot, nil, id, "")
+		if err != nil {
+			writeHuobiErr(w, err)
+			return
+		}
+		writeHuobiData(w, strconv.FormatInt(o.id, 10))
+	default:
+		id, err := strconv.ParseInt(path, 10, 64)
+		if err != nil || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		o, err := s.get(fintypes.MarketSpot, nil, id, "")
+		if err != nil {
+			writeHuobiErr(w, err)
+			return
+		}
+		writeHuobiData(w, huobiOrderJSON(o))
+	}
+}
+
+func (s *Server) huobiParseOrder(body map[string]interface{}) (order, error) {
+	o := order{clientId: huobiString(body["client-order-id"]), market: fintypes.MarketSpot, tif: fintypes.TimeInForceGTC}
+	if accId := huobiString(body["account-id"]); accId != strconv.Itoa(huobiAccountId) {
+		return o, errors.Wrapf(errInvalidOrder, "account %s not found", accId)
+	}
+	pair, err := s.huobiPair(huobiString(body["symbol"]))
+	if err == nil && pair == nil {
+		err = errors.Wrapf(errInvalidPair, "empty symbol")
+	}
+	if err != nil {
+		return o, err
+	}
+	o.pair = *pair
+
+	switch huobiString(body["type"]) {
+	case "buy-limit":
+		o.side, o.orderType = fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit
+	case "sell-limit":
+		o.side, o.orderType = fintypes.OrderSideSellShort, fintypes.OrderTypeLimit
+	case "buy-market":
+		o.side, o.orderType = fintypes.OrderSideBuyLong, fintypes.OrderTypeMarket
+	case "sell-market":
+		o.side, o.orderType = fintypes.OrderSideSellShort, fintypes.OrderTypeMarket
+	default:
+		return o, errors.Wrapf(errInvalidOrder, "unsupported order type %s", huobiString(body["type"]))
+	}
+
+	o.price, o.amount, o.quoteAmount = gdecimal.Zero, gdecimal.Zero, gdecimal.Zero
+	amount, err := gdecimal.NewFromString(huobiString(body["amount"]))
+	if err != nil {
+		return o, errors.Wrapf(errInvalidOrder, "invalid amount")
+	}
+	// amount of market buy order is quote amount
+	if o.orderType.IsMarket() && o.side.IsBuy() {
+		o.quoteAmount = amount
+	} else {
+		o.amount = amount
+	}
+	if o.orderType.IsLimit() {
+		if o.price, err = gdecimal.NewFromString(huobiString(body["price"])); err != nil {
+			return o, errors.Wrapf(errInvalidOrder, "invalid price")
+		}
+	}
+	return o, nil
+}
+
+func huobiOrderJSON(o order) map[string]interface{} {
+	side := "buy"
+	if o.side.IsSell() {
+		side = "sell"
+	}
+	amount := o.amount
+	if o.quoteAmount.IsPositive() {
+		amount = o.quoteAmount
+	}
+	return map[string]interface{}{
+		"id":                 o.id,
+		"client-order-id":    o.clientId,
+		"symbol":             huobiSymbol(o.pair),
+		"account-id":         huobiAccountId,
+		"amount":             amount.String(),
+		"price":              o.price.String(),
+		"created-at":         gtime.TimeToEpochMillis(o.created),
+		"type":               side + "-" + o.orderType.String(),
+		"field-amount":       o.dealAmount.String(),
+		"field-cash-amount":  o.dealQuote.String(),
+		"field-fees":         "0",
+		"filled-amount":      o.dealAmount.String(),
+		"filled-cash-amount": o.dealQuote.String(),
+		"filled-fees":        "0",
+		"state":              huobiStates[o.status],
+	}
+}
+
+// string of json value which is string or number
+func huobiString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(t)
+	}
+}
@@ -0,0 +1,466 @@
+package simulator
+
+/**
+offline exchange simulator, a local http server which emulates a subset of exchange rest api, so adapters can be
+tested deterministically without api keys and network, use Ex.SetBaseUrl (ex.SetBaseUrl) to point adapters at it.
+
+NOTE:
+spot market is emulated, and perp market of binance is emulated partially: perp orders share order books of spot
+markets, they freeze quote balance as margin without leverage, and positions are not opened by fills. other contract
+apis which are called by adapters implicitly (like ticks) return empty results.
+markets are canned: order books never change unless SetDepth is called, market orders and crossing limit orders are
+filled entirely at the best opposite price, resting limit orders are filled at their own prices when order books
+set by SetDepth cross them. partial fills and fees are not emulated, signatures and api keys are not verified.
+
+supported api:
+binance: exchange info, depth, ticker price, account, order place/query/cancel, open orders, all orders,
+         cancel all, user data stream and its websocket
+         perp exchange info, margin type, leverage, order place/query/cancel, open orders, cancel all
+huobi:   symbols, accounts, balance, depth, tickers, merged tick, order place/query/cancel by id or client id,
+         open orders, batch cancel
+*/
+
+import (
+	"encoding/json"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errInvalidPair         = errors.Errorf("invalid pair")
+	errInvalidOrder        = errors.Errorf("invalid order")
+	errMinNotional         = errors.Errorf("order value is less than min notional")
+	errInsufficientBalance = errors.Errorf("insufficient balance")
+	errOrderNotFound       = errors.Errorf("order not found")
+	errOrderFinished       = errors.Errorf("order is finished")
+	errDuplicateOrder      = errors.Errorf("duplicate client order id")
+	errPostOnly            = errors.Errorf("post only order would take liquidity")
+)
+
+type (
+	// canned market of simulator
+	Market struct {
+		Pair            fintypes.Pair
+		PricePrecision  int
+		AmountPrecision int
+		MinAmount       gdecimal.Decimal
+		MinNotional     gdecimal.Decimal
+		Depth           fintypes.Depth // Buys are sorted by price desc, Sells are sorted by price asc
+		last            gdecimal.Decimal
+	}
+
+	balance struct {
+		free   gdecimal.Decimal
+		locked gdecimal.Decimal
+	}
+
+	order struct {
+		id          int64
+		clientId    string
+		market      fintypes.Market // MarketSpot or MarketPerp
+		pair        fintypes.Pair
+		side        fintypes.OrderSide
+		orderType   fintypes.OrderType
+		tif         fintypes.TimeInForce
+		price       gdecimal.Decimal
+		amount      gdecimal.Decimal // unit amount
+		quoteAmount gdecimal.Decimal // market buy order of huobi only, it is converted to amount by best ask
+		dealAmount  gdecimal.Decimal
+		dealQuote   gdecimal.Decimal
+		frozen      gdecimal.Decimal // locked quote balance of buy or perp order, locked unit balance of spot sell order
+		status      fintypes.OrderStatus
+		created     time.Time
+		updated     time.Time
+	}
+
+	Server struct {
+		platform fintypes.Platform
+		clock    gtime.Clock
+		srv      *httptest.Server
+
+		mu       sync.Mutex
+		markets  map[fintypes.Pair]*Market
+		balances map[string]*balance // key is upper case asset
+		orders   []*order
+		nextId   int64
+		onUpdate func(o *order) // called with lock held after order and balances changed
+
+		streams   map[string]map[chan []byte]struct{} // binance user data streams by listen key
+		streamId  int
+		connected chan struct{}
+	}
+)
+
+// start a simulator of platform with canned markets, Close it after use
+func New(platform fintypes.Platform, markets []Market, c gtime.Clock) (*Server, error) {
+	s := &Server{
+		platform:  platform,
+		clock:     c,
+		markets:   map[fintypes.Pair]*Market{},
+		balances:  map[string]*balance{},
+		streams:   map[string]map[chan []byte]struct{}{},
+		connected: make(chan struct{}, 16),
+	}
+	if s.clock == nil {
+		s.clock = gtime.GetSysClock()
+	}
+	for i := range markets {
+		m := markets[i]
+		if err := m.Pair.Verify(); err != nil {
+			return nil, err
+		}
+		m.last = midPrice(m.Depth)
+		s.markets[m.Pair] = &m
+	}
+
+	var handler http.Handler
+	switch platform {
+	case fintypes.Binance:
+		handler = s.binanceHandler()
+	case fintypes.Huobi:
+		handler = s.huobiHandler()
+	default:
+		return nil, gerror.Errorf("simulator doesn't support %s", platform)
+	}
+	s.srv = httptest.NewServer(handler)
+	return s, nil
+}
+
+// base url of rest api, like 'http://127.0.0.1:12345'
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// base url of raw websocket streams, it ends with '/ws/'
+func (s *Server) WsURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/ws/"
+}
+
+// receives once a user data stream websocket is connected and ready for pushes, like a sync point of tests
+func (s *Server) Connected() <-chan struct{} {
+	return s.connected
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// add free balance of asset, amount could be negative
+func (s *Server) Deposit(asset string, amount gdecimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.balance(asset)
+	b.free = b.free.Add(amount)
+}
+
+// free and locked balance of asset
+func (s *Server) Balance(asset string) (free, locked gdecimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.balance(asset)
+	return b.free, b.locked
+}
+
+// replace order books of pair, resting limit orders crossed by new order books are filled at their own prices
+func (s *Server) SetDepth(pair fintypes.Pair, depth fintypes.Depth) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.markets[pair]
+	if !ok {
+		return errors.Wrapf(errInvalidPair, pair.String())
+	}
+	m.Depth = depth
+	for _, o := range s.orders {
+		if o.pair != pair || o.status.End() {
+			continue
+		}
+		if s.match(m, o, true) {
+			s.notify(o)
+		}
+	}
+	return nil
+}
+
+func (s *Server) balance(asset string) *balance {
+	asset = strings.ToUpper(asset)
+	b, ok := s.balances[asset]
+	if !ok {
+		b = &balance{free: gdecimal.Zero, locked: gdecimal.Zero}
+		s.balances[asset] = b
+	}
+	return b
+}
+
+func (s *Server) notify(o *order) {
+	if s.onUpdate != nil {
+		s.onUpdate(o)
+	}
+}
+
+// find market by custom symbol of exchange
+func (s *Server) marketOf(symbol string, format func(p fintypes.Pair) string) (*Market, error) {
+	for p, m := range s.markets {
+		if format(p) == symbol {
+			return m, nil
+		}
+	}
+	return nil, errors.Wrapf(errInvalidPair, symbol)
+}
+
+// place new order, id, status and times are set by simulator, a copy of placed order is returned
+func (s *Server) place(o order) (order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.markets[o.pair]
+	if !ok {
+		return order{}, errors.Wrapf(errInvalidPair, o.pair.String())
+	}
+	if o.clientId != "" {
+		for _, v := range s.orders {
+			if v.market == o.market && v.clientId == o.clientId && !v.status.End() {
+				return order{}, errors.Wrapf(errDuplicateOrder, o.clientId)
+			}
+		}
+	}
+	if !o.orderType.IsLimit() && !o.orderType.IsMarket() {
+		return order{}, errors.Wrapf(errInvalidOrder, "unsupported OrderType(%s)", o.orderType)
+	}
+
+	bid, ask := bestPrices(m.Depth)
+	refPrice := o.price
+	if o.orderType.IsMarket() {
+		refPrice = bid
+		if o.side.IsBuy() {
+			refPrice = ask
+		}
+		if !refPrice.IsPositive() {
+			return order{}, errors.Wrapf(errInvalidOrder, "no liquidity for market order")
+		}
+		o.price = gdecimal.Zero
+		if o.quoteAmount.IsPositive() {
+			o.amount = o.quoteAmount.Div(refPrice)
+		}
+	}
+	if !o.amount.IsPositive() || !refPrice.IsPositive() {
+		return order{}, errors.Wrapf(errInvalidOrder, "invalid amount %s or price %s", o.amount.String(), refPrice.String())
+	}
+	if o.amount.LessThan(m.MinAmount) {
+		return order{}, errors.Wrapf(errInvalidOrder, "amount %s is less than min amount %s", o.amount.String(), m.MinAmount.String())
+	}
+	if o.amount.Mul(refPrice).LessThan(m.MinNotional) {
+		return order{}, errors.Wrapf(errMinNotional, "min notional %s", m.MinNotional.String())
+	}
+	if o.tif.IsPostOnly() && ((o.side.IsBuy() && ask.IsPositive() && !o.price.LessThan(ask)) || (o.side.IsSell() && bid.IsPositive() && !o.price.GreaterThan(bid))) {
+		return order{}, errPostOnly
+	}
+
+	// freeze balance
+	asset, need := o.frozenAsset(), o.amount
+	if asset == o.pair.Quote() {
+		need = o.amount.Mul(refPrice)
+	}
+	b := s.balance(asset)
+	if b.free.LessThan(need) {
+		return order{}, errors.Wrapf(errInsufficientBalance, "%s free %s, required %s", asset, b.free.String(), need.String())
+	}
+	b.free = b.free.Sub(need)
+	b.locked = b.locked.Add(need)
+
+	s.nextId++
+	now := s.clock.Now()
+	o.id = s.nextId
+	o.frozen = need
+	o.dealAmount = gdecimal.Zero
+	o.dealQuote = gdecimal.Zero
+	o.status = fintypes.OrderStatusNew
+	o.created = now
+	o.updated = now
+	s.orders = append(s.orders, &o)
+
+	s.match(m, &o, false)
+	if !o.status.End() && (o.orderType.IsMarket() || o.tif == fintypes.TimeInForceIOC || o.tif == fintypes.TimeInForceFOK) {
+		s.release(&o, fintypes.OrderStatusExpired)
+	}
+	s.notify(&o)
+	return o, nil
+}
+
+// fill order entirely if it crosses order books, maker order is filled at its price, taker order at best opposite price
+func (s *Server) match(m *Market, o *order, maker bool) bool {
+	if o.status.End() {
+		return false
+	}
+	bid, ask := bestPrices(m.Depth)
+	price := gdecimal.Zero
+	if o.side.IsBuy() && ask.IsPositive() && (o.orderType.IsMarket() || !o.price.LessThan(ask)) {
+		price = ask
+	} else if o.side.IsSell() && bid.IsPositive() && (o.orderType.IsMarket() || !o.price.GreaterThan(bid)) {
+		price = bid
+	} else {
+		return false
+	}
+	if maker {
+		price = o.price
+	}
+
+	quote := o.amount.Mul(price)
+	unitBalance, quoteBalance := s.balance(o.pair.Unit()), s.balance(o.pair.Quote())
+	if o.market == fintypes.MarketPerp {
+		// positions are not emulated, margin is returned once filled
+		quoteBalance.locked = quoteBalance.locked.Sub(o.frozen)
+		quoteBalance.free = quoteBalance.free.Add(o.frozen)
+	} else if o.side.IsBuy() {
+		// frozen quote of limit order may be more than cost, the rest is returned
+		quoteBalance.locked = quoteBalance.locked.Sub(o.frozen)
+		quoteBalance.free = quoteBalance.free.Add(o.frozen.Sub(quote))
+		unitBalance.free = unitBalance.free.Add(o.amount)
+	} else {
+		unitBalance.locked = unitBalance.locked.Sub(o.frozen)
+		quoteBalance.free = quoteBalance.free.Add(quote)
+	}
+	o.frozen = gdecimal.Zero
+	o.dealAmount = o.amount
+	o.dealQuote = quote
+	o.status = fintypes.OrderStatusFilled
+	o.updated = s.clock.Now()
+	m.last = price
+	return true
+}
+
+// return frozen balance of unfinished order and finish it with status
+func (s *Server) release(o *order, status fintypes.OrderStatus) {
+	b := s.balance(o.frozenAsset())
+	b.locked = b.locked.Sub(o.frozen)
+	b.free = b.free.Add(o.frozen)
+	o.frozen = gdecimal.Zero
+	o.status = status
+	o.updated = s.clock.Now()
+}
+
+// pair is optional, order is found by client id if it is not empty
+func (s *Server) find(market fintypes.Market, pair *fintypes.Pair, id int64, clientId string) (*order, error) {
+	for _, o := range s.orders {
+		if o.market != market || (pair != nil && o.pair != *pair) {
+			continue
+		}
+		if (clientId != "" && o.clientId == clientId) || (clientId == "" && o.id == id) {
+			return o, nil
+		}
+	}
+	if clientId != "" {
+		return nil, errors.Wrapf(errOrderNotFound, "client id %s", clientId)
+	}
+	return nil, errors.Wrapf(errOrderNotFound, "id %d", id)
+}
+
+func (s *Server) get(market fintypes.Market, pair *fintypes.Pair, id int64, clientId string) (order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.find(market, pair, id, clientId)
+	if err != nil {
+		return order{}, err
+	}
+	return *o, nil
+}
+
+func (s *Server) cancel(market fintypes.Market, pair *fintypes.Pair, id int64, clientId string) (order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.find(market, pair, id, clientId)
+	if err != nil {
+		return order{}, err
+	}
+	if o.status.End() {
+		return order{}, errors.Wrapf(errOrderFinished, "order %d is %s", o.id, o.status)
+	}
+	s.release(o, fintypes.OrderStatusCanceled)
+	s.notify(o)
+	return *o, nil
+}
+
+// pair is optional, finished orders are included if all is true
+func (s *Server) list(market fintypes.Market, pair *fintypes.Pair, all bool) []order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r []order
+	for _, o := range s.orders {
+		if o.market != market || (pair != nil && o.pair != *pair) {
+			continue
+		}
+		if all || !o.status.End() {
+			r = append(r, *o)
+		}
+	}
+	return r
+}
+
+// asset frozen by order, perp orders freeze quote as margin
+func (o *order) frozenAsset() string {
+	if o.market == fintypes.MarketPerp || o.side.IsBuy() {
+		return o.pair.Quote()
+	}
+	return o.pair.Unit()
+}
+
+// markets sorted by pair, lock is held by caller
+func (s *Server) sortedMarkets() []*Market {
+	var r []*Market
+	for _, m := range s.markets {
+		r = append(r, m)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Pair.String() < r[j].Pair.String()
+	})
+	return r
+}
+
+func bestPrices(depth fintypes.Depth) (bid, ask gdecimal.Decimal) {
+	bid, ask = gdecimal.Zero, gdecimal.Zero
+	if len(depth.Buys) > 0 {
+		bid = depth.Buys[0].Price
+	}
+	if len(depth.Sells) > 0 {
+		ask = depth.Sells[0].Price
+	}
+	return bid, ask
+}
+
+func midPrice(depth fintypes.Depth) gdecimal.Decimal {
+	bid, ask := bestPrices(depth)
+	if bid.IsPositive() && ask.IsPositive() {
+		return bid.Add(ask).Div(gdecimal.NewFromInt(2))
+	}
+	return gdecimal.Max(bid, ask)
+}
+
+// price or amount step of precision, like '0.01' for precision 2
+func precisionStep(precision int) string {
+	if precision <= 0 {
+		return "1"
+	}
+	return "0." + strings.Repeat("0", precision-1) + "1"
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(mustMarshal(v))
+}
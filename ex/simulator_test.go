@@ -0,0 +1,181 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/ex/simulator"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"testing"
+	"time"
+)
+
+var testPair = fintypes.BTC.Against(fintypes.USDT)
+
+func testDepth(bid, ask int64) fintypes.Depth {
+	return fintypes.Depth{DepthRawData: fintypes.DepthRawData{
+		Buys:  fintypes.OrderBookList{{Price: gdecimal.NewFromInt(bid), Amount: gdecimal.NewFromInt(1)}},
+		Sells: fintypes.OrderBookList{{Price: gdecimal.NewFromInt(ask), Amount: gdecimal.NewFromInt(1)}},
+	}}
+}
+
+// returns exchange of platform pointed at a simulator with 10000 USDT deposited
+func newTestSimEx(t *testing.T, platform fintypes.Platform) (Ex, *simulator.Server) {
+	sim, err := simulator.New(platform, []simulator.Market{{
+		Pair:            testPair,
+		PricePrecision:  2,
+		AmountPrecision: 4,
+		MinAmount:       gdecimal.NewFromFloat64(0.0001),
+		MinNotional:     gdecimal.NewFromInt(10),
+		Depth:           testDepth(9999, 10001),
+	}}, nil)
+	gtest.Assert(t, err)
+	t.Cleanup(sim.Close)
+	sim.Deposit(fintypes.USDT.String(), gdecimal.NewFromInt(10000))
+
+	e, err := NewEx(platform, "access", "secret", "", nil, "")
+	gtest.Assert(t, err)
+	gtest.Assert(t, SetBaseUrl(e, fintypes.MarketSpot, sim.URL()))
+	gtest.Assert(t, SetBaseUrl(e, fintypes.MarketPerp, sim.URL()))
+	return e, sim
+}
+
+func TestSimulator(t *testing.T) {
+	for _, platform := range []fintypes.Platform{fintypes.Binance, fintypes.Huobi} {
+		e, sim := newTestSimEx(t, platform)
+
+		mi, err := e.GetMarketInfo(true)
+		gtest.Assert(t, err)
+		if _, ok := mi.Infos[testPair.SetM(fintypes.MarketSpot)]; !ok {
+			t.Errorf("%s: %s not found in market info", platform, testPair)
+		}
+
+		depth, err := e.GetDepth(fintypes.MarketSpot, testPair)
+		gtest.Assert(t, err)
+		if len(depth.Buys) != 1 || !depth.Buys[0].Price.Equal(gdecimal.NewFromInt(9999)) {
+			t.Errorf("%s: wrong depth %s", platform, depth.String())
+		}
+
+		// resting limit order, then filled as maker by new depth
+		id, err := e.Trade(fintypes.MarketSpot, fintypes.MarginNo, 0, testPair, fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit, gdecimal.NewFromFloat64(0.1), gdecimal.NewFromInt(9000), gdecimal.Zero)
+		gtest.Assert(t, err)
+		if _, locked := sim.Balance(fintypes.USDT.String()); !locked.Equal(gdecimal.NewFromInt(900)) {
+			t.Errorf("%s: locked USDT should be 900, but %s", platform, locked)
+		}
+		mkt, mgn := fintypes.MarketSpot, fintypes.MarginNo
+		opens, err := e.GetOpenOrders(&mkt, &mgn, &testPair)
+		gtest.Assert(t, err)
+		if len(opens) != 1 {
+			t.Errorf("%s: 1 open order expected, but %d", platform, len(opens))
+		}
+		gtest.Assert(t, sim.SetDepth(testPair, testDepth(8900, 8999)))
+		od, err := e.GetOrder(*id)
+		gtest.Assert(t, err)
+		if od.Status != fintypes.OrderStatusFilled || !od.DealAmount.Equal(gdecimal.NewFromFloat64(0.1)) {
+			t.Errorf("%s: order should be filled, but %s", platform, od.Status)
+		}
+		if free, _ := sim.Balance(fintypes.BTC.String()); !free.Equal(gdecimal.NewFromFloat64(0.1)) {
+			t.Errorf("%s: free BTC should be 0.1, but %s", platform, free)
+		}
+
+		// cancel
+		id, err = e.Trade(fintypes.MarketSpot, fintypes.MarginNo, 0, testPair, fintypes.OrderSideSellShort, fintypes.OrderTypeLimit, gdecimal.NewFromFloat64(0.1), gdecimal.NewFromInt(9500), gdecimal.Zero)
+		gtest.Assert(t, err)
+		gtest.Assert(t, e.CancelOrder(*id))
+		od, err = e.GetOrder(*id)
+		gtest.Assert(t, err)
+		if od.Status != fintypes.OrderStatusCanceled {
+			t.Errorf("%s: order should be canceled, but %s", platform, od.Status)
+		}
+
+		// market order and account
+		_, err = e.Trade(fintypes.MarketSpot, fintypes.MarginNo, 0, testPair, fintypes.OrderSideSellShort, fintypes.OrderTypeMarket, gdecimal.NewFromFloat64(0.1), gdecimal.Zero, gdecimal.Zero)
+		gtest.Assert(t, err)
+		acc, err := e.GetAccount()
+		gtest.Assert(t, err)
+		for _, b := range acc.Balances {
+			if b.Asset == fintypes.USDT.String() && !b.Free.Equal(gdecimal.NewFromInt(9990)) {
+				t.Errorf("%s: free USDT should be 9990, but %s", platform, b.Free)
+			}
+		}
+
+		// errors are classified like real exchange
+		_, err = e.Trade(fintypes.MarketSpot, fintypes.MarginNo, 0, testPair, fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit, gdecimal.NewFromInt(10), gdecimal.NewFromInt(8000), gdecimal.Zero)
+		if !errors.Is(err, fintypes.ErrInsufficientBalance) {
+			t.Errorf("%s: insufficient balance error expected, but %v", platform, err)
+		}
+		_, err = e.GetOrder(fintypes.NewOrderId(fintypes.MarketSpot, fintypes.MarginNo, testPair, "12345"))
+		if !errors.Is(err, fintypes.ErrOrderNotFound) {
+			t.Errorf("%s: order not found error expected, but %v", platform, err)
+		}
+	}
+}
+
+func TestSimulator_BinanceUserData(t *testing.T) {
+	e, sim := newTestSimEx(t, fintypes.Binance)
+	ws, ok := e.(interface {
+		SetWsBaseUrl(market fintypes.Market, wsBaseUrl string) error
+	})
+	if !ok {
+		gtest.PrintlnExit(t, "binance should support ws base url setting")
+	}
+	gtest.Assert(t, ws.SetWsBaseUrl(fintypes.MarketSpot, sim.WsURL()))
+	st, ok := AsStreamer(e)
+	if !ok {
+		gtest.PrintlnExit(t, "binance should be a streamer")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	orders, errs, err := st.SubOrders(ctx, fintypes.MarketSpot, fintypes.MarginNo)
+	gtest.Assert(t, err)
+	select {
+	case <-sim.Connected():
+	case <-time.After(5 * time.Second):
+		gtest.PrintlnExit(t, "user data stream not connected")
+	}
+
+	id, err := e.Trade(fintypes.MarketSpot, fintypes.MarginNo, 0, testPair, fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit, gdecimal.NewFromFloat64(0.1), gdecimal.NewFromInt(9000), gdecimal.Zero)
+	gtest.Assert(t, err)
+	select {
+	case od := <-orders:
+		if od.Id.StrId() != id.StrId() || od.Status != fintypes.OrderStatusNew {
+			t.Errorf("wrong order update %s %s", od.Id.StrId(), od.Status)
+		}
+	case err := <-errs:
+		t.Error(err)
+	case <-time.After(5 * time.Second):
+		t.Error("order update timeout")
+	}
+}
+
+func TestSimulator_BinancePerpCancelAll(t *testing.T) {
+	e, sim := newTestSimEx(t, fintypes.Binance)
+
+	id, err := e.Trade(fintypes.MarketPerp, fintypes.MarginIsolated, 1, testPair, fintypes.OrderSideBuyLong, fintypes.OrderTypeLimit, gdecimal.NewFromFloat64(0.1), gdecimal.NewFromInt(9000), gdecimal.Zero)
+	gtest.Assert(t, err)
+	if _, locked := sim.Balance(fintypes.USDT.String()); !locked.Equal(gdecimal.NewFromInt(900)) {
+		t.Errorf("locked USDT should be 900, but %s", locked)
+	}
+	mkt := fintypes.MarketPerp
+	opens, err := e.GetOpenOrders(&mkt, nil, &testPair)
+	gtest.Assert(t, err)
+	if len(opens) != 1 || opens[0].Id.StrId() != id.StrId() {
+		t.Errorf("perp open order %s expected, but %v", id.StrId(), opens)
+	}
+
+	res, err := e.CancelAllOrders(fintypes.MarketPerp, fintypes.MarginIsolated, testPair)
+	gtest.Assert(t, err)
+	if len(res) != 1 || res[0].Id.StrId() != id.StrId() || res[0].Err != nil {
+		gtest.PrintlnExit(t, "perp order %s should be canceled, but %v", id.StrId(), res)
+	}
+	od, err := e.GetOrder(*id)
+	gtest.Assert(t, err)
+	if od.Status != fintypes.OrderStatusCanceled {
+		t.Errorf("order should be canceled, but %s", od.Status)
+	}
+	if free, locked := sim.Balance(fintypes.USDT.String()); !free.Equal(gdecimal.NewFromInt(10000)) || !locked.IsZero() {
+		t.Errorf("USDT should be unlocked, but free %s, locked %s", free, locked)
+	}
+}
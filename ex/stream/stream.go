@@ -0,0 +1,132 @@
+package stream
+
+/**
+shared reconnection & cancellation logic of exchange streaming api
+
+a subscription is a connection started by DialFunc, doneC is closed when the connection is broken or stopped,
+closing stopC asks the connection to stop.
+Keep redials broken connections until ctx is done, so every exchange behaves the same way:
+  1. error of first dial is returned directly
+  2. broken connections are reported by ErrDisconnected in error channel and redialed with exponential backoff
+  3. after ctx is done, the connection is stopped and onExit is called, output channels should be closed there
+*/
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+type (
+	DialFunc func() (doneC, stopC chan struct{}, err error)
+)
+
+var (
+	ErrDisconnected = errors.Errorf("stream disconnected, reconnecting")
+
+	MinBackoff = time.Second
+	MaxBackoff = time.Minute
+
+	// websocket dialer used by Serve
+	Dialer = websocket.DefaultDialer
+
+	// read timeout of websocket in Serve, connection without any message in this duration is treated as broken
+	ReadTimeout = time.Minute * 3
+)
+
+// send error without blocking, errors are dropped if nobody reads them
+func SendErr(errC chan<- error, err error) {
+	if err == nil {
+		return
+	}
+	select {
+	case errC <- err:
+	default:
+	}
+}
+
+// keep connection alive until ctx is done
+func Keep(ctx context.Context, dial DialFunc, errC chan<- error, onExit func()) error {
+	doneC, stopC, err := dial()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer func() {
+			if onExit != nil {
+				onExit()
+			}
+		}()
+
+		backoff := MinBackoff
+		for {
+			select {
+			case <-ctx.Done():
+				close(stopC)
+				<-doneC
+				return
+			case <-doneC:
+			}
+
+			// connection broken, redial until success or ctx done
+			SendErr(errC, ErrDisconnected)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				doneC, stopC, err = dial()
+				if err == nil {
+					backoff = MinBackoff
+					break
+				}
+				SendErr(errC, err)
+				backoff *= 2
+				if backoff > MaxBackoff {
+					backoff = MaxBackoff
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// serve raw websocket messages of url, for streams which are not wrapped by exchange sdk
+func Serve(url string, header http.Header, handler func(msg []byte), errHandler func(err error)) (doneC, stopC chan struct{}, err error) {
+	c, _, err := Dialer.Dial(url, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	doneC = make(chan struct{})
+	stopC = make(chan struct{})
+
+	go func() {
+		defer close(doneC)
+		for {
+			_ = c.SetReadDeadline(time.Now().Add(ReadTimeout))
+			_, msg, err := c.ReadMessage()
+			if err != nil {
+				select {
+				case <-stopC: // stopped by caller, it is not an error
+				default:
+					errHandler(err)
+				}
+				return
+			}
+			handler(msg)
+		}
+	}()
+
+	go func() {
+		select {
+		case <-stopC:
+		case <-doneC:
+		}
+		_ = c.Close()
+	}()
+	return doneC, stopC, nil
+}
@@ -0,0 +1,60 @@
+package stream
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+func TestKeep(t *testing.T) {
+	MinBackoff = time.Millisecond
+	dials := make(chan chan struct{}, 10) // doneC of every connection
+	dial := func() (chan struct{}, chan struct{}, error) {
+		doneC, stopC := make(chan struct{}), make(chan struct{})
+		go func() {
+			<-stopC
+			close(doneC)
+		}()
+		dials <- doneC
+		return doneC, stopC, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := make(chan error, 10)
+	exitC := make(chan struct{})
+	if err := Keep(ctx, dial, errC, func() { close(exitC) }); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// break the first connection, it should be redialed
+	first := <-dials
+	close(first)
+	select {
+	case <-dials:
+	case <-time.After(time.Second):
+		t.Error("connection should be redialed")
+		return
+	}
+	if err := <-errC; err != ErrDisconnected {
+		t.Errorf("expect ErrDisconnected, but got %v", err)
+		return
+	}
+
+	// cancel stops the connection and calls onExit
+	cancel()
+	select {
+	case <-exitC:
+	case <-time.After(time.Second):
+		t.Error("onExit should be called after cancel")
+	}
+}
+
+func TestKeep_DialError(t *testing.T) {
+	dialErr := errors.Errorf("dial error")
+	err := Keep(context.Background(), func() (chan struct{}, chan struct{}, error) { return nil, nil, dialErr }, nil, nil)
+	if err != dialErr {
+		t.Errorf("expect dial error, but got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package ex
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+)
+
+type (
+	// Streamer is unified streaming api, exchanges created by NewEx implement it if supported, use AsStreamer to get it.
+	// every subscription returns a data channel and an error channel, it is kept alive until ctx is done,
+	// broken connections are reconnected automatically and reported by stream.ErrDisconnected in error channel,
+	// data channel is closed after ctx is done.
+	Streamer interface {
+		// closed bars only
+		SubKline(ctx context.Context, market fintypes.Market, target fintypes.Pair, period fintypes.Period) (<-chan fintypes.Bar, <-chan error, error)
+
+		// top order books snapshots
+		SubDepth(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.Depth, <-chan error, error)
+
+		SubTick(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.Tick, <-chan error, error)
+
+		// public trades of market
+		SubFills(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.Fill, <-chan error, error)
+
+		// private order updates
+		SubOrders(ctx context.Context, market fintypes.Market, margin fintypes.Margin) (<-chan fintypes.Order, <-chan error, error)
+
+		// private balance updates
+		SubBalances(ctx context.Context, market fintypes.Market, margin fintypes.Margin) (<-chan fintypes.Balance, <-chan error, error)
+	}
+
+	// DepthDiffStreamer provides snapshot with update id and incremental depth events, used by SubLocalDepth
+	DepthDiffStreamer interface {
+		// deepest order books snapshot and its last update id
+		GetDepthSnapshot(ctx context.Context, market fintypes.Market, target fintypes.Pair) (*fintypes.Depth, int64, error)
+
+		// incremental order books updates
+		SubDepthDiff(ctx context.Context, market fintypes.Market, target fintypes.Pair) (<-chan fintypes.DepthDiff, <-chan error, error)
+	}
+)
+
+// get streaming api of exchange
+func AsStreamer(ex Ex) (Streamer, bool) {
+	s, ok := ex.(Streamer)
+	return s, ok
+}
+
+// get depth diff streaming api of exchange
+func AsDepthDiffStreamer(ex Ex) (DepthDiffStreamer, bool) {
+	s, ok := ex.(DepthDiffStreamer)
+	return s, ok
+}
@@ -1,13 +1,22 @@
 package findata
 
 import (
+	"context"
 	"github.com/foxtrader/gofin/fintypes"
 	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"net/http"
 )
 
 type (
 	AssetDataSource interface {
 		GetDetails() ([]AssetDetail, error)
+		GetDetailsContext(ctx context.Context) ([]AssetDetail, error)
+	}
+
+	// implemented by data sources whose http requests can be sent by custom transport instead of proxy,
+	// like httpreplay.Transport in tests
+	TransportSetter interface {
+		SetTransport(rt http.RoundTripper)
 	}
 )
 
@@ -1,6 +1,7 @@
 package findata
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
@@ -8,14 +9,15 @@ import (
 	"github.com/pkg/errors"
 	"github.com/shawnwyckoff/gopkg/container/gstring"
 	"github.com/shawnwyckoff/gopkg/net/ghtml"
-	"github.com/shawnwyckoff/gopkg/net/ghttp"
+	"net/http"
 	"strings"
 	"time"
 )
 
 type (
 	CgkClient struct {
-		proxy string
+		proxy     string
+		transport http.RoundTripper
 	}
 
 	cgkCoinName struct {
@@ -119,8 +121,20 @@ func (cd *cgkCoinDetail) ToCoinDetail() *AssetDetail {
 	return &r
 }
 
+func (c *CgkClient) SetTransport(rt http.RoundTripper) {
+	c.transport = rt
+}
+
+func (c *CgkClient) get(uri string, timeout time.Duration) ([]byte, error) {
+	client, err := newHttpClient(c.transport, c.proxy, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return httpGetBytes(context.Background(), client, uri)
+}
+
 func (c *CgkClient) GetAssets() ([]fintypes.Asset, error) {
-	b, err := ghttp.GetBytes("https://api.coingecko.com/api/v3/coins/list", c.proxy, time.Minute*2)
+	b, err := c.get("https://api.coingecko.com/api/v3/coins/list", time.Minute*2)
 	if err != nil {
 		return nil, err
 	}
@@ -137,7 +151,7 @@ func (c *CgkClient) GetAssets() ([]fintypes.Asset, error) {
 
 func (c *CgkClient) GetDetail(coin fintypes.Asset) (*AssetDetail, error) {
 	uri := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s?tickers=false&market_data=false&community_data=true&developer_data=true&sparkline=false", strings.ToLower(coin.Name()))
-	b, err := ghttp.GetBytes(uri, c.proxy, time.Minute*2)
+	b, err := c.get(uri, time.Minute*2)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +170,7 @@ func (c *CgkClient) GetDetail(coin fintypes.Asset) (*AssetDetail, error) {
 		TotalSupply       float64 `json:"total_supply"`
 	}
 	uri = fmt.Sprintf("https://api.coingecko.com/api/v3/coins/markets?vs_currency=usd&ids=%s&order=market_cap_desc&per_page=1&page=1&sparkline=false", coin.Name())
-	b, err = ghttp.GetBytes(uri, c.proxy, time.Minute*2)
+	b, err = c.get(uri, time.Minute*2)
 	if err != nil {
 		return nil, err
 	}
@@ -172,8 +186,9 @@ func (c *CgkClient) GetDetail(coin fintypes.Asset) (*AssetDetail, error) {
 	}
 
 	if detail.Github() == "" && len(detail.Websites) > 0 {
-		html, err := ghttp.GetString(detail.Websites[0], c.proxy, time.Minute)
+		b, err := c.get(detail.Websites[0], time.Minute)
 		if err == nil {
+			html := string(b)
 			doc, err := ghtml.NewDocFromHtmlSrc(&html)
 			if err == nil {
 				doc.Find("a").Each(func(i int, selection *goquery.Selection) {
@@ -1,6 +1,7 @@
 package findata
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/foxtrader/gofin/fintypes"
 	"github.com/shawnwyckoff/gopkg/apputil/gerror"
@@ -8,17 +9,27 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type (
 	CmcClient struct {
-		apiKey string
-		proxy  string
+		apiKey    string
+		proxy     string
+		transport http.RoundTripper
 	}
 )
 
+func (c *CmcClient) SetTransport(rt http.RoundTripper) {
+	c.transport = rt
+}
+
 func (c *CmcClient) GetDetails() ([]AssetDetail, error) {
-	ids, err := c.getAssetIds()
+	return c.GetDetailsContext(context.Background())
+}
+
+func (c *CmcClient) GetDetailsContext(ctx context.Context) ([]AssetDetail, error) {
+	ids, err := c.getAssetIds(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +40,7 @@ func (c *CmcClient) GetDetails() ([]AssetDetail, error) {
 		if i+length > len(ids) {
 			length = len(ids) - i
 		}
-		details, err := c.getDetails(ids[i : i+length])
+		details, err := c.getDetails(ctx, ids[i:i+length])
 		if err != nil {
 			return nil, err
 		}
@@ -40,7 +51,7 @@ func (c *CmcClient) GetDetails() ([]AssetDetail, error) {
 
 // api document
 // https://coinmarketcap.com/api/documentation/v1/#operation/getV1CryptocurrencyMap
-func (c *CmcClient) getAssetIds() ([]int, error) {
+func (c *CmcClient) getAssetIds(ctx context.Context) ([]int, error) {
 	type (
 		cmcId struct {
 			Id int `json:"id"`
@@ -51,8 +62,11 @@ func (c *CmcClient) getAssetIds() ([]int, error) {
 		}
 	)
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://pro-api.coinmarketcap.com/v1/cryptocurrency/map", nil)
+	client, err := newHttpClient(c.transport, c.proxy, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://pro-api.coinmarketcap.com/v1/cryptocurrency/map", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -86,9 +100,12 @@ func (c *CmcClient) getAssetIds() ([]int, error) {
 	return res, nil
 }
 
-func (c CmcClient) getDetails(ids []int) ([]AssetDetail, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://pro-api.coinmarketcap.com/v1/cryptocurrency/info", nil)
+func (c CmcClient) getDetails(ctx context.Context, ids []int) ([]AssetDetail, error) {
+	client, err := newHttpClient(c.transport, c.proxy, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://pro-api.coinmarketcap.com/v1/cryptocurrency/info", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -12,7 +12,6 @@ import (
 	"github.com/lucazulian/cryptocomparego"
 	"github.com/pkg/errors"
 	"github.com/shawnwyckoff/gopkg/container/gdecimal"
-	"github.com/shawnwyckoff/gopkg/net/ghttp"
 	"github.com/shawnwyckoff/gopkg/sys/gtime"
 	"io/ioutil"
 	"net/http"
@@ -35,7 +34,8 @@ type (
 	}
 
 	CC struct {
-		proxy string
+		proxy     string
+		transport http.RoundTripper
 	}
 
 	ccResponse struct {
@@ -131,12 +131,14 @@ func ccGetAll(cli *http.Client) (cryptoBasicCCList, error) {
 }
 
 func CCGetAll(proxy string, timeout time.Duration) ([]fintypes.Asset, error) {
-	cli := http.DefaultClient
-	if err := ghttp.SetProxy(cli, proxy); err != nil {
+	cli, err := newHttpClient(nil, proxy, timeout)
+	if err != nil {
 		return nil, err
 	}
-	ghttp.SetTimeout(cli, timeout)
+	return ccGetAssets(cli)
+}
 
+func ccGetAssets(cli *http.Client) ([]fintypes.Asset, error) {
 	ccApi := cryptocomparego.NewClient(cli)
 	rawCCList, _, err := ccApi.Coin.List(context.Background())
 	if err != nil {
@@ -194,7 +196,7 @@ func (hr *ccHistodayRequest) FormattedQueryString(baseUrl string) string {
 	return fmt.Sprintf("%s?%s", baseUrl, values.Encode())
 }
 
-func ccGet(client *http.Client, histodayRequest *ccHistodayRequest) ([]ccHistoday, *ccResponse, error) {
+func ccGet(ctx context.Context, client *http.Client, histodayRequest *ccHistodayRequest) ([]ccHistoday, *ccResponse, error) {
 
 	path := ccHistodyBasePath
 
@@ -206,8 +208,12 @@ func ccGet(client *http.Client, histodayRequest *ccHistodayRequest) ([]ccHistoda
 
 	reqUrl := fmt.Sprintf("%s%s", minURL.String(), path)
 	fmt.Println(reqUrl)
-	resp, err := client.Get(reqUrl)
 	res := ccResponse{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return nil, &res, err
+	}
+	resp, err := client.Do(req)
 	res.Response = resp
 	if err != nil {
 		return nil, &res, err
@@ -235,19 +241,24 @@ func ccGet(client *http.Client, histodayRequest *ccHistodayRequest) ([]ccHistoda
 }
 
 func CCGetKline(symbol string, since *time.Time, proxy string) (*fintypes2.Kline, error) {
-	cli := http.DefaultClient
-	if proxy != "" {
-		if err := ghttp.SetProxy(cli, proxy); err != nil {
-			return nil, err
-		}
+	return CCGetKlineContext(context.Background(), symbol, since, proxy)
+}
+
+func CCGetKlineContext(ctx context.Context, symbol string, since *time.Time, proxy string) (*fintypes2.Kline, error) {
+	cli, err := newHttpClient(nil, proxy, 0)
+	if err != nil {
+		return nil, err
 	}
+	return ccGetKline(ctx, cli, symbol, since)
+}
 
+func ccGetKline(ctx context.Context, cli *http.Client, symbol string, since *time.Time) (*fintypes2.Kline, error) {
 	days := 3000
 	if since != nil {
 		_, days, _ = gtime.DaysBetween(time.Now(), *since)
 	}
 	hr := ccNewHistodayRequest(symbol, "USD", days, true)
-	data, _, err := ccGet(cli, hr)
+	data, _, err := ccGet(ctx, cli, hr)
 	if err != nil {
 		return nil, err
 	}
@@ -277,9 +288,17 @@ func NewCryptoCompare(proxy string) (*CC, error) {
 	return r, nil
 }
 
+func (cc *CC) SetTransport(rt http.RoundTripper) {
+	cc.transport = rt
+}
+
 func (cc *CC) GetKlineProviderInfo() (*fintypes.KlineProviderInfo, error) {
 	r := fintypes.KlineProviderInfo{}
-	allAssets, err := CCGetAll(cc.proxy, time.Minute*3)
+	cli, err := newHttpClient(cc.transport, cc.proxy, time.Minute*3)
+	if err != nil {
+		return nil, err
+	}
+	allAssets, err := ccGetAssets(cli)
 	if err != nil {
 		return nil, err
 	}
@@ -292,11 +311,19 @@ func (cc *CC) GetKlineProviderInfo() (*fintypes.KlineProviderInfo, error) {
 }
 
 func (cc *CC) GetKlineEx(platform fintypes.Platform, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes2.Kline, error) {
+	return cc.GetKlineExContext(context.Background(), platform, market, target, period, since)
+}
+
+func (cc *CC) GetKlineExContext(ctx context.Context, platform fintypes.Platform, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes2.Kline, error) {
 	if platform != fintypes.CryptoCompare {
 		return nil, errors.Errorf("PLTCC doesn't support kline of pair(%s)", target.String())
 	}
 	if target.Quote() != "USD" {
 		return nil, errors.Errorf("PLTCC doesn't support kline of pair(%s)", target.String())
 	}
-	return CCGetKline(target.Unit(), since, cc.proxy)
+	cli, err := newHttpClient(cc.transport, cc.proxy, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ccGetKline(ctx, cli, target.Unit(), since)
 }
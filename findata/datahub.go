@@ -5,7 +5,6 @@ import (
 	"github.com/gocarina/gocsv"
 	"github.com/pkg/errors"
 	"github.com/shawnwyckoff/gopkg/container/gdecimal"
-	"github.com/shawnwyckoff/gopkg/net/ghttp"
 	"github.com/shawnwyckoff/gopkg/sys/gtime"
 	"time"
 )
@@ -27,7 +26,7 @@ type (
 )
 
 func GetGoldKline() ([]GoldMonthlyPrice, *fintypes.Kline, error) {
-	s, err := ghttp.GetString(goldMonthlyPriceCsv, "", time.Minute)
+	s, err := httpGetString(goldMonthlyPriceCsv, time.Minute)
 	if err != nil {
 		return nil, nil, err
 	}
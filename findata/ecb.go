@@ -1,6 +1,8 @@
 package findata
 
 import (
+	"context"
+	"encoding/xml"
 	"github.com/foxtrader/gofin/fintypes"
 	fintypes2 "github.com/foxtrader/gofin/fintypes"
 	"github.com/openprovider/ecbrates"
@@ -8,7 +10,9 @@ import (
 	"github.com/shawnwyckoff/gopkg/container/gdecimal"
 	"github.com/shawnwyckoff/gopkg/sys/gtime"
 	"math"
+	"net/http"
 	"strconv"
+	"time"
 )
 
 /**
@@ -16,14 +20,58 @@ forex 外汇
 // https://fixer.io 更齐全, https://github.com/LordotU/go-fixerio/
 */
 
+const (
+	ecbDailyUrl   = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	ecbHistoryUrl = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml" // ALL history, eurofxref-hist-90d.xml is 90 days history
+)
+
 type (
-	Ecb struct{}
+	Ecb struct {
+		transport http.RoundTripper
+	}
 )
 
 func NewEcb() *Ecb {
 	return &Ecb{}
 }
 
+func (ecb *Ecb) SetTransport(rt http.RoundTripper) {
+	ecb.transport = rt
+}
+
+// rates of days in xml of ecb, it is what ecbrates does but requests are sent by transport of ecb
+func (ecb *Ecb) getRates(uri string) ([]ecbrates.Rates, error) {
+	client, err := newHttpClient(ecb.transport, "", time.Minute*2)
+	if err != nil {
+		return nil, err
+	}
+	b, err := httpGetBytes(context.Background(), client, uri)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube>Cube"`
+	}
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	var r []ecbrates.Rates
+	for _, day := range doc.Days {
+		item := ecbrates.Rates{Date: day.Time, Rate: map[ecbrates.Currency]interface{}{}}
+		for _, v := range day.Rates {
+			item.Rate[ecbrates.Currency(v.Currency)] = v.Rate
+		}
+		r = append(r, item)
+	}
+	return r, nil
+}
+
 func rate2tick(data ecbrates.Rates) (gtime.Date, map[fintypes.Pair]gdecimal.Decimal, error) {
 	rTick := map[fintypes.Pair]gdecimal.Decimal{}
 	rDate, err := gtime.ParseDateString(data.Date, true)
@@ -47,17 +95,19 @@ func rate2tick(data ecbrates.Rates) (gtime.Date, map[fintypes.Pair]gdecimal.Deci
 }
 
 func (ecb *Ecb) GetCurrentTicks() (map[fintypes.Pair]gdecimal.Decimal, error) {
-	data, err := ecbrates.New()
+	rates, err := ecb.getRates(ecbDailyUrl)
 	if err != nil {
 		return nil, err
 	}
-	_, rTick, err := rate2tick(*data)
+	if len(rates) == 0 {
+		return nil, gerror.New("empty ecb daily rates")
+	}
+	_, rTick, err := rate2tick(rates[0])
 	return rTick, err
 }
 
 func (ecb *Ecb) GetKline() (map[fintypes.Pair]fintypes2.Kline, error) {
-	//rates, err := ecbrates.Load() // 90 days history
-	rates, err := ecbrates.LoadAll() // ALL history
+	rates, err := ecb.getRates(ecbHistoryUrl)
 	if err != nil {
 		return nil, err
 	}
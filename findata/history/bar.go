@@ -0,0 +1,110 @@
+package history
+
+import (
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type (
+	// BarAggregator builds bars from fills in time order, like second bars or 7min bars which exchanges don't provide.
+	// bars without fills are skipped, like Kline.ToPeriod does.
+	BarAggregator struct {
+		period   fintypes.Period
+		config   fintypes.PeriodRoundConfig
+		interval time.Duration
+
+		cur *fintypes.Bar
+	}
+)
+
+// bars of period, like 1day bars whose open time is rounded by config
+func NewBarAggregator(period fintypes.Period, config fintypes.PeriodRoundConfig) (*BarAggregator, error) {
+	if period.ToSeconds() <= 0 {
+		return nil, errors.Errorf("invalid period %s", period.String())
+	}
+	return &BarAggregator{period: period, config: config}, nil
+}
+
+// bars of any interval aligned to unix epoch, like 1s, 5s or 7min
+func NewIntervalBarAggregator(interval time.Duration) (*BarAggregator, error) {
+	if interval <= 0 {
+		return nil, errors.Errorf("invalid bar interval %s", interval)
+	}
+	return &BarAggregator{interval: interval}, nil
+}
+
+// open time of bar which fill time belongs to
+func (a *BarAggregator) openTime(t time.Time) time.Time {
+	if a.interval > 0 {
+		ns := t.UnixNano()
+		mod := ns % int64(a.interval)
+		if mod < 0 {
+			mod += int64(a.interval)
+		}
+		return time.Unix(0, ns-mod).UTC()
+	}
+	return fintypes.RoundPeriodEarlier(t, a.period, a.config)
+}
+
+// Add adds fill into current bar, previous bar is returned if fill is in the next bar.
+func (a *BarAggregator) Add(fill fintypes.Fill) (closed *fintypes.Bar, err error) {
+	open := a.openTime(fill.Time)
+	if a.cur != nil {
+		if open.Before(a.cur.T) {
+			return nil, errors.Errorf("fill %d at %s is earlier than bar %s", fill.Id, fill.Time.String(), a.cur.T.String())
+		}
+		if open.Equal(a.cur.T) {
+			if fill.Price.GreaterThan(a.cur.H) {
+				a.cur.H = fill.Price
+			}
+			if fill.Price.LessThan(a.cur.L) {
+				a.cur.L = fill.Price
+			}
+			a.cur.C = fill.Price
+			a.cur.V = a.cur.V.Add(fill.UnitQty)
+			return nil, nil
+		}
+		closed = a.cur
+	}
+	a.cur = &fintypes.Bar{T: open, O: fill.Price, H: fill.Price, L: fill.Price, C: fill.Price, V: fill.UnitQty}
+	return closed, nil
+}
+
+// AddFills adds fills in time order, closed bars are returned.
+func (a *BarAggregator) AddFills(fills []fintypes.Fill) ([]fintypes.Bar, error) {
+	var r []fintypes.Bar
+	for _, v := range fills {
+		closed, err := a.Add(v)
+		if err != nil {
+			return r, err
+		}
+		if closed != nil {
+			r = append(r, *closed)
+		}
+	}
+	return r, nil
+}
+
+// Flush returns current bar which may be not closed, nil if no fill added since last flush.
+func (a *BarAggregator) Flush() *fintypes.Bar {
+	r := a.cur
+	a.cur = nil
+	return r
+}
+
+// FillsToKline builds kline of target period from fills.
+func FillsToKline(target fintypes.PairIMP, fills []fintypes.Fill, config fintypes.PeriodRoundConfig) (*fintypes.Kline, error) {
+	a, err := NewBarAggregator(target.I(), config)
+	if err != nil {
+		return nil, err
+	}
+	bars, err := a.AddFills(fills)
+	if err != nil {
+		return nil, err
+	}
+	if last := a.Flush(); last != nil {
+		bars = append(bars, *last)
+	}
+	return fintypes.NewKline(target, bars), nil
+}
@@ -0,0 +1,199 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"github.com/foxtrader/gofin/ex/binance"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type (
+	// public trades of a pair, ids of fills must be consecutive like binance agg trades, so missing fills can be detected
+	FillSource interface {
+		// one page of fills whose id >= fromId, in id order
+		GetFillsFromId(ctx context.Context, target fintypes.PairM, fromId int64) ([]fintypes.Fill, error)
+
+		// fills in window [begin, windowEnd) in id order, window length is limited by source, like 1 hour in binance.
+		// only the first fill is required, it is used to locate id of begin.
+		GetFillsFromTime(ctx context.Context, target fintypes.PairM, begin time.Time) (fills []fintypes.Fill, windowEnd time.Time, err error)
+	}
+
+	// fills with id in [FromId, ToId] are missing in source
+	FillGap struct {
+		FromId int64
+		ToId   int64
+	}
+
+	FillDownloader struct {
+		Source    FillSource
+		RateLimit time.Duration // min interval between two page requests
+
+		// called when fills are missing, download stops if it returns error.
+		// nil OnGap means gaps are not allowed, ErrFillGap is returned.
+		OnGap func(gap FillGap) error
+
+		lastRequest time.Time
+	}
+
+	binanceFillSource struct {
+		c *binance.Client
+	}
+)
+
+var (
+	ErrFillGap = errors.Errorf("fill gap")
+)
+
+// download agg trades of binance spot market
+func NewBinanceFillDownloader(c *binance.Client) *FillDownloader {
+	return &FillDownloader{
+		Source:    &binanceFillSource{c: c},
+		RateLimit: c.Property().RateLimits[fintypes.ExApiGetFill],
+	}
+}
+
+// binance doesn't accept fromId 0, but the first agg fill of a pair is of id 0, so it's found by time of fill 1
+func (s *binanceFillSource) GetFillsFromId(ctx context.Context, target fintypes.PairM, fromId int64) ([]fintypes.Fill, error) {
+	if target.M() != fintypes.MarketSpot {
+		return nil, errors.Errorf("binance agg fills of market %s not supported", target.M())
+	}
+	if fromId > 0 {
+		return s.c.GetAggFillsContext(ctx, target.Pair(), &fintypes.FillOption{BeginId: fromId, IdLimit: 1000})
+	}
+
+	page, err := s.c.GetAggFillsContext(ctx, target.Pair(), &fintypes.FillOption{BeginId: 1, IdLimit: 1000})
+	if err != nil || len(page) == 0 {
+		return page, err
+	}
+	// fill 0 is missing if it is more than 1 hour earlier than fill 1, the gap is reported by downloader then
+	prev, err := s.c.GetAggFillsContext(ctx, target.Pair(), &fintypes.FillOption{BeginTime: page[0].Time.Add(-time.Hour), TimeDuration: time.Hour})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range prev {
+		if v.Id == 0 {
+			return append([]fintypes.Fill{v}, page...), nil
+		}
+	}
+	return page, nil
+}
+
+func (s *binanceFillSource) GetFillsFromTime(ctx context.Context, target fintypes.PairM, begin time.Time) ([]fintypes.Fill, time.Time, error) {
+	if target.M() != fintypes.MarketSpot {
+		return nil, time.Time{}, errors.Errorf("binance agg fills of market %s not supported", target.M())
+	}
+	fills, err := s.c.GetAggFillsContext(ctx, target.Pair(), &fintypes.FillOption{BeginTime: begin, TimeDuration: time.Hour})
+	return fills, begin.Add(time.Hour), err
+}
+
+func (g FillGap) String() string {
+	return fmt.Sprintf("[%d, %d]", g.FromId, g.ToId)
+}
+
+// wait until the next request is allowed by RateLimit
+func (d *FillDownloader) wait(ctx context.Context) error {
+	if !d.lastRequest.IsZero() && d.RateLimit > 0 {
+		if wait := time.Until(d.lastRequest.Add(d.RateLimit)); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+	d.lastRequest = time.Now()
+	return ctx.Err()
+}
+
+func (d *FillDownloader) gap(gap FillGap) error {
+	if d.OnGap == nil {
+		return errors.Wrapf(ErrFillGap, "fills %s missing", gap.String())
+	}
+	return d.OnGap(gap)
+}
+
+// Download streams fills of target in [begin, end) to handler page by page, zero end means now.
+// the first fill id after begin is located by windows of source, then fills are downloaded by id.
+func (d *FillDownloader) Download(ctx context.Context, target fintypes.PairM, begin, end time.Time, handler func(fills []fintypes.Fill) error) error {
+	if err := target.Verify(); err != nil {
+		return err
+	}
+	for t := begin; end.IsZero() || t.Before(end); {
+		if err := d.wait(ctx); err != nil {
+			return err
+		}
+		fills, windowEnd, err := d.Source.GetFillsFromTime(ctx, target, t)
+		if err != nil {
+			return errors.Wrapf(err, "locate fills %s at %s", target.String(), t.String())
+		}
+		if len(fills) > 0 {
+			return d.DownloadFromId(ctx, target, fills[0].Id, end, handler)
+		}
+		if !windowEnd.After(t) {
+			return errors.Errorf("invalid fill window end %s of begin %s", windowEnd.String(), t.String())
+		}
+		if windowEnd.After(time.Now()) {
+			return nil // no fill until now
+		}
+		t = windowEnd
+	}
+	return nil
+}
+
+// DownloadFromId streams fills of target whose id >= fromId and time before end to handler, zero end means now.
+// it can be used to resume an interrupted Download, from id of the last handled fill + 1.
+func (d *FillDownloader) DownloadFromId(ctx context.Context, target fintypes.PairM, fromId int64, end time.Time, handler func(fills []fintypes.Fill) error) error {
+	if err := target.Verify(); err != nil {
+		return err
+	}
+	for {
+		if err := d.wait(ctx); err != nil {
+			return err
+		}
+		page, err := d.Source.GetFillsFromId(ctx, target, fromId)
+		if err != nil {
+			return errors.Wrapf(err, "download fills %s from id %d", target.String(), fromId)
+		}
+
+		var fills []fintypes.Fill
+		handled := 0
+		finished := len(page) == 0 // latest fill reached
+		for _, v := range page {
+			if v.Id < fromId {
+				continue // some sources return fills before fromId
+			}
+			if !end.IsZero() && !v.Time.Before(end) {
+				finished = true
+				break
+			}
+			if v.Id > fromId {
+				// fills before gap are handled first, so download can be resumed after them
+				if len(fills) > 0 {
+					if err := handler(fills); err != nil {
+						return err
+					}
+					fills = nil
+				}
+				if err := d.gap(FillGap{FromId: fromId, ToId: v.Id - 1}); err != nil {
+					return err
+				}
+			}
+			fills = append(fills, v)
+			fromId = v.Id + 1
+			handled++
+		}
+		if len(fills) > 0 {
+			if err := handler(fills); err != nil {
+				return err
+			}
+		}
+		if handled == 0 && !finished {
+			return errors.Errorf("no fill from id %d in page of %s", fromId, target.String())
+		}
+		if finished {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"github.com/foxtrader/gofin/ex/binance"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testFillPair = fintypes.BTC.Against(fintypes.USDT).SetM(fintypes.MarketSpot)
+
+// fill of id i is at i seconds, pages of 3 fills, windows of 10 seconds
+type testFillSource struct {
+	fills []fintypes.Fill
+}
+
+func newTestFillSource(ids ...int64) *testFillSource {
+	s := &testFillSource{}
+	for _, id := range ids {
+		s.fills = append(s.fills, fintypes.Fill{Id: id, Time: time.Unix(id, 0), Price: gdecimal.NewFromInt(100 + id), UnitQty: gdecimal.One})
+	}
+	return s
+}
+
+func (s *testFillSource) GetFillsFromId(ctx context.Context, target fintypes.PairM, fromId int64) ([]fintypes.Fill, error) {
+	var r []fintypes.Fill
+	for _, v := range s.fills {
+		if v.Id >= fromId && len(r) < 3 {
+			r = append(r, v)
+		}
+	}
+	return r, nil
+}
+
+func (s *testFillSource) GetFillsFromTime(ctx context.Context, target fintypes.PairM, begin time.Time) ([]fintypes.Fill, time.Time, error) {
+	end := begin.Add(10 * time.Second)
+	var r []fintypes.Fill
+	for _, v := range s.fills {
+		if !v.Time.Before(begin) && v.Time.Before(end) {
+			r = append(r, v)
+		}
+	}
+	return r, end, nil
+}
+
+func TestFillDownloader_Download(t *testing.T) {
+	src := newTestFillSource(25, 26, 27, 28, 30, 31, 32, 33)
+	d := &FillDownloader{Source: src}
+
+	// located in the third window, stopped at the gap
+	var got []fintypes.Fill
+	handler := func(fills []fintypes.Fill) error {
+		got = append(got, fills...)
+		return nil
+	}
+	err := d.Download(context.Background(), testFillPair, time.Unix(1, 0), time.Unix(33, 0), handler)
+	if !errors.Is(err, ErrFillGap) || len(got) != 4 || got[0].Id != 25 {
+		t.Errorf("4 fills then gap error expected, but %d fills, %v", len(got), err)
+	}
+
+	var gaps []FillGap
+	d.OnGap = func(gap FillGap) error {
+		gaps = append(gaps, gap)
+		return nil
+	}
+	got = nil
+	gtest.Assert(t, d.Download(context.Background(), testFillPair, time.Unix(1, 0), time.Unix(33, 0), handler))
+	if len(got) != 7 || got[6].Id != 32 {
+		t.Errorf("fills before end expected, but %d", len(got))
+	}
+	if len(gaps) != 1 || gaps[0] != (FillGap{FromId: 29, ToId: 29}) {
+		t.Errorf("gap [29, 29] expected, but %v", gaps)
+	}
+}
+
+func TestBinanceFillSource_FromIdZero(t *testing.T) {
+	// fill 0 at 1000ms is found by time window before fill 1 at 2000ms
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fill := `{"a":%d,"p":"100","q":"1","f":%d,"l":%d,"T":%d,"m":true,"M":true}`
+		switch {
+		case r.URL.Query().Get("fromId") == "1":
+			_, _ = fmt.Fprintf(w, "["+fill+","+fill+"]", 1, 1, 1, 2000, 2, 2, 2, 3000)
+		case r.URL.Query().Get("startTime") != "":
+			_, _ = fmt.Fprintf(w, "["+fill+","+fill+"]", 0, 0, 0, 1000, 1, 1, 1, 2000)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c, err := binance.New("", "", "", nil, "")
+	gtest.Assert(t, err)
+	gtest.Assert(t, c.SetBaseUrl(fintypes.MarketSpot, srv.URL))
+
+	fills, err := (&binanceFillSource{c: c}).GetFillsFromId(context.Background(), testFillPair, 0)
+	gtest.Assert(t, err)
+	if len(fills) != 3 || fills[0].Id != 0 || fills[1].Id != 1 || fills[2].Id != 2 {
+		t.Errorf("fills 0, 1, 2 expected, but %v", fills)
+	}
+}
+
+func TestBarAggregator(t *testing.T) {
+	fills := newTestFillSource(0, 1, 2, 5, 6, 13).fills
+	fills[1].Price = gdecimal.NewFromInt(99)
+
+	a, err := NewIntervalBarAggregator(5 * time.Second)
+	gtest.Assert(t, err)
+	bars, err := a.AddFills(fills)
+	gtest.Assert(t, err)
+	if len(bars) != 2 {
+		gtest.PrintlnExit(t, "2 closed bars expected, but %d", len(bars))
+	}
+	first := bars[0]
+	if !first.O.Equal(gdecimal.NewFromInt(100)) || !first.L.Equal(gdecimal.NewFromInt(99)) || !first.H.Equal(gdecimal.NewFromInt(102)) ||
+		!first.C.Equal(gdecimal.NewFromInt(102)) || !first.V.Equal(gdecimal.NewFromInt(3)) {
+		t.Errorf("invalid first bar %v", first)
+	}
+	if last := a.Flush(); last == nil || !last.T.Equal(time.Unix(10, 0)) {
+		t.Errorf("the last bar should open at 10s, but %v", last)
+	}
+	if _, err := a.Add(fills[0]); err != nil {
+		t.Errorf("fill after flush should be accepted, but %v", err)
+	}
+	if _, err := a.Add(fills[5]); err != nil {
+		t.Errorf("fill of later bar should be accepted, but %v", err)
+	}
+	if _, err := a.Add(fills[3]); err == nil {
+		t.Errorf("fill earlier than current bar should be rejected")
+	}
+
+	k, err := FillsToKline(testFillPair.SetI(fintypes.Period1Min).SetP(fintypes.Binance), fills, fintypes.DefaultPeriodRoundConfig)
+	gtest.Assert(t, err)
+	if k.Len() != 1 || !k.Items[0].V.Equal(gdecimal.NewFromInt(6)) {
+		t.Errorf("1 minute bar of volume 6 expected, but %v", k.Items)
+	}
+}
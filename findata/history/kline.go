@@ -0,0 +1,166 @@
+package history
+
+/**
+resumable downloader of full kline history
+
+GetKline of exchanges and data providers returns only one page from since, KlineDownloader pages through the history
+of a PairIMP until no new bar is returned, bars are saved into KlineStore page by page.
+the last saved bar is the checkpoint, an interrupted download resumes from it, the last bar is always downloaded again
+because it may be not closed when it was saved.
+*/
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/ex"
+	"github.com/foxtrader/gofin/findata"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type (
+	// one page of klines from since (inclusive), nil since means the beginning of history
+	KlineSource interface {
+		GetKlinePage(ctx context.Context, target fintypes.PairIMP, since *time.Time) (*fintypes.Kline, error)
+	}
+
+	// kline data providers of findata, like YFAPI and CC
+	KlineProvider interface {
+		GetKlineProviderInfo() (*fintypes.KlineProviderInfo, error)
+		GetKlineExContext(ctx context.Context, platform fintypes.Platform, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes.Kline, error)
+	}
+
+	KlineDownloader struct {
+		Source    KlineSource
+		Store     KlineStore
+		RateLimit time.Duration // min interval between two page requests
+		Begin     time.Time     // download from Begin if nothing saved, zero means the beginning of source
+		End       time.Time     // stop after bar of End downloaded, zero means now
+
+		lastRequest time.Time
+	}
+
+	exKlineSource struct {
+		e ex.Ex
+	}
+
+	providerKlineSource struct {
+		p KlineProvider
+	}
+)
+
+var (
+	_ KlineProvider = (*findata.YFAPI)(nil)
+	_ KlineProvider = (*findata.CC)(nil)
+)
+
+// download from TradeBeginTime of exchange at the rate limit of its GetKline
+func NewExKlineDownloader(e ex.Ex, store KlineStore) *KlineDownloader {
+	return &KlineDownloader{
+		Source:    &exKlineSource{e: e},
+		Store:     store,
+		RateLimit: e.Property().RateLimits[fintypes.ExApiGetKline],
+		Begin:     e.Property().TradeBeginTime,
+	}
+}
+
+// download from FirstTrade of provider at its KlineRequestRateLimit
+func NewProviderKlineDownloader(p KlineProvider, store KlineStore) (*KlineDownloader, error) {
+	info, err := p.GetKlineProviderInfo()
+	if err != nil {
+		return nil, err
+	}
+	return &KlineDownloader{
+		Source:    &providerKlineSource{p: p},
+		Store:     store,
+		RateLimit: info.KlineRequestRateLimit,
+		Begin:     info.FirstTrade,
+	}, nil
+}
+
+func (s *exKlineSource) GetKlinePage(ctx context.Context, target fintypes.PairIMP, since *time.Time) (*fintypes.Kline, error) {
+	if target.P() != s.e.Property().Name {
+		return nil, errors.Errorf("pair %s is not in exchange %s", target.String(), s.e.Property().Name.String())
+	}
+	return s.e.GetKlineContext(ctx, target.M(), target.Pair(), target.I(), since)
+}
+
+func (s *providerKlineSource) GetKlinePage(ctx context.Context, target fintypes.PairIMP, since *time.Time) (*fintypes.Kline, error) {
+	return s.p.GetKlineExContext(ctx, target.P(), target.M(), target.Pair(), target.I(), since)
+}
+
+// wait until the next request is allowed by RateLimit
+func (d *KlineDownloader) wait(ctx context.Context) error {
+	if d.lastRequest.IsZero() || d.RateLimit <= 0 {
+		return nil
+	}
+	if wait := time.Until(d.lastRequest.Add(d.RateLimit)); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil
+}
+
+// Download pages through history of target from the checkpoint in Store, or from Begin if nothing saved.
+// it returns when the latest bar is saved, or End reached, downloaded pages are kept if error returned.
+// KlineDownloader is not safe for concurrent use, downloads of different pairs should use different downloaders.
+func (d *KlineDownloader) Download(ctx context.Context, target fintypes.PairIMP) error {
+	if err := target.Verify(); err != nil {
+		return err
+	}
+	last, err := d.Store.LastTime(target)
+	if err != nil {
+		return err
+	}
+	var since *time.Time
+	if last != nil {
+		since = last
+	} else if !d.Begin.IsZero() {
+		begin := d.Begin
+		since = &begin
+	}
+
+	for {
+		if !d.End.IsZero() && last != nil && !last.Before(d.End) {
+			return nil
+		}
+		if err := d.wait(ctx); err != nil {
+			return err
+		}
+		d.lastRequest = time.Now()
+		page, err := d.Source.GetKlinePage(ctx, target, since)
+		if err != nil {
+			if since == nil {
+				return errors.Wrapf(err, "download kline %s", target.String())
+			}
+			return errors.Wrapf(err, "download kline %s since %s", target.String(), since.String())
+		}
+
+		// some sources return bars before since, like the first bar around TradeBeginTime of binance
+		var bars []fintypes.Bar
+		if page != nil {
+			page.Sort()
+			for _, v := range page.Items {
+				if since == nil || !v.T.Before(*since) {
+					bars = append(bars, v)
+				}
+			}
+		}
+		if len(bars) == 0 {
+			return nil
+		}
+		if err := d.Store.SaveKline(target, bars); err != nil {
+			return err
+		}
+
+		// no new bar but the overlapping one, history is finished
+		newLast := bars[len(bars)-1].T
+		if last != nil && !newLast.After(*last) {
+			return nil
+		}
+		last, since = &newLast, &newLast
+	}
+}
@@ -0,0 +1,110 @@
+package history
+
+import (
+	"context"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+var testTarget = fintypes.BTC.Against(fintypes.USDT).SetI(fintypes.Period1Min).SetM(fintypes.MarketSpot).SetP(fintypes.Binance)
+
+// 10 minute bars from unix 0, pages of 3 bars, the bar before since is returned too like binance does around TradeBeginTime
+type testKlineSource struct {
+	calls   int
+	failAt  int // call count which returns error, 0 means never
+	history []fintypes.Bar
+}
+
+func newTestKlineSource() *testKlineSource {
+	s := &testKlineSource{}
+	for i := 0; i < 10; i++ {
+		s.history = append(s.history, fintypes.Bar{T: time.Unix(int64(i)*60, 0).UTC(), C: gdecimal.NewFromInt(int64(i))})
+	}
+	return s
+}
+
+func (s *testKlineSource) GetKlinePage(ctx context.Context, target fintypes.PairIMP, since *time.Time) (*fintypes.Kline, error) {
+	s.calls++
+	if s.calls == s.failAt {
+		return nil, errors.Errorf("network error")
+	}
+	var bars []fintypes.Bar
+	for i, v := range s.history {
+		if !v.T.Before(*since) {
+			if i > 0 && len(bars) == 0 {
+				bars = append(bars, s.history[i-1])
+			}
+			bars = append(bars, v)
+		}
+		if len(bars) == 4 {
+			break
+		}
+	}
+	return fintypes.NewKline(target, bars), nil
+}
+
+func TestKlineDownloader_Download(t *testing.T) {
+	src := newTestKlineSource()
+	src.failAt = 3
+	store := NewMemKlineStore()
+	d := &KlineDownloader{Source: src, Store: store, RateLimit: time.Millisecond, Begin: time.Unix(0, 0)}
+
+	if err := d.Download(context.Background(), testTarget); err == nil {
+		t.Errorf("download should be interrupted")
+	}
+	if k := store.Kline(testTarget); k.Len() != 6 {
+		t.Errorf("6 bars should be saved before interrupted, but %d", k.Len())
+	}
+
+	// resume from checkpoint
+	gtest.Assert(t, d.Download(context.Background(), testTarget))
+	k := store.Kline(testTarget)
+	if k.Len() != 10 {
+		t.Errorf("10 bars expected, but %d", k.Len())
+	}
+	if _, dup := k.HasDuplicatedKeys(); dup {
+		t.Errorf("duplicated bars saved")
+	}
+
+	// nothing new
+	calls := src.calls
+	gtest.Assert(t, d.Download(context.Background(), testTarget))
+	if src.calls != calls+1 {
+		t.Errorf("only 1 request expected when history is finished, but %d", src.calls-calls)
+	}
+}
+
+func TestFileKlineStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofin-history")
+	gtest.Assert(t, err)
+	defer os.RemoveAll(dir)
+	store, err := NewFileKlineStore(dir)
+	gtest.Assert(t, err)
+
+	d := &KlineDownloader{Source: newTestKlineSource(), Store: store, Begin: time.Unix(0, 0)}
+	gtest.Assert(t, d.Download(context.Background(), testTarget))
+
+	// broken tail of interrupted write
+	f, err := os.OpenFile(store.path(testTarget), os.O_WRONLY|os.O_APPEND, 0644)
+	gtest.Assert(t, err)
+	_, err = f.WriteString(`{"T":"1970-01-01T00:`)
+	gtest.Assert(t, err)
+	gtest.Assert(t, f.Close())
+
+	last, err := store.LastTime(testTarget)
+	gtest.Assert(t, err)
+	if last == nil || !last.Equal(time.Unix(9*60, 0)) {
+		t.Errorf("last time should be 00:09, but %v", last)
+	}
+	k, err := store.LoadKline(testTarget)
+	gtest.Assert(t, err)
+	if k.Len() != 10 || !k.Items[9].C.Equal(gdecimal.NewFromInt(9)) {
+		t.Errorf("10 bars expected, but %d", k.Len())
+	}
+}
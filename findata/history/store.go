@@ -0,0 +1,194 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/foxtrader/gofin/fintypes"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// KlineStore saves downloaded bars, bars with the same time are replaced.
+	KlineStore interface {
+		// time of the last saved bar, nil if nothing saved
+		LastTime(target fintypes.PairIMP) (*time.Time, error)
+		SaveKline(target fintypes.PairIMP, bars []fintypes.Bar) error
+	}
+
+	MemKlineStore struct {
+		mu     sync.Mutex
+		klines map[fintypes.PairIMP]*fintypes.Kline
+	}
+
+	// one JSON lines file of bars per pair in Dir, like 'dir/BTC_USDT.1min.spot.binance.jsonl'.
+	// bars are appended, so the overlapping bar of the checkpoint may be saved twice, the later one wins in LoadKline.
+	FileKlineStore struct {
+		Dir string
+
+		mu sync.Mutex
+	}
+)
+
+var (
+	invalidFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+func NewMemKlineStore() *MemKlineStore {
+	return &MemKlineStore{klines: map[fintypes.PairIMP]*fintypes.Kline{}}
+}
+
+func (s *MemKlineStore) LastTime(target fintypes.PairIMP) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.klines[target]; ok {
+		if last, exists := k.LastTimeEx(); exists {
+			return last, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemKlineStore) SaveKline(target fintypes.PairIMP, bars []fintypes.Bar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.klines[target]
+	if !ok {
+		k = fintypes.NewKline(target, nil)
+		s.klines[target] = k
+	}
+	k.Upsert(fintypes.NewKline(target, append([]fintypes.Bar{}, bars...)))
+	return nil
+}
+
+// copy of saved kline, nil if nothing saved
+func (s *MemKlineStore) Kline(target fintypes.PairIMP) *fintypes.Kline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.klines[target]; ok {
+		return k.Clone()
+	}
+	return nil
+}
+
+func NewFileKlineStore(dir string) (*FileKlineStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileKlineStore{Dir: dir}, nil
+}
+
+func (s *FileKlineStore) path(target fintypes.PairIMP) string {
+	name := strings.Trim(invalidFileChars.ReplaceAllString(target.String(), "_"), "_")
+	return filepath.Join(s.Dir, name+".jsonl")
+}
+
+// read all bars in file, broken tail written by interrupted SaveKline is truncated
+func (s *FileKlineStore) load(target fintypes.PairIMP) ([]fintypes.Bar, error) {
+	f, err := os.OpenFile(s.path(target), os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var bars []fintypes.Bar
+	valid := int64(0)
+	rd := bufio.NewReader(f)
+	for {
+		line, rerr := rd.ReadBytes('\n')
+		if len(line) > 0 {
+			bar := fintypes.Bar{}
+			if line[len(line)-1] != '\n' || json.Unmarshal(line, &bar) != nil {
+				if rerr == nil {
+					return nil, errors.Errorf("invalid bar in %s: %s", s.path(target), string(line))
+				}
+				break // broken tail
+			}
+			bars = append(bars, bar)
+			valid += int64(len(line))
+		}
+		if rerr != nil {
+			break
+		}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() > valid {
+		if err := f.Truncate(valid); err != nil {
+			return nil, err
+		}
+	}
+	return bars, nil
+}
+
+func (s *FileKlineStore) LastTime(target fintypes.PairIMP) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bars, err := s.load(target)
+	if err != nil {
+		return nil, err
+	}
+	var last *time.Time
+	for i := range bars {
+		if last == nil || bars[i].T.After(*last) {
+			last = &bars[i].T
+		}
+	}
+	return last, nil
+}
+
+func (s *FileKlineStore) SaveKline(target fintypes.PairIMP, bars []fintypes.Bar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path(target), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, v := range bars {
+		b, err := json.Marshal(v)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// saved kline of target, empty if nothing saved
+func (s *FileKlineStore) LoadKline(target fintypes.PairIMP) (*fintypes.Kline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bars, err := s.load(target)
+	if err != nil {
+		return nil, err
+	}
+	// the later bar of the same time wins
+	index := map[int64]int{}
+	var uniq []fintypes.Bar
+	for _, v := range bars {
+		if i, ok := index[v.T.UnixNano()]; ok {
+			uniq[i] = v
+			continue
+		}
+		index[v.T.UnixNano()] = len(uniq)
+		uniq = append(uniq, v)
+	}
+	return fintypes.NewKline(target, uniq), nil
+}
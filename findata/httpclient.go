@@ -0,0 +1,55 @@
+package findata
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/net/ghttp"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// transport of requests made by package level functions like GetGoldKline and StockExchangeList,
+// and default transport of data sources whose SetTransport is not called.
+// nil means http.DefaultTransport with proxy, set it to httpreplay.Transport in tests
+var Transport http.RoundTripper
+
+// rt is preferred, then package level Transport, proxy is ignored if any of them is set
+func newHttpClient(rt http.RoundTripper, proxy string, timeout time.Duration) (*http.Client, error) {
+	if rt == nil {
+		rt = Transport
+	}
+	c := &http.Client{Transport: rt, Timeout: timeout}
+	if rt == nil && proxy != "" {
+		if err := ghttp.SetProxy(c, proxy); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func httpGetBytes(ctx context.Context, c *http.Client, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("GET %s: %s", uri, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// get uri by package level Transport
+func httpGetString(uri string, timeout time.Duration) (string, error) {
+	c, err := newHttpClient(nil, "", timeout)
+	if err != nil {
+		return "", err
+	}
+	b, err := httpGetBytes(context.Background(), c, uri)
+	return string(b), err
+}
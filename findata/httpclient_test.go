@@ -0,0 +1,21 @@
+package findata
+
+import (
+	"fmt"
+	"github.com/foxtrader/gofin/httpreplay"
+	"os"
+	"testing"
+)
+
+// requests of all tests are replayed from testdata/replay, record them with GOFIN_REPLAY=record and a reachable network
+func TestMain(m *testing.M) {
+	rt, err := httpreplay.New("testdata/replay")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// end of yahoo finance kline is today
+	rt.IgnoredParams = append(httpreplay.DefaultIgnoredParams, "period2")
+	Transport = rt
+	os.Exit(m.Run())
+}
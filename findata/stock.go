@@ -1,6 +1,8 @@
 package findata
 
 import (
+	"bytes"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
@@ -9,9 +11,7 @@ import (
 	"github.com/shawnwyckoff/gopkg/container/gnum"
 	"github.com/shawnwyckoff/gopkg/container/gstring"
 	"github.com/shawnwyckoff/gopkg/net/ghtml"
-	"github.com/shawnwyckoff/gopkg/net/ghttp"
 	"github.com/shawnwyckoff/gopkg/sys/gtime"
-	"net/http"
 	"regexp"
 	"sort"
 	"strings"
@@ -85,14 +85,17 @@ func newStockMarketList(market string) ([]string, error) {
 		return symbols, fmt.Errorf("invalid market")
 	}
 
-	resp, err := http.Get(url)
+	client, err := newHttpClient(nil, "", time.Minute)
+	if err != nil {
+		return symbols, err
+	}
+	b, err := httpGetBytes(context.Background(), client, url)
 	if err != nil {
 		return symbols, err
 	}
-	defer func() { _ = resp.Body.Close() }()
 
 	var csvdata [][]string
-	reader := csv.NewReader(resp.Body)
+	reader := csv.NewReader(bytes.NewReader(b))
 	csvdata, err = reader.ReadAll()
 	if err != nil {
 		return symbols, err
@@ -131,7 +134,7 @@ func StockExchangeList(exchange fintypes.Platform) ([]fintypes.PairP, error) {
 		// backup data source:
 		// http://quote.eastmoney.com/stocklist.html
 		uri := "http://www.sse.com.cn/js/common/ssesuggestdata.js"
-		s, err := ghttp.GetString(uri, "", time.Minute)
+		s, err := httpGetString(uri, time.Minute)
 		if err != nil {
 			return nil, err
 		}
@@ -199,7 +202,7 @@ func StockExchangeList(exchange fintypes.Platform) ([]fintypes.PairP, error) {
 	} else if exchange == fintypes.Hkex {
 		//uri := "https://www.hkex.com.hk/-/media/HKEX-Market/Services/Trading/Securities/Securities-Lists/Securities-Using-Standard-Transfer-Form-(including-GEM)-By-English-Stock-Short-Name-Order/englishstk_c.xls"
 		uri := "http://quote.eastmoney.com/hk/HStock_list.html"
-		s, err := ghttp.GetString(uri, "", time.Minute)
+		s, err := httpGetString(uri, time.Minute)
 		if err != nil {
 			return nil, err
 		}
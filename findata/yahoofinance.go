@@ -5,6 +5,7 @@ package findata
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -38,6 +39,7 @@ type (
 
 	YFAPI struct {
 		proxy            string
+		transport        http.RoundTripper
 		minAssetOpenDate gtime.Date
 	}
 
@@ -60,6 +62,10 @@ func NewYahooFinance(proxy string) (*YFAPI, error) {
 	return r, nil
 }
 
+func (yf *YFAPI) SetTransport(rt http.RoundTripper) {
+	yf.transport = rt
+}
+
 func (yf *YFAPI) GetKlineProviderInfo() (*fintypes.KlineProviderInfo, error) {
 	r := fintypes.KlineProviderInfo{}
 	stocks, indexes, err := StockListAll()
@@ -106,6 +112,10 @@ func (yf *YFAPI) pairPToYahooFinanceSymbol(target fintypes.PairP) (string, error
 
 // index Pair samples: DJI/USD@open, SHH/CNY@open
 func (yf *YFAPI) GetKlineEx(platform fintypes.Platform, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes2.Kline, error) {
+	return yf.GetKlineExContext(context.Background(), platform, market, target, period, since)
+}
+
+func (yf *YFAPI) GetKlineExContext(ctx context.Context, platform fintypes.Platform, market fintypes.Market, target fintypes.Pair, period fintypes.Period, since *time.Time) (*fintypes2.Kline, error) {
 	if period != fintypes.Period1Day {
 		return nil, errors.Errorf("Yahoo Finance doesn't support Period %s", period.String())
 	}
@@ -122,7 +132,7 @@ func (yf *YFAPI) GetKlineEx(platform fintypes.Platform, market fintypes.Market,
 	// WARN
 	// adjustQuote填true的话，close取的yahoo的 adj close, 存在close小于low的情况，
 	// adjustQuote填false的话，也存在少数这种情况
-	q, err := newQuoteFromYahoo(strings.ToUpper(symbol), sinceDate.ToTimeUTC(), gtime.Today(time.UTC).ToTimeUTC(), fintypes.Period1Day, false, yf.proxy, time.Minute)
+	q, err := newQuoteFromYahoo(ctx, strings.ToUpper(symbol), sinceDate.ToTimeUTC(), gtime.Today(time.UTC).ToTimeUTC(), fintypes.Period1Day, false, yf.transport, yf.proxy, time.Minute)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("stock(%s)", symbol))
 	}
@@ -163,7 +173,7 @@ func newQuote(symbol string, bars int) yfQuote {
 }
 
 // NewQuoteFromYahoo - Yahoo historical prices for a symbol
-func newQuoteFromYahoo(symbol string, from, to time.Time, period fintypes.Period, adjustQuote bool, proxy string, timeout time.Duration) (*yfQuote, error) {
+func newQuoteFromYahoo(ctx context.Context, symbol string, from, to time.Time, period fintypes.Period, adjustQuote bool, rt http.RoundTripper, proxy string, timeout time.Duration) (*yfQuote, error) {
 	if timeout == 0 {
 		timeout = time.Minute
 	}
@@ -173,18 +183,13 @@ func newQuoteFromYahoo(symbol string, from, to time.Time, period fintypes.Period
 
 	// Get crumb
 	jar, _ := cookiejar.New(nil)
-	client := &http.Client{
-		Timeout: timeout,
-		Jar:     jar,
-	}
-
-	if proxy != "" {
-		if err := ghttp.SetProxy(client, proxy); err != nil {
-			return nil, err
-		}
+	client, err := newHttpClient(rt, proxy, timeout)
+	if err != nil {
+		return nil, err
 	}
+	client.Jar = jar
 
-	initReq, err := http.NewRequest("GET", "https://finance.yahoo.com", nil)
+	initReq, err := http.NewRequestWithContext(ctx, "GET", "https://finance.yahoo.com", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -194,7 +199,7 @@ func newQuoteFromYahoo(symbol string, from, to time.Time, period fintypes.Period
 		return nil, err
 	}
 
-	crumbReq, err := http.NewRequest("GET", "https://query1.finance.yahoo.com/v1/test/getcrumb", nil)
+	crumbReq, err := http.NewRequestWithContext(ctx, "GET", "https://query1.finance.yahoo.com/v1/test/getcrumb", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -216,7 +221,11 @@ func newQuoteFromYahoo(symbol string, from, to time.Time, period fintypes.Period
 		from.Unix(),
 		to.Unix(),
 		crumb[0])
-	resp, err = client.Get(url)
+	dataReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = client.Do(dataReq)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("symbol '%s' not found\n", symbol))
 	}
@@ -129,6 +129,17 @@ func (a *Account) SetLockedAmount(ap AssetProperty, lockedAmount gdecimal.Decima
 	})
 }
 
+// deep copy, BorrowHist included
+func (a *Account) Clone() *Account {
+	r := NewEmptyAccount()
+	for _, v := range a.Balances {
+		item := v
+		item.BorrowHist = append([]Borrow(nil), v.BorrowHist...)
+		r.Balances = append(r.Balances, item)
+	}
+	return r
+}
+
 func (a *Account) String() string {
 	return a.JsonString()
 }
@@ -272,13 +283,19 @@ func (a *Account) Transfer(asset string, amount gdecimal.Decimal, from, to SubAc
 	// 执行转账的第二步：加法
 	if saToIdx < 0 { // 已有列表中不存在目的钱包，把资产转到新建钱包即可
 
-		a.Balances = append(a.Balances, Balance{AssetAmount: AssetAmount{
-			Free: amount,
-		},
+		a.Balances = append(a.Balances, Balance{
+			AssetProperty: AssetProperty{
+				Market:           saTo.Market,
+				Margin:           saTo.Margin,
+				CustomSubAccName: saTo.Name,
+				Asset:            asset,
+			},
+			AssetAmount: AssetAmount{
+				Free: amount,
+			},
 		})
 	} else { // 已有列表中存在目的钱包，需要把转出资产挪到目的钱包
-		p := &a.Balances[saToIdx]
-		p.Add(Balance{AssetAmount: AssetAmount{Free: amount}})
+		a.Balances[saToIdx].Free = a.Balances[saToIdx].Free.Add(amount)
 
 	}
 
@@ -394,7 +411,7 @@ func (a *Account) Lock(market Market, margin Margin, asset string, amount gdecim
 	for k, v := range a.Balances {
 		if v.Market == market && v.Margin == margin && v.Asset == asset {
 			if v.Free.LessThan(amount) {
-				return gerror.Errorf("Free(%s) < LockAmount(%s)", v.Locked.String(), amount.String())
+				return gerror.Errorf("Free(%s) < LockAmount(%s)", v.Free.String(), amount.String())
 			}
 			a.Balances[k].Free = a.Balances[k].Free.Sub(amount)
 			a.Balances[k].Locked = a.Balances[k].Locked.Add(amount)
@@ -0,0 +1,156 @@
+package fintypes
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+/**
+本地维护的L2盘口
+1. 用REST快照(带LastUpdateId)初始化: Reset
+2. 按顺序应用增量推送: Apply, 数量为0表示删除该价位
+3. update id不连续时返回ErrDepthGap, 调用方应重新获取快照并Reset
+*/
+
+var (
+	ErrDepthGap       = errors.Errorf("depth diff sequence gap")
+	ErrDepthNotSynced = errors.Errorf("local depth not synced")
+)
+
+type (
+	// 盘口增量推送, Buys/Sells are absolute amounts of price levels, zero amount means removing the level
+	DepthDiff struct {
+		Time             time.Time
+		FirstUpdateId    int64 // first update id in this event
+		LastUpdateId     int64 // last update id in this event
+		PrevLastUpdateId int64 // last update id of previous event, 0 if exchange doesn't provide it
+		DepthRawData
+	}
+
+	LocalDepth struct {
+		mu           sync.RWMutex
+		synced       bool
+		first        bool // no diff applied since last Reset
+		lastUpdateId int64
+		time         time.Time
+		buys         map[string]OrderBook // price string -> order book
+		sells        map[string]OrderBook
+	}
+)
+
+func NewLocalDepth() *LocalDepth {
+	return &LocalDepth{
+		buys:  map[string]OrderBook{},
+		sells: map[string]OrderBook{},
+	}
+}
+
+// seed local depth from snapshot, lastUpdateId is the update id of snapshot
+func (ld *LocalDepth) Reset(snapshot Depth, lastUpdateId int64) {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	ld.buys = map[string]OrderBook{}
+	ld.sells = map[string]OrderBook{}
+	updateLevels(ld.buys, snapshot.Buys)
+	updateLevels(ld.sells, snapshot.Sells)
+	ld.lastUpdateId = lastUpdateId
+	ld.time = snapshot.Time
+	ld.synced = true
+	ld.first = true
+}
+
+// mark local depth as out of sync, Depth returns ErrDepthNotSynced until next Reset
+func (ld *LocalDepth) Invalidate() {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+	ld.synced = false
+}
+
+// apply diff event, stale events are ignored, ErrDepthGap is returned and local depth is invalidated if sequence broken
+func (ld *LocalDepth) Apply(diff DepthDiff) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if !ld.synced {
+		return ErrDepthNotSynced
+	}
+	// 快照之前的推送
+	if diff.LastUpdateId <= ld.lastUpdateId {
+		return nil
+	}
+	if ld.first {
+		// 快照后第一个推送必须覆盖 lastUpdateId+1
+		if diff.FirstUpdateId > ld.lastUpdateId+1 {
+			ld.synced = false
+			return ErrDepthGap
+		}
+	} else if diff.PrevLastUpdateId > 0 {
+		if diff.PrevLastUpdateId != ld.lastUpdateId {
+			ld.synced = false
+			return ErrDepthGap
+		}
+	} else if diff.FirstUpdateId != ld.lastUpdateId+1 {
+		ld.synced = false
+		return ErrDepthGap
+	}
+
+	updateLevels(ld.buys, diff.Buys)
+	updateLevels(ld.sells, diff.Sells)
+	ld.lastUpdateId = diff.LastUpdateId
+	ld.time = diff.Time
+	ld.first = false
+	return nil
+}
+
+func (ld *LocalDepth) Synced() bool {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+	return ld.synced
+}
+
+func (ld *LocalDepth) LastUpdateId() int64 {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+	return ld.lastUpdateId
+}
+
+// sorted copy of top levels, limit <= 0 means all levels
+// result can be used by MarketBuyDetectEx/MarketSellDetectEx directly
+func (ld *LocalDepth) Depth(limit int) (*Depth, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	if !ld.synced {
+		return nil, ErrDepthNotSynced
+	}
+	r := &Depth{Time: ld.time}
+	for _, v := range ld.buys {
+		r.Buys = append(r.Buys, v)
+	}
+	for _, v := range ld.sells {
+		r.Sells = append(r.Sells, v)
+	}
+	r.Sort()
+	if limit > 0 {
+		if len(r.Buys) > limit {
+			r.Buys = r.Buys[:limit]
+		}
+		if len(r.Sells) > limit {
+			r.Sells = r.Sells[:limit]
+		}
+	}
+	return r, nil
+}
+
+func updateLevels(levels map[string]OrderBook, updates OrderBookList) {
+	for _, v := range updates {
+		key := v.Price.String()
+		if v.Amount.IsZero() {
+			delete(levels, key)
+		} else {
+			levels[key] = v
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package fintypes
+
+import (
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"testing"
+)
+
+func newTestDiff(first, last, prev int64, buys, sells OrderBookList) DepthDiff {
+	return DepthDiff{FirstUpdateId: first, LastUpdateId: last, PrevLastUpdateId: prev, DepthRawData: DepthRawData{Buys: buys, Sells: sells}}
+}
+
+func TestLocalDepth_Apply(t *testing.T) {
+	ld := NewLocalDepth()
+	if _, err := ld.Depth(0); err != ErrDepthNotSynced {
+		t.Errorf("depth should not be synced before Reset")
+		return
+	}
+	ld.Reset(newTestDepth(false), 100)
+
+	// stale diff is ignored
+	if err := ld.Apply(newTestDiff(90, 100, 0, OrderBookList{{Price: gdecimal.NewFromInt(10), Amount: gdecimal.Zero}}, nil)); err != nil {
+		t.Error(err)
+		return
+	}
+	// first diff covers lastUpdateId+1
+	if err := ld.Apply(newTestDiff(95, 105, 0, OrderBookList{{Price: gdecimal.NewFromInt(10), Amount: gdecimal.Zero}}, OrderBookList{{Price: gdecimal.NewFromFloat64(10.5), Amount: gdecimal.NewFromInt(2)}})); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := ld.Apply(newTestDiff(106, 108, 0, OrderBookList{{Price: gdecimal.NewFromFloat64(9.5), Amount: gdecimal.NewFromInt(3)}}, nil)); err != nil {
+		t.Error(err)
+		return
+	}
+	dp, err := ld.Depth(2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(dp.Buys) != 2 || dp.Buys[0].Price.String() != "9.5" || dp.Buys[1].Price.String() != "9" ||
+		len(dp.Sells) != 2 || dp.Sells[0].Price.String() != "10.5" || dp.Sells[1].Price.String() != "11" {
+		t.Errorf("local depth error %s", dp.String())
+		return
+	}
+	if ld.LastUpdateId() != 108 {
+		t.Errorf("last update id should be 108, but got %d", ld.LastUpdateId())
+		return
+	}
+
+	// gap
+	if err := ld.Apply(newTestDiff(110, 112, 0, nil, nil)); err != ErrDepthGap {
+		t.Errorf("expect ErrDepthGap, but got %v", err)
+		return
+	}
+	if ld.Synced() {
+		t.Errorf("local depth should be invalidated after gap")
+		return
+	}
+}
+
+// futures style diffs with previous last update id
+func TestLocalDepth_ApplyPrevId(t *testing.T) {
+	ld := NewLocalDepth()
+	ld.Reset(newTestDepth(false), 100)
+
+	if err := ld.Apply(newTestDiff(98, 103, 97, nil, nil)); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := ld.Apply(newTestDiff(104, 106, 103, nil, nil)); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := ld.Apply(newTestDiff(108, 110, 107, nil, nil)); err != ErrDepthGap {
+		t.Errorf("expect ErrDepthGap, but got %v", err)
+	}
+}
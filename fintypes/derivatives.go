@@ -0,0 +1,57 @@
+package fintypes
+
+import (
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"sort"
+	"time"
+)
+
+// market data of perp and future contracts, price-like series like mark price and index price are Kline
+
+type (
+	FundingRate struct {
+		T    time.Time        // settlement time
+		Rate gdecimal.Decimal // positive rate means long position pays short position
+	}
+
+	// funding rate history of perp pair
+	FundingRates struct {
+		Pair  PairMP
+		Items []FundingRate
+	}
+
+	OpenInterest struct {
+		T      time.Time        // statistic time
+		Amount gdecimal.Decimal // total open position in unit amount
+		Value  gdecimal.Decimal // total open position value in quote asset
+	}
+
+	// open interest history of contract pair
+	OpenInterests struct {
+		Pair  PairIMP
+		Items []OpenInterest
+	}
+)
+
+func (fr *FundingRates) Sort() {
+	sort.Slice(fr.Items, func(i, j int) bool {
+		return fr.Items[i].T.Before(fr.Items[j].T)
+	})
+}
+
+// sum of funding rates settled in [begin, end), it is the funding cost rate of long position held in the period
+func (fr FundingRates) Sum(begin, end time.Time) gdecimal.Decimal {
+	res := gdecimal.Zero
+	for _, v := range fr.Items {
+		if !v.T.Before(begin) && v.T.Before(end) {
+			res = res.Add(v.Rate)
+		}
+	}
+	return res
+}
+
+func (oi *OpenInterests) Sort() {
+	sort.Slice(oi.Items, func(i, j int) bool {
+		return oi.Items[i].T.Before(oi.Items[j].T)
+	})
+}
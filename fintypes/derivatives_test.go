@@ -0,0 +1,24 @@
+package fintypes
+
+import (
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"testing"
+	"time"
+)
+
+func TestFundingRates_Sum(t *testing.T) {
+	begin := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fr := FundingRates{Pair: BTC.Against(USDT).SetM(MarketPerp).SetP(Binance)}
+	for i := 3; i >= 0; i-- {
+		fr.Items = append(fr.Items, FundingRate{T: begin.Add(time.Duration(i) * 8 * time.Hour), Rate: gdecimal.NewFromFloat64(0.0001 * float64(i+1))})
+	}
+	fr.Sort()
+	if !fr.Items[0].T.Equal(begin) {
+		t.Errorf("sort error, first item %v", fr.Items[0])
+		return
+	}
+	// [begin, begin+16h) includes the first and second settlement only
+	if s := fr.Sum(begin, begin.Add(16*time.Hour)); s.String() != "0.0003" {
+		t.Errorf("sum should be 0.0003, but got %s", s.String())
+	}
+}
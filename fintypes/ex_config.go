@@ -3,6 +3,7 @@ package fintypes
 import (
 	"github.com/pkg/errors"
 	"github.com/shawnwyckoff/gopkg/sys/gtime"
+	"strings"
 	"time"
 )
 
@@ -21,7 +22,7 @@ const (
 
 var (
 	ErrFunctionNotSupported = errors.Errorf("function not supported")
-	AllSupportedExs         = []Platform{Binance}
+	AllSupportedExs         = []Platform{Binance, Huobi, Kraken}
 )
 
 type (
@@ -35,12 +36,15 @@ type (
 		PairDelimiterRightHead []string
 		PairNormalOrder        bool // whether is ISO order —— unit first quote second
 		PairUpperCase          bool
-		PairsSeparator         string // separator between multiple pairs in api request
+		PairsSeparator         string            // separator between multiple pairs in api request
+		AssetSymbols           map[string]string // exchange custom asset symbols, like BTC -> XBT in kraken
 		Periods                map[Period]string
 		OrderStatus            map[OrderStatus]string
 		OrderTypes             map[OrderType]string // FIXME 这里用OrderSide还是OrderType
+		TimeInForces           map[TimeInForce]string
 		OrderSides             map[OrderSide]string
-		RateLimits             map[ExApi]time.Duration
+		RateLimits             map[ExApi]time.Duration // min interval between two calls, enforced by adapter
+		RateLimitNoWait        bool                    // return ErrRateLimited instead of waiting when rate limit reached
 		MarketEnabled          map[Market]bool
 		Clock                  gtime.Clock
 		IsBackTestEx           bool
@@ -56,6 +60,24 @@ func (cc ExProperty) SupportedPeriods() []Period {
 	return r
 }
 
+// exchange custom symbol of standard asset symbol
+func (cc ExProperty) CustomAsset(asset string) string {
+	if v, ok := cc.AssetSymbols[strings.ToUpper(asset)]; ok {
+		return v
+	}
+	return asset
+}
+
+// standard asset symbol of exchange custom symbol
+func (cc ExProperty) StdAsset(custom string) string {
+	for k, v := range cc.AssetSymbols {
+		if strings.EqualFold(v, custom) {
+			return k
+		}
+	}
+	return custom
+}
+
 func (cc ExProperty) MinPeriod() Period {
 	minPeriod := PeriodError
 
@@ -0,0 +1,51 @@
+package fintypes
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+/**
+typed errors of exchange api
+adapters convert exchange error codes into *ExError, whose kind can be checked by errors.Is, like:
+	if errors.Is(err, fintypes.ErrInsufficientBalance) {...}
+and exchange raw code can be got by errors.As:
+	var exErr *fintypes.ExError
+	if errors.As(err, &exErr) {...}
+*/
+
+var (
+	ErrRateLimited         = errors.Errorf("rate limited")
+	ErrInsufficientBalance = errors.Errorf("insufficient balance")
+	ErrOrderNotFound       = errors.Errorf("order not found")
+	ErrMinNotional         = errors.Errorf("min notional violated")
+	ErrInvalidOrder        = errors.Errorf("invalid order") // price or amount violates filters like precision, lot size
+	ErrOrderRejected       = errors.Errorf("order rejected")
+	ErrInvalidTimestamp    = errors.Errorf("timestamp out of recv window")
+	ErrInvalidApiKey       = errors.Errorf("invalid api key or signature")
+	ErrInvalidPair         = errors.Errorf("invalid pair")
+	ErrDuplicateOrder      = errors.Errorf("duplicate client order id")
+	ErrUnknownResult       = errors.Errorf("unknown execution result") // like gateway timeout, request may or may not be processed
+)
+
+type ExError struct {
+	Platform Platform
+	Code     string // raw error code of exchange
+	Message  string // raw error message of exchange
+	Kind     error  // one of ErrXXX above, nil if unknown
+}
+
+func NewExError(platform Platform, code, message string, kind error) *ExError {
+	return &ExError{Platform: platform, Code: code, Message: message, Kind: kind}
+}
+
+func (e *ExError) Error() string {
+	if e.Kind == nil {
+		return fmt.Sprintf("%s error %s: %s", e.Platform, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s error %s: %s (%s)", e.Platform, e.Code, e.Message, e.Kind.Error())
+}
+
+func (e *ExError) Unwrap() error {
+	return e.Kind
+}
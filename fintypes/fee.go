@@ -0,0 +1,116 @@
+package fintypes
+
+import (
+	"github.com/shawnwyckoff/gopkg/apputil/gerror"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+)
+
+type (
+	FeeRate struct {
+		Maker gdecimal.Decimal // 挂单费率
+		Taker gdecimal.Decimal // 吃单费率
+	}
+
+	// VIP tier of exchange, like VIP1 of binance
+	FeeTier struct {
+		Name  string
+		Rates map[Market]FeeRate
+	}
+
+	// fee model of an exchange account
+	// rate of pair is the first one found in PairOverrides, MarketOverrides and Tiers[Tier].
+	FeeSchedule struct {
+		Platform        Platform
+		Tiers           []FeeTier          // Tiers[0] is the regular one
+		Tier            int                // VIP level of account
+		MarketOverrides map[Market]FeeRate // like commission rates of account returned by exchange
+		PairOverrides   map[PairM]FeeRate  // like zero fee promotion of some pairs
+
+		// fee is paid in DiscountAsset at discount if PayInDiscountAsset, like 25% off in BNB of binance spot
+		DiscountAsset      string
+		Discounts          map[Market]gdecimal.Decimal // 0.25 means 25% off
+		PayInDiscountAsset bool
+	}
+
+	Fee struct {
+		Asset  string
+		Amount gdecimal.Decimal
+	}
+)
+
+// fee schedule of static fees in market info, like MarketInfo of exchanges without commission api
+func NewFeeScheduleFromMarketInfo(platform Platform, mi *MarketInfo) *FeeSchedule {
+	fs := &FeeSchedule{Platform: platform, PairOverrides: map[PairM]FeeRate{}}
+	if mi != nil {
+		for pm, info := range mi.Infos {
+			fs.PairOverrides[pm] = FeeRate{Maker: info.MakerFee, Taker: info.TakerFee}
+		}
+	}
+	return fs
+}
+
+func (fr FeeRate) Get(taker bool) gdecimal.Decimal {
+	if taker {
+		return fr.Taker
+	}
+	return fr.Maker
+}
+
+// fee rate of pair before discount
+func (fs *FeeSchedule) Rate(pm PairM) (FeeRate, error) {
+	if r, ok := fs.PairOverrides[pm]; ok {
+		return r, nil
+	}
+	if r, ok := fs.MarketOverrides[pm.M()]; ok {
+		return r, nil
+	}
+	if fs.Tier >= 0 && fs.Tier < len(fs.Tiers) {
+		if r, ok := fs.Tiers[fs.Tier].Rates[pm.M()]; ok {
+			return r, nil
+		}
+	}
+	return FeeRate{}, gerror.Errorf("%s fee rate of %s not found in tier %d", fs.Platform, pm.String(), fs.Tier)
+}
+
+// Calc calculates fee of amount unit dealt at price.
+// spot buyer pays in unit asset, spot seller and contracts pay in quote asset.
+// if PayInDiscountAsset, fee is paid in DiscountAsset at discount, discountPrice is price of DiscountAsset in quote asset,
+// and it is ignored otherwise.
+func (fs *FeeSchedule) Calc(pm PairM, side OrderSide, price, amount gdecimal.Decimal, taker bool, discountPrice gdecimal.Decimal) (*Fee, error) {
+	r, err := fs.Rate(pm)
+	if err != nil {
+		return nil, err
+	}
+	rate := r.Get(taker)
+	quoteFee := amount.Mul(price).Mul(rate)
+
+	if fs.PayInDiscountAsset && fs.DiscountAsset != "" {
+		if !discountPrice.IsPositive() {
+			return nil, gerror.Errorf("invalid %s price %s", fs.DiscountAsset, discountPrice.String())
+		}
+		quoteFee = quoteFee.Mul(gdecimal.One.Sub(fs.Discounts[pm.M()]))
+		return &Fee{Asset: fs.DiscountAsset, Amount: quoteFee.Div(discountPrice)}, nil
+	}
+	if !pm.M().IsContract() && side.IsBuy() {
+		return &Fee{Asset: pm.Pair().Unit(), Amount: amount.Mul(rate)}, nil
+	}
+	return &Fee{Asset: pm.Pair().Quote(), Amount: quoteFee}, nil
+}
+
+// fee of filled part of order, taker is whether the order took liquidity, like market orders.
+// AvgPrice is preferred, Price is used if AvgPrice is not provided (like -1 of binance).
+func (fs *FeeSchedule) OrderFee(od Order, taker bool, discountPrice gdecimal.Decimal) (*Fee, error) {
+	price := od.AvgPrice
+	if !price.IsPositive() {
+		price = od.Price
+	}
+	if !price.IsPositive() && od.DealAmount.IsPositive() {
+		return nil, gerror.Errorf("order %s has neither AvgPrice nor Price", od.Id)
+	}
+	return fs.Calc(od.Pair.SetM(od.Market), od.Side, price, od.DealAmount, taker, discountPrice)
+}
+
+// fee of one fill of our order on side, Side of public fills is not used because it may be side of maker
+func (fs *FeeSchedule) FillFee(pm PairM, side OrderSide, fill Fill, taker bool, discountPrice gdecimal.Decimal) (*Fee, error) {
+	return fs.Calc(pm, side, fill.Price, fill.UnitQty, taker, discountPrice)
+}
@@ -0,0 +1,65 @@
+package fintypes
+
+import (
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"testing"
+)
+
+func TestFeeSchedule_Calc(t *testing.T) {
+	d := gdecimal.NewFromFloat64
+	spot := BTC.Against(USDT).SetM(MarketSpot)
+	perp := BTC.Against(USDT).SetM(MarketPerp)
+	fs := &FeeSchedule{
+		Tiers: []FeeTier{
+			{Name: "VIP0", Rates: map[Market]FeeRate{MarketSpot: {Maker: d(0.001), Taker: d(0.001)}, MarketPerp: {Maker: d(0.0002), Taker: d(0.0004)}}},
+			{Name: "VIP1", Rates: map[Market]FeeRate{MarketSpot: {Maker: d(0.0009), Taker: d(0.001)}}},
+		},
+		Tier:          1,
+		PairOverrides: map[PairM]FeeRate{perp: {Maker: gdecimal.Zero, Taker: d(0.0005)}},
+		DiscountAsset: "BNB",
+		Discounts:     map[Market]gdecimal.Decimal{MarketSpot: d(0.25)},
+	}
+
+	for _, v := range []struct {
+		pm     PairM
+		side   OrderSide
+		taker  bool
+		asset  string
+		amount string
+	}{
+		{spot, OrderSideBuyLong, false, "BTC", "0.0018"},
+		{spot, OrderSideSellShort, true, "USDT", "20"},
+		{perp, OrderSideBuyLong, true, "USDT", "10"},
+	} {
+		fee, err := fs.Calc(v.pm, v.side, d(10000), d(2), v.taker, gdecimal.Zero)
+		gtest.Assert(t, err)
+		if fee.Asset != v.asset || fee.Amount.String() != v.amount {
+			t.Errorf("%s %s fee should be %s %s, but %s %s", v.pm, v.side, v.amount, v.asset, fee.Amount, fee.Asset)
+		}
+	}
+
+	// 25% off in BNB
+	fs.PayInDiscountAsset = true
+	fee, err := fs.OrderFee(Order{Market: MarketSpot, Pair: spot.Pair(), Side: OrderSideBuyLong, AvgPrice: d(10000), DealAmount: d(2)}, true, d(15))
+	gtest.Assert(t, err)
+	if fee.Asset != "BNB" || fee.Amount.String() != "1" {
+		t.Errorf("fee should be 1 BNB, but %s %s", fee.Amount, fee.Asset)
+	}
+	if _, err := fs.OrderFee(Order{Market: MarketSpot, Pair: spot.Pair()}, true, gdecimal.Zero); err == nil {
+		t.Errorf("BNB price should be required")
+	}
+	// binance spot order has no AvgPrice
+	fee, err = fs.OrderFee(Order{Market: MarketSpot, Pair: spot.Pair(), Side: OrderSideBuyLong, Price: d(10000), AvgPrice: d(-1), DealAmount: d(2)}, true, d(15))
+	gtest.Assert(t, err)
+	if fee.Asset != "BNB" || fee.Amount.String() != "1" {
+		t.Errorf("fee should be 1 BNB, but %s %s", fee.Amount, fee.Asset)
+	}
+	if _, err := fs.OrderFee(Order{Market: MarketSpot, Pair: spot.Pair(), Side: OrderSideBuyLong, DealAmount: d(2)}, true, d(15)); err == nil {
+		t.Errorf("price of order should be required")
+	}
+	fs.Tier = 5
+	if _, err := fs.Rate(spot); err == nil {
+		t.Errorf("rate of unknown tier should not be found")
+	}
+}
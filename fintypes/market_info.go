@@ -18,6 +18,7 @@ type (
 		UnitMin        gdecimal.Decimal // unit min trade amount, mostly it is the same as LostStep
 		UnitStep       gdecimal.Decimal // unit min step amount, min trade amount in unit, same as amount in SetPosition()
 		QuoteStep      gdecimal.Decimal // quote min movement
+		MinNotional    gdecimal.Decimal // min order value in quote, amount * price, zero means no limit
 
 		// shared
 		MinLeverage           int // 最小杠杆倍数
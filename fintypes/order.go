@@ -4,7 +4,10 @@ package fintypes
 一个交易有多个属性，包括：
 
 OrderType 市价单或者限价单（必要）
-market/limit
+market/limit/stop_limit/take_profit/trailing_stop/oco
+
+TimeInForce 订单有效方式（可选）
+gtc/ioc/fok/gtx(post only)
 
 OrderSide 做空还是做多（必要）
 short/long
@@ -35,26 +38,30 @@ type (
 
 	OrderType string
 
+	TimeInForce string
+
 	TradeIntent string // 交易意图
 
 	TradeIncome string
 
 	Order struct {
-		Id         OrderId
-		Time       time.Time
-		Market     Market
-		Margin     Margin
-		Leverage   int
-		Pair       Pair
-		Side       OrderSide
-		Type       OrderType
-		Status     OrderStatus
-		StopPrice  gdecimal.Decimal // 止盈止损触发价，限价单才有 FIXME 如果该用*会导致程序崩溃
-		Price      gdecimal.Decimal
-		Amount     gdecimal.Decimal // initial total amount in unit, unit always
-		AvgPrice   gdecimal.Decimal // Binance貌似不提供AvgPrice
-		DealAmount gdecimal.Decimal // filled amount in unit, NOT quote,PaperEx在撮合的时候是这么理解的，如果以后要改，也要修正paperEx
-		Fee        gdecimal.Decimal // Binance貌似不提供Fee
+		Id          OrderId
+		ClientId    string // caller-supplied client order id, empty if not set
+		Time        time.Time
+		Market      Market
+		Margin      Margin
+		Leverage    int
+		Pair        Pair
+		Side        OrderSide
+		Type        OrderType
+		TimeInForce TimeInForce // empty means GTC
+		Status      OrderStatus
+		StopPrice   gdecimal.Decimal // 止盈止损触发价，限价单才有 FIXME 如果该用*会导致程序崩溃
+		Price       gdecimal.Decimal
+		Amount      gdecimal.Decimal // initial total amount in unit, unit always
+		AvgPrice    gdecimal.Decimal // Binance貌似不提供AvgPrice
+		DealAmount  gdecimal.Decimal // filled amount in unit, NOT quote,PaperEx在撮合的时候是这么理解的，如果以后要改，也要修正paperEx
+		Fee         gdecimal.Decimal // Binance貌似不提供Fee
 	}
 )
 
@@ -75,10 +82,19 @@ const (
 	OrderSideBuyLong   OrderSide = "buy"
 	OrderSideSellShort OrderSide = "sell"
 
-	OrderTypeError     OrderType = ""
-	OrderTypeLimit     OrderType = "limit"
-	OrderTypeMarket    OrderType = "market"
-	OrderTypeStopLimit OrderType = "stop_limit"
+	OrderTypeError        OrderType = ""
+	OrderTypeLimit        OrderType = "limit"
+	OrderTypeMarket       OrderType = "market"
+	OrderTypeStopLimit    OrderType = "stop_limit"    // 止损限价单, triggered by StopPrice, then works like limit order at Price
+	OrderTypeTakeProfit   OrderType = "take_profit"   // 止盈限价单, same as stop_limit but triggered in the profit direction
+	OrderTypeTrailingStop OrderType = "trailing_stop" // 跟踪止损, market order triggered when price retraces by callback rate, StopPrice is activation price
+	OrderTypeOCO          OrderType = "oco"           // one-cancels-the-other, a limit order at Price and a stop order at StopPrice
+
+	TimeInForceError TimeInForce = ""
+	TimeInForceGTC   TimeInForce = "gtc" // good till canceled
+	TimeInForceIOC   TimeInForce = "ioc" // immediate or cancel, unfilled part is canceled
+	TimeInForceFOK   TimeInForce = "fok" // fill or kill, filled entirely or canceled
+	TimeInForceGTX   TimeInForce = "gtx" // post only, canceled if it would take liquidity
 
 	TradeIntentError  TradeIntent = ""
 	TradeIntentOpen   TradeIntent = "open"   // 开仓进场
@@ -96,9 +112,29 @@ func (ts OrderStatus) String() string {
 	return string(ts)
 }
 
-// FIXME 如果部分完成后取消了，这个订单的状态是什么
+// order partially filled then canceled ends with OrderStatusPartiallyCanceled if exchange reports it (huobi, kraken),
+// otherwise it's OrderStatusCanceled with positive DealAmount (binance)
 func (ts OrderStatus) End() bool {
-	return ts == OrderStatusFilled || ts == OrderStatusCanceled || ts == OrderStatusRejected || ts == OrderStatusExpired
+	return ts == OrderStatusFilled || ts == OrderStatusCanceled || ts == OrderStatusPartiallyCanceled || ts == OrderStatusRejected || ts == OrderStatusExpired
+}
+
+// whether order status can change from ts to next, unchanged status is valid.
+// intermediate status may be skipped because updates can be lost, like new -> filled, but ended status never changes,
+// OrderStatusError is unknown status of order not seen yet, it can change to any status.
+func (ts OrderStatus) CanTransitTo(next OrderStatus) bool {
+	if ts == next || ts == OrderStatusError {
+		return true
+	}
+	switch ts {
+	case OrderStatusNew:
+		return next != OrderStatusError
+	case OrderStatusPartiallyFilled:
+		return next != OrderStatusError && next != OrderStatusNew && next != OrderStatusRejected
+	case OrderStatusCanceling:
+		return next.End() || next == OrderStatusPartiallyFilled // filled before canceling takes effect
+	default:
+		return false
+	}
 }
 
 func NewOrderId(market Market, margin Margin, pair Pair, strId string) OrderId {
@@ -241,6 +277,28 @@ func (tt OrderType) IsStopLimit() bool {
 	return tt == OrderTypeStopLimit
 }
 
+func (tt OrderType) IsTakeProfit() bool {
+	return tt == OrderTypeTakeProfit
+}
+
+func (tt OrderType) IsTrailingStop() bool {
+	return tt == OrderTypeTrailingStop
+}
+
+func (tt OrderType) IsOCO() bool {
+	return tt == OrderTypeOCO
+}
+
+// whether Price is required
+func (tt OrderType) HasPrice() bool {
+	return tt == OrderTypeLimit || tt == OrderTypeStopLimit || tt == OrderTypeTakeProfit || tt == OrderTypeOCO
+}
+
+// whether StopPrice is required, StopPrice of trailing stop order is optional activation price
+func (tt OrderType) HasStopPrice() bool {
+	return tt == OrderTypeStopLimit || tt == OrderTypeTakeProfit || tt == OrderTypeOCO
+}
+
 func (tt OrderType) CustomFormat(config *ExProperty) string {
 	for k, v := range config.OrderTypes {
 		if k == tt {
@@ -251,8 +309,35 @@ func (tt OrderType) CustomFormat(config *ExProperty) string {
 }
 
 func (tt OrderType) Verify() error {
-	if tt != OrderTypeLimit && tt != OrderTypeMarket {
-		return errors.Errorf("invalid Side(%s)", string(tt))
+	switch tt {
+	case OrderTypeLimit, OrderTypeMarket, OrderTypeStopLimit, OrderTypeTakeProfit, OrderTypeTrailingStop, OrderTypeOCO:
+		return nil
 	}
-	return nil
+	return errors.Errorf("invalid OrderType(%s)", string(tt))
+}
+
+func (tif TimeInForce) String() string {
+	return string(tif)
+}
+
+func (tif TimeInForce) IsPostOnly() bool {
+	return tif == TimeInForceGTX
+}
+
+func (tif TimeInForce) CustomFormat(config *ExProperty) string {
+	for k, v := range config.TimeInForces {
+		if k == tif {
+			return v
+		}
+	}
+	return tif.String()
+}
+
+// empty TimeInForce is valid, it means GTC
+func (tif TimeInForce) Verify() error {
+	switch tif {
+	case TimeInForceError, TimeInForceGTC, TimeInForceIOC, TimeInForceFOK, TimeInForceGTX:
+		return nil
+	}
+	return errors.Errorf("invalid TimeInForce(%s)", string(tif))
 }
@@ -0,0 +1,87 @@
+package fintypes
+
+import (
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"strings"
+)
+
+/**
+下单前按PairInfo规整订单
+
+amount is rounded down to UnitStep, so it never exceeds the amount caller wants to trade.
+price and stop price are rounded to the nearest QuoteStep.
+then amount is checked against UnitMin, and amount * price against MinNotional.
+market order has no price, caller can pass latest price as reference price of notional check, zero price skips the check.
+zero step or min means no such filter.
+*/
+
+// rounds d down to multiple of step
+func floorStep(d, step gdecimal.Decimal) gdecimal.Decimal {
+	if !step.IsPositive() {
+		return d
+	}
+	q := d.Div(step).String()
+	if idx := strings.Index(q, "."); idx >= 0 {
+		q = q[:idx]
+	}
+	n, err := gdecimal.NewFromString(q)
+	if err != nil {
+		return d
+	}
+	return n.Mul(step)
+}
+
+// rounds d to the nearest multiple of step
+func roundStep(d, step gdecimal.Decimal) gdecimal.Decimal {
+	if !step.IsPositive() {
+		return d
+	}
+	return floorStep(d.Add(step.Div(gdecimal.NewFromInt(2))), step)
+}
+
+// returns normalized amount, price and stop price, error wraps ErrInvalidOrder or ErrMinNotional
+func (pi PairInfo) NormalizeOrder(orderType OrderType, amount, price, stopPrice gdecimal.Decimal) (gdecimal.Decimal, gdecimal.Decimal, gdecimal.Decimal, error) {
+	if !amount.IsPositive() {
+		return amount, price, stopPrice, errors.Wrapf(ErrInvalidOrder, "amount %s should be positive", amount.String())
+	}
+	if orderType.HasPrice() && !price.IsPositive() {
+		return amount, price, stopPrice, errors.Wrapf(ErrInvalidOrder, "price %s of %s order should be positive", price.String(), orderType)
+	}
+	if orderType.HasStopPrice() && !stopPrice.IsPositive() {
+		return amount, price, stopPrice, errors.Wrapf(ErrInvalidOrder, "stop price %s should be positive", stopPrice.String())
+	}
+
+	normAmount := floorStep(amount, pi.UnitStep)
+	normPrice := price
+	if price.IsPositive() {
+		normPrice = roundStep(price, pi.QuoteStep)
+	}
+	normStopPrice := stopPrice
+	if stopPrice.IsPositive() {
+		normStopPrice = roundStep(stopPrice, pi.QuoteStep)
+	}
+
+	if !normAmount.IsPositive() || normAmount.LessThan(pi.UnitMin) {
+		return normAmount, normPrice, normStopPrice, errors.Wrapf(ErrInvalidOrder, "amount %s (rounded from %s by step %s) is less than min amount %s", normAmount.String(), amount.String(), pi.UnitStep.String(), pi.UnitMin.String())
+	}
+	if normPrice.IsPositive() && pi.MinNotional.IsPositive() {
+		if notional := normAmount.Mul(normPrice); notional.LessThan(pi.MinNotional) {
+			return normAmount, normPrice, normStopPrice, errors.Wrapf(ErrMinNotional, "notional %s (amount %s * price %s) is less than min notional %s", notional.String(), normAmount.String(), normPrice.String(), pi.MinNotional.String())
+		}
+	}
+	return normAmount, normPrice, normStopPrice, nil
+}
+
+// same as PairInfo.NormalizeOrder, ErrInvalidPair is returned if pm not found in market info
+func (mi *MarketInfo) NormalizeOrder(pm PairM, orderType OrderType, amount, price, stopPrice gdecimal.Decimal) (gdecimal.Decimal, gdecimal.Decimal, gdecimal.Decimal, error) {
+	info, ok := mi.Infos[pm]
+	if !ok {
+		return amount, price, stopPrice, errors.Wrapf(ErrInvalidPair, "PairM(%s) not found in market info", pm.String())
+	}
+	normAmount, normPrice, normStopPrice, err := info.NormalizeOrder(orderType, amount, price, stopPrice)
+	if err != nil {
+		return normAmount, normPrice, normStopPrice, errors.Wrapf(err, "PairM(%s)", pm.String())
+	}
+	return normAmount, normPrice, normStopPrice, nil
+}
@@ -0,0 +1,51 @@
+package fintypes
+
+import (
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"testing"
+)
+
+func TestPairInfo_NormalizeOrder(t *testing.T) {
+	info := PairInfo{
+		UnitMin:     gdecimal.NewFromFloat64(0.001),
+		UnitStep:    gdecimal.NewFromFloat64(0.001),
+		QuoteStep:   gdecimal.NewFromFloat64(0.05),
+		MinNotional: gdecimal.NewFromInt(10),
+	}
+
+	amount, price, stopPrice, err := info.NormalizeOrder(OrderTypeStopLimit, gdecimal.NewFromFloat64(1.23456), gdecimal.NewFromFloat64(100.123), gdecimal.NewFromFloat64(99.98))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if amount.String() != "1.234" || price.String() != "100.1" || stopPrice.String() != "100" {
+		t.Errorf("normalize error, amount %s, price %s, stop price %s", amount.String(), price.String(), stopPrice.String())
+		return
+	}
+
+	// amount less than min after rounding
+	_, _, _, err = info.NormalizeOrder(OrderTypeLimit, gdecimal.NewFromFloat64(0.0009), gdecimal.NewFromInt(100), gdecimal.Zero)
+	if !errors.Is(err, ErrInvalidOrder) {
+		t.Errorf("expect ErrInvalidOrder, but got %v", err)
+		return
+	}
+
+	// notional 0.05 * 100 < 10
+	_, _, _, err = info.NormalizeOrder(OrderTypeLimit, gdecimal.NewFromFloat64(0.05), gdecimal.NewFromInt(100), gdecimal.Zero)
+	if !errors.Is(err, ErrMinNotional) {
+		t.Errorf("expect ErrMinNotional, but got %v", err)
+		return
+	}
+
+	// market order without reference price skips notional check
+	if _, _, _, err = info.NormalizeOrder(OrderTypeMarket, gdecimal.NewFromFloat64(0.05), gdecimal.Zero, gdecimal.Zero); err != nil {
+		t.Error(err)
+		return
+	}
+
+	mi := MarketInfo{Infos: map[PairM]PairInfo{BTC.Against(USDT).SetM(MarketSpot): info}}
+	if _, _, _, err = mi.NormalizeOrder(ETH.Against(USDT).SetM(MarketSpot), OrderTypeMarket, gdecimal.One, gdecimal.Zero, gdecimal.Zero); !errors.Is(err, ErrInvalidPair) {
+		t.Errorf("expect ErrInvalidPair, but got %v", err)
+	}
+}
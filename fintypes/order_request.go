@@ -0,0 +1,94 @@
+package fintypes
+
+import (
+	"crypto/rand"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"math/big"
+	"regexp"
+)
+
+type (
+	// parameters of Ex.TradeEx, Ex.Trade is TradeEx with GTC and without extended fields
+	TradeRequest struct {
+		Market         Market
+		Margin         Margin
+		Leverage       int
+		Pair           Pair
+		Side           OrderSide
+		Type           OrderType
+		TimeInForce    TimeInForce      // optional, GTC by default
+		Amount         gdecimal.Decimal // always unit amount, whether buy or sell
+		Price          gdecimal.Decimal // limit price, ignored by market and trailing stop orders
+		StopPrice      gdecimal.Decimal // trigger price, optional activation price of trailing stop order
+		StopLimitPrice gdecimal.Decimal // OCO only, limit price of the stop leg, stop leg is stop-market order if zero
+		CallbackRate   gdecimal.Decimal // trailing stop only, in percent, 1 means 1%
+		ClientId       string           // optional caller-supplied client order id, see NewClientId
+		ReduceOnly     bool             // contract only, order can only reduce position, like orders of Ex.ClosePosition
+	}
+
+	// result of one order in batch requests like Ex.TradeBatch and Ex.CancelOrders
+	OrderResult struct {
+		Id  OrderId // empty if placing failed
+		Err error   // nil if succeed
+	}
+)
+
+// 1~36 letters, digits, '-' or '_', it is the intersection of binance, huobi spot and kraken rules
+var clientIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,36}$`)
+
+// generates random client order id of 18 digits, like "736402918273645510",
+// it is accepted by all supported exchanges, including huobi perp which requires integer and kraken spot which requires 18 chars at most
+func NewClientId() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(9e17))
+	if err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return n.Add(n, big.NewInt(1e17)).String()
+}
+
+func VerifyClientId(id string) error {
+	if !clientIdRegexp.MatchString(id) {
+		return errors.Errorf("invalid client order id(%s)", id)
+	}
+	return nil
+}
+
+func (tr TradeRequest) Verify() error {
+	if err := tr.Pair.Verify(); err != nil {
+		return err
+	}
+	if err := tr.Side.Verify(); err != nil {
+		return err
+	}
+	if err := tr.Type.Verify(); err != nil {
+		return err
+	}
+	if err := tr.TimeInForce.Verify(); err != nil {
+		return err
+	}
+	if !tr.Amount.IsPositive() {
+		return errors.Errorf("invalid amount %s", tr.Amount.String())
+	}
+	if tr.Type.HasPrice() && !tr.Price.IsPositive() {
+		return errors.Errorf("price required by OrderType(%s)", tr.Type)
+	}
+	if tr.Type.HasStopPrice() && !tr.StopPrice.IsPositive() {
+		return errors.Errorf("stop price required by OrderType(%s)", tr.Type)
+	}
+	if tr.Type.IsTrailingStop() && !tr.CallbackRate.IsPositive() {
+		return errors.Errorf("callback rate required by OrderType(%s)", tr.Type)
+	}
+	if tr.TimeInForce != TimeInForceError && tr.TimeInForce != TimeInForceGTC && !tr.Type.IsLimit() {
+		return errors.Errorf("TimeInForce(%s) is supported by limit order only", tr.TimeInForce)
+	}
+	if tr.ClientId != "" {
+		if err := VerifyClientId(tr.ClientId); err != nil {
+			return err
+		}
+	}
+	if tr.ReduceOnly && !tr.Market.IsContract() {
+		return errors.Errorf("reduce only order is not supported in Market(%s)", tr.Market)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package fintypes
+
+import (
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"testing"
+)
+
+func TestTradeRequest_Verify(t *testing.T) {
+	base := TradeRequest{Market: MarketSpot, Margin: MarginNo, Pair: BTC.Against(USDT), Side: OrderSideBuyLong, Amount: gdecimal.One}
+
+	for _, v := range []struct {
+		modify func(tr *TradeRequest)
+		valid  bool
+	}{
+		{func(tr *TradeRequest) { tr.Type = OrderTypeMarket }, true},
+		{func(tr *TradeRequest) {
+			tr.Type = OrderTypeLimit
+			tr.Price = gdecimal.NewFromInt(100)
+			tr.TimeInForce = TimeInForceGTX
+		}, true},
+		{func(tr *TradeRequest) { tr.Type = OrderTypeMarket; tr.TimeInForce = TimeInForceIOC }, false},
+		{func(tr *TradeRequest) { tr.Type = OrderTypeOCO; tr.Price = gdecimal.NewFromInt(110) }, false},
+		{func(tr *TradeRequest) {
+			tr.Type = OrderTypeOCO
+			tr.Price = gdecimal.NewFromInt(110)
+			tr.StopPrice = gdecimal.NewFromInt(90)
+		}, true},
+		{func(tr *TradeRequest) { tr.Type = OrderTypeTrailingStop }, false},
+		{func(tr *TradeRequest) { tr.Type = OrderTypeTrailingStop; tr.CallbackRate = gdecimal.One }, true},
+		{func(tr *TradeRequest) { tr.Type = OrderType("iceberg") }, false},
+		{func(tr *TradeRequest) { tr.Type = OrderTypeMarket; tr.ClientId = NewClientId() }, true},
+		{func(tr *TradeRequest) { tr.Type = OrderTypeMarket; tr.ClientId = "my order#1" }, false},
+		{func(tr *TradeRequest) { tr.Type = OrderTypeMarket; tr.ReduceOnly = true }, false},
+		{func(tr *TradeRequest) { tr.Type = OrderTypeMarket; tr.Market = MarketPerp; tr.ReduceOnly = true }, true},
+	} {
+		tr := base
+		v.modify(&tr)
+		if err := tr.Verify(); (err == nil) != v.valid {
+			t.Errorf("Verify of %v should be %v, but got %v", tr, v.valid, err)
+			return
+		}
+	}
+}
+
+func TestNewClientId(t *testing.T) {
+	ids := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id := NewClientId()
+		if len(id) != 18 || VerifyClientId(id) != nil {
+			t.Errorf("invalid client id %s", id)
+			return
+		}
+		if ids[id] {
+			t.Errorf("duplicate client id %s", id)
+			return
+		}
+		ids[id] = true
+	}
+}
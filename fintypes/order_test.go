@@ -0,0 +1,27 @@
+package fintypes
+
+import "testing"
+
+func TestOrderStatus_CanTransitTo(t *testing.T) {
+	for _, v := range []struct {
+		from, to OrderStatus
+		valid    bool
+	}{
+		{OrderStatusError, OrderStatusFilled, true},
+		{OrderStatusNew, OrderStatusNew, true},
+		{OrderStatusNew, OrderStatusFilled, true},
+		{OrderStatusPartiallyFilled, OrderStatusPartiallyCanceled, true},
+		{OrderStatusPartiallyFilled, OrderStatusNew, false},
+		{OrderStatusCanceling, OrderStatusPartiallyFilled, true},
+		{OrderStatusCanceling, OrderStatusNew, false},
+		{OrderStatusFilled, OrderStatusCanceled, false},
+		{OrderStatusPartiallyCanceled, OrderStatusPartiallyFilled, false},
+	} {
+		if v.from.CanTransitTo(v.to) != v.valid {
+			t.Errorf("transition %s -> %s should be %v", v.from, v.to, v.valid)
+		}
+	}
+	if !OrderStatusPartiallyCanceled.End() {
+		t.Errorf("partially canceled order should be ended")
+	}
+}
@@ -146,8 +146,10 @@ func ParsePair(s string) (Pair, error) {
 
 func ParsePairCustom(s string, config *ExProperty) (Pair, error) {
 	if config != nil {
-		if pair, _, _, err := parsePairWithOptions(s, []string{config.PairDelimiter}, config.PairDelimiterLeftTail, config.PairDelimiterRightHead, config.PairNormalOrder); err != nil {
+		if pair, unit, quote, err := parsePairWithOptions(s, []string{config.PairDelimiter}, config.PairDelimiterLeftTail, config.PairDelimiterRightHead, config.PairNormalOrder); err != nil {
 			return Pair(""), err
+		} else if len(config.AssetSymbols) > 0 {
+			return NewPair(config.StdAsset(unit), config.StdAsset(quote)), nil
 		} else {
 			return pair, nil
 		}
@@ -228,26 +230,27 @@ func (p Pair) Quote() string {
 
 func (p Pair) CustomFormat(config *ExProperty) string {
 	delimiter, normalOrder, upperCase := config.PairDelimiter, config.PairNormalOrder, config.PairUpperCase
+	unit, quote := config.CustomAsset(p.Unit()), config.CustomAsset(p.Quote())
 	first := ""
 	second := ""
 	if normalOrder {
 		if upperCase {
-			first = strings.ToUpper(p.Unit())
-			second = strings.ToUpper(p.Quote())
+			first = strings.ToUpper(unit)
+			second = strings.ToUpper(quote)
 			delimiter = strings.ToUpper(delimiter)
 		} else {
-			first = strings.ToLower(p.Unit())
-			second = strings.ToLower(p.Quote())
+			first = strings.ToLower(unit)
+			second = strings.ToLower(quote)
 			delimiter = strings.ToLower(delimiter)
 		}
 	} else {
 		if upperCase {
-			first = strings.ToUpper(p.Quote())
-			second = strings.ToUpper(p.Unit())
+			first = strings.ToUpper(quote)
+			second = strings.ToUpper(unit)
 			delimiter = strings.ToUpper(delimiter)
 		} else {
-			first = strings.ToLower(p.Quote())
-			second = strings.ToLower(p.Unit())
+			first = strings.ToLower(quote)
+			second = strings.ToLower(unit)
 			delimiter = strings.ToLower(delimiter)
 		}
 	}
@@ -170,3 +170,19 @@ func TestParsePairExtString(t *testing.T) {
 	}
 	fmt.Println(pair, period, platform, market)
 }
+
+func TestPair_CustomFormatAssetSymbols(t *testing.T) {
+	cfg := ExProperty{PairUpperCase: true, PairNormalOrder: true, AssetSymbols: map[string]string{"BTC": "XBT"}}
+	if s := BTC.Against(USD).CustomFormat(&cfg); s != "XBTUSD" {
+		t.Errorf("expected XBTUSD, but get %s", s)
+		return
+	}
+	pair, err := ParsePairCustom("XBTUSD", &cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if pair != BTC.Against(USD) {
+		t.Errorf("expected %s, but get %s", BTC.Against(USD).String(), pair.String())
+	}
+}
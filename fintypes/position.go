@@ -0,0 +1,42 @@
+package fintypes
+
+import (
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/container/gjson"
+	"time"
+)
+
+type (
+	// open position of perp or future contract, one-way position mode (单向持仓) only
+	Position struct {
+		Market           Market
+		Margin           Margin // isolated or cross
+		Leverage         int
+		Pair             Pair
+		Side             OrderSide        // OrderSideBuyLong means long position, OrderSideSellShort means short position
+		Amount           gdecimal.Decimal // unit amount like orders, always positive
+		EntryPrice       gdecimal.Decimal
+		MarkPrice        gdecimal.Decimal
+		LiquidationPrice gdecimal.Decimal // zero if there is no liquidation risk
+		UnrealizedPnl    gdecimal.Decimal // in quote asset, computed by mark price
+		IsolatedMargin   gdecimal.Decimal // isolated margin only
+		Time             time.Time        // last update time
+	}
+)
+
+func (p Position) String() string {
+	return gjson.MarshalStringDefault(p, false)
+}
+
+// position value by mark price
+func (p Position) Notional() gdecimal.Decimal {
+	return p.Amount.Mul(p.MarkPrice)
+}
+
+// side of the order which closes this position
+func (p Position) CloseSide() OrderSide {
+	if p.Side.IsBuy() {
+		return OrderSideSellShort
+	}
+	return OrderSideBuyLong
+}
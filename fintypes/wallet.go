@@ -0,0 +1,84 @@
+package fintypes
+
+import (
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/container/gdecimal"
+	"github.com/shawnwyckoff/gopkg/container/gjson"
+	"time"
+)
+
+/**
+deposit and withdrawal of exchange wallet
+
+network is the exchange specific chain name, like "TRX" in binance and "trc20usdt" in huobi for USDT on Tron,
+empty network means the default network of the asset.
+*/
+
+type (
+	WalletStatus string
+
+	DepositAddress struct {
+		Asset   string
+		Network string
+		Address string
+		Tag     string // memo or tag required by chains like XRP and EOS, empty if not required
+	}
+
+	// parameters of Ex.Withdraw
+	WithdrawRequest struct {
+		Asset    string
+		Network  string // optional, default network of asset if empty
+		Address  string // whitelist of withdrawal addresses may be required by exchange
+		Tag      string // optional memo or tag
+		Amount   gdecimal.Decimal
+		ClientId string // optional caller-supplied withdrawal id, not supported by all exchanges
+	}
+
+	// deposit or withdrawal history record
+	WalletRecord struct {
+		Id       string
+		ClientId string // withdrawal only
+		Time     time.Time
+		Asset    string
+		Network  string
+		Address  string
+		Tag      string
+		Amount   gdecimal.Decimal
+		Fee      gdecimal.Decimal // withdrawal only
+		TxId     string           // blockchain transaction id, empty before broadcasting
+		Status   WalletStatus
+	}
+)
+
+const (
+	WalletStatusError    WalletStatus = ""
+	WalletStatusPending  WalletStatus = "pending" // waiting for confirmations, reviewing or processing
+	WalletStatusSuccess  WalletStatus = "success"
+	WalletStatusFailed   WalletStatus = "failed" // rejected by exchange or failed on chain
+	WalletStatusCanceled WalletStatus = "canceled"
+)
+
+func (ws WalletStatus) String() string {
+	return string(ws)
+}
+
+func (ws WalletStatus) End() bool {
+	return ws == WalletStatusSuccess || ws == WalletStatusFailed || ws == WalletStatusCanceled
+}
+
+func (wr WithdrawRequest) Verify() error {
+	if wr.Asset == "" {
+		return errors.Errorf("empty withdraw asset")
+	}
+	if wr.Address == "" {
+		return errors.Errorf("empty withdraw address")
+	}
+	if !wr.Amount.IsPositive() {
+		return errors.Errorf("invalid withdraw amount %s", wr.Amount.String())
+	}
+	return nil
+}
+
+func (wr WalletRecord) String() string {
+	return gjson.MarshalStringDefault(wr, false)
+}
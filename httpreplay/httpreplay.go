@@ -0,0 +1,299 @@
+package httpreplay
+
+/**
+record and replay http transport for offline tests of data providers and exchanges
+
+in ModeRecord, requests are sent by Base and responses are saved into fixture files in Dir,
+in ModeReplay, responses are loaded from fixture files and nothing is sent to internet.
+
+fixture is chosen by method, url and body of request, volatile or secret params like timestamp, nonce and signature
+are ignored, so signed requests of exchanges are matched too. headers of requests are never saved, but api keys
+in ignored params are removed from saved urls.
+responses of the same request are replayed in recorded order, the last one is repeated, like polling of order status.
+
+record fixtures of a test:
+GOFIN_REPLAY=record go test -run TestXxx ./findata
+*/
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type (
+	Mode string
+
+	Transport struct {
+		Base          http.RoundTripper // used in ModeRecord, nil means http.DefaultTransport
+		Dir           string            // directory of fixture files
+		Mode          Mode
+		IgnoredParams []string // query or form params ignored in matching, DefaultIgnoredParams if nil
+
+		mu       sync.Mutex
+		recorded map[string]*fixture // fixtures recorded by this transport, old files are overwritten
+		loaded   map[string]*fixture
+		replayed map[string]int // fixture key -> count of replayed responses
+	}
+
+	fixture struct {
+		Method    string
+		URL       string
+		Body      string `json:",omitempty"`
+		Responses []response
+	}
+
+	response struct {
+		StatusCode int
+		Header     http.Header
+		Body       string `json:",omitempty"`
+		BodyBase64 string `json:",omitempty"` // binary body
+	}
+)
+
+const (
+	ModeReplay Mode = "replay"
+	ModeRecord Mode = "record"
+
+	// environment variable to select mode of New, ModeReplay if it is empty
+	EnvMode = "GOFIN_REPLAY"
+)
+
+var (
+	// errors.Is(err, ErrNoFixture) is true if request is not recorded, tests may skip with it
+	ErrNoFixture = errors.Errorf("replay fixture not found")
+
+	// volatile or secret params of binance, huobi, kraken and data providers
+	DefaultIgnoredParams = []string{
+		"timestamp", "signature", "recvWindow", // binance
+		"AccessKeyId", "SignatureMethod", "SignatureVersion", "Timestamp", "Signature", // huobi
+		"nonce", "otp", // kraken
+		"api_key", "apikey", "crumb", // data providers
+	}
+
+	invalidFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// new transport of fixtures in dir, mode is read from environment variable GOFIN_REPLAY
+func New(dir string) (*Transport, error) {
+	mode := Mode(strings.ToLower(os.Getenv(EnvMode)))
+	switch mode {
+	case "":
+		mode = ModeReplay
+	case ModeReplay, ModeRecord:
+	default:
+		return nil, errors.Errorf("unknown %s=%s, replay or record expected", EnvMode, mode)
+	}
+	return &Transport{Dir: dir, Mode: mode}, nil
+}
+
+// http client using t, like a replacement of http.DefaultClient
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	fx := fixture{Method: req.Method, URL: t.cleanUrl(req.URL), Body: t.cleanBody(req, reqBody)}
+	key := fx.Method + " " + fx.URL + "\n" + fx.Body
+
+	if t.Mode == ModeRecord {
+		return t.record(req, key, fx)
+	}
+	return t.replay(req, key)
+}
+
+func (t *Transport) record(req *http.Request, key string, fx fixture) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	r := response{StatusCode: resp.StatusCode, Header: resp.Header}
+	if utf8.Valid(body) {
+		r.Body = string(body)
+	} else {
+		r.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.recorded == nil {
+		t.recorded = map[string]*fixture{}
+	}
+	rec, ok := t.recorded[key]
+	if !ok {
+		rec = &fx
+		t.recorded[key] = rec
+	}
+	rec.Responses = append(rec.Responses, r)
+	if err := t.save(key, rec); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *Transport) replay(req *http.Request, key string) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loaded == nil {
+		t.loaded = map[string]*fixture{}
+		t.replayed = map[string]int{}
+	}
+	fx, ok := t.loaded[key]
+	if !ok {
+		var err error
+		if fx, err = t.load(key); err != nil {
+			return nil, err
+		}
+		t.loaded[key] = fx
+	}
+	if len(fx.Responses) == 0 {
+		return nil, errors.Wrapf(ErrNoFixture, "empty fixture %s", t.path(key))
+	}
+	i := t.replayed[key]
+	if i >= len(fx.Responses) {
+		i = len(fx.Responses) - 1
+	}
+	t.replayed[key]++
+
+	r := fx.Responses[i]
+	body := []byte(r.Body)
+	if r.BodyBase64 != "" {
+		b, err := base64.StdEncoding.DecodeString(r.BodyBase64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid body of fixture %s", t.path(key))
+		}
+		body = b
+	}
+	header := http.Header{}
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *Transport) ignored() map[string]bool {
+	params := t.IgnoredParams
+	if params == nil {
+		params = DefaultIgnoredParams
+	}
+	r := map[string]bool{}
+	for _, v := range params {
+		r[v] = true
+	}
+	return r
+}
+
+// url without ignored params, params are sorted
+func (t *Transport) cleanUrl(u *url.URL) string {
+	cpy := *u
+	cpy.User = nil
+	cpy.Fragment = ""
+	cpy.RawQuery = t.cleanValues(u.Query()).Encode()
+	return cpy.String()
+}
+
+// form body without ignored params, other bodies are kept
+func (t *Transport) cleanBody(req *http.Request, body []byte) string {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if vals, err := url.ParseQuery(string(body)); err == nil {
+			return t.cleanValues(vals).Encode()
+		}
+	}
+	return string(body)
+}
+
+func (t *Transport) cleanValues(vals url.Values) url.Values {
+	ignored := t.ignored()
+	r := url.Values{}
+	for k, v := range vals {
+		if !ignored[k] {
+			r[k] = v
+		}
+	}
+	return r
+}
+
+// fixture file path, like 'dir/api.binance.com_api_v3_depth_3f2a9c1b.json'
+func (t *Transport) path(key string) string {
+	fx := strings.SplitN(strings.SplitN(key, "\n", 2)[0], " ", 2)
+	name := fx[0]
+	if u, err := url.Parse(fx[1]); err == nil {
+		name = u.Host + u.Path
+	}
+	name = strings.Trim(invalidFileChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(t.Dir, name+"_"+hex.EncodeToString(sum[:4])+".json")
+}
+
+func (t *Transport) load(key string) (*fixture, error) {
+	b, err := ioutil.ReadFile(t.path(key))
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(ErrNoFixture, "%s, record it with %s=%s", strings.TrimSpace(key), EnvMode, ModeRecord)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fx := fixture{}
+	if err := json.Unmarshal(b, &fx); err != nil {
+		return nil, errors.Wrapf(err, "invalid fixture %s", t.path(key))
+	}
+	return &fx, nil
+}
+
+func (t *Transport) save(key string, fx *fixture) error {
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(t.path(key), b, 0644)
+}
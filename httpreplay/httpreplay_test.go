@@ -0,0 +1,79 @@
+package httpreplay
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/shawnwyckoff/gopkg/apputil/gtest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testGet(t *testing.T, c *http.Client, uri string) (string, error) {
+	resp, err := c.Get(uri)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	gtest.Assert(t, err)
+	return resp.Status + " " + string(b), nil
+}
+
+func TestTransport(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Method == http.MethodPost {
+			_ = r.ParseForm()
+			_, _ = fmt.Fprintf(w, "posted %s", r.PostForm.Get("symbol"))
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s %d", r.URL.Query().Get("symbol"), hits)
+	}))
+	dir, err := ioutil.TempDir("", "httpreplay")
+	gtest.Assert(t, err)
+	defer os.RemoveAll(dir)
+
+	rec := &Transport{Dir: dir, Mode: ModeRecord}
+	for _, uri := range []string{srv.URL + "/depth?symbol=BTC&timestamp=1", srv.URL + "/depth?symbol=BTC&timestamp=2", srv.URL + "/depth?symbol=ETH"} {
+		_, err := testGet(t, rec.Client(), uri)
+		gtest.Assert(t, err)
+	}
+	_, err = rec.Client().PostForm(srv.URL+"/order", url.Values{"symbol": {"BTC"}, "signature": {"abc"}})
+	gtest.Assert(t, err)
+	srv.Close()
+
+	// responses are replayed in order and the last one is repeated, ignored params don't matter
+	rep := &Transport{Dir: dir, Mode: ModeReplay}
+	for _, v := range []struct {
+		uri  string
+		want string
+	}{
+		{srv.URL + "/depth?timestamp=9&symbol=BTC", "200 OK BTC 1"},
+		{srv.URL + "/depth?symbol=BTC", "200 OK BTC 2"},
+		{srv.URL + "/depth?symbol=BTC&signature=x", "200 OK BTC 2"},
+		{srv.URL + "/depth?symbol=ETH", "200 OK ETH 3"},
+	} {
+		got, err := testGet(t, rep.Client(), v.uri)
+		gtest.Assert(t, err)
+		if got != v.want {
+			t.Errorf("%s: want %s, got %s", v.uri, v.want, got)
+		}
+	}
+	resp, err := rep.Client().PostForm(srv.URL+"/order", url.Values{"symbol": {"BTC"}, "signature": {"def"}})
+	gtest.Assert(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "posted BTC" {
+		t.Errorf("wrong replayed post response %s", string(b))
+	}
+
+	_, err = testGet(t, rep.Client(), srv.URL+"/depth?symbol=XRP")
+	if !errors.Is(err, ErrNoFixture) || !strings.Contains(err.Error(), "symbol=XRP") {
+		t.Errorf("ErrNoFixture expected, but %v", err)
+	}
+}